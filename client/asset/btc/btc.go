@@ -37,8 +37,11 @@ const (
 	methodGetBlockHeader = "getblockheader"
 	// Use RawRequest to get the verbose block with verbose txs, as the btcd
 	// rpcclient.Client's GetBlockVerboseTx appears to be busted.
-	methodGetBlockVerboseTx = "getblock"
-	methodGetNetworkInfo    = "getnetworkinfo"
+	methodGetBlockVerboseTx  = "getblock"
+	methodGetNetworkInfo     = "getnetworkinfo"
+	methodGetBlockchainInfo  = "getblockchaininfo"
+	methodGetConnectionCount = "getconnectioncount"
+	methodRescanBlockchain   = "rescanblockchain"
 	// BipID is the BIP-0044 asset ID.
 	BipID = 0
 
@@ -120,6 +123,8 @@ var (
 	WalletInfo = &asset.WalletInfo{
 		Name:              "Bitcoin",
 		Units:             "Satoshis",
+		ConventionalUnit:  "BTC",
+		ConversionFactor:  1e8,
 		DefaultConfigPath: dexbtc.SystemConfigPath("bitcoin"),
 		ConfigOpts:        configOpts,
 	}
@@ -332,6 +337,36 @@ func (d *Driver) Info() *asset.WalletInfo {
 	return WalletInfo
 }
 
+// ValidateAddress checks that the address is a Bitcoin address for the
+// network. Satisfies asset.AddressValidator.
+func (d *Driver) ValidateAddress(address string, network dex.Network) bool {
+	params, err := netParams(network)
+	if err != nil {
+		return false
+	}
+	return ValidAddress(address, params)
+}
+
+// ValidAddress checks that the address can be decoded and is for the network
+// of the chain parameters. Exported for use by clone assets.
+func ValidAddress(address string, params *chaincfg.Params) bool {
+	addr, err := btcutil.DecodeAddress(address, params)
+	return err == nil && addr.IsForNet(params)
+}
+
+// netParams returns the Bitcoin chain parameters for the network.
+func netParams(network dex.Network) (*chaincfg.Params, error) {
+	switch network {
+	case dex.Mainnet:
+		return &chaincfg.MainNetParams, nil
+	case dex.Testnet:
+		return &chaincfg.TestNet3Params, nil
+	case dex.Regtest:
+		return &chaincfg.RegressionNetParams, nil
+	}
+	return nil, fmt.Errorf("unknown network ID %v", network)
+}
+
 func init() {
 	asset.Register(BipID, &Driver{})
 }
@@ -394,16 +429,9 @@ var _ asset.Wallet = (*ExchangeWallet)(nil)
 // canceled. The configPath can be an empty string, in which case the standard
 // system location of the bitcoind config file is assumed.
 func NewWallet(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
-	var params *chaincfg.Params
-	switch network {
-	case dex.Mainnet:
-		params = &chaincfg.MainNetParams
-	case dex.Testnet:
-		params = &chaincfg.TestNet3Params
-	case dex.Regtest:
-		params = &chaincfg.RegressionNetParams
-	default:
-		return nil, fmt.Errorf("unknown network ID %v", network)
+	params, err := netParams(network)
+	if err != nil {
+		return nil, err
 	}
 	cloneCFG := &BTCCloneCFG{
 		WalletCFG:          cfg,
@@ -643,8 +671,8 @@ func (btc *ExchangeWallet) FundOrder(ord *asset.Order) (asset.Coins, []dex.Bytes
 		return nil, nil, fmt.Errorf("error parsing unspent outputs: %v", err)
 	}
 	if avail < ord.Value {
-		return nil, nil, fmt.Errorf("insufficient funds. %.8f requested, %.8f available",
-			btcutil.Amount(ord.Value).ToBTC(), btcutil.Amount(avail).ToBTC())
+		return nil, nil, dex.NewError(asset.InsufficientFundsError, fmt.Sprintf("%.8f requested, %.8f available",
+			btcutil.Amount(ord.Value).ToBTC(), btcutil.Amount(avail).ToBTC()))
 	}
 	var sum uint64
 	var size uint32
@@ -678,8 +706,8 @@ out:
 		reqFunds := calc.RequiredOrderFunds(ord.Value, uint64(size), ord.MaxSwapCount, ord.DEXConfig)
 		fees := reqFunds - ord.Value
 		if len(utxos) == 0 {
-			return nil, nil, fmt.Errorf("not enough to cover requested funds (%d) + fees (%d) = %d",
-				ord.Value, fees, reqFunds)
+			return nil, nil, dex.NewError(asset.InsufficientFundsError, fmt.Sprintf("not enough to cover requested funds (%d) + fees (%d) = %d",
+				ord.Value, fees, reqFunds))
 		}
 		// On each loop, find the smallest UTXO that is enough for the value. If
 		// no UTXO is large enough, add the largest and continue.
@@ -1771,6 +1799,49 @@ func (btc *ExchangeWallet) Withdraw(address string, value uint64) (asset.Coin, e
 	return newOutput(btc.node, txHash, vout, sent), nil
 }
 
+// FeeRate returns the current fee rate estimate in sat/byte for the
+// confirmation target used by Withdraw. Unlike Withdraw, there is no fallback
+// to the configured fee rate; an error is returned if no estimate is
+// available. Part of the asset.Wallet interface.
+func (btc *ExchangeWallet) FeeRate() (uint64, error) {
+	return btc.feeRate(2)
+}
+
+// SyncStatus is the node's sync progress, with the number of validated blocks
+// and the best known header height as the target. The node is synced once it
+// has left initial block download and validated every known header. Satisfies
+// asset.SyncReporter.
+func (btc *ExchangeWallet) SyncStatus() (*asset.SyncStatus, error) {
+	r := &struct {
+		Blocks               int64 `json:"blocks"`
+		Headers              int64 `json:"headers"`
+		InitialBlockDownload bool  `json:"initialblockdownload"`
+	}{}
+	err := btc.wallet.call(methodGetBlockchainInfo, nil, r)
+	if err != nil {
+		return nil, err
+	}
+	synced := !r.InitialBlockDownload && r.Blocks >= r.Headers
+	return asset.NewSyncStatus(synced, r.Blocks, r.Headers), nil
+}
+
+// PeerCount returns the number of peers connected to the node. Satisfies
+// asset.PeerReporter.
+func (btc *ExchangeWallet) PeerCount() (uint32, error) {
+	var count uint32
+	err := btc.wallet.call(methodGetConnectionCount, nil, &count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Rescan rescans the entire blockchain for the wallet's transactions, blocking
+// until it is complete. Satisfies asset.Rescanner.
+func (btc *ExchangeWallet) Rescan() error {
+	return btc.wallet.call(methodRescanBlockchain, nil, nil)
+}
+
 // ValidateSecret checks that the secret satisfies the contract.
 func (btc *ExchangeWallet) ValidateSecret(secret, secretHash []byte) bool {
 	h := sha256.Sum256(secret)
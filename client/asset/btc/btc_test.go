@@ -119,6 +119,7 @@ type tRPCClient struct {
 	mpVerboseTxs  map[string]*btcjson.TxRawResult
 	rawVerboseErr error
 	lockedCoins   []*RPCOutpoint
+	estFeeErr     error
 }
 
 func newTRPCClient() *tRPCClient {
@@ -140,6 +141,9 @@ func newTRPCClient() *tRPCClient {
 }
 
 func (c *tRPCClient) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult, error) {
+	if c.estFeeErr != nil {
+		return nil, c.estFeeErr
+	}
 	optimalRate := float64(optimalFeeRate) * 1e-5 // ~0.00024
 	//fmt.Println((float64(optimalFeeRate) * 1e-5) - optimalRate)
 	//fmt.Println(uint64(math.Round(feefloat * 1e5)))
@@ -1961,6 +1965,129 @@ func TestWithdraw(t *testing.T) {
 	testSender(t, tWithdrawSender)
 }
 
+func TestFeeRate(t *testing.T) {
+	wallet, node, shutdown := tNewWallet(true)
+	defer shutdown()
+
+	feeRate, err := wallet.FeeRate()
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	// feeRate adds an extra sat/byte to the estimate.
+	if feeRate != optimalFeeRate+1 {
+		t.Fatalf("wrong fee rate. wanted %d, got %d", optimalFeeRate+1, feeRate)
+	}
+
+	// No fallback on estimatesmartfee error.
+	node.estFeeErr = tErr
+	_, err = wallet.FeeRate()
+	if err == nil {
+		t.Fatalf("no error for estimatesmartfee error")
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	wallet, node, shutdown := tNewWallet(true)
+	defer shutdown()
+
+	type blockchainInfo struct {
+		Blocks               int64 `json:"blocks"`
+		Headers              int64 `json:"headers"`
+		InitialBlockDownload bool  `json:"initialblockdownload"`
+	}
+
+	node.rawRes[methodGetBlockchainInfo] = mustMarshal(t, &blockchainInfo{
+		Blocks:               25,
+		Headers:              100,
+		InitialBlockDownload: true,
+	})
+	ss, err := wallet.SyncStatus()
+	if err != nil {
+		t.Fatalf("SyncStatus error: %v", err)
+	}
+	if ss.Synced || ss.Height != 25 || ss.Target != 100 || ss.Progress != 25 {
+		t.Fatalf("wrong sync status for a syncing node: %+v", ss)
+	}
+
+	node.rawRes[methodGetBlockchainInfo] = mustMarshal(t, &blockchainInfo{
+		Blocks:  100,
+		Headers: 100,
+	})
+	ss, err = wallet.SyncStatus()
+	if err != nil {
+		t.Fatalf("SyncStatus error: %v", err)
+	}
+	if !ss.Synced || ss.Progress != 100 {
+		t.Fatalf("wrong sync status for a synced node: %+v", ss)
+	}
+
+	node.rawErr[methodGetBlockchainInfo] = tErr
+	if _, err = wallet.SyncStatus(); err == nil {
+		t.Fatalf("no error for getblockchaininfo error")
+	}
+}
+
+func TestPeerCount(t *testing.T) {
+	wallet, node, shutdown := tNewWallet(true)
+	defer shutdown()
+
+	node.rawRes[methodGetConnectionCount] = mustMarshal(t, 8)
+	count, err := wallet.PeerCount()
+	if err != nil {
+		t.Fatalf("PeerCount error: %v", err)
+	}
+	if count != 8 {
+		t.Fatalf("wrong peer count %d", count)
+	}
+
+	node.rawErr[methodGetConnectionCount] = tErr
+	if _, err = wallet.PeerCount(); err == nil {
+		t.Fatalf("no error for getconnectioncount error")
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network dex.Network
+		valid   bool
+	}{
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", dex.Mainnet, true},
+		{"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", dex.Mainnet, true},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", dex.Testnet, true},
+		// Wrong network.
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", dex.Testnet, false},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", dex.Mainnet, false},
+		// Typo, so the checksum fails.
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", dex.Mainnet, false},
+		{"", dex.Mainnet, false},
+	}
+	d := &Driver{}
+	for _, test := range tests {
+		if valid := d.ValidateAddress(test.addr, test.network); valid != test.valid {
+			t.Fatalf("%q on %s: wanted valid = %v, got %v", test.addr, test.network, test.valid, valid)
+		}
+	}
+}
+
+func TestRescan(t *testing.T) {
+	wallet, node, shutdown := tNewWallet(true)
+	defer shutdown()
+
+	node.rawRes[methodRescanBlockchain] = mustMarshal(t, map[string]int64{
+		"start_height": 0,
+		"stop_height":  200,
+	})
+	if err := wallet.Rescan(); err != nil {
+		t.Fatalf("Rescan error: %v", err)
+	}
+
+	node.rawErr[methodRescanBlockchain] = tErr
+	if err := wallet.Rescan(); err == nil {
+		t.Fatalf("no error for rescanblockchain error")
+	}
+}
+
 func TestConfirmations(t *testing.T) {
 	wallet, node, shutdown := tNewWallet(true)
 	defer shutdown()
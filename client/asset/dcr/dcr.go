@@ -26,6 +26,7 @@ import (
 	"decred.org/dcrdex/dex/calc"
 	dexdcr "decred.org/dcrdex/dex/networks/dcr"
 	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/dcrec"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 	"github.com/decred/dcrd/dcrjson/v3"
@@ -56,6 +57,7 @@ const (
 	methodListUnspent        = "listunspent"
 	methodListLockUnspent    = "listlockunspent"
 	methodSignRawTransaction = "signrawtransaction"
+	methodRescanWallet       = "rescanwallet"
 )
 
 var (
@@ -121,6 +123,8 @@ var (
 	WalletInfo = &asset.WalletInfo{
 		Name:              "Decred",
 		Units:             "atoms",
+		ConventionalUnit:  "DCR",
+		ConversionFactor:  1e8,
 		DefaultConfigPath: defaultConfigPath,
 		ConfigOpts:        configOpts,
 	}
@@ -133,6 +137,8 @@ type rpcClient interface {
 	GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*chainjson.GetTxOutResult, error)
 	GetBalanceMinConf(account string, minConfirms int) (*walletjson.GetBalanceResult, error)
 	GetBestBlock() (*chainhash.Hash, int64, error)
+	GetBlockChainInfo() (*chainjson.GetBlockChainInfoResult, error)
+	GetConnectionCount() (int64, error)
 	GetBlockHash(blockHeight int64) (*chainhash.Hash, error)
 	GetBlockVerbose(blockHash *chainhash.Hash, verboseTx bool) (*chainjson.GetBlockVerboseResult, error)
 	GetRawMempool(txType chainjson.GetRawMempoolTxTypeCmd) ([]*chainhash.Hash, error)
@@ -337,6 +343,24 @@ func (d *Driver) Info() *asset.WalletInfo {
 	return WalletInfo
 }
 
+// ValidateAddress checks that the address is a Decred address for the network.
+// Satisfies asset.AddressValidator.
+func (d *Driver) ValidateAddress(address string, network dex.Network) bool {
+	var params *chaincfg.Params
+	switch network {
+	case dex.Simnet:
+		params = chaincfg.SimNetParams()
+	case dex.Testnet:
+		params = chaincfg.TestNet3Params()
+	case dex.Mainnet:
+		params = chaincfg.MainNetParams()
+	default:
+		return false
+	}
+	_, err := dcrutil.DecodeAddress(address, params)
+	return err == nil
+}
+
 func init() {
 	asset.Register(BipID, &Driver{})
 }
@@ -672,7 +696,7 @@ func (dcr *ExchangeWallet) fund(enough func(sum uint64, size uint32, unspent *co
 		return nil, nil, 0, 0, nil, err
 	}
 	if len(unspents) == 0 {
-		return nil, nil, 0, 0, nil, fmt.Errorf("insufficient funds. 0 DCR available in %q account", dcr.acct)
+		return nil, nil, 0, 0, nil, dex.NewError(asset.InsufficientFundsError, fmt.Sprintf("0 DCR available in %q account", dcr.acct))
 	}
 
 	// Parse utxos to include script size for spending input.
@@ -761,7 +785,7 @@ func (dcr *ExchangeWallet) fund(enough func(sum uint64, size uint32, unspent *co
 			return nil, nil, 0, 0, nil, err
 		}
 		if !ok {
-			return nil, nil, 0, 0, nil, fmt.Errorf("not enough to cover requested funds. %v available", sum)
+			return nil, nil, 0, 0, nil, dex.NewError(asset.InsufficientFundsError, fmt.Sprintf("not enough to cover requested funds. %v available", sum))
 		}
 	}
 
@@ -1781,6 +1805,47 @@ func (dcr *ExchangeWallet) Withdraw(address string, value uint64) (asset.Coin, e
 	return newOutput(dcr.node, msgTx.CachedTxHash(), 0, net, wire.TxTreeRegular), nil
 }
 
+// FeeRate returns the current fee rate estimate in atoms/byte for the
+// confirmation target used by Withdraw. Unlike Withdraw, there is no fallback
+// to the configured fee rate; an error is returned if no estimate is
+// available. Part of the asset.Wallet interface.
+func (dcr *ExchangeWallet) FeeRate() (uint64, error) {
+	return dcr.feeRate(2)
+}
+
+// SyncStatus is the dcrd node's sync progress, with the number of validated
+// blocks and the sync height as the target. The node is synced once it has left
+// initial block download and reached the sync height. Satisfies
+// asset.SyncReporter.
+func (dcr *ExchangeWallet) SyncStatus() (*asset.SyncStatus, error) {
+	chainInfo, err := dcr.node.GetBlockChainInfo()
+	if err != nil {
+		return nil, fmt.Errorf("getblockchaininfo error: %w", err)
+	}
+	synced := !chainInfo.InitialBlockDownload && chainInfo.Blocks >= chainInfo.SyncHeight
+	return asset.NewSyncStatus(synced, chainInfo.Blocks, chainInfo.SyncHeight), nil
+}
+
+// PeerCount returns the number of peers connected to the dcrd node. Satisfies
+// asset.PeerReporter.
+func (dcr *ExchangeWallet) PeerCount() (uint32, error) {
+	count, err := dcr.node.GetConnectionCount()
+	if err != nil {
+		return 0, fmt.Errorf("getconnectioncount error: %w", err)
+	}
+	return uint32(count), nil
+}
+
+// Rescan rescans the blockchain from the genesis block for the wallet's
+// transactions, blocking until it is complete. Satisfies asset.Rescanner.
+func (dcr *ExchangeWallet) Rescan() error {
+	err := dcr.nodeRawRequest(methodRescanWallet, nil, nil)
+	if err != nil {
+		return fmt.Errorf("rescanwallet error: %w", err)
+	}
+	return nil
+}
+
 // ValidateSecret checks that the secret satisfies the contract.
 func (dcr *ExchangeWallet) ValidateSecret(secret, secretHash []byte) bool {
 	h := sha256.Sum256(secret)
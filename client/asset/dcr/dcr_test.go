@@ -205,6 +205,11 @@ type tRPCClient struct {
 	lluCoins       []walletjson.ListUnspentResult // Returned from ListLockUnspent
 	lockedCoins    []*wire.OutPoint               // Last submitted to LockUnspent
 	listLockedErr  error
+	estFeeErr      error
+	chainInfo      *chainjson.GetBlockChainInfoResult
+	chainInfoErr   error
+	peerCount      int64
+	peerCountErr   error
 }
 
 func defaultSignFunc(tx *wire.MsgTx) (*wire.MsgTx, bool, error) { return tx, true, nil }
@@ -228,6 +233,9 @@ func newTRPCClient() *tRPCClient {
 }
 
 func (c *tRPCClient) EstimateSmartFee(confirmations int64, mode chainjson.EstimateSmartFeeMode) (float64, error) {
+	if c.estFeeErr != nil {
+		return 0, c.estFeeErr
+	}
 	optimalRate := float64(optimalFeeRate) * 1e-5
 	// fmt.Println((float64(optimalFeeRate)*1e-5)-0.00022)
 	return optimalRate, nil // optimalFeeRate: 22 atoms/byte = 0.00022 DCR/KB * 1e8 atoms/DCR * 1e-3 KB/Byte
@@ -263,6 +271,14 @@ func (c *tRPCClient) GetBestBlock() (*chainhash.Hash, int64, error) {
 	return bestHash, bestBlkHeight, nil
 }
 
+func (c *tRPCClient) GetBlockChainInfo() (*chainjson.GetBlockChainInfoResult, error) {
+	return c.chainInfo, c.chainInfoErr
+}
+
+func (c *tRPCClient) GetConnectionCount() (int64, error) {
+	return c.peerCount, c.peerCountErr
+}
+
 func (c *tRPCClient) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
 	c.blockchainMtx.RLock()
 	defer c.blockchainMtx.RUnlock()
@@ -1692,6 +1708,119 @@ func TestWithdraw(t *testing.T) {
 	testSender(t, tWithdrawSender)
 }
 
+func TestFeeRate(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	feeRate, err := wallet.FeeRate()
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	// feeRate adds an extra atom/byte to the estimate.
+	if feeRate != optimalFeeRate+1 {
+		t.Fatalf("wrong fee rate. wanted %d, got %d", optimalFeeRate+1, feeRate)
+	}
+
+	// No fallback on estimatesmartfee error.
+	node.estFeeErr = tErr
+	_, err = wallet.FeeRate()
+	if err == nil {
+		t.Fatalf("no error for estimatesmartfee error")
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.chainInfo = &chainjson.GetBlockChainInfoResult{
+		Blocks:               50,
+		SyncHeight:           200,
+		InitialBlockDownload: true,
+	}
+	ss, err := wallet.SyncStatus()
+	if err != nil {
+		t.Fatalf("SyncStatus error: %v", err)
+	}
+	if ss.Synced || ss.Height != 50 || ss.Target != 200 || ss.Progress != 25 {
+		t.Fatalf("wrong sync status for a syncing node: %+v", ss)
+	}
+
+	node.chainInfo = &chainjson.GetBlockChainInfoResult{
+		Blocks:     200,
+		SyncHeight: 200,
+	}
+	ss, err = wallet.SyncStatus()
+	if err != nil {
+		t.Fatalf("SyncStatus error: %v", err)
+	}
+	if !ss.Synced || ss.Progress != 100 {
+		t.Fatalf("wrong sync status for a synced node: %+v", ss)
+	}
+
+	node.chainInfoErr = tErr
+	if _, err = wallet.SyncStatus(); err == nil {
+		t.Fatalf("no error for getblockchaininfo error")
+	}
+}
+
+func TestPeerCount(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.peerCount = 8
+	count, err := wallet.PeerCount()
+	if err != nil {
+		t.Fatalf("PeerCount error: %v", err)
+	}
+	if count != 8 {
+		t.Fatalf("wrong peer count %d", count)
+	}
+
+	node.peerCountErr = tErr
+	if _, err = wallet.PeerCount(); err == nil {
+		t.Fatalf("no error for getconnectioncount error")
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network dex.Network
+		valid   bool
+	}{
+		{"DsTya4cCFBgtofDLiRhkyPYEQjgs3HnarVP", dex.Mainnet, true},
+		{"TsfDLrRkk9ciUuwfp2b8PawwnukYD7yAjGd", dex.Testnet, true},
+		// Wrong network.
+		{"DsTya4cCFBgtofDLiRhkyPYEQjgs3HnarVP", dex.Testnet, false},
+		{"TsfDLrRkk9ciUuwfp2b8PawwnukYD7yAjGd", dex.Mainnet, false},
+		// Typo, so the checksum fails.
+		{"DsTya4cCFBgtofDLiRhkyPYEQjgs3HnarVQ", dex.Mainnet, false},
+		{"", dex.Mainnet, false},
+	}
+	d := &Driver{}
+	for _, test := range tests {
+		if valid := d.ValidateAddress(test.addr, test.network); valid != test.valid {
+			t.Fatalf("%q on %s: wanted valid = %v, got %v", test.addr, test.network, test.valid, valid)
+		}
+	}
+}
+
+func TestRescan(t *testing.T) {
+	wallet, node, shutdown := tNewWallet()
+	defer shutdown()
+
+	node.rawRes[methodRescanWallet] = json.RawMessage("null")
+	if err := wallet.Rescan(); err != nil {
+		t.Fatalf("Rescan error: %v", err)
+	}
+
+	node.rawErr[methodRescanWallet] = tErr
+	if err := wallet.Rescan(); err == nil {
+		t.Fatalf("no error for rescanwallet error")
+	}
+}
+
 func TestConfirmations(t *testing.T) {
 	wallet, node, shutdown := tNewWallet()
 	defer shutdown()
@@ -59,6 +59,32 @@ func DecodeCoinID(assetID uint32, coinID []byte) (string, error) {
 	return drv.DecodeCoinID(coinID)
 }
 
+// AddressValidator is implemented by a Driver that can check the format of an
+// address for a network without a wallet. This is optional, so consumers must
+// check for it with a type assertion.
+type AddressValidator interface {
+	// ValidateAddress checks whether the address is well-formed for the
+	// network.
+	ValidateAddress(address string, network dex.Network) bool
+}
+
+// ValidateAddress checks whether the address is well-formed for the asset on
+// the network. An error is returned if the asset has no registered driver, or
+// its driver cannot validate addresses.
+func ValidateAddress(assetID uint32, address string, network dex.Network) (bool, error) {
+	driversMtx.RLock()
+	drv, ok := drivers[assetID]
+	driversMtx.RUnlock()
+	if !ok {
+		return false, fmt.Errorf("asset: unknown asset driver %d", assetID)
+	}
+	validator, ok := drv.(AddressValidator)
+	if !ok {
+		return false, fmt.Errorf("asset: driver for asset %d cannot validate addresses", assetID)
+	}
+	return validator.ValidateAddress(address, network), nil
+}
+
 // A registered asset is information about a supported asset.
 type RegisteredAsset struct {
 	ID     uint32
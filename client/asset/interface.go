@@ -16,6 +16,10 @@ import (
 // exist and be unspent.
 const CoinNotFoundError = dex.ErrorKind("coin not found")
 
+// InsufficientFundsError is returned when the wallet does not have enough
+// available funds, e.g. from FundOrder.
+const InsufficientFundsError = dex.ErrorKind("insufficient funds")
+
 // WalletInfo is auxiliary information about an ExchangeWallet.
 type WalletInfo struct {
 	// Name is the display name for the currency, e.g. "Decred"
@@ -23,6 +27,12 @@ type WalletInfo struct {
 	// Units is the unit used for the smallest (integer) denomination of the
 	// currency, in plural form e.g. atoms, Satoshis.
 	Units string `json:"units"`
+	// ConventionalUnit is the unit amounts are conventionally expressed in,
+	// e.g. DCR, BTC.
+	ConventionalUnit string `json:"conventionalUnit"`
+	// ConversionFactor is the number of Units in one ConventionalUnit, e.g.
+	// 1e8 atoms per DCR.
+	ConversionFactor uint64 `json:"conversionFactor"`
 	// DefaultConfigPath is the default file path that the Wallet uses for its
 	// configuration file.
 	DefaultConfigPath string `json:"configpath"`
@@ -148,10 +158,71 @@ type Wallet interface {
 	// Withdraw withdraws funds to the specified address. Fees are subtracted from
 	// the value.
 	Withdraw(address string, value uint64) (Coin, error)
+	// FeeRate returns the wallet's current network fee rate estimate, in
+	// atoms/byte, as would be used for a withdrawal. An error is returned if
+	// the backend is unable to provide an estimate.
+	FeeRate() (uint64, error)
 	// ValidateSecret checks that the secret hashes to the secret hash.
 	ValidateSecret(secret, secretHash []byte) bool
 }
 
+// SyncReporter is implemented by a Wallet that can report the sync progress of
+// its backing blockchain node. This is optional, so consumers must check for it
+// with a type assertion.
+type SyncReporter interface {
+	// SyncStatus returns the current sync progress.
+	SyncStatus() (*SyncStatus, error)
+}
+
+// PeerReporter is implemented by a Wallet that can report the number of network
+// peers of its backing blockchain node. A node with no peers cannot sync. This
+// is optional, so consumers must check for it with a type assertion.
+type PeerReporter interface {
+	// PeerCount returns the number of peers the node is connected to.
+	PeerCount() (uint32, error)
+}
+
+// Rescanner is implemented by a Wallet that can rescan the blockchain for its
+// transactions, e.g. to recover ones that it missed. This is optional, so
+// consumers must check for it with a type assertion.
+type Rescanner interface {
+	// Rescan rescans the blockchain for the wallet's transactions. It blocks
+	// until the rescan is complete, which may take a long time.
+	Rescan() error
+}
+
+// SyncStatus is the blockchain sync progress of a wallet.
+type SyncStatus struct {
+	// Synced is true once the wallet's node has caught up to the network and
+	// the wallet is usable.
+	Synced bool `json:"synced"`
+	// Height is the number of blocks that have been scanned.
+	Height int64 `json:"height"`
+	// Target is the best block height known to the node, which is the height
+	// being synced to.
+	Target int64 `json:"target"`
+	// Progress is the percentage of the Target that has been scanned.
+	Progress float64 `json:"progress"`
+}
+
+// NewSyncStatus creates a SyncStatus with the Progress computed from the
+// height and target. A synced wallet is always at 100%.
+func NewSyncStatus(synced bool, height, target int64) *SyncStatus {
+	var progress float64
+	switch {
+	case synced || (target > 0 && height >= target):
+		progress = 100
+	case target > 0 && height > 0:
+		progress = 100 * float64(height) / float64(target)
+	}
+	return &SyncStatus{
+		Synced:   synced,
+		Height:   height,
+		Target:   target,
+		Progress: progress,
+	}
+}
+
 // Balance is categorized information about a wallet's balance.
 type Balance struct {
 	// Available is the balance that is available for trading immediately.
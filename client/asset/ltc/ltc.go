@@ -78,6 +78,8 @@ var (
 	WalletInfo = &asset.WalletInfo{
 		Name:              "Litecoin",
 		Units:             "Litoshi",
+		ConventionalUnit:  "LTC",
+		ConversionFactor:  1e8,
 		DefaultConfigPath: dexbtc.SystemConfigPath("litecoin"),
 		ConfigOpts:        configOpts,
 	}
@@ -107,21 +109,37 @@ func (d *Driver) Info() *asset.WalletInfo {
 	return WalletInfo
 }
 
+// ValidateAddress checks that the address is a Litecoin address for the
+// network. Satisfies asset.AddressValidator.
+func (d *Driver) ValidateAddress(address string, network dex.Network) bool {
+	params, err := netParams(network)
+	if err != nil {
+		return false
+	}
+	return btc.ValidAddress(address, params)
+}
+
+// netParams returns the Litecoin chain parameters for the network.
+func netParams(network dex.Network) (*chaincfg.Params, error) {
+	switch network {
+	case dex.Mainnet:
+		return dexltc.MainNetParams, nil
+	case dex.Testnet:
+		return dexltc.TestNet4Params, nil
+	case dex.Regtest:
+		return dexltc.RegressionNetParams, nil
+	}
+	return nil, fmt.Errorf("unknown network ID %v", network)
+}
+
 // NewWallet is the exported constructor by which the DEX will import the
 // exchange wallet. The wallet will shut down when the provided context is
 // canceled. The configPath can be an empty string, in which case the standard
 // system location of the litecoind config file is assumed.
 func NewWallet(cfg *asset.WalletConfig, logger dex.Logger, network dex.Network) (asset.Wallet, error) {
-	var params *chaincfg.Params
-	switch network {
-	case dex.Mainnet:
-		params = dexltc.MainNetParams
-	case dex.Testnet:
-		params = dexltc.TestNet4Params
-	case dex.Regtest:
-		params = dexltc.RegressionNetParams
-	default:
-		return nil, fmt.Errorf("unknown network ID %v", network)
+	params, err := netParams(network)
+	if err != nil {
+		return nil, err
 	}
 
 	// Designate the clone ports. These will be overwritten by any explicit
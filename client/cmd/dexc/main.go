@@ -37,10 +37,11 @@ func main() {
 		fmt.Fprintf(os.Stderr, "configration error: %v\n", err)
 		os.Exit(1)
 	}
+	cfg.AppVersion = Version()
 
 	if cfg.TUI {
 		// Run in TUI mode.
-		ui.Run(appCtx)
+		ui.Run(appCtx, cancel)
 		os.Exit(0)
 	}
 
@@ -101,12 +102,40 @@ func main() {
 	if cfg.RPCOn {
 		rpcserver.SetLogger(logMaker.Logger("RPC"))
 		rpcCfg := &rpcserver.Config{
-			Core: clientCore,
-			Addr: cfg.RPCAddr,
-			User: cfg.RPCUser,
-			Pass: cfg.RPCPass,
-			Cert: cfg.RPCCert,
-			Key:  cfg.RPCKey,
+			Core:  clientCore,
+			Addr:  cfg.RPCAddr,
+			User:  cfg.RPCUser,
+			Pass:  cfg.RPCPass,
+			Token: cfg.RPCToken,
+			Cert:  cfg.RPCCert,
+			Key:   cfg.RPCKey,
+
+			ClientCAs:       cfg.RPCCAs,
+			HMACSecret:      cfg.RPCHMAC,
+			AdminToken:      cfg.RPCAdmin,
+			ReadTimeout:     cfg.RPCReadTimeout,
+			WriteTimeout:    cfg.RPCWriteTimeout,
+			UnixNoTLS:       cfg.RPCUnixNoTLS,
+			DrainTimeout:    cfg.RPCDrainTimeout,
+			RateLimit:       cfg.RPCRateLimit,
+			RateBurst:       cfg.RPCRateBurst,
+			Metrics:         cfg.RPCMetrics,
+			MetricsToken:    cfg.RPCMetricsToken,
+			AllowedOrigins:  cfg.RPCOrigins,
+			CertOrg:         cfg.RPCCertOrg,
+			CertValidity:    cfg.RPCCertValidity,
+			AltNames:        cfg.RPCAltNames,
+			DisableAutoCert: cfg.RPCNoAutoCert,
+			AllowIPs:        cfg.RPCAllowIPs,
+			RequestLogLevel: cfg.RPCReqLogLevel,
+			MinTLSVersion:   cfg.RPCMinTLS,
+			AuthChallenge:   cfg.RPCChallenge,
+			UnsafeRaw:       cfg.RPCUnsafeRaw,
+			EnvPassPrefix:   cfg.RPCEnvPrefix,
+			SetLogLevel:     ui.SetLogLevel,
+			WSAuthTimeout:   cfg.RPCWSTimeout,
+			Shutdown:        cancel,
+			AppVersion:      Version(),
 		}
 		rpcSrv, err := rpcserver.New(rpcCfg)
 		if err != nil {
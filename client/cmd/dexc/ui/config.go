@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"decred.org/dcrdex/dex"
 	"github.com/decred/dcrd/dcrutil/v2"
@@ -59,11 +60,15 @@ type Config struct {
 	Config     string `long:"config" description:"Path to an INI configuration file."`
 	DBPath     string `long:"db" description:"Database filepath. Database will be created if it does not exist."`
 	RPCOn      bool   `long:"rpc" description:"turn on the rpc server"`
-	RPCAddr    string `long:"rpcaddr" description:"RPC server listen address"`
+	RPCAddr    string `long:"rpcaddr" description:"RPC server listen address, or unix:///path/to/socket for a unix socket"`
 	RPCUser    string `long:"rpcuser" description:"RPC server user name"`
 	RPCPass    string `long:"rpcpass" description:"RPC server password"`
+	RPCToken   string `long:"rpctoken" description:"RPC server bearer token, accepted in addition to or instead of the rpcuser/rpcpass"`
+	RPCHMAC    string `long:"rpchmacsecret" description:"RPC server HMAC secret for verifying signed requests, accepted in addition to or instead of the other credentials"`
+	RPCAdmin   string `long:"rpcadmintoken" description:"RPC server bearer token granting the admin scope, which is required for admin routes such as wsdisconnect. Admin routes are disabled if not set"`
 	RPCCert    string `long:"rpccert" description:"RPC server certificate file location"`
 	RPCKey     string `long:"rpckey" description:"RPC server key file location"`
+	RPCCAs     string `long:"rpcclientcas" description:"CA certificates file. If set, RPC clients must present a certificate signed by one of these CAs"`
 	WebAddr    string `long:"webaddr" description:"HTTP server address"`
 	NoWeb      bool   `long:"noweb" description:"disable the web server."`
 	TUI        bool   `long:"tui" description:"enable the terminal-based user interface."`
@@ -73,6 +78,29 @@ type Config struct {
 	DebugLevel string `long:"log" description:"Logging level {trace, debug, info, warn, error, critical}"`
 	LocalLogs  bool   `long:"loglocal" description:"Use local time zone time stamps in log entries."`
 	Net        dex.Network
+	// AppVersion is the application version, set by the caller of Configure.
+	AppVersion string
+
+	RPCReadTimeout  time.Duration `long:"rpcreadtimeout" description:"Maximum time to read an RPC request. Default is 10s. Does not apply to websocket connections."`
+	RPCWriteTimeout time.Duration `long:"rpcwritetimeout" description:"Maximum time to write an RPC response, e.g. a large book. Default is 10s. Does not apply to websocket connections."`
+	RPCDrainTimeout time.Duration `long:"rpcdraintimeout" description:"Time allowed for in-flight RPC requests to finish on shutdown before websocket clients are disconnected. Default is 5s."`
+	RPCRateLimit    float64       `long:"rpcratelimit" description:"Maximum sustained RPC requests per second from each IP address or IPv6 /64 network. A websocket connection counts as one request. Disabled by default."`
+	RPCUnixNoTLS    bool          `long:"rpcunixnotls" description:"Disable TLS when rpcaddr is a unix socket. Authentication is still required."`
+	RPCRateBurst    int           `long:"rpcrateburst" description:"Number of RPC requests an IP address may make at once before rpcratelimit applies. Default is rpcratelimit, rounded up."`
+	RPCMetrics      bool          `long:"rpcmetrics" description:"Serve Prometheus metrics of RPC requests and websocket clients at /metrics on the RPC server."`
+	RPCMetricsToken string        `long:"rpcmetricstoken" description:"Bearer token required to scrape /metrics. If not set, /metrics does not require authentication."`
+	RPCCertOrg      string        `long:"rpccertorg" description:"Organization of a generated RPC server certificate. Only used if rpccert and rpckey do not exist. Default is \"dcrdex autogenerated cert\"."`
+	RPCCertValidity time.Duration `long:"rpccertvalidity" description:"Validity period of a generated RPC server certificate, e.g. 2160h. Only used if rpccert and rpckey do not exist. Default is 10 years."`
+	RPCAltNames     []string      `long:"rpcaltname" description:"Additional host name or IP address for a generated RPC server certificate. May be repeated. localhost, the host name, its interface addresses, and the rpcaddr host are always included."`
+	RPCNoAutoCert   bool          `long:"rpcnoautocert" description:"Require the rpccert and rpckey files to exist. The RPC server fails to start rather than generating a new key pair if they do not."`
+	RPCOrigins      []string      `long:"rpcallowedorigin" description:"Origin, e.g. https://example.com, of a browser page allowed to open an RPC websocket connection. May be repeated. Same-origin and non-browser clients are always allowed. If not set, localhost pages are also allowed. * allows any origin."`
+	RPCAllowIPs     []string      `long:"rpcallowip" description:"IP address or CIDR range, e.g. 192.168.1.0/24, from which RPC requests are accepted. May be repeated. If not set, all addresses are allowed."`
+	RPCReqLogLevel  string        `long:"rpcreqloglevel" description:"Logging level {trace, debug, info, warn, error, critical, off} of each handled RPC request. Failed requests are logged at warn or higher. Default is debug."`
+	RPCMinTLS       string        `long:"rpcmintls" description:"Minimum TLS version {1.2, 1.3} accepted by the RPC server. Default is 1.2."`
+	RPCUnsafeRaw    bool          `long:"rpcunsaferaw" description:"UNSAFE. Enable the raw RPC route, which sends arbitrary requests to a DEX server, bypassing the client's checks. Also requires rpcadmintoken. Every use is logged."`
+	RPCWSTimeout    time.Duration `long:"rpcwsauthtimeout" description:"Time an RPC websocket client has to make a successful request, e.g. ping, before it is disconnected. Disabled by default."`
+	RPCChallenge    string        `long:"rpcauthchallenge" description:"WWW-Authenticate challenge {basic, digest, none} sent by the RPC server with a 401 response. none avoids browser login prompts. digest uses HTTP Digest auth with rpcuser/rpcpass so that the password is not sent. Default is basic."`
+	RPCEnvPrefix    string        `long:"rpcenvpassprefix" description:"Allow RPC password arguments of the form env:VARNAME, read from the environment variable VARNAME of the dexc process, for variables whose names begin with this prefix, e.g. DEXC_. Disabled by default."`
 }
 
 var defaultConfig = Config{
@@ -6,8 +6,10 @@ package ui
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"decred.org/dcrdex/dex"
+	"github.com/decred/slog"
 	"github.com/jrick/logrotate/rotator"
 )
 
@@ -18,6 +20,15 @@ var (
 	debugLevel   string
 	log          dex.Logger
 	masterLogger = func([]byte) {}
+
+	// logMakers are all of the LoggerMakers created by CustomLogMaker, so
+	// that SetLogLevel can change the level of every logger. If levelSet,
+	// SetLogLevel has changed the level to appLevel, which is used for new
+	// LoggerMakers instead of debugLevel.
+	logMakersMtx sync.Mutex
+	logMakers    []*dex.LoggerMaker
+	levelSet     bool
+	appLevel     slog.Level
 )
 
 // logWriter implements an io.Writer that outputs to three separate
@@ -66,7 +77,33 @@ func CustomLogMaker(f func(p []byte), utc bool) (*dex.LoggerMaker, error) {
 	if f == nil {
 		f = func([]byte) {}
 	}
-	return dex.NewLoggerMaker(logWriter{f: f}, debugLevel, utc)
+	lm, err := dex.NewLoggerMaker(logWriter{f: f}, debugLevel, utc)
+	if err != nil {
+		return nil, err
+	}
+	logMakersMtx.Lock()
+	defer logMakersMtx.Unlock()
+	if levelSet {
+		lm.SetLevel(appLevel)
+	}
+	logMakers = append(logMakers, lm)
+	return lm, nil
+}
+
+// SetLogLevel sets the level of every logger, including those created later,
+// replacing any levels set for individual subsystems. The previous level of
+// the application's LoggerMaker, the first created, is returned.
+func SetLogLevel(lvl slog.Level) slog.Level {
+	logMakersMtx.Lock()
+	defer logMakersMtx.Unlock()
+	prev := lvl
+	for i, lm := range logMakers {
+		if p := lm.SetLevel(lvl); i == 0 {
+			prev = p
+		}
+	}
+	levelSet, appLevel = true, lvl
+	return prev
 }
 
 // Close closes the log rotator.
@@ -21,6 +21,9 @@ import (
 var (
 	// The application context. Provided to the Run function, but stored globally.
 	appCtx context.Context
+	// Cancels the application context, stopping the app. Provided to the Run
+	// function and used by the RPC server's shutdown route.
+	appShutdown func()
 	// The tview application.
 	app *tview.Application
 	// The core DEX client application. Used by both the RPC server and the
@@ -51,9 +54,11 @@ var (
 // For brevity, a commonly used tview callback.
 type inputCapture func(event *tcell.EventKey) *tcell.EventKey
 
-// Run the TUI app.
-func Run(ctx context.Context) {
+// Run the TUI app. The app is stopped when ctx is canceled, e.g. by calling
+// shutdown.
+func Run(ctx context.Context, shutdown func()) {
 	appCtx = ctx
+	appShutdown = shutdown
 	// Initialize logging to a widget
 	appJournal = newJournal("Application Log", handleAppLogKey)
 	InitLogging(func(p []byte) {
@@ -63,6 +68,10 @@ func Run(ctx context.Context) {
 	defer Close()
 	// Create the UI and start the app.
 	createApp()
+	go func() {
+		<-appCtx.Done()
+		app.Stop()
+	}()
 	if err := app.SetRoot(screen, true).SetFocus(mainMenu).Run(); err != nil {
 		panic(err)
 	}
@@ -171,16 +180,45 @@ func createWidgets() {
 		cm.Wait()
 	})
 	rpcView = newServerView("RPC", cfg.RPCAddr, func(ctx context.Context, _ string, logger dex.Logger) {
-		setRPCLabelOn(true)
 		defer setRPCLabelOn(false)
 		rpcserver.SetLogger(logger)
 		rpcCfg := &rpcserver.Config{
-			Core: clientCore,
-			Addr: cfg.RPCAddr,
-			User: cfg.RPCUser,
-			Pass: cfg.RPCPass,
-			Cert: cfg.RPCCert,
-			Key:  cfg.RPCKey,
+			Core:      clientCore,
+			Addr:      cfg.RPCAddr,
+			User:      cfg.RPCUser,
+			Pass:      cfg.RPCPass,
+			Token:     cfg.RPCToken,
+			Cert:      cfg.RPCCert,
+			Key:       cfg.RPCKey,
+			ClientCAs: cfg.RPCCAs,
+
+			HMACSecret:      cfg.RPCHMAC,
+			AdminToken:      cfg.RPCAdmin,
+			ReadTimeout:     cfg.RPCReadTimeout,
+			WriteTimeout:    cfg.RPCWriteTimeout,
+			UnixNoTLS:       cfg.RPCUnixNoTLS,
+			DrainTimeout:    cfg.RPCDrainTimeout,
+			RateLimit:       cfg.RPCRateLimit,
+			RateBurst:       cfg.RPCRateBurst,
+			Metrics:         cfg.RPCMetrics,
+			MetricsToken:    cfg.RPCMetricsToken,
+			AllowedOrigins:  cfg.RPCOrigins,
+			CertOrg:         cfg.RPCCertOrg,
+			CertValidity:    cfg.RPCCertValidity,
+			AltNames:        cfg.RPCAltNames,
+			DisableAutoCert: cfg.RPCNoAutoCert,
+			AllowIPs:        cfg.RPCAllowIPs,
+			RequestLogLevel: cfg.RPCReqLogLevel,
+			MinTLSVersion:   cfg.RPCMinTLS,
+			AuthChallenge:   cfg.RPCChallenge,
+			UnsafeRaw:       cfg.RPCUnsafeRaw,
+			EnvPassPrefix:   cfg.RPCEnvPrefix,
+			SetLogLevel:     SetLogLevel,
+			WSAuthTimeout:   cfg.RPCWSTimeout,
+			Shutdown:        appShutdown,
+			AppVersion:      cfg.AppVersion,
+			// Only show the server as on once it is listening.
+			Ready: func(string) { setRPCLabelOn(true) },
 		}
 		rpcSrv, err := rpcserver.New(rpcCfg)
 		if err != nil {
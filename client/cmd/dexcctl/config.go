@@ -37,13 +37,18 @@ type config struct {
 	Config       string   `short:"C" long:"config" description:"Path to configuration file"`
 	RPCUser      string   `short:"u" long:"rpcuser" description:"RPC username"`
 	RPCPass      string   `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
-	RPCAddr      string   `short:"a" long:"rpcaddr" description:"RPC server to connect to"`
+	RPCToken     string   `long:"rpctoken" default-mask:"-" description:"RPC bearer token, used instead of the RPC username and password"`
+	RPCHMAC      string   `long:"rpchmacsecret" default-mask:"-" description:"RPC HMAC secret for signing requests, used instead of the RPC token, username, and password"`
+	RPCAddr      string   `short:"a" long:"rpcaddr" description:"RPC server to connect to, or unix:///path/to/socket for a unix socket"`
 	RPCCert      string   `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
+	NoTLS        bool     `long:"notls" description:"Disable TLS for a unix socket RPC server"`
+	ClientCert   string   `long:"clientcert" description:"Client certificate file, for servers requiring client certificates"`
+	ClientKey    string   `long:"clientkey" description:"Client key file, for servers requiring client certificates"`
 	PrintJSON    bool     `short:"j" long:"json" description:"Print json messages sent and received"`
 	Proxy        string   `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser    string   `long:"proxyuser" description:"Username for proxy server"`
 	ProxyPass    string   `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
-	PasswordArgs []string `short:"p" long:"passarg" description:"Password arguments to bypass stdin prompts."`
+	PasswordArgs []string `short:"p" long:"passarg" description:"Password arguments to bypass stdin prompts. Use - to read a password from a line of stdin instead. Passwords given on the command line are visible in process listings and shell history, so prompts or - are preferred. For init, login, newwallet, openwallet, and register, env:VARNAME uses the environment variable VARNAME of the dexc process if allowed by its rpcenvpassprefix, and a password beginning with env: must be preceded by a backslash."`
 }
 
 // fileExists reports whether the named file or directory exists.
@@ -120,6 +125,17 @@ func configure() (*config, []string, bool, error) {
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
 
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, nil, false, fmt.Errorf("clientcert and clientkey must be set together")
+	}
+	if cfg.ClientCert != "" {
+		cfg.ClientCert = cleanAndExpandPath(cfg.ClientCert)
+		cfg.ClientKey = cleanAndExpandPath(cfg.ClientKey)
+	}
+	if cfg.NoTLS && !strings.HasPrefix(cfg.RPCAddr, unixAddrPrefix) {
+		return nil, nil, false, fmt.Errorf("notls is only allowed for a unix socket rpcaddr")
+	}
+
 	return cfg, remainingArgs, false, nil
 }
 
@@ -5,9 +5,12 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -102,6 +105,7 @@ func TestReadTextFile(t *testing.T) {
 		file.Close()
 	}
 	certTxt := "Hi. I'm a TLS certificate."
+	certPEM := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
 	cfgTxt := "Hi, I'm a config"
 	tests := []struct {
 		name, cmd, txtFilePath, txtToSave string
@@ -114,6 +118,18 @@ func TestReadTextFile(t *testing.T) {
 		txtFilePath: "./cert",
 		txtToSave:   certTxt,
 		want:        []string{"1.2.3.4:3000", certTxt},
+	}, {
+		name: "ok inline cert",
+		cmd:  "register",
+		args: []string{"1.2.3.4:3000", "100000000", certPEM},
+		want: []string{"1.2.3.4:3000", "100000000", certPEM},
+	}, {
+		name:        "getdexconfig ok with cert",
+		cmd:         "getdexconfig",
+		args:        []string{"1.2.3.4:3000", "./cert"},
+		txtFilePath: "./cert",
+		txtToSave:   certTxt,
+		want:        []string{"1.2.3.4:3000", certTxt},
 	}, {
 		name: "ok no cert",
 		cmd:  "getfee",
@@ -139,6 +155,13 @@ func TestReadTextFile(t *testing.T) {
 		cmd:  "newwallet",
 		args: []string{"42"},
 		want: []string{"42"},
+	}, {
+		name:        "reconfigwallet ok, with cfg file",
+		cmd:         "reconfigwallet",
+		args:        []string{"42", "./w.conf", `{"account":"default"}`},
+		txtFilePath: "./w.conf",
+		txtToSave:   cfgTxt,
+		want:        []string{"42", cfgTxt, `{"account":"default"}`},
 	}}
 	for _, test := range tests {
 		if test.txtFilePath != "" {
@@ -159,3 +182,68 @@ func TestReadTextFile(t *testing.T) {
 		}
 	}
 }
+
+func TestPromptPWs(t *testing.T) {
+	tests := []struct {
+		name, cmd, stdin string
+		cmdPWs, want     []string
+		wantErr          bool
+	}{{
+		name:   "plaintext",
+		cmd:    "login",
+		cmdPWs: []string{"abc"},
+		want:   []string{"abc"},
+	}, {
+		name:   "from stdin",
+		cmd:    "newwallet",
+		stdin:  "apppass\r\nwalletpass\n",
+		cmdPWs: []string{"-", "-"},
+		want:   []string{"apppass", "walletpass"},
+	}, {
+		name:   "mixed",
+		cmd:    "newwallet",
+		stdin:  "walletpass",
+		cmdPWs: []string{"apppass", "-"},
+		want:   []string{"apppass", "walletpass"},
+	}, {
+		name:    "not enough lines",
+		cmd:     "newwallet",
+		stdin:   "apppass\n",
+		cmdPWs:  []string{"-", "-"},
+		wantErr: true,
+	}, {
+		name:    "empty line",
+		cmd:     "login",
+		stdin:   "\n",
+		cmdPWs:  []string{"-"},
+		wantErr: true,
+	}, {
+		name:    "wrong number",
+		cmd:     "login",
+		cmdPWs:  []string{"a", "b"},
+		wantErr: true,
+	}, {
+		name: "no passwords",
+		cmd:  "version",
+	}}
+	for _, test := range tests {
+		bio := bufio.NewReader(strings.NewReader(test.stdin))
+		pws, err := promptPWs(context.Background(), test.cmd, test.cmdPWs, bio)
+		if err != nil {
+			if test.wantErr {
+				continue
+			}
+			t.Fatalf("unexpected error for %s: %v", test.name, err)
+		} else if test.wantErr {
+			t.Fatalf("expected error for test %s", test.name)
+		}
+		if len(pws) != len(test.want) {
+			t.Fatalf("wanted %d passwords, got %d for test %s", len(test.want), len(pws), test.name)
+		}
+		for i, pw := range pws {
+			if string(pw) != test.want[i] {
+				t.Fatalf("wanted password %q, got %q for test %s", test.want[i], string(pw), test.name)
+			}
+		}
+	}
+}
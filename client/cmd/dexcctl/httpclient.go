@@ -13,11 +13,16 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 
+	"decred.org/dcrdex/client/rpcserver"
 	"decred.org/dcrdex/dex/msgjson"
 	"github.com/decred/go-socks/socks"
 )
 
+// unixAddrPrefix is the RPCAddr prefix for a unix socket path.
+const unixAddrPrefix = "unix://"
+
 // newHTTPClient returns a new HTTP client that is configured according to the
 // proxy and TLS settings in the associated connection configuration.
 func newHTTPClient(cfg *config, urlStr string) (*http.Client, error) {
@@ -38,6 +43,17 @@ func newHTTPClient(cfg *config, urlStr string) (*http.Client, error) {
 		}
 	}
 
+	// Connect to a unix socket.
+	if strings.HasPrefix(cfg.RPCAddr, unixAddrPrefix) {
+		path := strings.TrimPrefix(cfg.RPCAddr, unixAddrPrefix)
+		dial = func(string, string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
+	}
+	if cfg.NoTLS {
+		return &http.Client{Transport: &http.Transport{Dial: dial}}, nil
+	}
+
 	// Configure TLS.
 	pem, err := ioutil.ReadFile(cfg.RPCCert)
 	if err != nil {
@@ -58,6 +74,13 @@ func newHTTPClient(cfg *config, urlStr string) (*http.Client, error) {
 		RootCAs:    pool,
 		ServerName: uri.Hostname(),
 	}
+	if cfg.ClientCert != "" {
+		keypair, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{keypair}
+	}
 
 	// Create and return the new HTTP client potentially configured with a
 	// proxy and TLS.
@@ -77,6 +100,14 @@ func newHTTPClient(cfg *config, urlStr string) (*http.Client, error) {
 func sendPostRequest(marshalledJSON []byte, cfg *config) (*msgjson.Message, error) {
 	// Generate a request to the configured RPC server.
 	urlStr := "https://" + cfg.RPCAddr
+	if strings.HasPrefix(cfg.RPCAddr, unixAddrPrefix) {
+		// The host is only used to verify the server certificate, which
+		// includes localhost.
+		urlStr = "https://localhost"
+		if cfg.NoTLS {
+			urlStr = "http://localhost"
+		}
+	}
 	if cfg.PrintJSON {
 		fmt.Println(string(marshalledJSON))
 	}
@@ -88,8 +119,15 @@ func sendPostRequest(marshalledJSON []byte, cfg *config) (*msgjson.Message, erro
 	httpRequest.Close = true
 	httpRequest.Header.Set("Content-Type", "application/json")
 
-	// Configure basic access authorization.
-	httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPass)
+	// Sign the request, or configure bearer token or basic access
+	// authorization.
+	if cfg.RPCHMAC != "" {
+		rpcserver.SignRequest(httpRequest, cfg.RPCHMAC, marshalledJSON)
+	} else if cfg.RPCToken != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+cfg.RPCToken)
+	} else {
+		httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPass)
+	}
 
 	// Create the new HTTP client that is configured according to the user-
 	// specified options and submit the request.
@@ -52,30 +52,38 @@ func main() {
 // promptPasswords is a map of routes to password prompts. Passwords are
 // prompted in the order given.
 var promptPasswords = map[string][]string{
-	"cancel":     {"App password:"},
-	"init":       {"Set new app password:"},
-	"login":      {"App password:"},
-	"newwallet":  {"App password:", "Wallet password:"},
-	"openwallet": {"App password:"},
-	"register":   {"App password:"},
-	"trade":      {"App password:"},
-	"withdraw":   {"App password:"},
+	"backup":         {"App password:"},
+	"cancel":         {"App password:"},
+	"changeapppass":  {"App password:", "Set new app password:"},
+	"disconnectdex":  {"App password:"},
+	"init":           {"Set new app password:"},
+	"login":          {"App password:"},
+	"newwallet":      {"App password:", "Wallet password:"},
+	"openwallet":     {"App password:"},
+	"reconfigwallet": {"App password:"},
+	"register":       {"App password:"},
+	"trade":          {"App password:"},
+	"tradereport":    {"App password:"},
+	"withdraw":       {"App password:"},
 }
 
 // optionalTextFiles is a map of routes to arg index for routes that should read
 // the text content of a file, where the file path _may_ be found in the route's
 // cmd args at the specified index.
 var optionalTextFiles = map[string]int{
-	"getfee":    1,
-	"register":  2,
-	"newwallet": 1,
+	"getfee":         1,
+	"getdexconfig":   1,
+	"register":       2,
+	"newwallet":      1,
+	"reconfigwallet": 1,
 }
 
 // promptPWs prompts for passwords on stdin and returns an error if prompting
 // fails or a password is empty. Returns passwords as a slice of []byte. If
 // cmdPWs is provided, the passwords will be drawn from cmdPWs instead of stdin
-// prompts.
-func promptPWs(ctx context.Context, cmd string, cmdPWs []string) ([]encode.PassBytes, error) {
+// prompts. A cmdPWs value of "-" is read as a line from bio, so that a script
+// can pipe in the password without it appearing in the process listing.
+func promptPWs(ctx context.Context, cmd string, cmdPWs []string, bio *bufio.Reader) ([]encode.PassBytes, error) {
 	prompts, exists := promptPasswords[cmd]
 	if !exists {
 		return nil, nil
@@ -88,6 +96,16 @@ func promptPWs(ctx context.Context, cmd string, cmdPWs []string) ([]encode.PassB
 			return nil, fmt.Errorf("Wrong number of command-line passwords, expected %d, got %d. Prompts = %v", len(prompts), len(cmdPWs), prompts)
 		}
 		for i, strPW := range cmdPWs {
+			if strPW == "-" {
+				line, err := readStdinLine(bio)
+				if err != nil {
+					return nil, err
+				}
+				if line == "" {
+					return nil, fmt.Errorf("empty password read from stdin for %q", prompts[i])
+				}
+				strPW = line
+			}
 			pws[i] = encode.PassBytes(strPW)
 		}
 		return pws, nil
@@ -105,13 +123,18 @@ func promptPWs(ctx context.Context, cmd string, cmdPWs []string) ([]encode.PassB
 
 // readTextFile reads the text content of the file whose path is specified at
 // args' index as expected for cmd and sets the args value at the expected index
-// to the file's text content. The passed args are modified.
+// to the file's text content. The passed args are modified. An arg that is
+// already PEM-encoded content, e.g. a certificate from an environment variable,
+// is left as is.
 func readTextFile(cmd string, args []string) error {
 	fileArgIndx, readFile := optionalTextFiles[cmd]
 	// Not an error if file path arg is not provided for optional file args.
 	if !readFile || len(args) < fileArgIndx+1 || args[fileArgIndx] == "" {
 		return nil
 	}
+	if isPEM(args[fileArgIndx]) {
+		return nil
+	}
 	path := cleanAndExpandPath(args[fileArgIndx])
 	if !fileExists(path) {
 		return fmt.Errorf("no file found at %s", path)
@@ -124,6 +147,24 @@ func readTextFile(cmd string, args []string) error {
 	return nil
 }
 
+// readStdinLine reads the next line from bio, which reads from stdin, without
+// the line ending.
+func readStdinLine(bio *bufio.Reader) (string, error) {
+	line, err := bio.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("Failed to read data from stdin: %v", err)
+	}
+	if err == io.EOF && len(line) == 0 {
+		return "", errors.New("Not enough lines provided on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// isPEM checks whether s is inline PEM-encoded content rather than a file path.
+func isPEM(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "-----BEGIN")
+}
+
 func run(ctx context.Context) error {
 	cfg, args, stop, err := configure()
 	if err != nil {
@@ -147,14 +188,10 @@ func run(ctx context.Context) error {
 	params := make([]string, 0, len(args[1:]))
 	for _, arg := range args[1:] {
 		if arg == "-" {
-			param, err := bio.ReadString('\n')
-			if err != nil && err != io.EOF {
-				return fmt.Errorf("Failed to read data from stdin: %v", err)
-			}
-			if err == io.EOF && len(param) == 0 {
-				return errors.New("Not enough lines provided on stdin")
+			param, err := readStdinLine(bio)
+			if err != nil {
+				return err
 			}
-			param = strings.TrimRight(param, "\r\n")
 			params = append(params, param)
 			continue
 		}
@@ -162,7 +199,7 @@ func run(ctx context.Context) error {
 	}
 
 	// Prompt for passwords.
-	pws, err := promptPWs(ctx, args[0], cfg.PasswordArgs)
+	pws, err := promptPWs(ctx, args[0], cfg.PasswordArgs, bio)
 	if err != nil {
 		return err
 	}
@@ -8,8 +8,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -20,25 +22,54 @@ import (
 
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
+	"github.com/decred/go-socks/socks"
 	"github.com/gorilla/websocket"
 )
 
 const (
-	// bufferSize is buffer size for a websocket connection's read channel.
-	readBuffSize = 128
-
-	// The maximum time in seconds to write to a connection.
-	writeWait = time.Second * 3
-
 	// reconnetInterval is the initial and increment between reconnect tries.
 	reconnectInterval = 5 * time.Second
 
 	// maxReconnetInterval is the maximum allowed reconnect interval.
 	maxReconnectInterval = time.Minute
 
+	// pingJitter is the largest fraction of the PingInterval by which each
+	// ping is randomly sent early, so that many connections started together
+	// do not ping in step.
+	pingJitter = 0.2
+
+	// closeWait is how long Close waits for the server to acknowledge the
+	// close message before closing the connection.
+	closeWait = time.Second
+
+	// maxCloseReasonLen is the maximum length of a close message reason. A
+	// control frame payload is limited to 125 bytes, 2 of which are the close
+	// code.
+	maxCloseReasonLen = 123
+
 	// DefaultResponseTimeout is the default timeout for responses after a
 	// request is successfully sent.
 	DefaultResponseTimeout = 30 * time.Second
+
+	// DefaultDialTimeout is the default time allowed to dial the server and
+	// complete the websocket handshake.
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultWriteWait is the default time allowed to write a message or a
+	// ping, pong, or close control frame to the connection.
+	DefaultWriteWait = 3 * time.Second
+
+	// DefaultMaxMessageSize is the default maximum size of a received message.
+	// It is large enough for the order book snapshot of a busy market.
+	DefaultMaxMessageSize = 32 << 20 // 32 MiB
+
+	// DefaultWriteQueueSize is the default number of outgoing messages that
+	// may be queued for writing.
+	DefaultWriteQueueSize = 128
+
+	// DefaultReadQueueSize is the default number of received messages that
+	// may be waiting to be read from the MessageSource.
+	DefaultReadQueueSize = 128
 )
 
 // ErrInvalidCert is the error returned when attempting to use an invalid cert
@@ -49,15 +80,85 @@ var ErrInvalidCert = fmt.Errorf("invalid certificate")
 // cert was provided.
 var ErrCertRequired = fmt.Errorf("certificate required")
 
+// ErrWriteQueueFull is the error returned when a message cannot be sent because
+// the write queue is full, e.g. if the server is slow to read.
+var ErrWriteQueueFull = fmt.Errorf("write queue full")
+
+// ErrDialTimeout is the error returned when a ws connection attempt does not
+// complete within the dial timeout.
+var ErrDialTimeout = fmt.Errorf("dial timeout")
+
 // WsConn is an interface for a websocket client.
 type WsConn interface {
 	NextID() uint64
 	IsDown() bool
+	WaitForConnect(ctx context.Context) error
+	Close(reason string) error
 	Send(msg *msgjson.Message) error
 	Request(msg *msgjson.Message, respHandler func(*msgjson.Message)) error
 	RequestWithTimeout(msg *msgjson.Message, respHandler func(*msgjson.Message), expireTime time.Duration, expire func()) error
+	RequestWithRetry(msg *msgjson.Message, respHandler func(*msgjson.Message), expireTime time.Duration, expire func(), idempotent bool) error
 	Connect(ctx context.Context) (*sync.WaitGroup, error)
 	MessageSource() <-chan *msgjson.Message
+	Stats() ConnStats
+	Status() ConnStatus
+}
+
+// ConnStats is a snapshot of a WsConn's connection statistics. The counters
+// are cumulative over all connections, including reconnects.
+type ConnStats struct {
+	// Reconnects is the number of successful reconnects.
+	Reconnects uint64 `json:"reconnects"`
+	// BytesRead and BytesWritten are the bytes read from and written to the
+	// network, including TLS and websocket framing.
+	BytesRead    uint64 `json:"bytesRead"`
+	BytesWritten uint64 `json:"bytesWritten"`
+	// QueuedMessages is the number of received messages waiting to be read
+	// from the MessageSource.
+	QueuedMessages int `json:"queuedMessages"`
+	// DroppedMessages is the number of received messages that were dropped
+	// because the read queue was full. See WsCfg.ReadQueueDrop.
+	DroppedMessages uint64 `json:"droppedMessages"`
+	// LastConnect is the time of the last successful connect. It is zero if
+	// the WsConn has never connected.
+	LastConnect time.Time `json:"lastConnect"`
+	// Compressed is true if permessage-deflate compression was negotiated
+	// with the server for the last successful connect. It is false if
+	// WsCfg.Compress is not set or the server declined compression, and may
+	// change on reconnect.
+	Compressed bool `json:"compressed"`
+}
+
+// ConnStatus is a snapshot of a WsConn's connection state.
+type ConnStatus struct {
+	// Connected is true if the connection is up.
+	Connected bool
+	// Reconnecting is true if the connection was lost, or the initial
+	// connection attempt timed out, and a reconnect is in progress or
+	// scheduled.
+	Reconnecting bool
+	// LastError is the most recent error that took the connection down or
+	// caused a connection attempt to fail. It is not cleared by a successful
+	// reconnect. It is nil if there has been no error.
+	LastError error
+}
+
+// MarshalJSON encodes the ConnStatus with LastError as a string, which is
+// omitted if LastError is nil.
+func (s ConnStatus) MarshalJSON() ([]byte, error) {
+	var lastErr string
+	if s.LastError != nil {
+		lastErr = s.LastError.Error()
+	}
+	return json.Marshal(&struct {
+		Connected    bool   `json:"connected"`
+		Reconnecting bool   `json:"reconnecting"`
+		LastError    string `json:"lastError,omitempty"`
+	}{
+		Connected:    s.Connected,
+		Reconnecting: s.Reconnecting,
+		LastError:    lastErr,
+	})
 }
 
 // When the DEX sends a request to the client, a responseHandler is created
@@ -65,6 +166,12 @@ type WsConn interface {
 type responseHandler struct {
 	expiration *time.Timer
 	f          func(*msgjson.Message)
+	// retry is the request to re-send after a reconnect, and is nil if the
+	// request is not idempotent. retries is the number of re-sends left, and
+	// sentOn is the value of the reconnects counter when it was last sent.
+	retry   *msgjson.Message
+	retries int
+	sentOn  uint64
 }
 
 // WsCfg is the configuration struct for initializing a WsConn.
@@ -75,10 +182,90 @@ type WsCfg struct {
 	// should be larger than the server's ping interval to allow for network
 	// latency.
 	PingWait time.Duration
-	// The server's certificate.
+	// PingInterval is how often to ping the server, which keeps an otherwise
+	// idle connection from being dropped by a NAT or firewall idle timeout.
+	// Each pong extends the read deadline by PingWait, so PingInterval must be
+	// shorter than PingWait. If no pong is received within PingWait of a ping,
+	// the connection is considered half-open, even if the server's pings are
+	// still arriving, and it is closed and reconnected. Pings are sent up to
+	// 20% early at random. If zero, the client does not send pings and relies
+	// on the server's pings.
+	PingInterval time.Duration
+	// DialTimeout is the maximum time allowed to dial the server, including
+	// any proxy, and complete the TLS and websocket handshakes. If zero,
+	// DefaultDialTimeout is used.
+	DialTimeout time.Duration
+	// WriteWait is the maximum time allowed to write a message or a ping,
+	// pong, or close control frame. A slow link may need a longer deadline so
+	// that pings and pongs do not fail spuriously. If zero, DefaultWriteWait
+	// is used.
+	WriteWait time.Duration
+	// MaxMessageSize is the maximum size in bytes of a message received from
+	// the server. A larger message closes the connection and triggers a
+	// reconnect. If zero, DefaultMaxMessageSize is used.
+	MaxMessageSize int64
+	// WriteQueueSize is the number of outgoing messages that may be waiting to
+	// be written. When the queue is full, Send and Request fail with
+	// ErrWriteQueueFull instead of blocking. If zero, DefaultWriteQueueSize is
+	// used.
+	WriteQueueSize int
+	// ReadQueueSize is the number of received requests and notifications that
+	// may be waiting to be read from the MessageSource. If zero,
+	// DefaultReadQueueSize is used.
+	ReadQueueSize int
+	// ReadQueueDrop sets what happens to a received message when the read
+	// queue is full. By default, reading from the connection blocks until the
+	// consumer catches up. No messages are lost, but while blocked, pings from
+	// the server are not answered and the read deadline may pass, so a slow
+	// consumer can cause a reconnect. If ReadQueueDrop is true, the message is
+	// dropped with a warning instead, which keeps the connection alive at the
+	// cost of the lost message. Dropped messages are counted in
+	// ConnStats.DroppedMessages.
+	ReadQueueDrop bool
+	// RequestRetries is the number of times a request sent with
+	// RequestWithRetry and marked idempotent is re-sent after a reconnect if
+	// it has not been answered. The request still expires at the time set
+	// when it was first sent. Other requests are never re-sent. If zero,
+	// requests are not retried.
+	RequestRetries int
+	// The server's certificate. If empty, the server's certificate must be
+	// trusted by the host's system root pool, e.g. a publicly trusted CA. This
+	// may be a bundle of PEM-encoded certificates, in which case the server
+	// may present any of them, e.g. both the old and new certificate while a
+	// server rotates its certificate.
 	Cert []byte
+	// CertUpdate is called when a reconnect fails because the server's
+	// certificate is not trusted, e.g. after the server rotated it. It should
+	// return the new PEM-encoded certificate or bundle, which replaces Cert for
+	// the following connection attempts, and the reconnect is retried right
+	// away. If CertUpdate is nil or returns an error or no certificate,
+	// reconnects continue on the usual schedule with the current certificate.
+	CertUpdate func() ([]byte, error)
+	// ProxyAddr is the address of a SOCKS5 proxy, e.g. a Tor daemon, through
+	// which to connect. Optional. TLS is still verified end-to-end with the
+	// server.
+	ProxyAddr string
+	// ProxyUser and ProxyPass are the SOCKS5 proxy credentials, if required.
+	ProxyUser, ProxyPass string
+	// Headers are additional HTTP headers sent with the websocket upgrade
+	// request on every connection attempt, e.g. an Authorization header
+	// required by a reverse proxy. The websocket protocol headers, such as
+	// Upgrade and Sec-Websocket-Key, may not be set.
+	Headers http.Header
+	// Compress requests permessage-deflate compression, which greatly reduces
+	// the size of order book messages. If the server does not support it,
+	// messages are sent uncompressed.
+	Compress bool
+	// MinTLSVersion is the minimum TLS version, "1.2" or "1.3", accepted from
+	// the server. If empty, TLS 1.2 is the minimum.
+	MinTLSVersion string
 	// ReconnectSync runs the needed reconnection synchronization after
-	// a reconnect.
+	// a reconnect, e.g. resubscribing to order book feeds. Responses and
+	// server requests are processed while it runs, since a request such as
+	// preimage may have a deadline shorter than the sync, but notifications
+	// received on the new connection are held and delivered to the
+	// MessageSource only after ReconnectSync returns, so none are missed or
+	// handled out of order with the resynchronization.
 	ReconnectSync func()
 	// ConnectEventFunc runs whenever connection status changes.
 	//
@@ -91,24 +278,72 @@ type WsCfg struct {
 
 // wsConn represents a client websocket connection.
 type wsConn struct {
+	// 64-bit atomic counters first for alignment on 32-bit platforms.
+	reconnects   uint64
+	bytesRead    uint64
+	bytesWritten uint64
+	dropped      uint64
+	lastConnect  int64  // unix nanoseconds
+	closing      uint32 // set by Close to prevent reconnects
+	compressed   uint32 // set on connect if compression was negotiated
+
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
+	// readWg tracks the goroutines that send on readCh, read and keepAlive,
+	// so that readCh is only closed after they return.
+	readWg sync.WaitGroup
 	log    dex.Logger
 	rID    uint64
 	cfg    *WsCfg
-	tlsCfg *tls.Config
 	readCh chan *msgjson.Message
 
+	// certMtx guards the certificate and the TLS configuration, which are
+	// replaced if CertUpdate provides a new certificate.
+	certMtx sync.RWMutex
+	cert    []byte
+	tlsCfg  *tls.Config
+
 	wsMtx sync.Mutex
 	ws    *websocket.Conn
 
+	// connectedMtx guards the connection state reported by Status.
+	// connectedCh is closed when the connection comes up, and replaced with
+	// an open channel when it goes down.
 	connectedMtx sync.RWMutex
 	connected    bool
+	connectedCh  chan struct{}
+	reconnecting bool
+	lastErr      error
 
 	reqMtx       sync.RWMutex
 	respHandlers map[uint64]*responseHandler
 
 	reconnectCh chan struct{} // trigger for immediate reconnect
+	// peerClosed is signaled by read when the connection is closed after
+	// Close sent a close message, which is expected to be the server's close
+	// acknowledgement.
+	peerClosed chan struct{}
+
+	// syncing is set while ReconnectSync runs, during which notifications
+	// are queued in held rather than sent on readCh.
+	syncMtx sync.Mutex
+	syncing bool
+	held    []*msgjson.Message
+
+	// writeCh queues messages and control frames for writePump, which
+	// serializes all writes to the websocket. done is closed when the wsConn
+	// is stopped.
+	writeCh chan *wsWrite
+	done    <-chan struct{}
+}
+
+// wsWrite is a message or control frame queued for writing to a
+// websocket.Conn. The result of the write is sent on errCh.
+type wsWrite struct {
+	ws      *websocket.Conn
+	msgType int
+	data    []byte
+	errCh   chan error
 }
 
 // NewWsConn creates a client websocket connection.
@@ -116,41 +351,199 @@ func NewWsConn(cfg *WsCfg) (WsConn, error) {
 	if cfg.PingWait < 0 {
 		return nil, fmt.Errorf("ping wait cannot be negative")
 	}
+	if cfg.PingInterval < 0 {
+		return nil, fmt.Errorf("ping interval cannot be negative")
+	}
+	if cfg.DialTimeout < 0 {
+		return nil, fmt.Errorf("dial timeout cannot be negative")
+	}
+	if cfg.WriteWait < 0 {
+		return nil, fmt.Errorf("write wait cannot be negative")
+	}
+	if cfg.MaxMessageSize < 0 {
+		return nil, fmt.Errorf("max message size cannot be negative")
+	}
+	if cfg.WriteQueueSize < 0 {
+		return nil, fmt.Errorf("write queue size cannot be negative")
+	}
+	if cfg.ReadQueueSize < 0 {
+		return nil, fmt.Errorf("read queue size cannot be negative")
+	}
+	if cfg.RequestRetries < 0 {
+		return nil, fmt.Errorf("request retries cannot be negative")
+	}
+	if cfg.PingInterval > 0 && cfg.PingInterval >= cfg.PingWait {
+		return nil, fmt.Errorf("ping interval %v must be shorter than ping wait %v",
+			cfg.PingInterval, cfg.PingWait)
+	}
+	minTLSVersion, err := ParseTLSVersion(cfg.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
 
-	var tlsConfig *tls.Config
-	if len(cfg.Cert) > 0 {
-
-		uri, err := url.Parse(cfg.URL)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing URL: %v", err)
-		}
+	uri, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %v", err)
+	}
 
-		rootCAs, _ := x509.SystemCertPool()
-		if rootCAs == nil {
-			rootCAs = x509.NewCertPool()
-		}
+	rootCAs, err := rootCAsPool(cfg.Cert)
+	if err != nil {
+		return nil, err
+	}
 
-		if ok := rootCAs.AppendCertsFromPEM(cfg.Cert); !ok {
-			return nil, ErrInvalidCert
-		}
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: minTLSVersion,
+		ServerName: uri.Hostname(),
+	}
 
-		tlsConfig = &tls.Config{
-			RootCAs:    rootCAs,
-			MinVersion: tls.VersionTLS12,
-			ServerName: uri.Hostname(),
-		}
+	writeQueueSize := cfg.WriteQueueSize
+	if writeQueueSize == 0 {
+		writeQueueSize = DefaultWriteQueueSize
+	}
+	readQueueSize := cfg.ReadQueueSize
+	if readQueueSize == 0 {
+		readQueueSize = DefaultReadQueueSize
 	}
 
 	return &wsConn{
 		cfg:          cfg,
 		log:          cfg.Logger,
+		cert:         cfg.Cert,
 		tlsCfg:       tlsConfig,
-		readCh:       make(chan *msgjson.Message, readBuffSize),
+		readCh:       make(chan *msgjson.Message, readQueueSize),
+		writeCh:      make(chan *wsWrite, writeQueueSize),
 		respHandlers: make(map[uint64]*responseHandler),
 		reconnectCh:  make(chan struct{}, 1),
+		connectedCh:  make(chan struct{}),
+		peerClosed:   make(chan struct{}, 1),
 	}, nil
 }
 
+// rootCAsPool creates the pool of root CAs for verifying the server's
+// certificate, which is the host's system root pool with the certificates in
+// the PEM-encoded bundle added. If the bundle is empty, the returned pool is
+// nil, which uses the host's system root pool.
+func rootCAsPool(bundle []byte) (*x509.CertPool, error) {
+	if len(bundle) == 0 {
+		return nil, nil
+	}
+	rootCAs, _ := x509.SystemCertPool()
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if err := addCertBundle(rootCAs, bundle); err != nil {
+		return nil, err
+	}
+	return rootCAs, nil
+}
+
+// certConfig returns the current certificate and TLS configuration.
+func (conn *wsConn) certConfig() ([]byte, *tls.Config) {
+	conn.certMtx.RLock()
+	defer conn.certMtx.RUnlock()
+	return conn.cert, conn.tlsCfg
+}
+
+// isCertError checks if the error from connect is because the server's
+// certificate is not trusted.
+func isCertError(err error) bool {
+	return errors.Is(err, ErrInvalidCert) || errors.Is(err, ErrCertRequired)
+}
+
+// updateCert gets a new certificate from the CertUpdate func and uses it for
+// the following connection attempts. It returns true if the certificate was
+// updated.
+func (conn *wsConn) updateCert() bool {
+	if conn.cfg.CertUpdate == nil {
+		return false
+	}
+	cert, err := conn.cfg.CertUpdate()
+	if err != nil {
+		conn.log.Errorf("Error getting an updated certificate for %s: %v", conn.cfg.URL, err)
+		return false
+	}
+	if len(cert) == 0 {
+		return false
+	}
+	rootCAs, err := rootCAsPool(cert)
+	if err != nil {
+		conn.log.Errorf("Updated certificate for %s is invalid: %v", conn.cfg.URL, err)
+		return false
+	}
+	conn.certMtx.Lock()
+	defer conn.certMtx.Unlock()
+	tlsCfg := conn.tlsCfg.Clone()
+	tlsCfg.RootCAs = rootCAs
+	conn.cert, conn.tlsCfg = cert, tlsCfg
+	return true
+}
+
+// addCertBundle adds each certificate in the PEM-encoded bundle to the pool.
+// ErrInvalidCert is returned if the bundle has no certificates or any block
+// is not a valid certificate.
+func addCertBundle(pool *x509.CertPool, bundle []byte) error {
+	var n int
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("%w: unexpected PEM block type %q", ErrInvalidCert, block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidCert, err)
+		}
+		pool.AddCert(cert)
+		n++
+	}
+	if n == 0 {
+		return ErrInvalidCert
+	}
+	return nil
+}
+
+// ParseTLSVersion parses a minimum TLS version, "1.2" or "1.3", into its
+// crypto/tls constant. An empty version is TLS 1.2. Any other version is an
+// error.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported minimum TLS version %q, must be 1.2 or 1.3", version)
+}
+
+// maxMessageSize is the configured MaxMessageSize or the default.
+func (conn *wsConn) maxMessageSize() int64 {
+	if conn.cfg.MaxMessageSize > 0 {
+		return conn.cfg.MaxMessageSize
+	}
+	return DefaultMaxMessageSize
+}
+
+// writeWait is the configured WriteWait or the default.
+func (conn *wsConn) writeWait() time.Duration {
+	if conn.cfg.WriteWait > 0 {
+		return conn.cfg.WriteWait
+	}
+	return DefaultWriteWait
+}
+
+// isTimeout checks if the error is from a dial or handshake that timed out.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // IsDown indicates if the connection is known to be down.
 func (conn *wsConn) IsDown() bool {
 	conn.connectedMtx.RLock()
@@ -159,35 +552,126 @@ func (conn *wsConn) IsDown() bool {
 }
 
 // setConnected updates the connection's connected state and runs the
-// ConnectEventFunc in case of a change.
+// ConnectEventFunc in case of a change. A reconnect is no longer in progress
+// once the connection is up or has been stopped.
 func (conn *wsConn) setConnected(connected bool) {
 	conn.connectedMtx.Lock()
 	statusChange := conn.connected != connected
 	conn.connected = connected
+	conn.reconnecting = false
+	if statusChange {
+		if connected {
+			close(conn.connectedCh)
+		} else {
+			conn.connectedCh = make(chan struct{})
+		}
+	}
 	conn.connectedMtx.Unlock()
 	if statusChange && conn.cfg.ConnectEventFunc != nil {
 		conn.cfg.ConnectEventFunc(connected)
 	}
 }
 
+// WaitForConnect blocks until the connection is up or the context is
+// canceled, in which case the context's error is returned. It returns
+// immediately if the connection is already up.
+func (conn *wsConn) WaitForConnect(ctx context.Context) error {
+	conn.connectedMtx.RLock()
+	connectedCh := conn.connectedCh
+	conn.connectedMtx.RUnlock()
+	select {
+	case <-connectedCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setReconnecting flags that a reconnect is in progress or scheduled because
+// of err, which is recorded as the last error.
+func (conn *wsConn) setReconnecting(err error) {
+	conn.connectedMtx.Lock()
+	conn.reconnecting = true
+	conn.lastErr = err
+	conn.connectedMtx.Unlock()
+}
+
+// setLastError records the error as the last error.
+func (conn *wsConn) setLastError(err error) {
+	conn.connectedMtx.Lock()
+	conn.lastErr = err
+	conn.connectedMtx.Unlock()
+}
+
+// Status returns a snapshot of the connection state.
+func (conn *wsConn) Status() ConnStatus {
+	conn.connectedMtx.RLock()
+	defer conn.connectedMtx.RUnlock()
+	return ConnStatus{
+		Connected:    conn.connected,
+		Reconnecting: conn.reconnecting,
+		LastError:    conn.lastErr,
+	}
+}
+
 // connect attempts to establish a websocket connection.
 func (conn *wsConn) connect(ctx context.Context) error {
+	dialTimeout := conn.cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	cert, tlsCfg := conn.certConfig()
+	// The HandshakeTimeout bounds the context passed to NetDialContext as well
+	// as the TLS and websocket handshakes.
 	dialer := &websocket.Dialer{
-		Proxy:            http.ProxyFromEnvironment,
-		HandshakeTimeout: 10 * time.Second,
-		TLSClientConfig:  conn.tlsCfg,
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  dialTimeout,
+		TLSClientConfig:   tlsCfg,
+		EnableCompression: conn.cfg.Compress,
+	}
+	if conn.cfg.ProxyAddr != "" {
+		proxy := &socks.Proxy{
+			Addr:     conn.cfg.ProxyAddr,
+			Username: conn.cfg.ProxyUser,
+			Password: conn.cfg.ProxyPass,
+		}
+		dialer.Proxy = nil
+		dialer.NetDialContext = proxy.DialContext
+	}
+	// Count the bytes on every connection for Stats.
+	netDial := dialer.NetDialContext
+	if netDial == nil {
+		netDial = (&net.Dialer{}).DialContext
+	}
+	dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := netDial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: c, stats: conn}, nil
 	}
 
-	ws, _, err := dialer.Dial(conn.cfg.URL, nil)
+	ws, resp, err := dialer.DialContext(ctx, conn.cfg.URL, conn.cfg.Headers)
 	if err != nil {
-		if _, isUnknownAuthError := err.(x509.UnknownAuthorityError); isUnknownAuthError {
-			if conn.tlsCfg == nil {
+		var authErr x509.UnknownAuthorityError
+		if errors.As(err, &authErr) {
+			if len(cert) == 0 {
 				return ErrCertRequired
 			}
 			return ErrInvalidCert
 		}
+		if ctx.Err() == nil && isTimeout(err) {
+			return fmt.Errorf("%w: no connection to %s after %v: %v",
+				ErrDialTimeout, conn.cfg.URL, dialTimeout, err)
+		}
 		return err
 	}
+	compressed := conn.cfg.Compress && strings.Contains(resp.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
+	if conn.cfg.Compress && !compressed {
+		conn.log.Infof("Server at %s does not support compression. Continuing without it.", conn.cfg.URL)
+	}
+
+	ws.SetReadLimit(conn.maxMessageSize())
 
 	// Set the initial read deadline for the first ping. Subsequent read
 	// deadlines are set in the ping handler.
@@ -208,7 +692,7 @@ func (conn *wsConn) connect(ctx context.Context) error {
 		}
 
 		// Respond with a pong.
-		err = ws.WriteControl(websocket.PongMessage, []byte{}, now.Add(writeWait))
+		err = conn.queueWrite(ws, websocket.PongMessage, []byte{})
 		if err != nil {
 			// read loop handles reconnect
 			conn.log.Errorf("pong write error: %v", err)
@@ -218,6 +702,22 @@ func (conn *wsConn) connect(ctx context.Context) error {
 		return nil
 	})
 
+	// pongCh signals the ping loop that a pong was received.
+	pongCh := make(chan struct{}, 1)
+	if conn.cfg.PingInterval > 0 {
+		ws.SetPongHandler(func(string) error {
+			select {
+			case pongCh <- struct{}{}:
+			default:
+			}
+			err := ws.SetReadDeadline(time.Now().Add(conn.cfg.PingWait))
+			if err != nil {
+				conn.log.Errorf("set read deadline failed: %v", err)
+			}
+			return err
+		})
+	}
+
 	conn.wsMtx.Lock()
 	// If keepAlive called connect, the wsConn's current websocket.Conn may need
 	// to be closed depending on the error that triggered the reconnect.
@@ -227,21 +727,152 @@ func (conn *wsConn) connect(ctx context.Context) error {
 	conn.ws = ws
 	conn.wsMtx.Unlock()
 
+	atomic.StoreInt64(&conn.lastConnect, time.Now().UnixNano())
+	var negotiated uint32
+	if compressed {
+		negotiated = 1
+	}
+	atomic.StoreUint32(&conn.compressed, negotiated)
 	conn.setConnected(true)
 	conn.wg.Add(1)
+	conn.readWg.Add(1)
 	go func() {
 		defer conn.wg.Done()
+		defer conn.readWg.Done()
 		conn.read(ctx)
 	}()
 
+	if conn.cfg.PingInterval > 0 {
+		conn.wg.Add(1)
+		go func() {
+			defer conn.wg.Done()
+			conn.ping(ctx, ws, pongCh)
+		}()
+	}
+
 	return nil
 }
 
+// ping sends a ping on the websocket.Conn every PingInterval, less a random
+// jitter, until the context is canceled, the connection is replaced by a
+// reconnect, or a write fails. If no pong is signaled on pongCh within
+// PingWait of a ping, the connection is half-open, and the websocket.Conn is
+// closed so that the read loop reconnects. This should be run as a goroutine.
+// Increment the wg before calling ping.
+func (conn *wsConn) ping(ctx context.Context, ws *websocket.Conn, pongCh <-chan struct{}) {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	nextPing := func() time.Duration {
+		jitter := time.Duration(rnd.Float64() * pingJitter * float64(conn.cfg.PingInterval))
+		return conn.cfg.PingInterval - jitter
+	}
+	pingTimer := time.NewTimer(nextPing())
+	defer pingTimer.Stop()
+	// pongTimer runs while a ping is awaiting a pong.
+	pongTimer := time.NewTimer(conn.cfg.PingWait)
+	pongTimer.Stop()
+	defer pongTimer.Stop()
+	var awaitingPong bool
+
+	for {
+		select {
+		case <-pingTimer.C:
+			conn.wsMtx.Lock()
+			current := conn.ws == ws
+			conn.wsMtx.Unlock()
+			if !current {
+				return
+			}
+			err := conn.queueWrite(ws, websocket.PingMessage, []byte{})
+			if err != nil {
+				// read loop handles reconnect
+				conn.log.Errorf("ping write error: %v", err)
+				return
+			}
+			// The pong deadline is for the oldest unanswered ping.
+			if !awaitingPong {
+				awaitingPong = true
+				pongTimer.Reset(conn.cfg.PingWait)
+			}
+			pingTimer.Reset(nextPing())
+		case <-pongCh:
+			if awaitingPong && !pongTimer.Stop() {
+				<-pongTimer.C
+			}
+			awaitingPong = false
+		case <-pongTimer.C:
+			conn.wsMtx.Lock()
+			current := conn.ws == ws
+			conn.wsMtx.Unlock()
+			if !current {
+				return
+			}
+			conn.log.Warnf("No pong from %s within %v of a ping. Closing the half-open connection.",
+				conn.cfg.URL, conn.cfg.PingWait)
+			// Closing the websocket.Conn fails the read, which reconnects.
+			ws.Close()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writePump writes the queued messages and control frames until the context is
+// canceled. This should be run as a goroutine. Increment the wg before calling
+// writePump.
+func (conn *wsConn) writePump(ctx context.Context) {
+	for {
+		select {
+		case w := <-conn.writeCh:
+			w.errCh <- conn.write(w)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// write writes a message or control frame. Only writePump should call write.
+func (conn *wsConn) write(w *wsWrite) error {
+	deadline := time.Now().Add(conn.writeWait())
+	if w.msgType == websocket.PingMessage || w.msgType == websocket.PongMessage {
+		return w.ws.WriteControl(w.msgType, w.data, deadline)
+	}
+	if err := w.ws.SetWriteDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	return w.ws.WriteMessage(w.msgType, w.data)
+}
+
+// queueWrite queues a message or control frame for writing to the
+// websocket.Conn and waits for the result. ErrWriteQueueFull is returned
+// without waiting if the queue is full.
+func (conn *wsConn) queueWrite(ws *websocket.Conn, msgType int, data []byte) error {
+	w := &wsWrite{
+		ws:      ws,
+		msgType: msgType,
+		data:    data,
+		errCh:   make(chan error, 1),
+	}
+	select {
+	case conn.writeCh <- w:
+	default:
+		return ErrWriteQueueFull
+	}
+	select {
+	case err := <-w.errCh:
+		return err
+	case <-conn.done:
+		return fmt.Errorf("connection stopped")
+	}
+}
+
+// close sends a close message and closes the websocket.Conn. WriteControl and
+// Close may be used concurrently with writePump.
 func (conn *wsConn) close() {
 	// Attempt to send a close message in case the connection is still live.
 	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")
 	_ = conn.ws.WriteControl(websocket.CloseMessage, msg,
-		time.Now().Add(50*time.Millisecond)) // ignore any error
+		time.Now().Add(conn.writeWait())) // ignore any error
 	// Forcibly close the underlying connection.
 	conn.ws.Close()
 }
@@ -249,9 +880,13 @@ func (conn *wsConn) close() {
 // read fetches and parses incoming messages for processing. This should be
 // run as a goroutine. Increment the wg before calling read.
 func (conn *wsConn) read(ctx context.Context) {
-	reconnect := func() {
+	reconnect := func(err error) {
 		conn.setConnected(false)
-		conn.reconnectCh <- struct{}{}
+		conn.setReconnecting(err)
+		select {
+		case conn.reconnectCh <- struct{}{}:
+		case <-ctx.Done():
+		}
 	}
 
 	for {
@@ -269,12 +904,31 @@ func (conn *wsConn) read(ctx context.Context) {
 		if ctx.Err() != nil {
 			return
 		}
+		// Don't reconnect if the connection was closed intentionally.
+		if err != nil && atomic.LoadUint32(&conn.closing) == 1 {
+			conn.log.Debugf("Connection to %s closed: %v", conn.cfg.URL, err)
+			conn.setConnected(false)
+			select {
+			case conn.peerClosed <- struct{}{}:
+			default:
+			}
+			return
+		}
 		if err != nil {
 			// Read timeout should flag the connection as down asap.
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
 				conn.log.Errorf("Read timeout on connection to %s.", conn.cfg.URL)
-				reconnect()
+				reconnect(err)
+				return
+			}
+
+			// An oversized message closes the connection. The server may be
+			// malicious or buggy, but reconnect anyway.
+			if errors.Is(err, websocket.ErrReadLimit) {
+				conn.log.Errorf("Message from %s exceeded the %d byte limit. Attempting reconnection.",
+					conn.cfg.URL, conn.maxMessageSize())
+				reconnect(err)
 				return
 			}
 
@@ -295,7 +949,7 @@ func (conn *wsConn) read(ctx context.Context) {
 			if websocket.IsCloseError(err, websocket.CloseGoingAway,
 				websocket.CloseNormalClosure) ||
 				strings.Contains(err.Error(), "websocket: close sent") {
-				reconnect()
+				reconnect(err)
 				return
 			}
 
@@ -304,14 +958,14 @@ func (conn *wsConn) read(ctx context.Context) {
 				if strings.Contains(opErr.Err.Error(),
 					"use of closed network connection") {
 					conn.log.Errorf("read quitting: %v", err)
-					reconnect()
+					reconnect(err)
 					return
 				}
 			}
 
 			// Log all other errors and trigger a reconnection.
 			conn.log.Errorf("read error (%v), attempting reconnection", err)
-			reconnect()
+			reconnect(err)
 			// Successful reconnect via connect() will start read() again.
 			return
 		}
@@ -334,14 +988,70 @@ func (conn *wsConn) read(ctx context.Context) {
 			}()
 			continue
 		}
-		conn.readCh <- msg
+		if conn.hold(msg) {
+			continue
+		}
+		conn.deliver(ctx, msg)
 	}
 }
 
+// deliver sends the message on readCh. If the read queue is full, deliver
+// blocks, or drops the message if WsCfg.ReadQueueDrop is set.
+func (conn *wsConn) deliver(ctx context.Context, msg *msgjson.Message) {
+	if conn.cfg.ReadQueueDrop {
+		select {
+		case conn.readCh <- msg:
+		default:
+			n := atomic.AddUint64(&conn.dropped, 1)
+			conn.log.Warnf("Read queue full. Dropped %s message for route %q from %s (%d dropped).",
+				msg.Type, msg.Route, conn.cfg.URL, n)
+		}
+		return
+	}
+	select {
+	case conn.readCh <- msg:
+	case <-ctx.Done():
+	}
+}
+
+// hold queues the message if it is a notification and ReconnectSync is
+// running, returning true if the message was queued. Requests are never held.
+func (conn *wsConn) hold(msg *msgjson.Message) bool {
+	if msg.Type != msgjson.Notification {
+		return false
+	}
+	conn.syncMtx.Lock()
+	defer conn.syncMtx.Unlock()
+	if !conn.syncing {
+		return false
+	}
+	conn.held = append(conn.held, msg)
+	return true
+}
+
+// setSyncing sets the syncing flag. When syncing ends, any held messages are
+// delivered to readCh in the order they were received.
+func (conn *wsConn) setSyncing(ctx context.Context, syncing bool) {
+	conn.syncMtx.Lock()
+	defer conn.syncMtx.Unlock()
+	conn.syncing = syncing
+	if syncing {
+		return
+	}
+	// Deliver while locked so read cannot send a newer message first.
+	for _, msg := range conn.held {
+		conn.deliver(ctx, msg)
+	}
+	conn.held = nil
+}
+
 // keepAlive maintains an active websocket connection by reconnecting when
 // the established connection is broken. This should be run as a goroutine.
 func (conn *wsConn) keepAlive(ctx context.Context) {
 	rcInt := reconnectInterval
+	// certUpdated is set when the last attempt used a certificate from
+	// CertUpdate, so that a rejected update is not retried right away.
+	var certUpdated bool
 	for {
 		select {
 		case <-conn.reconnectCh:
@@ -350,12 +1060,39 @@ func (conn *wsConn) keepAlive(ctx context.Context) {
 			if ctx.Err() != nil {
 				return
 			}
+			// Close has been called, so the context will be canceled soon.
+			if atomic.LoadUint32(&conn.closing) == 1 {
+				continue
+			}
 
 			conn.log.Infof("Attempting to reconnect to %s...", conn.cfg.URL)
+			// Hold incoming messages until ReconnectSync is done. This is set
+			// before connect starts the new read loop.
+			if conn.cfg.ReconnectSync != nil {
+				conn.setSyncing(ctx, true)
+			}
 			err := conn.connect(ctx)
 			if err != nil {
-				conn.log.Errorf("Reconnect failed. Scheduling reconnect to %s in %.1f seconds.",
-					conn.cfg.URL, rcInt.Seconds())
+				conn.setSyncing(ctx, false)
+				conn.setReconnecting(err)
+				if isCertError(err) {
+					if !certUpdated && conn.updateCert() {
+						certUpdated = true
+						conn.log.Infof("Certificate for %s updated. Retrying now.", conn.cfg.URL)
+						select {
+						case conn.reconnectCh <- struct{}{}:
+						default: // a reconnect is already pending
+						}
+						continue
+					}
+					conn.log.Errorf("Reconnect failed: the TLS certificate presented by %s is not trusted (%v). "+
+						"If the server's certificate has changed, the pinned certificate must be updated. "+
+						"Scheduling reconnect in %.1f seconds.", conn.cfg.URL, err, rcInt.Seconds())
+				} else {
+					conn.log.Errorf("Reconnect failed. Scheduling reconnect to %s in %.1f seconds.",
+						conn.cfg.URL, rcInt.Seconds())
+				}
+				certUpdated = false
 				time.AfterFunc(rcInt, func() {
 					conn.reconnectCh <- struct{}{}
 				})
@@ -367,12 +1104,16 @@ func (conn *wsConn) keepAlive(ctx context.Context) {
 			}
 
 			conn.log.Info("Successfully reconnected.")
+			atomic.AddUint64(&conn.reconnects, 1)
 			rcInt = reconnectInterval
+			certUpdated = false
 
 			// Synchronize after a reconnection.
 			if conn.cfg.ReconnectSync != nil {
 				conn.cfg.ReconnectSync()
+				conn.setSyncing(ctx, false)
 			}
+			conn.resendRequests()
 
 		case <-ctx.Done():
 			return
@@ -386,16 +1127,26 @@ func (conn *wsConn) NextID() uint64 {
 }
 
 // Connect connects the client. Any error encountered during the initial
-// connection will be returned. If the connection is successful, an
-// auto-reconnect goroutine will be started. To shutdown auto-reconnect, use
-// Stop() or cancel the context.
+// connection will be returned. An auto-reconnect goroutine is started
+// regardless. If the initial connection attempt timed out, ErrDialTimeout is
+// returned and a reconnect is scheduled. To shutdown auto-reconnect, use Stop()
+// or cancel the context.
 func (conn *wsConn) Connect(ctx context.Context) (*sync.WaitGroup, error) {
 	var ctxInternal context.Context
 	ctxInternal, conn.cancel = context.WithCancel(ctx)
+	conn.done = ctxInternal.Done()
+
+	conn.wg.Add(1)
+	go func() {
+		defer conn.wg.Done()
+		conn.writePump(ctxInternal)
+	}()
 
 	conn.wg.Add(1)
+	conn.readWg.Add(1)
 	go func() {
 		defer conn.wg.Done()
+		defer conn.readWg.Done()
 		conn.keepAlive(ctxInternal)
 	}()
 
@@ -410,10 +1161,27 @@ func (conn *wsConn) Connect(ctx context.Context) (*sync.WaitGroup, error) {
 			conn.close()
 		}
 		conn.wsMtx.Unlock()
+		// Wait for read and keepAlive, which deliver messages, so that
+		// nothing is sent on readCh after it is closed.
+		conn.readWg.Wait()
 		close(conn.readCh) // signal to receivers that the wsConn is dead
 	}()
 
-	return &conn.wg, conn.connect(ctxInternal)
+	err := conn.connect(ctxInternal)
+	if errors.Is(err, ErrDialTimeout) {
+		conn.setReconnecting(err)
+		conn.log.Errorf("Initial connection timed out. Scheduling reconnect to %s in %.1f seconds.",
+			conn.cfg.URL, reconnectInterval.Seconds())
+		time.AfterFunc(reconnectInterval, func() {
+			select {
+			case conn.reconnectCh <- struct{}{}:
+			default: // a reconnect is already pending
+			}
+		})
+	} else if err != nil {
+		conn.setLastError(err)
+	}
+	return &conn.wg, err
 }
 
 // Stop can be used to close the connection and all of the goroutines started by
@@ -422,11 +1190,52 @@ func (conn *wsConn) Stop() {
 	conn.cancel()
 }
 
+// Close sends a close message with the normal closure code and the reason, waits
+// briefly for the server to acknowledge it, and then stops the connection and
+// all of the goroutines started by Connect, like Stop. No reconnect is
+// attempted after Close is called. Reasons longer than 123 bytes are
+// truncated. The connection is stopped even if the close message cannot be
+// written, in which case the write error is returned.
+func (conn *wsConn) Close(reason string) error {
+	if !atomic.CompareAndSwapUint32(&conn.closing, 0, 1) {
+		return nil // already closing
+	}
+	if conn.cancel == nil {
+		return nil // never connected
+	}
+	defer conn.cancel()
+
+	if len(reason) > maxCloseReasonLen {
+		reason = reason[:maxCloseReasonLen]
+	}
+
+	conn.wsMtx.Lock()
+	ws := conn.ws
+	conn.wsMtx.Unlock()
+	if ws == nil || conn.IsDown() {
+		return nil
+	}
+
+	conn.log.Debugf("Closing connection to %s: %s", conn.cfg.URL, reason)
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason)
+	err := ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(conn.writeWait()))
+	if err != nil {
+		return fmt.Errorf("failed to write close message: %w", err)
+	}
+
+	select {
+	case <-conn.peerClosed:
+	case <-time.After(closeWait):
+		conn.log.Debugf("No close acknowledgement from %s after %v.", conn.cfg.URL, closeWait)
+	}
+	return nil
+}
+
 // Send pushes outgoing messages over the websocket connection. Sending of the
 // message is synchronous, so a nil error guarantees that the message was
 // successfully sent. A non-nil error may indicate that the connection is known
-// to be down, the message failed to marshall to JSON, or writing to the
-// websocket link failed.
+// to be down, the message failed to marshall to JSON, the write queue is full,
+// or writing to the websocket link failed.
 func (conn *wsConn) Send(msg *msgjson.Message) error {
 	if conn.IsDown() {
 		return fmt.Errorf("cannot send on a broken connection")
@@ -441,16 +1250,12 @@ func (conn *wsConn) Send(msg *msgjson.Message) error {
 	}
 
 	conn.wsMtx.Lock()
-	defer conn.wsMtx.Unlock()
-	err = conn.ws.SetWriteDeadline(time.Now().Add(writeWait))
-	if err != nil {
-		conn.log.Errorf("Send: failed to set write deadline: %v", err)
-		return err
-	}
+	ws := conn.ws
+	conn.wsMtx.Unlock()
 
-	err = conn.ws.WriteMessage(websocket.TextMessage, b)
+	err = conn.queueWrite(ws, websocket.TextMessage, b)
 	if err != nil {
-		conn.log.Errorf("Send: WriteMessage error: %v", err)
+		conn.log.Errorf("Send: write error: %v", err)
 		return err
 	}
 	return nil
@@ -490,11 +1295,25 @@ func (conn *wsConn) Request(msg *msgjson.Message, f func(*msgjson.Message)) erro
 // }
 // return <-errChan // timeout or response error
 func (conn *wsConn) RequestWithTimeout(msg *msgjson.Message, f func(*msgjson.Message), expireTime time.Duration, expire func()) error {
+	return conn.RequestWithRetry(msg, f, expireTime, expire, false)
+}
+
+// RequestWithRetry is like RequestWithTimeout, but if idempotent is true and
+// the connection is lost before the response is received, the request is
+// re-sent after reconnecting, up to WsCfg.RequestRetries times. Only requests
+// that are safe to process more than once, such as lookups, should be marked
+// idempotent. Requests that change state on the server, such as orders, must
+// not be. The expiration is not extended by a retry.
+func (conn *wsConn) RequestWithRetry(msg *msgjson.Message, f func(*msgjson.Message), expireTime time.Duration, expire func(), idempotent bool) error {
 	if msg.Type != msgjson.Request {
 		return fmt.Errorf("Message is not a request: %v", msg.Type)
 	}
 	// Register the response and expire handlers for this request.
-	conn.logReq(msg.ID, f, expireTime, expire)
+	var retry *msgjson.Message
+	if idempotent && conn.cfg.RequestRetries > 0 {
+		retry = msg
+	}
+	conn.logReq(msg.ID, f, expireTime, expire, retry)
 	err := conn.Send(msg)
 	if err != nil {
 		// Neither expire nor the handler should run. Stop the expire timer
@@ -516,8 +1335,9 @@ func (conn *wsConn) expire(id uint64) bool {
 }
 
 // logReq stores the response handler in the respHandlers map. Requests to the
-// client are associated with a response handler.
-func (conn *wsConn) logReq(id uint64, respHandler func(*msgjson.Message), expireTime time.Duration, expire func()) {
+// client are associated with a response handler. retry is the request to
+// re-send after a reconnect, or nil if it should not be re-sent.
+func (conn *wsConn) logReq(id uint64, respHandler func(*msgjson.Message), expireTime time.Duration, expire func(), retry *msgjson.Message) {
 	conn.reqMtx.Lock()
 	defer conn.reqMtx.Unlock()
 	doExpire := func() {
@@ -531,6 +1351,35 @@ func (conn *wsConn) logReq(id uint64, respHandler func(*msgjson.Message), expire
 	conn.respHandlers[id] = &responseHandler{
 		expiration: time.AfterFunc(expireTime, doExpire),
 		f:          respHandler,
+		retry:      retry,
+		retries:    conn.cfg.RequestRetries,
+		sentOn:     atomic.LoadUint64(&conn.reconnects),
+	}
+}
+
+// resendRequests re-sends the idempotent requests that were sent before the
+// last reconnect and have not been answered or expired, if they have retries
+// left.
+func (conn *wsConn) resendRequests() {
+	reconnects := atomic.LoadUint64(&conn.reconnects)
+	var msgs []*msgjson.Message
+	conn.reqMtx.Lock()
+	for _, h := range conn.respHandlers {
+		if h.retry == nil || h.retries == 0 || h.sentOn >= reconnects {
+			continue
+		}
+		h.retries--
+		h.sentOn = reconnects
+		msgs = append(msgs, h.retry)
+	}
+	conn.reqMtx.Unlock()
+
+	for _, msg := range msgs {
+		conn.log.Debugf("Re-sending %s request %d after reconnect", msg.Route, msg.ID)
+		if err := conn.Send(msg); err != nil {
+			// The request expires as usual if the response never comes.
+			conn.log.Errorf("Error re-sending %s request %d: %v", msg.Route, msg.ID, err)
+		}
 	}
 }
 
@@ -555,3 +1404,41 @@ func (conn *wsConn) respHandler(id uint64) *responseHandler {
 func (conn *wsConn) MessageSource() <-chan *msgjson.Message {
 	return conn.readCh
 }
+
+// Stats returns a snapshot of the connection statistics. Stats does not block
+// the read or write of messages.
+func (conn *wsConn) Stats() ConnStats {
+	stats := ConnStats{
+		Reconnects:      atomic.LoadUint64(&conn.reconnects),
+		BytesRead:       atomic.LoadUint64(&conn.bytesRead),
+		BytesWritten:    atomic.LoadUint64(&conn.bytesWritten),
+		QueuedMessages:  len(conn.readCh),
+		DroppedMessages: atomic.LoadUint64(&conn.dropped),
+		Compressed:      atomic.LoadUint32(&conn.compressed) == 1,
+	}
+	if t := atomic.LoadInt64(&conn.lastConnect); t != 0 {
+		stats.LastConnect = time.Unix(0, t)
+	}
+	return stats
+}
+
+// countingConn is a net.Conn that adds the bytes read and written to a
+// wsConn's counters.
+type countingConn struct {
+	net.Conn
+	stats *wsConn
+}
+
+// Read reads from the net.Conn, counting the bytes read.
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.stats.bytesRead, uint64(n))
+	return n, err
+}
+
+// Write writes to the net.Conn, counting the bytes written.
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.stats.bytesWritten, uint64(n))
+	return n, err
+}
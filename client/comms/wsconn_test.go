@@ -4,20 +4,30 @@ import (
 	"bytes"
 	"context"
 	"crypto/elliptic"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
 	"github.com/decred/dcrd/certgen"
 	"github.com/gorilla/websocket"
@@ -116,7 +126,7 @@ func TestWsConn(t *testing.T) {
 				select {
 				case <-pingCh:
 					err := c.WriteControl(websocket.PingMessage, []byte{},
-						time.Now().Add(writeWait))
+						time.Now().Add(DefaultWriteWait))
 					if err != nil {
 						if hCtx.Err() == nil {
 							// Only a failure if the server isn't shutting down.
@@ -292,9 +302,10 @@ func TestWsConn(t *testing.T) {
 		runtime.Gosched()
 
 		// Wait for a reconnection.
-		for wsc.IsDown() {
-			time.Sleep(time.Millisecond * 10)
-			continue
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		if err := wsc.WaitForConnect(ctx); err != nil {
+			t.Fatalf("reconnect not completed: %v", err)
 		}
 
 		// Send a ping.
@@ -466,3 +477,1737 @@ func TestWsConn(t *testing.T) {
 		t.Error("read source should have been closed")
 	}
 }
+
+// tSOCKS5Server is a minimal SOCKS5 proxy supporting the CONNECT command with
+// either no authentication or username/password authentication.
+type tSOCKS5Server struct {
+	ln         net.Listener
+	user, pass string // required credentials, if set
+	wg         sync.WaitGroup
+
+	mtx     sync.Mutex
+	targets []string
+}
+
+func newTSOCKS5Server(t *testing.T, user, pass string) *tSOCKS5Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("proxy listen error: %v", err)
+	}
+	s := &tSOCKS5Server{ln: ln, user: user, pass: pass}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(c)
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *tSOCKS5Server) serve(c net.Conn) {
+	defer c.Close()
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(c, b); err != nil {
+			return nil
+		}
+		return b
+	}
+
+	// Greeting: version, number of methods, methods.
+	b := read(2)
+	if b == nil || b[0] != 5 {
+		return
+	}
+	methods := read(int(b[1]))
+	method := byte(0) // no authentication
+	if s.user != "" {
+		method = 2 // username/password
+	}
+	if !bytes.Contains(methods, []byte{method}) {
+		c.Write([]byte{5, 0xff})
+		return
+	}
+	c.Write([]byte{5, method})
+	if method == 2 {
+		if b = read(2); b == nil {
+			return
+		}
+		user := read(int(b[1]))
+		if b = read(1); b == nil {
+			return
+		}
+		pass := read(int(b[0]))
+		if string(user) != s.user || string(pass) != s.pass {
+			c.Write([]byte{1, 1})
+			return
+		}
+		c.Write([]byte{1, 0})
+	}
+
+	// Request: version, CONNECT, reserved, domain address type, length.
+	b = read(5)
+	if b == nil || b[1] != 1 || b[3] != 3 {
+		return
+	}
+	host := read(int(b[4]))
+	port := read(2)
+	if port == nil {
+		return
+	}
+	target := net.JoinHostPort(string(host), strconv.Itoa(int(port[0])<<8|int(port[1])))
+	s.mtx.Lock()
+	s.targets = append(s.targets, target)
+	s.mtx.Unlock()
+
+	tc, err := net.Dial("tcp", target)
+	if err != nil {
+		c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // connection refused
+		return
+	}
+	defer tc.Close()
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(tc, c)
+		tc.Close()
+		close(done)
+	}()
+	io.Copy(c, tc)
+	c.Close()
+	<-done
+}
+
+func (s *tSOCKS5Server) close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func TestWsConnProxy(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		ntfn, _ := msgjson.NewNotification(msgjson.MatchRoute, "proxied")
+		if err := c.WriteJSON(ntfn); err != nil {
+			t.Errorf("write error: %v", err)
+			return
+		}
+		// Hold the connection until the client hangs up.
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	defer hWG.Wait()
+
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	srvAddr := strings.TrimPrefix(srv.URL, "https://")
+
+	const user, pass = "user", "pass"
+	proxy := newTSOCKS5Server(t, user, pass)
+	defer proxy.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connect := func(cert []byte, proxyPass string) (*wsConn, *dex.ConnectionMaster, error) {
+		wsc, err := NewWsConn(&WsCfg{
+			URL:       "wss://" + srvAddr + "/ws",
+			PingWait:  5 * time.Second,
+			Cert:      cert,
+			ProxyAddr: proxy.ln.Addr().String(),
+			ProxyUser: user,
+			ProxyPass: proxyPass,
+			Logger:    tLogger,
+		})
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		cm := dex.NewConnectionMaster(wsc)
+		return wsc.(*wsConn), cm, cm.Connect(ctx)
+	}
+
+	// Successful connection through the proxy.
+	wsc, cm, err := connect(certB, pass)
+	if err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	select {
+	case msg := <-wsc.MessageSource():
+		var s string
+		if err := msg.Unmarshal(&s); err != nil || s != "proxied" {
+			t.Fatalf("wrong message received: %v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message received through the proxy")
+	}
+	cm.Disconnect()
+
+	proxy.mtx.Lock()
+	targets := proxy.targets
+	proxy.mtx.Unlock()
+	if len(targets) != 1 || targets[0] != srvAddr {
+		t.Fatalf("wrong proxy targets. wanted [%s], got %v", srvAddr, targets)
+	}
+
+	// Wrong proxy credentials.
+	_, cm, err = connect(certB, "wrong")
+	cm.Disconnect()
+	if err == nil {
+		t.Fatalf("no error for wrong proxy password")
+	}
+
+	// The server's certificate is still verified over the proxy.
+	otherCert, _, err := certgen.NewTLSCertPair(elliptic.P256(), "other", time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("error generating cert: %v", err)
+	}
+	_, cm, err = connect(otherCert, pass)
+	cm.Disconnect()
+	if err == nil {
+		t.Fatalf("no error for unknown server certificate over proxy")
+	}
+}
+
+func TestWsConnHeaders(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	headers := make(chan http.Header, 2)
+	var hWG sync.WaitGroup
+	var connects uint32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		headers <- r.Header.Clone()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		// Hang up on the first connection to force a reconnect.
+		if atomic.AddUint32(&connects, 1) == 1 {
+			c.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"),
+				time.Now().Add(time.Second))
+			return
+		}
+		// Hold the connection until the client hangs up.
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	defer hWG.Wait()
+
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+
+	cfgHeaders := http.Header{}
+	cfgHeaders.Set("Authorization", "Bearer abc")
+	cfgHeaders.Set("X-Dex-Route", "dex1")
+	connected := make(chan bool, 3)
+	wsc, err := NewWsConn(&WsCfg{
+		URL:              "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait:         5 * time.Second,
+		Cert:             certB,
+		Headers:          cfgHeaders,
+		ConnectEventFunc: func(up bool) { connected <- up },
+		Logger:           tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer cm.Disconnect()
+
+	// The headers must be sent on the initial connection and the reconnect.
+	for i, desc := range []string{"connect", "reconnect"} {
+		select {
+		case h := <-headers:
+			for k := range cfgHeaders {
+				if h.Get(k) != cfgHeaders.Get(k) {
+					t.Fatalf("%s: wrong %s header. wanted %q, got %q", desc, k, cfgHeaders.Get(k), h.Get(k))
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no upgrade request #%d (%s)", i+1, desc)
+		}
+	}
+
+	// Wait for the reconnect to complete before shutting down.
+	for _, want := range []bool{true, false, true} {
+		select {
+		case up := <-connected:
+			if up != want {
+				t.Fatalf("wrong connection status. wanted %v, got %v", want, up)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for connection status %v", want)
+		}
+	}
+}
+
+func TestNewWsConnTLSConfig(t *testing.T) {
+	// Without a cert, the system root pool is used.
+	wsc, err := NewWsConn(&WsCfg{URL: "wss://dex.example.com:7232/ws", Logger: tLogger})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	tlsCfg := wsc.(*wsConn).tlsCfg
+	if tlsCfg == nil {
+		t.Fatalf("no TLS config without a cert")
+	}
+	if tlsCfg.RootCAs != nil {
+		t.Fatalf("expected nil RootCAs (system pool) without a cert")
+	}
+	if tlsCfg.ServerName != "dex.example.com" {
+		t.Fatalf("wrong server name %q", tlsCfg.ServerName)
+	}
+	if tlsCfg.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("wrong min TLS version %x", tlsCfg.MinVersion)
+	}
+
+	// With a cert, it must be in the root pool.
+	certB, _, err := certgen.NewTLSCertPair(elliptic.P256(), "test", time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("error generating cert: %v", err)
+	}
+	wsc, err = NewWsConn(&WsCfg{URL: "wss://dex.example.com:7232/ws", Cert: certB, Logger: tLogger})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	block, _ := pem.Decode(certB)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing cert: %v", err)
+	}
+	pool := wsc.(*wsConn).tlsCfg.RootCAs
+	if pool == nil {
+		t.Fatalf("nil RootCAs with a cert")
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+		t.Fatalf("configured cert not trusted: %v", err)
+	}
+
+	// The minimum TLS version may be raised to 1.3.
+	wsc, err = NewWsConn(&WsCfg{URL: "wss://dex.example.com:7232/ws", MinTLSVersion: "1.3", Logger: tLogger})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	if v := wsc.(*wsConn).tlsCfg.MinVersion; v != tls.VersionTLS13 {
+		t.Fatalf("wrong min TLS version %x", v)
+	}
+
+	// Unknown versions are rejected.
+	_, err = NewWsConn(&WsCfg{URL: "wss://dex.example.com:7232/ws", MinTLSVersion: "1.1", Logger: tLogger})
+	if err == nil {
+		t.Fatalf("no error for an unsupported minimum TLS version")
+	}
+}
+
+// tCountingListener counts the bytes written to its connections.
+type tCountingListener struct {
+	net.Listener
+	written *int64
+}
+
+func (l *tCountingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &tCountingConn{Conn: c, written: l.written}, nil
+}
+
+type tCountingConn struct {
+	net.Conn
+	written *int64
+}
+
+func (c *tCountingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+func TestWsConnCompression(t *testing.T) {
+	// A representative order book snapshot.
+	book := &msgjson.OrderBook{
+		MarketID: "dcr_btc",
+		Seq:      1234,
+		Epoch:    16000000,
+	}
+	for i := 0; i < 500; i++ {
+		book.Orders = append(book.Orders, &msgjson.BookOrderNote{
+			OrderNote: msgjson.OrderNote{
+				Seq:      uint64(i),
+				MarketID: "dcr_btc",
+				OrderID:  encode.RandomBytes(32),
+			},
+			TradeNote: msgjson.TradeNote{
+				Side:     uint8(i%2 + 1),
+				Quantity: uint64(i+1) * 1e8,
+				Rate:     1e6 + uint64(i)*1e3,
+				TiF:      1,
+				Time:     1600000000000 + uint64(i),
+			},
+		})
+	}
+	note, _ := msgjson.NewNotification(msgjson.BookOrderRoute, book)
+
+	// sendBook serves the book to one client, and returns the number of bytes
+	// written by the server and whether the client reports compression.
+	sendBook := func(serverCompress, clientCompress bool) (int64, bool) {
+		t.Helper()
+		var written int64
+		upgrader := websocket.Upgrader{EnableCompression: serverCompress}
+		var hWG sync.WaitGroup
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hWG.Add(1)
+			defer hWG.Done()
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("unable to upgrade http connection: %s", err)
+				return
+			}
+			defer c.Close()
+			if err := c.WriteJSON(note); err != nil {
+				t.Errorf("write error: %v", err)
+				return
+			}
+			// Hold the connection until the client hangs up.
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+		srv.Listener = &tCountingListener{Listener: srv.Listener, written: &written}
+		srv.StartTLS()
+		defer srv.Close()
+		defer hWG.Wait()
+
+		certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+		wsc, err := NewWsConn(&WsCfg{
+			URL:      "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+			PingWait: 5 * time.Second,
+			Cert:     certB,
+			Compress: clientCompress,
+			Logger:   tLogger,
+		})
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cm := dex.NewConnectionMaster(wsc)
+		if err := cm.Connect(ctx); err != nil {
+			t.Fatalf("Connect error: %v", err)
+		}
+		defer cm.Disconnect()
+
+		select {
+		case msg := <-wsc.MessageSource():
+			var gotBook msgjson.OrderBook
+			if err := msg.Unmarshal(&gotBook); err != nil {
+				t.Fatalf("error decoding book: %v", err)
+			}
+			if len(gotBook.Orders) != len(book.Orders) {
+				t.Fatalf("wrong number of orders. wanted %d, got %d", len(book.Orders), len(gotBook.Orders))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("book not received")
+		}
+		return atomic.LoadInt64(&written), wsc.Stats().Compressed
+	}
+
+	plain, negotiated := sendBook(false, false)
+	if negotiated {
+		t.Fatalf("compression reported without Compress")
+	}
+	compressed, negotiated := sendBook(true, true)
+	if !negotiated {
+		t.Fatalf("negotiated compression not reported")
+	}
+	// Measured at about 70% smaller, most of the remainder being the random
+	// order IDs.
+	t.Logf("book message bytes written: %d uncompressed, %d compressed (%.0f%% smaller)",
+		plain, compressed, 100*(1-float64(compressed)/float64(plain)))
+	if compressed >= plain/2 {
+		t.Fatalf("compression did not reduce the book size enough: %d >= %d / 2", compressed, plain)
+	}
+
+	// If the server does not support compression, the client falls back to no
+	// compression.
+	fallback, negotiated := sendBook(false, true)
+	if fallback < plain*9/10 {
+		t.Fatalf("fallback was compressed: %d bytes vs %d uncompressed", fallback, plain)
+	}
+	if negotiated {
+		t.Fatalf("compression reported when the server declined it")
+	}
+	// Compression is opt-in.
+	optOut, negotiated := sendBook(true, false)
+	if optOut < plain*9/10 {
+		t.Fatalf("compressed without Compress: %d bytes vs %d uncompressed", optOut, plain)
+	}
+	if negotiated {
+		t.Fatalf("compression reported without Compress")
+	}
+}
+
+func TestWsConnReconnectSync(t *testing.T) {
+	note, _ := msgjson.NewNotification(msgjson.BookOrderRoute, &msgjson.BookOrderNote{
+		OrderNote: msgjson.OrderNote{Seq: 2, MarketID: "dcr_btc"},
+	})
+
+	var connects uint32
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		if atomic.AddUint32(&connects, 1) == 1 {
+			// Drop the first connection to force a reconnect.
+			return
+		}
+		// Send notifications before the client's resubscribe request is
+		// answered.
+		for i := 0; i < 2; i++ {
+			if err := c.WriteJSON(note); err != nil {
+				t.Errorf("write error: %v", err)
+				return
+			}
+		}
+		for {
+			var req msgjson.Message
+			if err := c.ReadJSON(&req); err != nil {
+				return
+			}
+			resp, _ := msgjson.NewResponse(req.ID, true, nil)
+			if err := c.WriteJSON(resp); err != nil {
+				t.Errorf("write error: %v", err)
+				return
+			}
+		}
+	}))
+	srv.StartTLS()
+	defer srv.Close()
+	defer hWG.Wait()
+
+	var synced uint32
+	var wsc WsConn
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	wsc, err := NewWsConn(&WsCfg{
+		URL:      "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait: 5 * time.Second,
+		Cert:     certB,
+		ReconnectSync: func() {
+			// Responses must be processed while syncing.
+			req, _ := msgjson.NewRequest(wsc.NextID(), msgjson.OrderBookRoute, nil)
+			respC := make(chan struct{})
+			err := wsc.RequestWithTimeout(req, func(*msgjson.Message) { close(respC) },
+				5*time.Second, func() {})
+			if err != nil {
+				t.Errorf("request error: %v", err)
+				return
+			}
+			select {
+			case <-respC:
+				atomic.StoreUint32(&synced, 1)
+			case <-time.After(5 * time.Second):
+				t.Errorf("no response received while syncing")
+			}
+		},
+		Logger: tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer cm.Disconnect()
+
+	select {
+	case msg := <-wsc.MessageSource():
+		if msg.Route != msgjson.BookOrderRoute {
+			t.Fatalf("wrong route %q", msg.Route)
+		}
+		if atomic.LoadUint32(&synced) != 1 {
+			t.Fatalf("notification delivered before ReconnectSync completed")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("notification not received")
+	}
+
+	// The counters include both connections. The second notification is queued
+	// with the first, but is not necessarily in the buffer yet.
+	stats := wsc.Stats()
+	for i := 0; i < 100 && stats.QueuedMessages == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		stats = wsc.Stats()
+	}
+	if stats.Reconnects != 1 {
+		t.Fatalf("expected 1 reconnect, got %d", stats.Reconnects)
+	}
+	if stats.QueuedMessages != 1 {
+		t.Fatalf("expected 1 queued message, got %d", stats.QueuedMessages)
+	}
+	if stats.BytesRead == 0 || stats.BytesWritten == 0 {
+		t.Fatalf("bytes not counted. read = %d, written = %d", stats.BytesRead, stats.BytesWritten)
+	}
+	if time.Since(stats.LastConnect) > 10*time.Second {
+		t.Fatalf("wrong last connect time %v", stats.LastConnect)
+	}
+
+	// Receive the queued notification so that nothing is left on the read
+	// queue when the connection is stopped.
+	select {
+	case msg := <-wsc.MessageSource():
+		if msg.Route != msgjson.BookOrderRoute {
+			t.Fatalf("wrong route %q for the queued notification", msg.Route)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("queued notification not received")
+	}
+	if queued := wsc.Stats().QueuedMessages; queued != 0 {
+		t.Fatalf("expected an empty read queue, got %d queued messages", queued)
+	}
+}
+
+func TestWsConnReconnectSyncServerRequest(t *testing.T) {
+	req, _ := msgjson.NewRequest(1, msgjson.PreimageRoute, &msgjson.PreimageRequest{})
+
+	var connects uint32
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		if atomic.AddUint32(&connects, 1) == 1 {
+			// Drop the first connection to force a reconnect.
+			return
+		}
+		if err := c.WriteJSON(req); err != nil {
+			t.Errorf("write error: %v", err)
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	srv.StartTLS()
+	defer srv.Close()
+	defer hWG.Wait()
+
+	// ReconnectSync does not return until the request is received, or times
+	// out if the request is held.
+	reqReceived := make(chan struct{})
+	syncDone := make(chan struct{})
+	var receivedWhileSyncing uint32
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	wsc, err := NewWsConn(&WsCfg{
+		URL:      "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait: 5 * time.Second,
+		Cert:     certB,
+		ReconnectSync: func() {
+			defer close(syncDone)
+			select {
+			case <-reqReceived:
+				atomic.StoreUint32(&receivedWhileSyncing, 1)
+			case <-time.After(5 * time.Second):
+			}
+		},
+		Logger: tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer cm.Disconnect()
+
+	select {
+	case msg := <-wsc.MessageSource():
+		if msg.Type != msgjson.Request || msg.Route != msgjson.PreimageRoute {
+			t.Fatalf("wrong message %s %q", msg.Type, msg.Route)
+		}
+		close(reqReceived)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("request not received")
+	}
+	<-syncDone
+	if atomic.LoadUint32(&receivedWhileSyncing) != 1 {
+		t.Fatalf("server request held until ReconnectSync returned")
+	}
+}
+
+func TestWsConnRequestRetry(t *testing.T) {
+	// run sends a request to a server that drops the connection on the first
+	// request received without answering it, and returns whether the response
+	// was received and the number of times the server received the request.
+	run := func(idempotent bool) (bool, uint32) {
+		t.Helper()
+		var received uint32
+		upgrader := websocket.Upgrader{}
+		var hWG sync.WaitGroup
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hWG.Add(1)
+			defer hWG.Done()
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("unable to upgrade http connection: %s", err)
+				return
+			}
+			defer c.Close()
+			for {
+				var req msgjson.Message
+				if err := c.ReadJSON(&req); err != nil {
+					return
+				}
+				if atomic.AddUint32(&received, 1) == 1 {
+					// Drop the connection with the request unanswered.
+					return
+				}
+				resp, _ := msgjson.NewResponse(req.ID, true, nil)
+				if err := c.WriteJSON(resp); err != nil {
+					t.Errorf("write error: %v", err)
+					return
+				}
+			}
+		}))
+		srv.StartTLS()
+		defer srv.Close()
+		defer hWG.Wait()
+
+		certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+		wsc, err := NewWsConn(&WsCfg{
+			URL:            "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+			PingWait:       5 * time.Second,
+			Cert:           certB,
+			RequestRetries: 2,
+			Logger:         tLogger,
+		})
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cm := dex.NewConnectionMaster(wsc)
+		if err := cm.Connect(ctx); err != nil {
+			t.Fatalf("Connect error: %v", err)
+		}
+		defer cm.Disconnect()
+
+		req, _ := msgjson.NewRequest(wsc.NextID(), msgjson.ConfigRoute, nil)
+		respC := make(chan bool, 1)
+		err = wsc.RequestWithRetry(req, func(*msgjson.Message) { respC <- true },
+			2*time.Second, func() { respC <- false }, idempotent)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		select {
+		case answered := <-respC:
+			return answered, atomic.LoadUint32(&received)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("request neither answered nor expired")
+		}
+		return false, 0
+	}
+
+	// An idempotent request is re-sent after the reconnect and answered.
+	answered, received := run(true)
+	if !answered || received != 2 {
+		t.Fatalf("idempotent request: answered = %v, received %d times", answered, received)
+	}
+	// Any other request is not re-sent, and expires.
+	answered, received = run(false)
+	if answered || received != 1 {
+		t.Fatalf("unsafe request: answered = %v, received %d times", answered, received)
+	}
+
+	if _, err := NewWsConn(&WsCfg{URL: "wss://dex.example.com:7232/ws", RequestRetries: -1, Logger: tLogger}); err == nil {
+		t.Fatalf("no error for negative request retries")
+	}
+}
+
+func TestWsConnPingInterval(t *testing.T) {
+	const idleTimeout = 300 * time.Millisecond
+
+	// run connects to a server that, like a firewall, drops the connection if
+	// no data is received from the client for idleTimeout, and does not ping
+	// the client itself. It returns the number of connections made by the
+	// client after the connection has been idle for several idle timeouts.
+	run := func(pingInterval time.Duration) uint32 {
+		t.Helper()
+		var connects uint32
+		upgrader := websocket.Upgrader{}
+		var hWG sync.WaitGroup
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hWG.Add(1)
+			defer hWG.Done()
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("unable to upgrade http connection: %s", err)
+				return
+			}
+			defer c.Close()
+			atomic.AddUint32(&connects, 1)
+			c.SetPingHandler(func(string) error {
+				c.SetReadDeadline(time.Now().Add(idleTimeout))
+				return c.WriteControl(websocket.PongMessage, []byte{}, time.Now().Add(DefaultWriteWait))
+			})
+			c.SetReadDeadline(time.Now().Add(idleTimeout))
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+		srv.StartTLS()
+		defer srv.Close()
+		defer hWG.Wait()
+
+		certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+		wsc, err := NewWsConn(&WsCfg{
+			URL:          "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+			PingWait:     2 * idleTimeout,
+			PingInterval: pingInterval,
+			Cert:         certB,
+			Logger:       tLogger,
+		})
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cm := dex.NewConnectionMaster(wsc)
+		if err := cm.Connect(ctx); err != nil {
+			t.Fatalf("Connect error: %v", err)
+		}
+		defer cm.Disconnect()
+
+		time.Sleep(5 * idleTimeout)
+		return atomic.LoadUint32(&connects)
+	}
+
+	if n := run(idleTimeout / 3); n != 1 {
+		t.Fatalf("pinged connection was dropped. %d connections", n)
+	}
+	// Without pings, the idle connection is dropped and the client
+	// reconnects.
+	if n := run(0); n < 2 {
+		t.Fatalf("idle connection was not dropped without pings")
+	}
+
+	// The interval must be shorter than PingWait.
+	_, err := NewWsConn(&WsCfg{
+		URL:          "wss://dex.example.com:7232/ws",
+		PingWait:     time.Second,
+		PingInterval: time.Second,
+		Logger:       tLogger,
+	})
+	if err == nil {
+		t.Fatalf("no error for ping interval >= ping wait")
+	}
+}
+
+func TestWsConnHalfOpen(t *testing.T) {
+	const pingWait = 300 * time.Millisecond
+
+	// The server keeps pinging the client, which extends the client's read
+	// deadline, but stops answering the client's pings after the first
+	// connection, as if the client's half of the connection was lost.
+	var connects uint32
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		if atomic.AddUint32(&connects, 1) == 1 {
+			c.SetPingHandler(func(string) error { return nil })
+		}
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(pingWait / 4)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if c.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(DefaultWriteWait)) != nil {
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	srv.StartTLS()
+	defer srv.Close()
+	defer hWG.Wait()
+
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	wsc, err := NewWsConn(&WsCfg{
+		URL:          "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait:     pingWait,
+		PingInterval: pingWait / 3,
+		Cert:         certB,
+		Logger:       tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer cm.Disconnect()
+
+	// The client reconnects after the first unanswered ping, and the second
+	// connection, which answers pings, stays up.
+	time.Sleep(4 * pingWait)
+	if n := atomic.LoadUint32(&connects); n != 2 {
+		t.Fatalf("expected 2 connections, got %d", n)
+	}
+	if stats := wsc.Stats(); stats.Reconnects != 1 {
+		t.Fatalf("expected 1 reconnect, got %d", stats.Reconnects)
+	}
+}
+
+func TestWsConnDialTimeout(t *testing.T) {
+	// A listener that accepts connections but never completes the TLS
+	// handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	var conns []net.Conn
+	var connsMtx sync.Mutex
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connsMtx.Lock()
+			conns = append(conns, c)
+			connsMtx.Unlock()
+		}
+	}()
+	defer func() {
+		ln.Close()
+		connsMtx.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		connsMtx.Unlock()
+	}()
+
+	const dialTimeout = 200 * time.Millisecond
+	wsc, err := NewWsConn(&WsCfg{
+		URL:         "wss://" + ln.Addr().String() + "/ws",
+		PingWait:    time.Second,
+		DialTimeout: dialTimeout,
+		Logger:      tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	start := time.Now()
+	err = cm.Connect(ctx)
+	if !errors.Is(err, ErrDialTimeout) {
+		t.Fatalf("expected ErrDialTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*dialTimeout {
+		t.Fatalf("Connect took %v with a %v dial timeout", elapsed, dialTimeout)
+	}
+	// A reconnect is scheduled.
+	status := wsc.Status()
+	if status.Connected || !status.Reconnecting || !errors.Is(status.LastError, ErrDialTimeout) {
+		t.Fatalf("wrong status after dial timeout: %+v", status)
+	}
+	cm.Disconnect()
+	if status = wsc.Status(); status.Reconnecting {
+		t.Fatalf("still reconnecting after Disconnect")
+	}
+
+	_, err = NewWsConn(&WsCfg{
+		URL:         "wss://dex.example.com:7232/ws",
+		PingWait:    time.Second,
+		DialTimeout: -time.Second,
+		Logger:      tLogger,
+	})
+	if err == nil {
+		t.Fatalf("no error for negative dial timeout")
+	}
+}
+
+func TestWsConnCertBundle(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	// newServer starts a websocket server presenting a new certificate.
+	newServer := func() (*httptest.Server, []byte) {
+		t.Helper()
+		certB, keyB, err := certgen.NewTLSCertPair(elliptic.P256(), "test", time.Now().Add(time.Hour), nil)
+		if err != nil {
+			t.Fatalf("error generating cert: %v", err)
+		}
+		keyPair, err := tls.X509KeyPair(certB, keyB)
+		if err != nil {
+			t.Fatalf("error loading key pair: %v", err)
+		}
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hWG.Add(1)
+			defer hWG.Done()
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("unable to upgrade http connection: %s", err)
+				return
+			}
+			defer c.Close()
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+		srv.TLS = &tls.Config{Certificates: []tls.Certificate{keyPair}}
+		srv.StartTLS()
+		return srv, certB
+	}
+
+	oldSrv, oldCert := newServer()
+	defer oldSrv.Close()
+	newSrv, newCert := newServer()
+	defer newSrv.Close()
+	otherSrv, _ := newServer()
+	defer otherSrv.Close()
+	defer hWG.Wait()
+
+	bundle := append(append([]byte{}, oldCert...), newCert...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connect := func(srv *httptest.Server) error {
+		wsc, err := NewWsConn(&WsCfg{
+			URL:      "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+			PingWait: 5 * time.Second,
+			Cert:     bundle,
+			Logger:   tLogger,
+		})
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		cm := dex.NewConnectionMaster(wsc)
+		err = cm.Connect(ctx)
+		cm.Disconnect()
+		return err
+	}
+
+	// The server may present either certificate in the bundle.
+	if err := connect(oldSrv); err != nil {
+		t.Fatalf("error connecting to server with the old cert: %v", err)
+	}
+	if err := connect(newSrv); err != nil {
+		t.Fatalf("error connecting to server with the new cert: %v", err)
+	}
+	// But not one outside of it.
+	if err := connect(otherSrv); !errors.Is(err, ErrInvalidCert) {
+		t.Fatalf("expected ErrInvalidCert for a cert not in the bundle, got %v", err)
+	}
+
+	// Every block in the bundle must be a certificate.
+	junk := append(append([]byte{}, oldCert...), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("junk")})...)
+	_, err := NewWsConn(&WsCfg{URL: "wss://dex.example.com:7232/ws", Cert: junk, Logger: tLogger})
+	if !errors.Is(err, ErrInvalidCert) {
+		t.Fatalf("expected ErrInvalidCert for a bundle with an invalid cert, got %v", err)
+	}
+}
+
+func TestWsConnCertUpdate(t *testing.T) {
+	newKeyPair := func() (tls.Certificate, []byte) {
+		t.Helper()
+		certB, keyB, err := certgen.NewTLSCertPair(elliptic.P256(), "test", time.Now().Add(time.Hour), nil)
+		if err != nil {
+			t.Fatalf("error generating cert: %v", err)
+		}
+		keyPair, err := tls.X509KeyPair(certB, keyB)
+		if err != nil {
+			t.Fatalf("error loading key pair: %v", err)
+		}
+		return keyPair, certB
+	}
+	oldPair, oldCert := newKeyPair()
+	newPair, newCert := newKeyPair()
+	_, otherCert := newKeyPair()
+
+	// run connects to a server that rotates its certificate and drops the
+	// first connection, and returns the number of CertUpdate calls after the
+	// client reconnects or gives up waiting.
+	run := func(certUpdate func() ([]byte, error), wantReconnect bool) int {
+		t.Helper()
+		var certMtx sync.Mutex
+		keyPair := oldPair
+		var connects uint32
+		upgrader := websocket.Upgrader{}
+		var hWG sync.WaitGroup
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hWG.Add(1)
+			defer hWG.Done()
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("unable to upgrade http connection: %s", err)
+				return
+			}
+			defer c.Close()
+			if atomic.AddUint32(&connects, 1) == 1 {
+				// Rotate the certificate and drop the connection.
+				certMtx.Lock()
+				keyPair = newPair
+				certMtx.Unlock()
+				return
+			}
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+		srv.TLS = &tls.Config{GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certMtx.Lock()
+			defer certMtx.Unlock()
+			return &tls.Config{Certificates: []tls.Certificate{keyPair}}, nil
+		}}
+		srv.StartTLS()
+		defer srv.Close()
+		defer hWG.Wait()
+
+		var updates uint32
+		cfg := &WsCfg{
+			URL:      "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+			PingWait: 5 * time.Second,
+			Cert:     oldCert,
+			Logger:   tLogger,
+		}
+		if certUpdate != nil {
+			cfg.CertUpdate = func() ([]byte, error) {
+				atomic.AddUint32(&updates, 1)
+				return certUpdate()
+			}
+		}
+		wsc, err := NewWsConn(cfg)
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cm := dex.NewConnectionMaster(wsc)
+		if err := cm.Connect(ctx); err != nil {
+			t.Fatalf("Connect error: %v", err)
+		}
+		defer cm.Disconnect()
+
+		var reconnected bool
+		for i := 0; i < 100 && !reconnected; i++ {
+			time.Sleep(10 * time.Millisecond)
+			reconnected = wsc.Stats().Reconnects == 1
+		}
+		if reconnected != wantReconnect {
+			t.Fatalf("wanted reconnected = %v, got %v", wantReconnect, reconnected)
+		}
+		if !wantReconnect {
+			if status := wsc.Status(); status.Connected || !errors.Is(status.LastError, ErrInvalidCert) {
+				t.Fatalf("wrong status %+v", status)
+			}
+		}
+		return int(atomic.LoadUint32(&updates))
+	}
+
+	// The updated certificate is used right away.
+	if n := run(func() ([]byte, error) { return newCert, nil }, true); n != 1 {
+		t.Fatalf("wanted 1 cert update, got %d", n)
+	}
+	// Without CertUpdate, the client stays down with a certificate error.
+	run(nil, false)
+	// An update that is also rejected, or that fails, is not retried until
+	// the next scheduled reconnect.
+	if n := run(func() ([]byte, error) { return otherCert, nil }, false); n != 1 {
+		t.Fatalf("wanted 1 cert update, got %d", n)
+	}
+	if n := run(func() ([]byte, error) { return nil, errors.New("no cert") }, false); n != 1 {
+		t.Fatalf("wanted 1 cert update, got %d", n)
+	}
+}
+
+func TestWsConnMaxMessageSize(t *testing.T) {
+	const maxSize = 1024
+	var connects uint32
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		// Send an over-limit message on the first connection, and a small
+		// one after the client reconnects.
+		payload := "small"
+		if atomic.AddUint32(&connects, 1) == 1 {
+			payload = strings.Repeat("x", 2*maxSize)
+		}
+		ntfn, _ := msgjson.NewNotification(msgjson.MatchRoute, payload)
+		if err := c.WriteJSON(ntfn); err != nil {
+			t.Errorf("write error: %v", err)
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	defer hWG.Wait()
+
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	wsc, err := NewWsConn(&WsCfg{
+		URL:            "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait:       5 * time.Second,
+		MaxMessageSize: maxSize,
+		Cert:           certB,
+		Logger:         tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer cm.Disconnect()
+
+	// The oversized message is dropped with the connection, and the client
+	// reconnects to receive the next one.
+	select {
+	case msg := <-wsc.MessageSource():
+		var payload string
+		if err := msg.Unmarshal(&payload); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if payload != "small" {
+			t.Fatalf("received an over-limit message of length %d", len(payload))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message received after reconnect")
+	}
+	if n := atomic.LoadUint32(&connects); n != 2 {
+		t.Fatalf("expected 2 connections, got %d", n)
+	}
+	// The error that took the connection down is kept after the reconnect.
+	status := wsc.Status()
+	if !status.Connected || status.Reconnecting || !errors.Is(status.LastError, websocket.ErrReadLimit) {
+		t.Fatalf("wrong status after reconnect: %+v", status)
+	}
+	b, _ := json.Marshal(status)
+	if want := `{"connected":true,"reconnecting":false,"lastError":"websocket: read limit exceeded"}`; string(b) != want {
+		t.Fatalf("wrong status JSON %s, wanted %s", b, want)
+	}
+
+	_, err = NewWsConn(&WsCfg{
+		URL:            "wss://dex.example.com:7232/ws",
+		PingWait:       time.Second,
+		MaxMessageSize: -1,
+		Logger:         tLogger,
+	})
+	if err == nil {
+		t.Fatalf("no error for negative max message size")
+	}
+}
+
+func TestWsConnWriteWait(t *testing.T) {
+	cfg := &WsCfg{
+		URL:      "wss://dex.example.com:7232/ws",
+		PingWait: time.Second,
+		Logger:   tLogger,
+	}
+	wsc, err := NewWsConn(cfg)
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	if ww := wsc.(*wsConn).writeWait(); ww != DefaultWriteWait {
+		t.Fatalf("expected default write wait %v, got %v", DefaultWriteWait, ww)
+	}
+
+	cfg.WriteWait = 10 * time.Second
+	wsc, err = NewWsConn(cfg)
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	if ww := wsc.(*wsConn).writeWait(); ww != cfg.WriteWait {
+		t.Fatalf("expected write wait %v, got %v", cfg.WriteWait, ww)
+	}
+
+	cfg.WriteWait = -time.Second
+	if _, err = NewWsConn(cfg); err == nil {
+		t.Fatalf("no error for negative write wait")
+	}
+}
+
+func TestWsConnConcurrentRequests(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	// The server responds to each request with its ID.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		for {
+			msg := new(msgjson.Message)
+			if err := c.ReadJSON(msg); err != nil {
+				return
+			}
+			resp, _ := msgjson.NewResponse(msg.ID, msg.ID, nil)
+			if err := c.WriteJSON(resp); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	defer hWG.Wait()
+
+	const numReqs = 500
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	wsc, err := NewWsConn(&WsCfg{
+		URL:            "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait:       5 * time.Second,
+		PingInterval:   time.Millisecond,
+		WriteQueueSize: numReqs,
+		Cert:           certB,
+		Logger:         tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cm := dex.NewConnectionMaster(wsc)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer cm.Disconnect()
+
+	// Fire the requests simultaneously, along with the pings.
+	var wg sync.WaitGroup
+	errs := make(chan error, numReqs)
+	for i := 0; i < numReqs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := wsc.NextID()
+			req, _ := msgjson.NewRequest(id, msgjson.ConfigRoute, nil)
+			respC := make(chan error, 1)
+			err := wsc.RequestWithTimeout(req, func(msg *msgjson.Message) {
+				var respID uint64
+				if err := msg.UnmarshalResult(&respID); err != nil {
+					respC <- err
+				} else if respID != id {
+					respC <- fmt.Errorf("response for %d to request %d", respID, id)
+				} else {
+					respC <- nil
+				}
+			}, 5*time.Second, func() {
+				respC <- fmt.Errorf("request %d expired", id)
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- <-respC
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+	}
+}
+
+func TestWsConnWriteQueueFull(t *testing.T) {
+	wsc, err := NewWsConn(&WsCfg{
+		URL:            "wss://dex.example.com:7232/ws",
+		PingWait:       time.Second,
+		WriteQueueSize: 1,
+		Logger:         tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	conn := wsc.(*wsConn)
+	conn.setConnected(true)
+	done := make(chan struct{})
+	defer close(done)
+	conn.done = done
+
+	// Without a write pump, the first message fills the queue.
+	conn.writeCh <- &wsWrite{errCh: make(chan error, 1)}
+	msg, _ := msgjson.NewNotification(msgjson.MatchRoute, "full")
+	errC := make(chan error, 1)
+	go func() { errC <- conn.Send(msg) }()
+	select {
+	case err := <-errC:
+		if !errors.Is(err, ErrWriteQueueFull) {
+			t.Fatalf("expected ErrWriteQueueFull, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Send blocked on a full queue")
+	}
+
+	_, err = NewWsConn(&WsCfg{
+		URL:            "wss://dex.example.com:7232/ws",
+		PingWait:       time.Second,
+		WriteQueueSize: -1,
+		Logger:         tLogger,
+	})
+	if err == nil {
+		t.Fatalf("no error for negative write queue size")
+	}
+}
+
+func TestWsConnSlowConsumer(t *testing.T) {
+	const nMsgs, queueSize = 20, 4
+	const pingWait = 500 * time.Millisecond
+	var connects uint32
+	upgrader := websocket.Upgrader{}
+	var hWG sync.WaitGroup
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		atomic.AddUint32(&connects, 1)
+		for i := 0; i < nMsgs; i++ {
+			ntfn, _ := msgjson.NewNotification(msgjson.MatchRoute, i)
+			if err := c.WriteJSON(ntfn); err != nil {
+				t.Errorf("write error: %v", err)
+				return
+			}
+		}
+		// Ping well within the client's PingWait until the client leaves.
+		quit := make(chan struct{})
+		defer close(quit)
+		go func() {
+			ticker := time.NewTicker(pingWait / 5)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+						return
+					}
+				case <-quit:
+					return
+				}
+			}
+		}()
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	defer hWG.Wait()
+
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	connect := func(drop bool) (WsConn, func()) {
+		t.Helper()
+		wsc, err := NewWsConn(&WsCfg{
+			URL:           "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+			PingWait:      pingWait,
+			ReadQueueSize: queueSize,
+			ReadQueueDrop: drop,
+			Cert:          certB,
+			Logger:        tLogger,
+		})
+		if err != nil {
+			t.Fatalf("NewWsConn error: %v", err)
+		}
+		cm := dex.NewConnectionMaster(wsc)
+		if err := cm.Connect(context.Background()); err != nil {
+			t.Fatalf("Connect error: %v", err)
+		}
+		return wsc, cm.Disconnect
+	}
+	readN := func(wsc WsConn, n int) []int {
+		t.Helper()
+		vals := make([]int, 0, n)
+		for len(vals) < n {
+			select {
+			case msg := <-wsc.MessageSource():
+				var i int
+				if err := msg.Unmarshal(&i); err != nil {
+					t.Fatalf("unmarshal error: %v", err)
+				}
+				vals = append(vals, i)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("received %d of %d messages", len(vals), n)
+			}
+		}
+		return vals
+	}
+
+	// With drops, a consumer that does not read for several PingWaits does not
+	// stall the connection. The messages that did not fit are dropped.
+	wsc, disconnect := connect(true)
+	time.Sleep(3 * pingWait)
+	stats, status := wsc.Stats(), wsc.Status()
+	if !status.Connected || stats.Reconnects != 0 || atomic.LoadUint32(&connects) != 1 {
+		t.Fatalf("connection not kept alive: status %+v, stats %+v, %d connects",
+			status, stats, atomic.LoadUint32(&connects))
+	}
+	if stats.DroppedMessages != nMsgs-queueSize || stats.QueuedMessages != queueSize {
+		t.Fatalf("wanted %d dropped and %d queued, got %d and %d", nMsgs-queueSize,
+			queueSize, stats.DroppedMessages, stats.QueuedMessages)
+	}
+	if vals := readN(wsc, queueSize); !reflect.DeepEqual(vals, []int{0, 1, 2, 3}) {
+		t.Fatalf("wrong messages %v", vals)
+	}
+	disconnect()
+
+	// By default, every message is delivered in order.
+	wsc, disconnect = connect(false)
+	defer disconnect()
+	vals := readN(wsc, nMsgs)
+	for i, v := range vals {
+		if v != i {
+			t.Fatalf("wrong message order %v", vals)
+		}
+	}
+	if dropped := wsc.Stats().DroppedMessages; dropped != 0 {
+		t.Fatalf("%d messages dropped", dropped)
+	}
+
+	_, err := NewWsConn(&WsCfg{
+		URL:           "wss://dex.example.com:7232/ws",
+		PingWait:      time.Second,
+		ReadQueueSize: -1,
+		Logger:        tLogger,
+	})
+	if err == nil {
+		t.Fatalf("no error for negative read queue size")
+	}
+}
+
+func TestWsConnWaitForConnect(t *testing.T) {
+	wsc, err := NewWsConn(&WsCfg{
+		URL:      "wss://dex.example.com:7232/ws",
+		PingWait: time.Second,
+		Logger:   tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	conn := wsc.(*wsConn)
+
+	// Not connected, so the wait ends with the context.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	err = conn.WaitForConnect(ctx)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// A waiter is released when the connection comes up.
+	errC := make(chan error, 1)
+	go func() { errC <- conn.WaitForConnect(context.Background()) }()
+	time.Sleep(time.Millisecond * 10)
+	conn.setConnected(true)
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatalf("WaitForConnect error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("WaitForConnect not released on connect")
+	}
+
+	// Already connected returns immediately.
+	if err := conn.WaitForConnect(context.Background()); err != nil {
+		t.Fatalf("WaitForConnect error: %v", err)
+	}
+
+	// Waiting resumes after a disconnect.
+	conn.setConnected(false)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	err = conn.WaitForConnect(ctx)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded after disconnect, got %v", err)
+	}
+}
+
+func TestWsConnClose(t *testing.T) {
+	closeErrC := make(chan error, 1)
+	var connects uint32
+	var hWG sync.WaitGroup
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hWG.Add(1)
+		defer hWG.Done()
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade http connection: %s", err)
+			return
+		}
+		defer c.Close()
+		atomic.AddUint32(&connects, 1)
+		// The default close handler acknowledges the client's close message.
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				closeErrC <- err
+				return
+			}
+		}
+	}))
+	srv.StartTLS()
+	defer srv.Close()
+	defer hWG.Wait()
+
+	certB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	wsc, err := NewWsConn(&WsCfg{
+		URL:      "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws",
+		PingWait: time.Minute,
+		Cert:     certB,
+		Logger:   tLogger,
+	})
+	if err != nil {
+		t.Fatalf("NewWsConn error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg, err := wsc.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+
+	start := time.Now()
+	if err := wsc.Close("client shutting down"); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	// The server acknowledged the close, so Close should not wait for the
+	// full closeWait.
+	if elapsed := time.Since(start); elapsed >= closeWait {
+		t.Fatalf("Close waited %v for the close acknowledgement", elapsed)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-closeErrC:
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseNormalClosure {
+			t.Fatalf("server did not receive a normal close: %v", err)
+		}
+		if closeErr.Text != "client shutting down" {
+			t.Fatalf("wrong close reason %q", closeErr.Text)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("server connection not closed")
+	}
+
+	status := wsc.Status()
+	if status.Connected || status.Reconnecting {
+		t.Fatalf("wrong status after Close: %+v", status)
+	}
+	if n := atomic.LoadUint32(&connects); n != 1 {
+		t.Fatalf("reconnected after Close. %d connections", n)
+	}
+
+	// A second Close is a no-op.
+	if err := wsc.Close("again"); err != nil {
+		t.Fatalf("second Close error: %v", err)
+	}
+}
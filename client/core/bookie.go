@@ -20,8 +20,18 @@ import (
 var (
 	feederID        uint32
 	bookFeedTimeout = time.Minute
+	// bookSeq is the last sequence number assigned to a book update. It is
+	// shared by every bookie so that a sequence number from one book is never
+	// mistaken for one from another, e.g. a book that replaced it after a
+	// disconnect.
+	bookSeq uint64
 )
 
+// bookReplayLimit is the number of recent updates each bookie keeps to replay
+// to a subscriber that missed them. It must be less than the capacity of a
+// BookFeed's channel so the replay does not block.
+const bookReplayLimit = 128
+
 // BookFeed manages a channel for receiving order book updates. The only
 // exported field, C, is a channel on which to receive the updates as a series
 // of *BookUpdate. It is imperative that the feeder (*BookFeed).Close() when no
@@ -59,17 +69,27 @@ type bookie struct {
 	feeds      map[uint32]*BookFeed
 	close      func() // e.g. dexConnection.StopBook
 	closeTimer *time.Timer
+	// seq is the sequence number of the last update sent, or of the initial
+	// book if none have been sent.
+	seq uint64
+	// history is the most recent updates sent, oldest first, and historyBase
+	// is the sequence number that precedes the oldest.
+	history     []*BookUpdate
+	historyBase uint64
 }
 
 // newBookie is a constructor for a bookie. The caller should provide a callback
 // function to be called when there are no subscribers and the close timer has
 // expired.
 func newBookie(logger dex.Logger, close func()) *bookie {
+	seq := atomic.AddUint64(&bookSeq, 1)
 	return &bookie{
-		OrderBook: *orderbook.NewOrderBook(logger.SubLogger("book")),
-		log:       logger,
-		feeds:     make(map[uint32]*BookFeed, 1),
-		close:     close,
+		OrderBook:   *orderbook.NewOrderBook(logger.SubLogger("book")),
+		log:         logger,
+		feeds:       make(map[uint32]*BookFeed, 1),
+		close:       close,
+		seq:         seq,
+		historyBase: seq,
 	}
 }
 
@@ -141,10 +161,34 @@ func (b *bookie) closeFeed(feed *BookFeed) {
 	}
 }
 
-// send sends a *BookUpdate to all subscribers.
+// closeFeeds closes the channels of all of the bookie's feeds and removes the
+// feeds, signaling the subscribers that no more updates are coming. The close
+// timer is stopped, so the close func is not called.
+func (b *bookie) closeFeeds() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.closeTimer != nil {
+		b.closeTimer.Stop()
+		b.closeTimer = nil
+	}
+	for fid, feed := range b.feeds {
+		close(feed.C)
+		delete(b.feeds, fid)
+	}
+}
+
+// send assigns the *BookUpdate the next sequence number, records it for
+// replay, and sends it to all subscribers.
 func (b *bookie) send(u *BookUpdate) {
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
+	u.Seq = atomic.AddUint64(&bookSeq, 1)
+	b.seq = u.Seq
+	b.history = append(b.history, u)
+	if len(b.history) > bookReplayLimit {
+		b.historyBase = b.history[0].Seq
+		b.history = b.history[1:]
+	}
 	for fid, feed := range b.feeds {
 		select {
 		case feed.C <- u:
@@ -156,6 +200,22 @@ func (b *bookie) send(u *BookUpdate) {
 	}
 }
 
+// missedUpdates returns the updates sent after the update with sequence number
+// seq, or after the initial book if seq is its sequence number. The boolean is
+// false if seq is not known to the bookie or the updates that followed it are
+// no longer all in the history. The bookie's mtx must be locked.
+func (b *bookie) missedUpdates(seq uint64) ([]*BookUpdate, bool) {
+	if seq == b.historyBase {
+		return b.history, true
+	}
+	for i, u := range b.history {
+		if u.Seq == seq {
+			return b.history[i+1:], true
+		}
+	}
+	return nil, false
+}
+
 // book returns the bookie's current order book.
 func (b *bookie) book() *OrderBook {
 	buys, sells, epoch := b.Orders()
@@ -168,8 +228,11 @@ func (b *bookie) book() *OrderBook {
 
 // syncBook subscribes to the order book and returns the book and a BookFeed to
 // receive order book updates. The BookFeed must be Close()d when it is no
-// longer in use. Use stopBook to unsubscribed and clean up the feed.
-func (dc *dexConnection) syncBook(base, quote uint32) (*BookFeed, error) {
+// longer in use. Use stopBook to unsubscribed and clean up the feed. If since
+// is non-zero and the updates that followed the one with that sequence number
+// can be replayed, they are queued on the feed instead of the book, and the
+// boolean is true.
+func (dc *dexConnection) syncBook(base, quote uint32, since uint64) (*BookFeed, bool, error) {
 
 	dc.booksMtx.Lock()
 	defer dc.booksMtx.Unlock()
@@ -182,18 +245,18 @@ func (dc *dexConnection) syncBook(base, quote uint32) (*BookFeed, error) {
 		_, found = dc.marketMap[mkt]
 		dc.marketMtx.RUnlock()
 		if !found {
-			return nil, fmt.Errorf("unknown market %s", mkt)
+			return nil, false, fmt.Errorf("unknown market %s", mkt)
 		}
 
 		obRes, err := dc.subscribe(base, quote)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		booky = newBookie(dc.log.SubLogger(mkt), func() { dc.stopBook(base, quote) })
 		err = booky.Sync(obRes)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		dc.books[mkt] = booky
 	}
@@ -204,10 +267,20 @@ func (dc *dexConnection) syncBook(base, quote uint32) (*BookFeed, error) {
 	defer booky.mtx.Unlock()
 	feed := booky.feed()
 
+	if since > 0 {
+		if missed, ok := booky.missedUpdates(since); ok {
+			for _, u := range missed {
+				feed.C <- u
+			}
+			return feed, true, nil
+		}
+	}
+
 	feed.C <- &BookUpdate{
 		Action:   FreshBookAction,
 		Host:     dc.acct.host,
 		MarketID: mkt,
+		Seq:      booky.seq,
 		Payload: &MarketOrderBook{
 			Base:  base,
 			Quote: quote,
@@ -215,7 +288,7 @@ func (dc *dexConnection) syncBook(base, quote uint32) (*BookFeed, error) {
 		},
 	}
 
-	return feed, nil
+	return feed, false, nil
 }
 
 // subscribe subscribes to the given market's order book via the 'orderbook'
@@ -276,6 +349,18 @@ func (dc *dexConnection) stopBook(base, quote uint32) {
 	}
 }
 
+// closeBooks closes the feeds of every order book and removes the books. No
+// unsubscribe requests are sent, so closeBooks should only be used when the
+// connection to the DEX is being closed.
+func (dc *dexConnection) closeBooks() {
+	dc.booksMtx.Lock()
+	defer dc.booksMtx.Unlock()
+	for mkt, booky := range dc.books {
+		booky.closeFeeds()
+		delete(dc.books, mkt)
+	}
+}
+
 // unsubscribe unsubscribes from to the given market's order book.
 func (dc *dexConnection) unsubscribe(base, quote uint32) error {
 	mkt := marketName(base, quote)
@@ -311,7 +396,25 @@ func (c *Core) SyncBook(host string, base, quote uint32) (*BookFeed, error) {
 		return nil, fmt.Errorf("unknown DEX '%s'", host)
 	}
 
-	return dc.syncBook(base, quote)
+	feed, _, err := dc.syncBook(base, quote, 0)
+	return feed, err
+}
+
+// SyncBookSince is like SyncBook, but for a subscriber that already has the
+// book as of the update with sequence number since, e.g. one that is
+// resubscribing after a brief disconnect. If the updates it missed can be
+// replayed, they are queued on the BookFeed instead of the book, and the
+// boolean is true. Otherwise, the book is sent as with SyncBook. The BookFeed
+// must be Close()d when it is no longer in use.
+func (c *Core) SyncBookSince(host string, base, quote uint32, since uint64) (*BookFeed, bool, error) {
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, false, fmt.Errorf("unknown DEX '%s'", host)
+	}
+
+	return dc.syncBook(base, quote, since)
 }
 
 // Book fetches the order book. If a subscription doesn't exist, one will be
@@ -695,7 +798,8 @@ func handleEpochOrderMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error
 		return fmt.Errorf("epoch order note unmarshal error: %v", err)
 	}
 
-	if dc.setEpoch(note.MarketID, note.Epoch) {
+	newEpoch := dc.setEpoch(note.MarketID, note.Epoch)
+	if newEpoch {
 		c.refreshUser()
 	}
 
@@ -708,6 +812,15 @@ func handleEpochOrderMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error
 			note.MarketID)
 	}
 
+	if newEpoch {
+		book.send(&BookUpdate{
+			Action:   NewEpochAction,
+			Host:     dc.acct.host,
+			MarketID: note.MarketID,
+			Payload:  &EpochUpdate{Epoch: note.Epoch},
+		})
+	}
+
 	err = book.Enqueue(note)
 	if err != nil {
 		return fmt.Errorf("failed to Enqueue epoch order: %v", err)
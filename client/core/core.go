@@ -7,12 +7,17 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,11 +36,13 @@ import (
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/order"
 	"decred.org/dcrdex/dex/wait"
+	"decred.org/dcrdex/server/account"
 	"github.com/decred/dcrd/dcrec/secp256k1/v2"
 )
 
 const (
 	keyParamsKey      = "keyParams"
+	tradeSettingsKey  = "tradeSettings"
 	conversionFactor  = 1e8
 	regFeeAssetSymbol = "dcr" // Hard-coded to Decred for registration fees, for now.
 
@@ -340,7 +347,7 @@ func (c *Core) syncOrderPlaced(oid order.OrderID) {
 // the provided interface.
 func (dc *dexConnection) signAndRequest(signable msgjson.Signable, route string, result interface{}, timeout time.Duration) error {
 	if dc.acct.locked() {
-		return fmt.Errorf("cannot sign: %s account locked", dc.acct.host)
+		return newError(loginRequiredErr, "cannot sign: %s account locked", dc.acct.host)
 	}
 	err := sign(dc.acct.privKey, signable)
 	if err != nil {
@@ -809,6 +816,19 @@ type Core struct {
 
 	piSyncMtx sync.Mutex
 	piSyncers map[order.OrderID]chan struct{}
+
+	// retryPolicy is nil until set with SetRetryPolicy, in which case
+	// defaultRetryPolicy applies.
+	retryMtx    sync.RWMutex
+	retryPolicy *RetryPolicy
+
+	// settings are loaded from the DB by New and saved by SetSettings.
+	settingsMtx sync.RWMutex
+	settings    TradeSettings
+
+	// loggedIn is set by Login and cleared by Logout.
+	loginMtx sync.RWMutex
+	loggedIn bool
 }
 
 // New is the constructor for a new Core.
@@ -838,6 +858,10 @@ func New(cfg *Config) (*Core, error) {
 		latencyQ:      wait.NewTickerQueue(recheckInterval),
 	}
 
+	if err := core.loadSettings(); err != nil {
+		return nil, err
+	}
+
 	// Populate the initial user data. User won't include any DEX info yet, as
 	// those are retrieved when Run is called and the core connects to the DEXes.
 	core.refreshUser()
@@ -863,6 +887,21 @@ func (c *Core) Run(ctx context.Context) {
 		c.latencyQ.Run(ctx)
 	}()
 	c.wg.Wait()
+
+	// The DEX connections and wallets were connected with ctx, which is now
+	// canceled. Wait for them to shut down.
+	c.connMtx.RLock()
+	for _, dc := range c.conns {
+		dc.connMaster.Disconnect()
+	}
+	c.connMtx.RUnlock()
+	c.walletMtx.RLock()
+	for _, wallet := range c.wallets {
+		if wallet.connected() {
+			wallet.Disconnect()
+		}
+	}
+	c.walletMtx.RUnlock()
 	c.log.Infof("DEX client core off")
 }
 
@@ -920,24 +959,52 @@ func (c *Core) Exchanges() map[string]*Exchange {
 	defer c.connMtx.RUnlock()
 	infos := make(map[string]*Exchange, len(c.conns))
 	for host, dc := range c.conns {
-		dc.cfgMtx.RLock()
-		dc.assetsMtx.RLock()
-		requiredConfs := uint32(dc.cfg.RegFeeConfirms)
-		infos[host] = &Exchange{
-			Host:          host,
-			Markets:       dc.markets(),
-			Assets:        dc.assets,
-			FeePending:    dc.acct.feePending(),
-			Connected:     dc.connected,
-			ConfsRequired: requiredConfs,
-			RegConfirms:   dc.getRegConfirms(),
-		}
-		dc.cfgMtx.RUnlock()
-		dc.assetsMtx.RUnlock()
+		infos[host] = dc.exchangeInfo(host)
 	}
 	return infos
 }
 
+// exchangeInfo builds the Exchange for the dexConnection.
+func (dc *dexConnection) exchangeInfo(host string) *Exchange {
+	dc.cfgMtx.RLock()
+	defer dc.cfgMtx.RUnlock()
+	dc.assetsMtx.RLock()
+	defer dc.assetsMtx.RUnlock()
+	return &Exchange{
+		Host:          host,
+		Markets:       dc.markets(),
+		Assets:        dc.assets,
+		FeePending:    dc.acct.feePending(),
+		Connected:     dc.connected,
+		ConfsRequired: uint32(dc.cfg.RegFeeConfirms),
+		RegConfirms:   dc.getRegConfirms(),
+	}
+}
+
+// ConnStats returns a snapshot of the websocket connection statistics for each
+// DEX, keyed by host.
+func (c *Core) ConnStats() map[string]comms.ConnStats {
+	c.connMtx.RLock()
+	defer c.connMtx.RUnlock()
+	stats := make(map[string]comms.ConnStats, len(c.conns))
+	for host, dc := range c.conns {
+		stats[host] = dc.Stats()
+	}
+	return stats
+}
+
+// ConnStatus returns the websocket connection state for each DEX, keyed by
+// host.
+func (c *Core) ConnStatus() map[string]comms.ConnStatus {
+	c.connMtx.RLock()
+	defer c.connMtx.RUnlock()
+	statuses := make(map[string]comms.ConnStatus, len(c.conns))
+	for host, dc := range c.conns {
+		statuses[host] = dc.Status()
+	}
+	return statuses
+}
+
 // wallet gets the wallet for the specified asset ID in a thread-safe way.
 func (c *Core) wallet(assetID uint32) (*xcWallet, bool) {
 	c.walletMtx.RLock()
@@ -1079,6 +1146,13 @@ func (c *Core) Wallets() []*WalletState {
 	return state
 }
 
+// refreshPeers updates the peer status of the wallet, logging any error.
+func (c *Core) refreshPeers(wallet *xcWallet) {
+	if err := wallet.refreshPeers(); err != nil {
+		c.log.Debugf("error getting %s wallet peer count: %v", unbip(wallet.AssetID), err)
+	}
+}
+
 // SupportedAssets returns a list of asset information for supported assets that
 // may or may not have a wallet yet.
 func (c *Core) SupportedAssets() map[uint32]*SupportedAsset {
@@ -1102,6 +1176,94 @@ func (c *Core) SupportedAssets() map[uint32]*SupportedAsset {
 	return assets
 }
 
+// FeeRate returns the current network fee rate estimate, in atoms/byte, for
+// the specified asset's wallet. The wallet must be connected, and an error is
+// returned if the wallet's backend cannot provide an estimate.
+func (c *Core) FeeRate(assetID uint32) (uint64, error) {
+	wallet, found := c.wallet(assetID)
+	if !found {
+		return 0, newError(missingWalletErr, "%s wallet not found", unbip(assetID))
+	}
+	if !wallet.connected() {
+		return 0, newError(walletErr, "%s wallet is not connected", unbip(assetID))
+	}
+	feeRate, err := wallet.FeeRate()
+	if err != nil {
+		return 0, newError(walletErr, "unable to estimate %s fee rate: %v", unbip(assetID), err)
+	}
+	return feeRate, nil
+}
+
+// NewDepositAddress returns a receiving address from the asset's wallet. The
+// wallet must be connected and unlocked. Whether the address is new is up to
+// the wallet, e.g. the BTC and DCR wallets return a new address on each call,
+// so the result is not cached and the wallet's reported address is unchanged.
+func (c *Core) NewDepositAddress(assetID uint32) (string, error) {
+	wallet, found := c.wallet(assetID)
+	if !found {
+		return "", newError(missingWalletErr, "%s wallet not found", unbip(assetID))
+	}
+	if !wallet.connected() || !wallet.unlocked() {
+		return "", newError(walletAuthErr, "%s wallet must be open to get an address", unbip(assetID))
+	}
+	addr, err := wallet.Address()
+	if err != nil {
+		return "", newError(walletErr, "unable to get %s address: %v", unbip(assetID), err)
+	}
+	return addr, nil
+}
+
+// ValidateAddress checks whether the address is well-formed for the asset on
+// the client's network, e.g. before a withdraw. A wallet is not required. An
+// error is only returned if the asset is not supported.
+func (c *Core) ValidateAddress(assetID uint32, address string) (bool, error) {
+	valid, err := asset.ValidateAddress(assetID, address, c.net)
+	if err != nil {
+		return false, newError(assetSupportErr, "unable to validate %s address: %v", unbip(assetID), err)
+	}
+	return valid, nil
+}
+
+// RescanWallet starts a rescan of the blockchain for the wallet's
+// transactions, e.g. to find ones it missed. The wallet must be connected and
+// support rescanning. RescanWallet returns once the rescan is started, and the
+// rescan continues in the background. The wallet's state reports it as
+// rescanning and not synced until it is done. Only one rescan of a wallet may
+// run at a time.
+func (c *Core) RescanWallet(assetID uint32) error {
+	wallet, found := c.wallet(assetID)
+	if !found {
+		return newError(missingWalletErr, "%s wallet not found", unbip(assetID))
+	}
+	if !wallet.connected() {
+		return newError(walletErr, "%s wallet is not connected", unbip(assetID))
+	}
+	rescanner, ok := wallet.Wallet.(asset.Rescanner)
+	if !ok {
+		return newError(walletErr, "%s wallet does not support rescanning", unbip(assetID))
+	}
+	if !wallet.startRescan() {
+		return newError(walletErr, "a %s wallet rescan is already in progress", unbip(assetID))
+	}
+	c.log.Infof("Rescanning %s wallet", unbip(assetID))
+	c.notify(newWalletStateNote(wallet.state()))
+
+	go func() {
+		err := rescanner.Rescan()
+		wallet.endRescan()
+		if err != nil {
+			c.log.Errorf("%s wallet rescan error: %v", unbip(assetID), err)
+		} else {
+			c.log.Infof("Finished rescanning %s wallet", unbip(assetID))
+		}
+		if err := wallet.refreshSyncStatus(); err != nil {
+			c.log.Debugf("error getting %s wallet sync status: %v", unbip(assetID), err)
+		}
+		c.notify(newWalletStateNote(wallet.state()))
+	}()
+	return nil
+}
+
 // User is a thread-safe getter for the User.
 func (c *Core) User() *User {
 	c.userMtx.RLock()
@@ -1266,9 +1428,9 @@ func (c *Core) loadWallet(dbWallet *db.Wallet) (*xcWallet, error) {
 
 // WalletState returns the *WalletState for the asset ID.
 func (c *Core) WalletState(assetID uint32) *WalletState {
-	c.walletMtx.Lock()
-	defer c.walletMtx.Unlock()
+	c.walletMtx.RLock()
 	wallet, has := c.wallets[assetID]
+	c.walletMtx.RUnlock()
 	if !has {
 		c.log.Tracef("wallet status requested for unknown asset %d -> %s", assetID, unbip(assetID))
 		return nil
@@ -1553,6 +1715,32 @@ func (c *Core) GetFee(dexAddr, cert string) (uint64, error) {
 	return dc.cfg.Fee, nil
 }
 
+// DEXConfig returns the markets, assets and registration requirements of the
+// DEX at the specified address. If the DEX is already registered, the existing
+// connection is used. Otherwise, a temporary connection is created and closed
+// after the configuration is retrieved.
+func (c *Core) DEXConfig(dexAddr, cert string) (*Exchange, error) {
+	host, err := addrHost(dexAddr)
+	if err != nil {
+		return nil, newError(addressParseErr, "error parsing address: %v", err)
+	}
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if found {
+		return dc.exchangeInfo(host), nil
+	}
+	dc, err = c.connectDEX(&db.AccountInfo{
+		Host: host,
+		Cert: []byte(cert),
+	})
+	if err != nil {
+		return nil, newError(connectionErr, "error connecting to DEX at %s: %v", host, err)
+	}
+	defer dc.connMaster.Disconnect()
+	return dc.exchangeInfo(host), nil
+}
+
 // Register registers an account with a new DEX. If an error occurs while
 // fetching the DEX configuration or creating the fee transaction, it will be
 // returned immediately.
@@ -1790,6 +1978,138 @@ func (c *Core) InitializeClient(pw []byte) error {
 	return nil
 }
 
+// ChangeAppPass changes the app-wide password. The account private keys and
+// wallet passwords are re-encrypted with a key derived from the new password
+// and stored along with the new key parameters in a single database update.
+// Unlocked accounts and wallets remain unlocked, so there is no need to log in
+// again. A passwordErr is returned if the old password is incorrect.
+func (c *Core) ChangeAppPass(oldPW, newPW []byte) error {
+	if len(newPW) == 0 {
+		return fmt.Errorf("empty password not allowed")
+	}
+	oldCrypter, err := c.encryptionKey(oldPW)
+	if err != nil {
+		return newError(passwordErr, "ChangeAppPass password error: %v", err)
+	}
+	newCrypter := c.newCrypter(newPW)
+
+	recrypt := func(encB []byte) ([]byte, error) {
+		b, err := oldCrypter.Decrypt(encB)
+		if err != nil {
+			return nil, err
+		}
+		return newCrypter.Encrypt(b)
+	}
+
+	// Hold the wallets while the credentials are re-encrypted so that the
+	// in-memory wallet passwords are updated with those in the database. The
+	// connMtx is not held at the same time, since PromptShutdown locks the
+	// connMtx before the walletMtx.
+	acctKeys, err := c.recryptCredentials(newCrypter, recrypt)
+	if err != nil {
+		return err
+	}
+
+	c.connMtx.RLock()
+	for host, dc := range c.conns {
+		encKey, found := acctKeys[host]
+		if !found {
+			continue
+		}
+		dc.acct.keyMtx.Lock()
+		dc.acct.encKey = encKey
+		dc.acct.keyMtx.Unlock()
+	}
+	c.connMtx.RUnlock()
+
+	c.log.Infof("App password changed")
+	return nil
+}
+
+// recryptCredentials re-encrypts the account keys and wallet passwords in the
+// database with recrypt, storing newCrypter as the app's crypter, and updates
+// the wallets' in-memory passwords. The re-encrypted account keys are returned
+// so that the caller can update the connections.
+func (c *Core) recryptCredentials(newCrypter encrypt.Crypter, recrypt func([]byte) ([]byte, error)) (map[string][]byte, error) {
+	c.walletMtx.Lock()
+	defer c.walletMtx.Unlock()
+
+	accts, err := c.db.Accounts()
+	if err != nil {
+		return nil, codedError(dbErr, err)
+	}
+	acctKeys := make(map[string][]byte, len(accts))
+	for _, acct := range accts {
+		acctKeys[acct.Host], err = recrypt(acct.EncKey)
+		if err != nil {
+			return nil, newError(encryptionErr, "error re-encrypting %s account key: %v", acct.Host, err)
+		}
+	}
+	dbWallets, err := c.db.Wallets()
+	if err != nil {
+		return nil, codedError(dbErr, err)
+	}
+	walletPWs := make(map[uint32][]byte, len(dbWallets))
+	for _, dbWallet := range dbWallets {
+		if len(dbWallet.EncryptedPW) == 0 {
+			continue
+		}
+		walletPWs[dbWallet.AssetID], err = recrypt(dbWallet.EncryptedPW)
+		if err != nil {
+			return nil, newError(encryptionErr, "error re-encrypting %s wallet password: %v",
+				unbip(dbWallet.AssetID), err)
+		}
+	}
+
+	err = c.db.Recrypt(keyParamsKey, newCrypter.Serialize(), acctKeys, walletPWs)
+	if err != nil {
+		return nil, newError(dbErr, "error storing re-encrypted credentials: %v", err)
+	}
+
+	for assetID, wallet := range c.wallets {
+		encPW, found := walletPWs[assetID]
+		if !found {
+			continue
+		}
+		wallet.mtx.Lock()
+		wallet.encPW = encPW
+		wallet.mtx.Unlock()
+	}
+	return acctKeys, nil
+}
+
+// Backup writes a copy of the client database to the specified path and
+// returns the absolute path of the backup and its SHA-256 checksum. The app
+// password is required. Account keys and wallet passwords remain encrypted
+// with the app password in the backup. An existing file at the path is only
+// overwritten if overwrite is true.
+func (c *Core) Backup(appPW []byte, path string, overwrite bool) (string, []byte, error) {
+	if _, err := c.encryptionKey(appPW); err != nil {
+		return "", nil, newError(passwordErr, "Backup password error: %v", err)
+	}
+	if path == "" {
+		return "", nil, fmt.Errorf("no backup path specified")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid backup path: %w", err)
+	}
+	if err = c.db.BackupTo(path, overwrite); err != nil {
+		return "", nil, newError(dbErr, "error backing up database: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("error opening backup file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", nil, fmt.Errorf("error reading backup file: %w", err)
+	}
+	c.log.Infof("Database backed up to %s", path)
+	return path, h.Sum(nil), nil
+}
+
 // Login logs the user in, decrypting the account keys for all known DEXes.
 func (c *Core) Login(pw []byte) (*LoginResult, error) {
 	// Make sure the app has been initialized. This condition would error when
@@ -1803,7 +2123,7 @@ func (c *Core) Login(pw []byte) (*LoginResult, error) {
 
 	crypter, err := c.encryptionKey(pw)
 	if err != nil {
-		return nil, err
+		return nil, codedError(passwordErr, err)
 	}
 
 	// Attempt to connect to and retrieve balance from all known wallets. It is
@@ -1850,6 +2170,7 @@ func (c *Core) Login(pw []byte) (*LoginResult, error) {
 	}
 
 	c.refreshUser()
+	c.setLoggedIn(true)
 	result := &LoginResult{
 		Notifications: notes,
 		DEXes:         dexStats,
@@ -1885,10 +2206,82 @@ func (c *Core) Logout() error {
 	for _, dc := range c.conns {
 		dc.acct.lock()
 	}
+	c.setLoggedIn(false)
+
+	return nil
+}
+
+// DisconnectDEX closes the connection to the DEX at addr and closes its order
+// book feeds. The app password is required. If forget is true, the account is
+// also disabled so that the DEX is not connected again on the next start.
+// DisconnectDEX refuses if there are active orders with the DEX unless force is
+// true, in which case those trades will not progress while disconnected.
+func (c *Core) DisconnectDEX(pw []byte, addr string, forget, force bool) error {
+	if _, err := c.encryptionKey(pw); err != nil {
+		return newError(passwordErr, "DisconnectDEX password error: %v", err)
+	}
+	host, err := addrHost(addr)
+	if err != nil {
+		return newError(addressParseErr, "error parsing address: %v", err)
+	}
+
+	defer c.refreshUser()
+	dc, err := c.removeConn(host, forget, force)
+	if err != nil {
+		return err
+	}
 
+	// The connection is shut down without holding the connMtx, since the
+	// connection's goroutines may be waiting on it, e.g. in handleReconnect.
+	dc.closeBooks()
+	dc.connMaster.Disconnect()
+	c.log.Infof("Disconnected from %s (account forgotten = %v)", host, forget)
 	return nil
 }
 
+// removeConn removes the connection to the DEX at host from c.conns so that it
+// can be disconnected, disabling the account first if forget is true. An error
+// is returned if the DEX has active orders, unless force is true.
+func (c *Core) removeConn(host string, forget, force bool) (*dexConnection, error) {
+	c.connMtx.Lock()
+	defer c.connMtx.Unlock()
+	dc, found := c.conns[host]
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", host)
+	}
+	if dc.hasActiveOrders() && !force {
+		return nil, fmt.Errorf("cannot disconnect from %s with active orders", host)
+	}
+
+	if forget {
+		acctInfo, err := c.db.Account(host)
+		if err != nil {
+			return nil, newError(dbErr, "error retrieving account: %v", err)
+		}
+		if err = c.db.DisableAccount(acctInfo); err != nil {
+			return nil, newError(dbErr, "error disabling account: %v", err)
+		}
+	}
+
+	delete(c.conns, host)
+	return dc, nil
+}
+
+// LoggedIn reports whether the user has logged in with Login, and has not
+// since logged out.
+func (c *Core) LoggedIn() bool {
+	c.loginMtx.RLock()
+	defer c.loginMtx.RUnlock()
+	return c.loggedIn
+}
+
+// setLoggedIn sets the logged in state reported by LoggedIn.
+func (c *Core) setLoggedIn(loggedIn bool) {
+	c.loginMtx.Lock()
+	c.loggedIn = loggedIn
+	c.loginMtx.Unlock()
+}
+
 // Orders fetches a batch of user orders, filtered with the provided
 // OrderFilter.
 func (c *Core) Orders(filter *OrderFilter) ([]*Order, error) {
@@ -1900,47 +2293,339 @@ func (c *Core) Orders(filter *OrderFilter) ([]*Order, error) {
 		copy(oid[:], filter.Offset)
 	}
 
+	var mkt *db.OrderFilterMarket
+	if filter.Market != nil {
+		mkt = &db.OrderFilterMarket{
+			Base:  filter.Market.Base,
+			Quote: filter.Market.Quote,
+		}
+	}
+
+	ords, err := c.db.Orders(&db.OrderFilter{
+		N:        filter.N,
+		Offset:   oid,
+		Hosts:    filter.Hosts,
+		Assets:   filter.Assets,
+		Market:   mkt,
+		Statuses: filter.Statuses,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("UserOrders error: %v", err)
+	}
+
+	cords := make([]*Order, 0, len(ords))
+	for _, mOrd := range ords {
+		corder, err := c.coreOrderFromMetaOrder(mOrd)
+		if err != nil {
+			return nil, err
+		}
+		cords = append(cords, corder)
+	}
+
+	return cords, nil
+}
+
+// OrdersPage fetches a page of up to limit of the user's orders that pass the
+// filter, skipping the first offset. The orders are sorted by submission time,
+// newest first, so pages are stable as orders are updated. The filter's N and
+// Offset are ignored. A limit of zero returns all orders after the offset.
+func (c *Core) OrdersPage(filter *OrderFilter, offset, limit int) (*OrdersPage, error) {
+	if offset < 0 || limit < 0 {
+		return nil, fmt.Errorf("offset and limit cannot be negative")
+	}
+
+	var mkt *db.OrderFilterMarket
+	if filter.Market != nil {
+		mkt = &db.OrderFilterMarket{
+			Base:  filter.Market.Base,
+			Quote: filter.Market.Quote,
+		}
+	}
+
+	ords, total, err := c.db.OrdersPage(&db.OrderFilter{
+		Hosts:    filter.Hosts,
+		Assets:   filter.Assets,
+		Market:   mkt,
+		Statuses: filter.Statuses,
+	}, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("OrdersPage error: %w", err)
+	}
+
+	page := &OrdersPage{
+		Orders: make([]*Order, 0, len(ords)),
+		Total:  total,
+		Offset: offset,
+	}
+	for _, mOrd := range ords {
+		corder, err := c.coreOrderFromMetaOrder(mOrd)
+		if err != nil {
+			return nil, err
+		}
+		page.Orders = append(page.Orders, corder)
+	}
+	if next := offset + len(page.Orders); len(page.Orders) > 0 && next < total {
+		page.NextOffset = next
+	}
+	return page, nil
+}
+
+// coreOrderFromMetaOrder creates an *Order from a *db.MetaOrder, including
+// loading matches from the database.
+func (c *Core) coreOrderFromMetaOrder(mOrd *db.MetaOrder) (*Order, error) {
+	corder := coreOrderFromTrade(mOrd.Order, mOrd.MetaData)
+	oid := mOrd.Order.ID()
+	matches, err := c.db.MatchesForOrder(oid)
+	if err != nil {
+		return nil, fmt.Errorf("MatchesForOrder error loading matches for %s: %v", oid, err)
+	}
+	corder.Matches = make([]*Match, 0, len(matches))
+	for _, match := range matches {
+		corder.Matches = append(corder.Matches, matchFromMetaMatch(match))
+	}
+	return corder, nil
+}
+
+// Order fetches a single user order.
+func (c *Core) Order(oidB dex.Bytes) (*Order, error) {
+	if len(oidB) != order.OrderIDSize {
+		return nil, fmt.Errorf("wrong oid string length. wanted %d, got %d", order.OrderIDSize, len(oidB))
+	}
+	var oid order.OrderID
+	copy(oid[:], oidB)
+	mOrd, err := c.db.Order(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving order %s: %w", oid, err)
+	}
+	return c.coreOrderFromMetaOrder(mOrd)
+}
+
+// Matches returns the settlement state of the matches for the specified order.
+// For orders that are still being tracked, the confirmations of any unredeemed
+// swap contracts are included. An empty list is returned for an order with no
+// matches.
+func (c *Core) Matches(oidB dex.Bytes) ([]*MatchSettlement, error) {
+	if len(oidB) != order.OrderIDSize {
+		return nil, fmt.Errorf("wrong oid string length. wanted %d, got %d", order.OrderIDSize, len(oidB))
+	}
+	var oid order.OrderID
+	copy(oid[:], oidB)
+
+	var tracker *trackedTrade
+	c.connMtx.RLock()
+	for _, dc := range c.conns {
+		t, _, isCancel := dc.findOrder(oid)
+		if t != nil && !isCancel {
+			tracker = t
+			break
+		}
+	}
+	c.connMtx.RUnlock()
+	if tracker != nil {
+		return tracker.matchSettlements(), nil
+	}
+
+	mOrd, err := c.db.Order(oid)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving order %s: %w", oid, err)
+	}
+	if mOrd == nil {
+		return nil, fmt.Errorf("order %s not found", oid)
+	}
+	metaMatches, err := c.db.MatchesForOrder(oid)
+	if err != nil {
+		return nil, fmt.Errorf("MatchesForOrder error loading matches for %s: %v", oid, err)
+	}
+	settlements := make([]*MatchSettlement, 0, len(metaMatches))
+	for _, metaMatch := range metaMatches {
+		settlements = append(settlements, &MatchSettlement{
+			Match:            matchFromMetaMatch(metaMatch),
+			SwapConfs:        -1,
+			CounterSwapConfs: -1,
+		})
+	}
+	return settlements, nil
+}
+
+// MaxCandles is the most candles that Candles will return.
+const MaxCandles = 1000
+
+// candleBinSizes are the supported Candles bin sizes.
+var candleBinSizes = map[string]time.Duration{
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// CandleBinDuration returns the duration of a Candles bin size, e.g. "5m", "1h"
+// or "1d". The boolean is false if the bin size is not supported.
+func CandleBinDuration(binSize string) (time.Duration, bool) {
+	dur, found := candleBinSizes[binSize]
+	return dur, found
+}
+
+// Candles returns up to count of the most recent candles for the market,
+// oldest first. The server does not provide a trade history, so the candles
+// are built from this client's own matches on the market. Bins with no matches
+// are omitted. If count is zero or more than MaxCandles, MaxCandles is used.
+// The returned slice is empty but non-nil if there are no matches.
+func (c *Core) Candles(dex string, base, quote uint32, binSize string, count int) ([]*Candle, error) {
+	binDur, found := CandleBinDuration(binSize)
+	if !found {
+		return nil, fmt.Errorf("unsupported bin size %q", binSize)
+	}
+	if count <= 0 || count > MaxCandles {
+		count = MaxCandles
+	}
+	host, err := addrHost(dex)
+	if err != nil {
+		return nil, newError(addressParseErr, "error parsing address: %v", err)
+	}
+
 	ords, err := c.db.Orders(&db.OrderFilter{
-		N:        filter.N,
-		Offset:   oid,
-		Hosts:    filter.Hosts,
-		Assets:   filter.Assets,
-		Statuses: filter.Statuses,
+		Hosts:  []string{host},
+		Market: &db.OrderFilterMarket{Base: base, Quote: quote},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("UserOrders error: %v", err)
+		return nil, fmt.Errorf("error retrieving orders: %v", err)
 	}
-
-	cords := make([]*Order, 0, len(ords))
+	var matches []*db.MetaMatch
 	for _, mOrd := range ords {
-		corder, err := c.coreOrderFromMetaOrder(mOrd)
+		oid := mOrd.Order.ID()
+		ms, err := c.db.MatchesForOrder(oid)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error retrieving matches for order %s: %v", oid, err)
+		}
+		for _, m := range ms {
+			// Cancel order matches have no address and are not trades.
+			if m.Match.Address == "" {
+				continue
+			}
+			matches = append(matches, m)
 		}
-		cords = append(cords, corder)
 	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].MetaData.Stamp < matches[j].MetaData.Stamp
+	})
 
-	return cords, nil
+	binMs := uint64(binDur / time.Millisecond)
+	candles := make([]*Candle, 0)
+	var candle *Candle
+	for _, m := range matches {
+		stamp, rate := m.MetaData.Stamp, m.Match.Rate
+		start := stamp - stamp%binMs
+		if candle == nil || candle.StartStamp != start {
+			candle = &Candle{
+				StartStamp: start,
+				Open:       rate,
+				High:       rate,
+				Low:        rate,
+			}
+			candles = append(candles, candle)
+		}
+		if rate > candle.High {
+			candle.High = rate
+		}
+		if rate < candle.Low {
+			candle.Low = rate
+		}
+		candle.Close = rate
+		candle.Volume += m.Match.Quantity
+	}
+	if len(candles) > count {
+		candles = candles[len(candles)-count:]
+	}
+	return candles, nil
 }
 
-// coreOrderFromMetaOrder creates an *Order from a *db.MetaOrder, including
-// loading matches from the database.
-func (c *Core) coreOrderFromMetaOrder(mOrd *db.MetaOrder) (*Order, error) {
-	corder := coreOrderFromTrade(mOrd.Order, mOrd.MetaData)
-	oid := mOrd.Order.ID()
-	matches, err := c.db.MatchesForOrder(oid)
+// SignedTradeReport creates a report of the order and its matches, signed
+// with the account key for the order's DEX. The signature can be checked
+// against the included public key with (*SignedReport).Verify.
+func (c *Core) SignedTradeReport(pw []byte, oidB dex.Bytes) (*SignedReport, error) {
+	crypter, err := c.encryptionKey(pw)
 	if err != nil {
-		return nil, fmt.Errorf("MatchesForOrder error loading matches for %s: %v", oid, err)
+		return nil, codedError(passwordErr, err)
 	}
-	corder.Matches = make([]*Match, 0, len(matches))
-	for _, match := range matches {
-		corder.Matches = append(corder.Matches, matchFromMetaMatch(match))
+	corder, err := c.Order(oidB)
+	if err != nil {
+		return nil, codedError(unknownOrderErr, err)
 	}
-	return corder, nil
+
+	c.connMtx.RLock()
+	dc, found := c.conns[corder.Host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", corder.Host)
+	}
+	privKey, err := dc.acct.privateKey(crypter)
+	if err != nil {
+		return nil, newError(acctKeyErr, "error decrypting account key: %v", err)
+	}
+	pubKey := privKey.PubKey().SerializeCompressed()
+	acctID := account.NewID(pubKey)
+
+	report, err := json.Marshal(&TradeReport{
+		Host:      corder.Host,
+		AccountID: acctID[:],
+		Order:     corder,
+		Stamp:     encode.UnixMilliU(time.Now()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding trade report: %v", err)
+	}
+	hash := sha256.Sum256(report)
+	sig, err := privKey.Sign(hash[:])
+	if err != nil {
+		return nil, newError(signatureErr, "error signing trade report: %v", err)
+	}
+	return &SignedReport{
+		Report: report,
+		PubKey: pubKey,
+		Sig:    sig.Serialize(),
+	}, nil
 }
 
-// Order fetches a single user order.
-func (c *Core) Order(oidB dex.Bytes) (*Order, error) {
+// RetryPolicy returns the current redemption and refund retry policy.
+func (c *Core) RetryPolicy() *RetryPolicy {
+	p := c.retryPolicyInternal()
+	return &p
+}
+
+// retryPolicyInternal returns a copy of the retry policy in effect.
+func (c *Core) retryPolicyInternal() RetryPolicy {
+	c.retryMtx.RLock()
+	defer c.retryMtx.RUnlock()
+	if c.retryPolicy == nil {
+		return defaultRetryPolicy
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy sets the redemption and refund retry policy. The policy
+// applies to subsequent failures. Matches that have already exhausted their
+// attempts are not retried.
+func (c *Core) SetRetryPolicy(policy *RetryPolicy) error {
+	if err := policy.validate(); err != nil {
+		return newError(retryPolicyErr, "invalid retry policy: %v", err)
+	}
+	p := *policy
+	c.retryMtx.Lock()
+	c.retryPolicy = &p
+	c.retryMtx.Unlock()
+	c.log.Infof("Retry policy set: %d redeem attempts, %d refund attempts, backoff %v up to %v",
+		p.MaxRedeemAttempts, p.MaxRefundAttempts, p.Backoff, p.MaxBackoff)
+	return nil
+}
+
+// SwapCosts reconciles the estimated swap and redemption fees for each of an
+// order's matches against the fees actually paid. Estimates use the DEX's
+// swap size for the asset and the match's swap fee rate. The server does not
+// provide a redemption fee rate, so redemptions are estimated at the to-asset's
+// MaxFeeRate. Actual fees are only recorded per transaction, and a single
+// transaction may cover several matches, so the order's total swap and
+// redemption fees are split evenly across the matches that have a swap or
+// redemption coin, with any remainder going to the first such match.
+func (c *Core) SwapCosts(oidB dex.Bytes) (*SwapCostReport, error) {
 	if len(oidB) != order.OrderIDSize {
 		return nil, fmt.Errorf("wrong oid string length. wanted %d, got %d", order.OrderIDSize, len(oidB))
 	}
@@ -1948,9 +2633,88 @@ func (c *Core) Order(oidB dex.Bytes) (*Order, error) {
 	copy(oid[:], oidB)
 	mOrd, err := c.db.Order(oid)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving order %s: %w", oid, err)
+		return nil, newError(unknownOrderErr, "error retrieving order %s: %v", oid, err)
 	}
-	return c.coreOrderFromMetaOrder(mOrd)
+	corder, err := c.coreOrderFromMetaOrder(mOrd)
+	if err != nil {
+		return nil, err
+	}
+
+	c.connMtx.RLock()
+	dc, found := c.conns[corder.Host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", corder.Host)
+	}
+
+	fromID, toID := corder.QuoteID, corder.BaseID
+	if corder.Sell {
+		fromID, toID = toID, fromID
+	}
+	dc.assetsMtx.RLock()
+	fromAsset, toAsset := dc.assets[fromID], dc.assets[toID]
+	dc.assetsMtx.RUnlock()
+	if fromAsset == nil || toAsset == nil {
+		return nil, newError(assetSupportErr, "asset configuration not found for market %s", corder.MarketID)
+	}
+
+	report := &SwapCostReport{
+		OrderID: oid[:],
+		FromID:  fromID,
+		ToID:    toID,
+		Matches: make([]*MatchCost, 0, len(corder.Matches)),
+		Est:     new(FeeBreakdown),
+		Actual:  new(FeeBreakdown),
+	}
+	if corder.FeesPaid != nil {
+		report.Actual.Swap = corder.FeesPaid.Swap
+		report.Actual.Redemption = corder.FeesPaid.Redemption
+	}
+
+	var swapped, redeemed []*MatchCost
+	for _, match := range corder.Matches {
+		if match.IsCancel {
+			continue
+		}
+		cost := &MatchCost{
+			MatchID:       match.MatchID,
+			EstSwap:       fromAsset.SwapSize * match.FeeRate,
+			EstRedemption: toAsset.SwapSize * toAsset.MaxFeeRate,
+		}
+		report.Est.Swap += cost.EstSwap
+		report.Est.Redemption += cost.EstRedemption
+		if len(match.Swap) > 0 {
+			swapped = append(swapped, cost)
+		}
+		if len(match.Redeem) > 0 {
+			redeemed = append(redeemed, cost)
+		}
+		report.Matches = append(report.Matches, cost)
+	}
+
+	// Split the recorded fees across the matches that paid them.
+	apportion := func(costs []*MatchCost, total uint64, set func(*MatchCost, uint64)) {
+		if len(costs) == 0 {
+			return
+		}
+		share := total / uint64(len(costs))
+		for _, cost := range costs {
+			set(cost, share)
+		}
+		set(costs[0], share+total%uint64(len(costs)))
+	}
+	apportion(swapped, report.Actual.Swap, func(cost *MatchCost, fees uint64) {
+		cost.ActualSwap = fees
+	})
+	apportion(redeemed, report.Actual.Redemption, func(cost *MatchCost, fees uint64) {
+		cost.ActualRedemption = fees
+	})
+
+	for _, cost := range report.Matches {
+		cost.SwapDiff = int64(cost.ActualSwap) - int64(cost.EstSwap)
+		cost.RedemptionDiff = int64(cost.ActualRedemption) - int64(cost.EstRedemption)
+	}
+	return report, nil
 }
 
 // initializeDEXConnections connects to the DEX servers in the conns map and
@@ -2126,7 +2890,7 @@ func (c *Core) wait(assetID uint32, trigger func() (bool, error), action func(er
 
 func (c *Core) notifyFee(dc *dexConnection, coinID []byte) error {
 	if dc.acct.locked() {
-		return fmt.Errorf("%s account locked. cannot notify fee. log in first", dc.acct.host)
+		return newError(loginRequiredErr, "%s account locked. cannot notify fee. log in first", dc.acct.host)
 	}
 	// Notify the server of the fee coin once there are enough confirmations.
 	req := &msgjson.NotifyFee{
@@ -2182,14 +2946,14 @@ func (c *Core) notifyFee(dc *dexConnection, coinID []byte) error {
 func (c *Core) Withdraw(pw []byte, assetID uint32, value uint64, address string) (asset.Coin, error) {
 	crypter, err := c.encryptionKey(pw)
 	if err != nil {
-		return nil, fmt.Errorf("Withdraw password error: %v", err)
+		return nil, newError(passwordErr, "Withdraw password error: %v", err)
 	}
 	if value == 0 {
 		return nil, fmt.Errorf("%s zero withdraw", unbip(assetID))
 	}
 	wallet, found := c.wallet(assetID)
 	if !found {
-		return nil, fmt.Errorf("%s wallet not found", unbip(assetID))
+		return nil, newError(missingWalletErr, "%s wallet not found", unbip(assetID))
 	}
 	err = c.connectAndUnlock(crypter, wallet)
 	if err != nil {
@@ -2199,6 +2963,9 @@ func (c *Core) Withdraw(pw []byte, assetID uint32, value uint64, address string)
 	if err != nil {
 		details := fmt.Sprintf("Error encountered during %s withdraw: %v", unbip(assetID), err)
 		c.notify(newWithdrawNote("Withdraw error", details, db.ErrorLevel))
+		if errors.Is(err, asset.InsufficientFundsError) {
+			return nil, codedError(insufficientFundsErr, err)
+		}
 		return nil, err
 	}
 
@@ -2209,12 +2976,62 @@ func (c *Core) Withdraw(pw []byte, assetID uint32, value uint64, address string)
 	return coin, nil
 }
 
+// Settings returns the trading limits that are checked before an order is
+// submitted.
+func (c *Core) Settings() *TradeSettings {
+	s := c.tradeSettings()
+	return &s
+}
+
+// tradeSettings returns a copy of the trading limits in effect.
+func (c *Core) tradeSettings() TradeSettings {
+	c.settingsMtx.RLock()
+	defer c.settingsMtx.RUnlock()
+	return c.settings
+}
+
+// SetSettings sets and saves the trading limits. The limits apply to orders
+// submitted afterwards.
+func (c *Core) SetSettings(settings *TradeSettings) error {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return newError(settingsErr, "error encoding settings: %v", err)
+	}
+	c.settingsMtx.Lock()
+	defer c.settingsMtx.Unlock()
+	if err = c.db.Store(tradeSettingsKey, b); err != nil {
+		return newError(dbErr, "error saving settings: %v", err)
+	}
+	c.settings = *settings
+	c.log.Infof("Trade settings set: max fee rate %d, max order lots %d",
+		settings.MaxFeeRate, settings.MaxOrderLots)
+	return nil
+}
+
+// loadSettings loads the trading limits saved by SetSettings, if any.
+func (c *Core) loadSettings() error {
+	exists, err := c.db.ValueExists(tradeSettingsKey)
+	if err != nil || !exists {
+		return err
+	}
+	b, err := c.db.Get(tradeSettingsKey)
+	if err != nil {
+		return fmt.Errorf("error loading trade settings: %w", err)
+	}
+	c.settingsMtx.Lock()
+	defer c.settingsMtx.Unlock()
+	if err = json.Unmarshal(b, &c.settings); err != nil {
+		return fmt.Errorf("error decoding trade settings: %w", err)
+	}
+	return nil
+}
+
 // Trade is used to place a market or limit order.
 func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	// Check the user password.
 	crypter, err := c.encryptionKey(pw)
 	if err != nil {
-		return nil, fmt.Errorf("Trade password error: %v", err)
+		return nil, newError(passwordErr, "Trade password error: %v", err)
 	}
 	host, err := addrHost(form.Host)
 	if err != nil {
@@ -2231,7 +3048,7 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	}
 
 	if dc.acct.locked() {
-		return nil, fmt.Errorf("cannot place order on a locked %s account. Are you logged in?", dc.acct.host)
+		return nil, newError(loginRequiredErr, "cannot place order on a locked %s account. Are you logged in?", dc.acct.host)
 	}
 
 	if !connected {
@@ -2252,6 +3069,237 @@ func (c *Core) Trade(pw []byte, form *TradeForm) (*Order, error) {
 	return corder, nil
 }
 
+// MarketInfo returns the configuration and status of the market at the DEX,
+// without the rest of the exchange's markets and assets.
+func (c *Core) MarketInfo(dex string, base, quote uint32) (*MarketInfo, error) {
+	host, err := addrHost(dex)
+	if err != nil {
+		return nil, newError(addressParseErr, "error parsing address: %v", err)
+	}
+
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", dex)
+	}
+
+	mktID := marketName(base, quote)
+	var mkt msgjson.Market
+	dc.cfgMtx.RLock()
+	mktCfg := dc.marketConfig(mktID)
+	if mktCfg != nil {
+		mkt = *mktCfg
+	}
+	dc.cfgMtx.RUnlock()
+	if mktCfg == nil {
+		return nil, newError(unknownMarketErr, "unknown market %q at %s", mktID, host)
+	}
+
+	dc.assetsMtx.RLock()
+	baseAsset, quoteAsset := dc.assets[base], dc.assets[quote]
+	dc.assetsMtx.RUnlock()
+	if baseAsset == nil || quoteAsset == nil {
+		return nil, newError(assetSupportErr, "asset configuration not found for market %s", mktID)
+	}
+
+	return &MarketInfo{
+		Host:            host,
+		Name:            mktID,
+		BaseID:          base,
+		BaseSymbol:      baseAsset.Symbol,
+		QuoteID:         quote,
+		QuoteSymbol:     quoteAsset.Symbol,
+		LotSize:         baseAsset.LotSize,
+		RateStep:        quoteAsset.RateStep,
+		EpochLen:        mkt.EpochLen,
+		MarketBuyBuffer: mkt.MarketBuyBuffer,
+		Running:         mkt.Running(),
+		StartEpoch:      mkt.StartEpoch,
+		FinalEpoch:      mkt.FinalEpoch,
+	}, nil
+}
+
+// FeesPaid sums the fees paid at the DEX for orders placed since the UNIX
+// time sinceUnix, in seconds, by asset and type of fee. A sinceUnix of zero is
+// all-time. Swap fees are paid in the asset an order is selling and redemption
+// fees in the asset it is buying. Every asset supported by the DEX is listed,
+// with zero totals if nothing was paid in it. The amount and time of the
+// registration fee payment are not recorded, so the DEX's current fee is
+// reported for a paid account, and only for all-time.
+func (c *Core) FeesPaid(dexAddr string, sinceUnix int64) (*FeesPaid, error) {
+	if sinceUnix < 0 {
+		return nil, fmt.Errorf("invalid since time %d", sinceUnix)
+	}
+	host, err := addrHost(dexAddr)
+	if err != nil {
+		return nil, newError(addressParseErr, "error parsing address: %v", err)
+	}
+
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", dexAddr)
+	}
+
+	fees := &FeesPaid{
+		Host:   host,
+		Since:  sinceUnix,
+		Assets: make(map[uint32]*AssetFees),
+	}
+	assetFees := func(assetID uint32) *AssetFees {
+		af, found := fees.Assets[assetID]
+		if !found {
+			af = &AssetFees{Symbol: unbip(assetID)}
+			fees.Assets[assetID] = af
+		}
+		return af
+	}
+	dc.assetsMtx.RLock()
+	for assetID, a := range dc.assets {
+		fees.Assets[assetID] = &AssetFees{Symbol: a.Symbol}
+	}
+	dc.assetsMtx.RUnlock()
+
+	if sinceUnix == 0 && dc.acct.feePaid() {
+		regFeeAssetID, _ := dex.BipSymbolID(regFeeAssetSymbol)
+		dc.cfgMtx.RLock()
+		assetFees(regFeeAssetID).Registration = dc.cfg.Fee
+		dc.cfgMtx.RUnlock()
+	}
+
+	ords, err := c.db.AccountOrders(host, 0, uint64(sinceUnix)*1000)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving orders for %s: %v", host, err)
+	}
+	for _, ord := range ords {
+		trade := ord.Order.Trade()
+		if trade == nil { // cancel orders pay no fees
+			continue
+		}
+		fromID, toID := ord.Order.Quote(), ord.Order.Base()
+		if trade.Sell {
+			fromID, toID = toID, fromID
+		}
+		assetFees(fromID).Swap += ord.MetaData.SwapFeesPaid
+		assetFees(toID).Redemption += ord.MetaData.RedemptionFeesPaid
+	}
+	return fees, nil
+}
+
+// PreOrder checks the parameters of a prospective order against the market's
+// lot size and rate step and estimates the fees that the order would incur.
+// Orders that would be rejected by Trade for their quantity, their rate or the
+// TradeSettings are rejected here with an explanation, so the form can be corrected before any
+// funds are locked. The swap and redemption fees are worst-case estimates,
+// assuming each lot is matched separately at the asset's max fee rate.
+func (c *Core) PreOrder(form *TradeForm) (*OrderEstimate, error) {
+	host, err := addrHost(form.Host)
+	if err != nil {
+		return nil, newError(addressParseErr, "error parsing address: %v", err)
+	}
+
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", form.Host)
+	}
+
+	mktID := marketName(form.Base, form.Quote)
+	if dc.market(mktID) == nil {
+		return nil, newError(marketErr, "unknown market %q", mktID)
+	}
+
+	dc.assetsMtx.RLock()
+	baseAsset, quoteAsset := dc.assets[form.Base], dc.assets[form.Quote]
+	dc.assetsMtx.RUnlock()
+	if baseAsset == nil || quoteAsset == nil {
+		return nil, newError(assetSupportErr, "asset configuration not found for market %s", mktID)
+	}
+
+	if form.IsLimit {
+		if form.Rate == 0 {
+			return nil, newError(orderParamsErr, "zero-rate order not allowed")
+		}
+		if rem := form.Rate % quoteAsset.RateStep; rem != 0 {
+			lower := form.Rate - rem
+			return nil, newError(orderParamsErr, "rate %d is not a multiple of the rate step %d. "+
+				"Try %d or %d", form.Rate, quoteAsset.RateStep, lower, lower+quoteAsset.RateStep)
+		}
+	}
+	if err = checkOrderQty(form, baseAsset); err != nil {
+		return nil, err
+	}
+
+	lots := form.Qty / baseAsset.LotSize
+	if !form.IsLimit && !form.Sell {
+		// Market buy quantities are in units of the quote asset. Estimate the
+		// lots from the book as Trade does, falling back to a single lot.
+		lots = 1
+		dc.booksMtx.RLock()
+		book, found := dc.books[mktID]
+		dc.booksMtx.RUnlock()
+		if found {
+			if midGap, err := book.MidGap(); err == nil {
+				baseQty := calc.QuoteToBase(midGap, form.Qty)
+				lots = baseQty / baseAsset.LotSize
+				if lots == 0 {
+					return nil, newError(orderParamsErr, "order quantity is too low for current market rates. "+
+						"qty = %d %s, mid-gap = %d, base-qty = %d %s, minimum order = %d %s",
+						form.Qty, quoteAsset.Symbol, midGap, baseQty, baseAsset.Symbol,
+						baseAsset.LotSize, baseAsset.Symbol)
+				}
+			}
+		}
+	}
+
+	fromAsset, toAsset := baseAsset, quoteAsset
+	if !form.Sell {
+		fromAsset, toAsset = quoteAsset, baseAsset
+	}
+	settings := c.tradeSettings()
+	if err := settings.check(fromAsset, lots); err != nil {
+		return nil, codedError(orderParamsErr, err)
+	}
+	return &OrderEstimate{
+		Host:     host,
+		MarketID: mktID,
+		FromID:   fromAsset.ID,
+		ToID:     toAsset.ID,
+		LotSize:  baseAsset.LotSize,
+		RateStep: quoteAsset.RateStep,
+		MinOrder: baseAsset.LotSize,
+		Lots:     lots,
+		Fees: &FeeBreakdown{
+			Swap:       lots * fromAsset.SwapSize * fromAsset.MaxFeeRate,
+			Redemption: lots * toAsset.SwapSize * toAsset.MaxFeeRate,
+		},
+	}, nil
+}
+
+// checkOrderQty checks that the quantity of a limit or market sell order is a
+// non-zero multiple of the lot size, suggesting the nearest valid quantities if
+// it is not. Market buy quantities are in units of the quote asset and are not
+// checked here.
+func checkOrderQty(form *TradeForm, baseAsset *dex.Asset) error {
+	if !form.IsLimit && !form.Sell {
+		return nil
+	}
+	lotSize := baseAsset.LotSize
+	if form.Qty < lotSize {
+		return newError(orderParamsErr, "order quantity %d %s is less than the minimum order of 1 lot (%d %s)",
+			form.Qty, baseAsset.Symbol, lotSize, baseAsset.Symbol)
+	}
+	if rem := form.Qty % lotSize; rem != 0 {
+		lower := form.Qty - rem
+		return newError(orderParamsErr, "order quantity %d %s is not a multiple of the lot size %d. Try %d or %d",
+			form.Qty, baseAsset.Symbol, lotSize, lower, lower+lotSize)
+	}
+	return nil
+}
+
 // Send an order, process result, prepare and store the trackedTrade.
 func (c *Core) prepareTrackedTrade(dc *dexConnection, form *TradeForm, crypter encrypt.Crypter) (*Order, uint32, error) {
 	mktID := marketName(form.Base, form.Quote)
@@ -2276,6 +3324,11 @@ func (c *Core) prepareTrackedTrade(dc *dexConnection, form *TradeForm, crypter e
 		return nil, 0, err
 	}
 
+	// Catch a bad quantity before any funds are locked.
+	if err = checkOrderQty(form, wallets.baseAsset); err != nil {
+		return nil, 0, err
+	}
+
 	fromWallet, toWallet := wallets.fromWallet, wallets.toWallet
 	err = c.connectAndUnlock(crypter, fromWallet)
 	if err != nil {
@@ -2337,6 +3390,11 @@ func (c *Core) prepareTrackedTrade(dc *dexConnection, form *TradeForm, crypter e
 			qty, wallets.baseAsset.Symbol, rate, wallets.baseAsset.LotSize)
 	}
 
+	settings := c.tradeSettings()
+	if err := settings.check(wallets.fromAsset, lots); err != nil {
+		return nil, 0, codedError(orderParamsErr, err)
+	}
+
 	coins, redeemScripts, err := fromWallet.FundOrder(&asset.Order{
 		Value:        fundQty,
 		MaxSwapCount: lots,
@@ -2344,7 +3402,11 @@ func (c *Core) prepareTrackedTrade(dc *dexConnection, form *TradeForm, crypter e
 		Immediate:    isImmediate,
 	})
 	if err != nil {
-		return nil, 0, codedError(walletErr, fmt.Errorf("FundOrder error for %s, funding quantity %d (%d lots): %w",
+		code := walletErr
+		if errors.Is(err, asset.InsufficientFundsError) {
+			code = insufficientFundsErr
+		}
+		return nil, 0, codedError(code, fmt.Errorf("FundOrder error for %s, funding quantity %d (%d lots): %w",
 			wallets.fromAsset.Symbol, fundQty, lots, err))
 	}
 	coinIDs := make([]order.CoinID, 0, len(coins))
@@ -2510,11 +3572,11 @@ func (c *Core) walletSet(dc *dexConnection, baseID, quoteID uint32, sell bool) (
 	// Connect and open the wallets if needed.
 	baseWallet, found := c.wallet(baseID)
 	if !found {
-		return nil, fmt.Errorf("%s wallet not found", unbip(baseID))
+		return nil, newError(missingWalletErr, "%s wallet not found", unbip(baseID))
 	}
 	quoteWallet, found := c.wallet(quoteID)
 	if !found {
-		return nil, fmt.Errorf("%s wallet not found", unbip(quoteID))
+		return nil, newError(missingWalletErr, "%s wallet not found", unbip(quoteID))
 	}
 
 	// We actually care less about base/quote, and more about from/to, which
@@ -2541,7 +3603,7 @@ func (c *Core) Cancel(pw []byte, oidB dex.Bytes) error {
 	// Check the user password.
 	_, err := c.encryptionKey(pw)
 	if err != nil {
-		return fmt.Errorf("Cancel password error: %v", err)
+		return newError(passwordErr, "Cancel password error: %v", err)
 	}
 
 	if len(oidB) != order.OrderIDSize {
@@ -3293,8 +4355,11 @@ func (c *Core) connectDEX(acctInfo *db.AccountInfo) (*dexConnection, error) {
 		URL:      wsURL.String(),
 		PingWait: 20 * time.Second, // larger than server's pingPeriod (server/comms/server.go)
 		Cert:     acctInfo.Cert,
+		// Resynchronize before the WsConn delivers any notifications
+		// received on the new connection, so book updates are applied to
+		// the fresh snapshots.
 		ReconnectSync: func() {
-			go c.handleReconnect(host)
+			c.handleReconnect(host)
 		},
 		ConnectEventFunc: func(connected bool) {
 			go c.handleConnectEvent(host, connected)
@@ -3454,7 +4519,8 @@ func handleMatchProofMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error
 	}
 
 	// Expire the epoch
-	if dc.setEpoch(note.MarketID, note.Epoch+1) {
+	newEpoch := dc.setEpoch(note.MarketID, note.Epoch+1)
+	if newEpoch {
 		c.refreshUser()
 	}
 
@@ -3470,7 +4536,35 @@ func handleMatchProofMsg(c *Core, dc *dexConnection, msg *msgjson.Message) error
 	// Reset the epoch queue after processing the match proof message.
 	defer book.ResetEpoch()
 
-	return book.ValidateMatchProof(note)
+	if err := book.ValidateMatchProof(note); err != nil {
+		return err
+	}
+
+	misses := make([]string, 0, len(note.Misses))
+	for _, oid := range note.Misses {
+		misses = append(misses, token(oid))
+	}
+	book.send(&BookUpdate{
+		Action:   MatchProofAction,
+		Host:     dc.acct.host,
+		MarketID: note.MarketID,
+		Payload: &MatchProofUpdate{
+			Epoch:     note.Epoch,
+			Preimages: len(note.Preimages),
+			Misses:    misses,
+			CSum:      note.CSum,
+			Seed:      note.Seed,
+		},
+	})
+	if newEpoch {
+		book.send(&BookUpdate{
+			Action:   NewEpochAction,
+			Host:     dc.acct.host,
+			MarketID: note.MarketID,
+			Payload:  &EpochUpdate{Epoch: note.Epoch + 1},
+		})
+	}
+	return nil
 }
 
 // handleRevokeOrderMsg is called when a revoke_order message is received.
@@ -3944,6 +5038,12 @@ func (c *Core) tipChange(assetID uint32, nodeErr error) {
 		return
 	}
 	c.log.Tracef("processing tip change for %s", unbip(assetID))
+	if wallet, found := c.wallet(assetID); found {
+		if err := wallet.refreshSyncStatus(); err != nil {
+			c.log.Debugf("error getting %s wallet sync status: %v", unbip(assetID), err)
+		}
+		c.refreshPeers(wallet)
+	}
 	c.waiterMtx.Lock()
 	for id, waiter := range c.blockWaiters {
 		if waiter.assetID != assetID {
@@ -4088,6 +5188,51 @@ func stampAndSign(privKey *secp256k1.PrivateKey, payload msgjson.Stampable) erro
 	return sign(privKey, payload)
 }
 
+// SendRaw sends a request with the route and payload to the DEX server and
+// returns the response payload as received, including any error from the
+// server. It is meant for debugging and for server routes that Core does not
+// wrap. Core's checks and state tracking are bypassed, so a request that changes
+// the account's state on the server, e.g. an order or a swap step, can leave
+// Core out of sync with the server.
+func (c *Core) SendRaw(dex, route string, payload json.RawMessage) (*msgjson.ResponsePayload, error) {
+	host, err := addrHost(dex)
+	if err != nil {
+		return nil, newError(addressParseErr, "error parsing address: %v", err)
+	}
+	c.connMtx.RLock()
+	dc, found := c.conns[host]
+	c.connMtx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown DEX %s", host)
+	}
+
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	}
+	reqMsg, err := msgjson.NewRequest(dc.NextID(), route, payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding %q request: %w", route, err)
+	}
+	c.log.Warnf("Sending raw %q request to %s. Core's checks are bypassed.", route, host)
+
+	type rawResult struct {
+		resp *msgjson.ResponsePayload
+		err  error
+	}
+	resChan := make(chan *rawResult, 1)
+	err = dc.RequestWithTimeout(reqMsg, func(msg *msgjson.Message) {
+		resp, err := msg.Response()
+		resChan <- &rawResult{resp, err}
+	}, DefaultResponseTimeout, func() {
+		resChan <- &rawResult{err: fmt.Errorf("timed out waiting for %q response", route)}
+	})
+	if err != nil {
+		return nil, codedError(connectionErr, err)
+	}
+	res := <-resChan
+	return res.resp, res.err
+}
+
 // sendRequest sends a request via the specified ws connection and unmarshals
 // the response into the provided interface.
 func sendRequest(conn comms.WsConn, route string, request, response interface{}, timeout time.Duration) error {
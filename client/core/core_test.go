@@ -10,7 +10,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -228,6 +230,9 @@ func (conn *TWebsocket) Send(msg *msgjson.Message) error { return conn.sendErr }
 func (conn *TWebsocket) Request(msg *msgjson.Message, f msgFunc) error {
 	return conn.RequestWithTimeout(msg, f, 0, func() {})
 }
+func (conn *TWebsocket) RequestWithRetry(msg *msgjson.Message, f func(*msgjson.Message), expireTime time.Duration, expire func(), _ bool) error {
+	return conn.RequestWithTimeout(msg, f, expireTime, expire)
+}
 func (conn *TWebsocket) RequestWithTimeout(msg *msgjson.Message, f func(*msgjson.Message), _ time.Duration, _ func()) error {
 	if conn.reqErr != nil {
 		return conn.reqErr
@@ -243,9 +248,17 @@ func (conn *TWebsocket) RequestWithTimeout(msg *msgjson.Message, f func(*msgjson
 	return fmt.Errorf("no handler for route %q", msg.Route)
 }
 func (conn *TWebsocket) MessageSource() <-chan *msgjson.Message { return conn.msgs }
+func (conn *TWebsocket) Stats() comms.ConnStats                 { return comms.ConnStats{} }
+func (conn *TWebsocket) Status() comms.ConnStatus               { return comms.ConnStatus{} }
 func (conn *TWebsocket) IsDown() bool {
 	return false
 }
+func (conn *TWebsocket) WaitForConnect(context.Context) error {
+	return nil
+}
+func (conn *TWebsocket) Close(string) error {
+	return nil
+}
 func (conn *TWebsocket) Connect(context.Context) (*sync.WaitGroup, error) {
 	return &sync.WaitGroup{}, conn.connectErr
 }
@@ -260,6 +273,8 @@ type TDB struct {
 	accts              []*db.AccountInfo
 	updateOrderErr     error
 	activeDEXOrders    []*db.MetaOrder
+	orders             []*db.MetaOrder
+	ordersErr          error
 	matchesForOID      []*db.MetaMatch
 	matchesForOIDErr   error
 	activeMatchOIDs    []order.OrderID
@@ -274,6 +289,17 @@ type TDB struct {
 	linkedFromID       order.OrderID
 	linkedToID         order.OrderID
 	existValues        map[string]bool
+	stored             map[string][]byte
+	notes              []*db.Notification
+	notesErr           error
+	notesN             int
+	wallets            []*db.Wallet
+	recryptErr         error
+	recryptKeyParams   []byte
+	recryptAcctKeys    map[string][]byte
+	recryptWalletPWs   map[uint32][]byte
+	backupData         []byte
+	backupErr          error
 }
 
 func (tdb *TDB) Run(context.Context) {}
@@ -312,7 +338,7 @@ func (tdb *TDB) ActiveOrders() ([]*db.MetaOrder, error) {
 }
 
 func (tdb *TDB) AccountOrders(dex string, n int, since uint64) ([]*db.MetaOrder, error) {
-	return nil, nil
+	return tdb.orders, tdb.ordersErr
 }
 
 func (tdb *TDB) Order(oid order.OrderID) (*db.MetaOrder, error) {
@@ -323,7 +349,21 @@ func (tdb *TDB) Order(oid order.OrderID) (*db.MetaOrder, error) {
 }
 
 func (tdb *TDB) Orders(*db.OrderFilter) ([]*db.MetaOrder, error) {
-	return nil, nil
+	return tdb.orders, tdb.ordersErr
+}
+
+func (tdb *TDB) OrdersPage(_ *db.OrderFilter, offset, limit int) ([]*db.MetaOrder, int, error) {
+	if tdb.ordersErr != nil {
+		return nil, 0, tdb.ordersErr
+	}
+	if offset >= len(tdb.orders) {
+		return nil, len(tdb.orders), nil
+	}
+	ords := tdb.orders[offset:]
+	if limit > 0 && limit < len(ords) {
+		ords = ords[:limit]
+	}
+	return ords, len(tdb.orders), nil
 }
 
 func (tdb *TDB) MarketOrders(dex string, base, quote uint32, n int, since uint64) ([]*db.MetaOrder, error) {
@@ -376,7 +416,17 @@ func (tdb *TDB) UpdateBalance(wid []byte, balance *db.Balance) error {
 }
 
 func (tdb *TDB) Wallets() ([]*db.Wallet, error) {
-	return nil, nil
+	return tdb.wallets, nil
+}
+
+func (tdb *TDB) Recrypt(k string, v []byte, acctKeys map[string][]byte, walletPWs map[uint32][]byte) error {
+	if tdb.recryptErr != nil {
+		return tdb.recryptErr
+	}
+	tdb.recryptKeyParams = v
+	tdb.recryptAcctKeys = acctKeys
+	tdb.recryptWalletPWs = walletPWs
+	return nil
 }
 
 func (tdb *TDB) Wallet([]byte) (*db.Wallet, error) {
@@ -387,11 +437,21 @@ func (tdb *TDB) AccountPaid(proof *db.AccountProof) error {
 	return nil
 }
 
-func (tdb *TDB) SaveNotification(*db.Notification) error        { return nil }
-func (tdb *TDB) NotificationsN(int) ([]*db.Notification, error) { return nil, nil }
+func (tdb *TDB) SaveNotification(*db.Notification) error { return nil }
+func (tdb *TDB) NotificationsN(n int) ([]*db.Notification, error) {
+	tdb.notesN = n
+	return tdb.notes, tdb.notesErr
+}
 
 func (tdb *TDB) Store(k string, b []byte) error {
-	return tdb.storeErr
+	if tdb.storeErr != nil {
+		return tdb.storeErr
+	}
+	if tdb.stored == nil {
+		tdb.stored = make(map[string][]byte)
+	}
+	tdb.stored[k] = b
+	return nil
 }
 
 func (tdb *TDB) ValueExists(k string) (bool, error) {
@@ -402,13 +462,20 @@ func (tdb *TDB) Get(k string) ([]byte, error) {
 	if k == keyParamsKey {
 		return nil, tdb.encKeyErr
 	}
-	return nil, tdb.getErr
+	return tdb.stored[k], tdb.getErr
 }
 
 func (tdb *TDB) Backup() error {
 	return nil
 }
 
+func (tdb *TDB) BackupTo(dst string, overwrite bool) error {
+	if tdb.backupErr != nil {
+		return tdb.backupErr
+	}
+	return ioutil.WriteFile(dst, tdb.backupData, 0600)
+}
+
 func (tdb *TDB) AckNotification(id []byte) error { return nil }
 
 type tCoin struct {
@@ -488,6 +555,7 @@ type TXCWallet struct {
 	mtx               sync.RWMutex
 	payFeeCoin        *tCoin
 	payFeeErr         error
+	addr              string
 	addrErr           error
 	signCoinErr       error
 	lastSwaps         *asset.Swaps
@@ -510,11 +578,22 @@ type TXCWallet struct {
 	fundingCoinErr    error
 	lockErr           error
 	changeCoin        *tCoin
+	feeRate           uint64
+	feeRateErr        error
+	confs             uint32
+	confsErr          error
+	syncStatus        *asset.SyncStatus
+	syncStatusErr     error
+	peerCount         uint32
+	peerCountErr      error
+	rescanErr         error
+	rescanWait        chan struct{}
 }
 
 func newTWallet(assetID uint32) (*xcWallet, *TXCWallet) {
 	w := &TXCWallet{
 		changeCoin: &tCoin{id: encode.RandomBytes(36)},
+		feeRate:    24,
 	}
 	return &xcWallet{
 		Wallet:    w,
@@ -548,7 +627,7 @@ func (w *TXCWallet) Balance() (*asset.Balance, error) {
 }
 
 func (w *TXCWallet) FeeRate() (uint64, error) {
-	return 24, nil
+	return w.feeRate, w.feeRateErr
 }
 
 func (w *TXCWallet) FundOrder(ord *asset.Order) (asset.Coins, []dex.Bytes, error) {
@@ -611,7 +690,7 @@ func (w *TXCWallet) Refund(dex.Bytes, dex.Bytes) (dex.Bytes, error) {
 }
 
 func (w *TXCWallet) Address() (string, error) {
-	return "", w.addrErr
+	return w.addr, w.addrErr
 }
 
 func (w *TXCWallet) Unlock(pw string, dur time.Duration) error {
@@ -629,7 +708,7 @@ func (w *TXCWallet) Send(address string, fee uint64, _ *dex.Asset) (asset.Coin,
 }
 
 func (w *TXCWallet) Confirmations(id dex.Bytes) (uint32, error) {
-	return 0, nil
+	return w.confs, w.confsErr
 }
 
 func (w *TXCWallet) ConfirmTime(id dex.Bytes, nConfs uint32) (time.Time, error) {
@@ -644,6 +723,21 @@ func (w *TXCWallet) Withdraw(address string, value uint64) (asset.Coin, error) {
 	return w.payFeeCoin, w.payFeeErr
 }
 
+func (w *TXCWallet) SyncStatus() (*asset.SyncStatus, error) {
+	return w.syncStatus, w.syncStatusErr
+}
+
+func (w *TXCWallet) PeerCount() (uint32, error) {
+	return w.peerCount, w.peerCountErr
+}
+
+func (w *TXCWallet) Rescan() error {
+	if w.rescanWait != nil {
+		<-w.rescanWait
+	}
+	return w.rescanErr
+}
+
 func (w *TXCWallet) ValidateSecret(secret, secretHash []byte) bool {
 	return !w.badSecret
 }
@@ -1106,6 +1200,58 @@ func (drv *tDriver) Info() *asset.WalletInfo {
 	return drv.winfo
 }
 
+// tValidatingDriver is a tDriver that satisfies asset.AddressValidator.
+type tValidatingDriver struct {
+	tDriver
+	network dex.Network
+}
+
+func (drv *tValidatingDriver) ValidateAddress(address string, net dex.Network) bool {
+	drv.network = net
+	return address == "valid"
+}
+
+func TestValidateAddress(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	const validatingID, nonValidatingID = 65432, 65433
+	drv := &tValidatingDriver{}
+	asset.Register(validatingID, drv)
+	asset.Register(nonValidatingID, &tDriver{})
+
+	valid, err := tCore.ValidateAddress(validatingID, "valid")
+	if err != nil {
+		t.Fatalf("ValidateAddress error: %v", err)
+	}
+	if !valid {
+		t.Fatalf("valid address reported invalid")
+	}
+	if drv.network != tCore.net {
+		t.Fatalf("wrong network %s, wanted %s", drv.network, tCore.net)
+	}
+
+	// An invalid address is not an error.
+	valid, err = tCore.ValidateAddress(validatingID, "invalid")
+	if err != nil {
+		t.Fatalf("ValidateAddress error for an invalid address: %v", err)
+	}
+	if valid {
+		t.Fatalf("invalid address reported valid")
+	}
+
+	// Unknown asset
+	_, err = tCore.ValidateAddress(12345, "valid")
+	if !errorHasCode(err, assetSupportErr) {
+		t.Fatalf("wrong error for unknown asset: %v", err)
+	}
+
+	// Driver that cannot validate addresses
+	_, err = tCore.ValidateAddress(nonValidatingID, "valid")
+	if !errorHasCode(err, assetSupportErr) {
+		t.Fatalf("wrong error for non-validating driver: %v", err)
+	}
+}
+
 func TestCreateWallet(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
@@ -1230,6 +1376,98 @@ func TestGetFee(t *testing.T) {
 	}
 }
 
+func TestDEXConfig(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	// DEX already registered uses the existing connection.
+	xc, err := tCore.DEXConfig(tDexHost, "")
+	if err != nil {
+		t.Fatalf("DEXConfig error for registered DEX: %v", err)
+	}
+	if xc.Host != tDexHost || len(xc.Markets) == 0 {
+		t.Fatalf("wrong Exchange for registered DEX: %+v", xc)
+	}
+
+	// Lose the dexConnection
+	tCore.connMtx.Lock()
+	delete(tCore.conns, tDexHost)
+	tCore.connMtx.Unlock()
+
+	// connectDEX error
+	_, err = tCore.DEXConfig(tUnparseableHost, "")
+	if !errorHasCode(err, connectionErr) {
+		t.Fatalf("wrong connectDEX error: %v", err)
+	}
+
+	// Queue a config response for success
+	rig.queueConfig()
+
+	// Success
+	xc, err = tCore.DEXConfig(tDexHost, "")
+	if err != nil {
+		t.Fatalf("DEXConfig error: %v", err)
+	}
+	if xc.Host != tDexHost || len(xc.Markets) == 0 || len(xc.Assets) == 0 {
+		t.Fatalf("wrong Exchange: %+v", xc)
+	}
+	if tCore.isRegistered(tDexHost) {
+		t.Fatalf("DEXConfig should not register the DEX")
+	}
+}
+
+func TestSendRaw(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	const route = "someroute"
+
+	// Unknown DEX.
+	_, err := tCore.SendRaw("unknown.dex", route, nil)
+	if err == nil {
+		t.Fatalf("no error for unknown DEX")
+	}
+
+	// The payload is sent unaltered and the result is returned as received.
+	var gotPayload json.RawMessage
+	rig.ws.queueResponse(route, func(msg *msgjson.Message, f msgFunc) error {
+		gotPayload = msg.Payload
+		resp, _ := msgjson.NewResponse(msg.ID, map[string]int{"a": 1}, nil)
+		f(resp)
+		return nil
+	})
+	resp, err := tCore.SendRaw(tDexHost, route, json.RawMessage(`{"x":[1,2]}`))
+	if err != nil {
+		t.Fatalf("SendRaw error: %v", err)
+	}
+	if string(gotPayload) != `{"x":[1,2]}` {
+		t.Fatalf("wrong payload sent: %s", gotPayload)
+	}
+	if string(resp.Result) != `{"a":1}` || resp.Error != nil {
+		t.Fatalf("wrong response: %+v", resp)
+	}
+
+	// A server error is returned in the response payload.
+	rig.ws.queueResponse(route, func(msg *msgjson.Message, f msgFunc) error {
+		resp, _ := msgjson.NewResponse(msg.ID, nil, msgjson.NewError(msgjson.UnknownMessageType, "unknown route"))
+		f(resp)
+		return nil
+	})
+	resp, err = tCore.SendRaw(tDexHost, route, nil)
+	if err != nil {
+		t.Fatalf("SendRaw error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != msgjson.UnknownMessageType {
+		t.Fatalf("wrong response error: %+v", resp.Error)
+	}
+
+	// Request error.
+	rig.ws.reqErr = tErr
+	_, err = tCore.SendRaw(tDexHost, route, nil)
+	if !errorHasCode(err, connectionErr) {
+		t.Fatalf("wrong request error: %v", err)
+	}
+}
+
 func TestRegister(t *testing.T) {
 	// This test takes a little longer because the key is decrypted every time
 	// Register is called.
@@ -1532,11 +1770,17 @@ func TestLogin(t *testing.T) {
 	tCore := rig.core
 	rig.acct.markFeePaid()
 
+	if tCore.LoggedIn() {
+		t.Fatalf("logged in before Login")
+	}
 	rig.queueConnect(nil, nil, nil)
 	_, err := tCore.Login(tPW)
 	if err != nil || !rig.acct.authed() {
 		t.Fatalf("initial Login error: %v", err)
 	}
+	if !tCore.LoggedIn() {
+		t.Fatalf("not logged in after Login")
+	}
 
 	// No encryption key.
 	rig.acct.unauth()
@@ -1836,6 +2080,234 @@ func TestInitializeClient(t *testing.T) {
 	}
 }
 
+func TestFeeRate(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = wallet
+	tWallet.feeRate = 22
+
+	// Successful
+	feeRate, err := tCore.FeeRate(tDCR.ID)
+	if err != nil {
+		t.Fatalf("FeeRate error: %v", err)
+	}
+	if feeRate != 22 {
+		t.Fatalf("wrong fee rate. wanted 22, got %d", feeRate)
+	}
+
+	// no wallet
+	_, err = tCore.FeeRate(12345)
+	if !errorHasCode(err, missingWalletErr) {
+		t.Fatalf("wrong error for unknown wallet: %v", err)
+	}
+
+	// estimate error
+	tWallet.feeRateErr = tErr
+	_, err = tCore.FeeRate(tDCR.ID)
+	if !errorHasCode(err, walletErr) {
+		t.Fatalf("wrong error for fee rate estimate error: %v", err)
+	}
+	tWallet.feeRateErr = nil
+
+	// not connected
+	wallet.hookedUp = false
+	_, err = tCore.FeeRate(tDCR.ID)
+	if !errorHasCode(err, walletErr) {
+		t.Fatalf("wrong error for disconnected wallet: %v", err)
+	}
+}
+
+func TestNewDepositAddress(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = wallet
+	tWallet.addr = "DsAddr1"
+
+	// Successful
+	addr, err := tCore.NewDepositAddress(tDCR.ID)
+	if err != nil {
+		t.Fatalf("NewDepositAddress error: %v", err)
+	}
+	if addr != "DsAddr1" {
+		t.Fatalf("wrong address. wanted DsAddr1, got %s", addr)
+	}
+
+	// Not cached. The wallet's next address is returned.
+	tWallet.addr = "DsAddr2"
+	addr, err = tCore.NewDepositAddress(tDCR.ID)
+	if err != nil {
+		t.Fatalf("NewDepositAddress error: %v", err)
+	}
+	if addr != "DsAddr2" {
+		t.Fatalf("wrong address. wanted DsAddr2, got %s", addr)
+	}
+
+	// no wallet
+	_, err = tCore.NewDepositAddress(12345)
+	if !errorHasCode(err, missingWalletErr) {
+		t.Fatalf("wrong error for unknown wallet: %v", err)
+	}
+
+	// address error
+	tWallet.addrErr = tErr
+	_, err = tCore.NewDepositAddress(tDCR.ID)
+	if !errorHasCode(err, walletErr) {
+		t.Fatalf("wrong error for address error: %v", err)
+	}
+	tWallet.addrErr = nil
+
+	// locked
+	wallet.lockTime = time.Time{}
+	_, err = tCore.NewDepositAddress(tDCR.ID)
+	if !IsWalletLocked(err) {
+		t.Fatalf("wrong error for locked wallet: %v", err)
+	}
+	wallet.lockTime = time.Now().Add(time.Hour)
+
+	// not connected
+	wallet.hookedUp = false
+	_, err = tCore.NewDepositAddress(tDCR.ID)
+	if !IsWalletLocked(err) {
+		t.Fatalf("wrong error for disconnected wallet: %v", err)
+	}
+}
+
+func TestWalletSyncStatus(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = wallet
+	tWallet.bal = &asset.Balance{}
+
+	if ss := tCore.WalletState(tDCR.ID).SyncStatus; ss != nil {
+		t.Fatalf("unexpected sync status before any tip change: %+v", ss)
+	}
+
+	// A tip change refreshes the sync status.
+	tWallet.syncStatus = asset.NewSyncStatus(false, 30, 120)
+	tCore.tipChange(tDCR.ID, nil)
+	ss := tCore.WalletState(tDCR.ID).SyncStatus
+	if ss == nil || ss.Synced || ss.Height != 30 || ss.Target != 120 || ss.Progress != 25 {
+		t.Fatalf("wrong sync status: %+v", ss)
+	}
+
+	// The sync status is omitted if it cannot be retrieved.
+	tWallet.syncStatus, tWallet.syncStatusErr = nil, tErr
+	tCore.tipChange(tDCR.ID, nil)
+	if ss := tCore.WalletState(tDCR.ID).SyncStatus; ss != nil {
+		t.Fatalf("sync status not cleared on error: %+v", ss)
+	}
+	b, _ := json.Marshal(tCore.WalletState(tDCR.ID))
+	if strings.Contains(string(b), "syncStatus") {
+		t.Fatalf("syncStatus not omitted: %s", b)
+	}
+}
+
+func TestRescanWallet(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = wallet
+	tWallet.bal = &asset.Balance{}
+	tWallet.syncStatus = asset.NewSyncStatus(true, 120, 120)
+	tCore.tipChange(tDCR.ID, nil)
+	tWallet.rescanWait = make(chan struct{})
+
+	// Successful. The wallet is not synced until the rescan is done.
+	if err := tCore.RescanWallet(tDCR.ID); err != nil {
+		t.Fatalf("RescanWallet error: %v", err)
+	}
+	state := tCore.WalletState(tDCR.ID)
+	if !state.Rescanning || state.SyncStatus.Synced {
+		t.Fatalf("wrong state during rescan: rescanning = %v, sync status = %+v",
+			state.Rescanning, state.SyncStatus)
+	}
+
+	// Only one rescan at a time.
+	err := tCore.RescanWallet(tDCR.ID)
+	if !errorHasCode(err, walletErr) {
+		t.Fatalf("wrong error for a rescan in progress: %v", err)
+	}
+
+	close(tWallet.rescanWait)
+	timeout := time.After(5 * time.Second)
+	for tCore.WalletState(tDCR.ID).Rescanning {
+		select {
+		case <-timeout:
+			t.Fatalf("rescan not finished")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if !tCore.WalletState(tDCR.ID).SyncStatus.Synced {
+		t.Fatalf("wallet not synced after rescan")
+	}
+
+	// no wallet
+	err = tCore.RescanWallet(12345)
+	if !errorHasCode(err, missingWalletErr) {
+		t.Fatalf("wrong error for unknown wallet: %v", err)
+	}
+
+	// not connected
+	wallet.hookedUp = false
+	err = tCore.RescanWallet(tDCR.ID)
+	if !errorHasCode(err, walletErr) {
+		t.Fatalf("wrong error for disconnected wallet: %v", err)
+	}
+}
+
+func TestWalletPeers(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	wallet, tWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = wallet
+	tWallet.bal = &asset.Balance{}
+
+	// The peers are counted when a new block is reported.
+	tWallet.peerCount = 8
+	if peers := tCore.WalletState(tDCR.ID).Peers; peers != nil {
+		t.Fatalf("peers counted before a tip change: %+v", peers)
+	}
+	tCore.tipChange(tDCR.ID, nil)
+	peers := tCore.WalletState(tDCR.ID).Peers
+	if peers == nil || !peers.Connected || peers.Count != 8 {
+		t.Fatalf("wrong peer status: %+v", peers)
+	}
+
+	// A node with no peers is still connected.
+	tWallet.peerCount = 0
+	tCore.tipChange(tDCR.ID, nil)
+	states := tCore.Wallets()
+	if len(states) != 1 || states[0].Peers == nil || !states[0].Peers.Connected || states[0].Peers.Count != 0 {
+		t.Fatalf("wrong peer status for node with no peers: %+v", states[0].Peers)
+	}
+
+	// The node is not connected if the peers cannot be counted.
+	tWallet.peerCountErr = tErr
+	tCore.tipChange(tDCR.ID, nil)
+	if peers = tCore.WalletState(tDCR.ID).Peers; peers == nil || peers.Connected {
+		t.Fatalf("wrong peer status on error: %+v", peers)
+	}
+	tWallet.peerCountErr = nil
+
+	// The peers are counted when the wallet connects, and the peer status is
+	// omitted for a disconnected wallet.
+	tWallet.peerCount = 3
+	if err := wallet.Connect(tCtx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	if peers = tCore.WalletState(tDCR.ID).Peers; peers == nil || !peers.Connected || peers.Count != 3 {
+		t.Fatalf("wrong peer status after connecting: %+v", peers)
+	}
+	wallet.Disconnect()
+	b, _ := json.Marshal(tCore.WalletState(tDCR.ID))
+	if strings.Contains(string(b), `"peers"`) {
+		t.Fatalf("peers not omitted for disconnected wallet: %s", b)
+	}
+}
+
 func TestWithdraw(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
@@ -2021,6 +2493,16 @@ func TestTrade(t *testing.T) {
 		t.Fatalf("no error for closing BTC wallet with active orders")
 	}
 
+	// Order exceeds the trade settings.
+	tCore.settings = TradeSettings{MaxOrderLots: lots - 1}
+	ensureErr("lot limit")
+	if tDcrWallet.fundedVal != 0 {
+		t.Fatalf("order exceeding the lot limit was funded")
+	}
+	tCore.settings = TradeSettings{MaxFeeRate: tDCR.MaxFeeRate - 1}
+	ensureErr("fee rate limit")
+	tCore.settings = TradeSettings{}
+
 	// Dex not found
 	form.Host = "someotherdex.org"
 	_, err = tCore.Trade(tPW, form)
@@ -2035,6 +2517,9 @@ func TestTrade(t *testing.T) {
 	if err == nil {
 		t.Fatalf("no error for disconnected dex")
 	}
+	if !IsLoginRequired(err) {
+		t.Fatalf("wrong error for locked account: %v", err)
+	}
 	rig.dc.acct.unlock(rig.crypter)
 
 	// DEX not connected
@@ -2060,11 +2545,32 @@ func TestTrade(t *testing.T) {
 	ensureErr("zero rate limit")
 	form.Rate = rate
 
+	// Wrong password
+	rig.crypter.recryptErr = tErr
+	ensureErr("bad password")
+	if !IsPasswordError(err) {
+		t.Fatalf("wrong error for bad password: %v", err)
+	}
+	rig.crypter.recryptErr = nil
+
 	// No from wallet
 	delete(tCore.wallets, tDCR.ID)
 	ensureErr("no dcr wallet")
+	if !IsWalletNotFound(err) {
+		t.Fatalf("wrong error for missing wallet: %v", err)
+	}
 	tCore.wallets[tDCR.ID] = dcrWallet
 
+	// Wallet unlock failure
+	dcrWallet.Lock()
+	tDcrWallet.unlockErr = tErr
+	ensureErr("wallet unlock error")
+	if !IsWalletLocked(err) {
+		t.Fatalf("wrong error for locked wallet: %v", err)
+	}
+	tDcrWallet.unlockErr = nil
+	dcrWallet.Unlock(rig.crypter, time.Hour)
+
 	// No to wallet
 	delete(tCore.wallets, tBTC.ID)
 	ensureErr("no btc wallet")
@@ -2075,9 +2581,18 @@ func TestTrade(t *testing.T) {
 	ensureErr("address error")
 	tBtcWallet.addrErr = nil
 
-	// Not enough funds
+	// Funding error
 	tDcrWallet.fundingCoinErr = tErr
 	ensureErr("funds error")
+	if IsInsufficientFunds(err) {
+		t.Fatalf("generic funding error reported as insufficient funds")
+	}
+	// Not enough funds
+	tDcrWallet.fundingCoinErr = dex.NewError(asset.InsufficientFundsError, "0 available")
+	ensureErr("insufficient funds")
+	if !IsInsufficientFunds(err) {
+		t.Fatalf("wrong error for insufficient funds: %v", err)
+	}
 	tDcrWallet.fundingCoinErr = nil
 
 	// Lot size violation
@@ -2356,24 +2871,204 @@ func TestHandleRevokeOrderMsg(t *testing.T) {
 	}
 }
 
-func TestHandleRevokeMatchMsg(t *testing.T) {
+func TestOrdersPage(t *testing.T) {
 	rig := newTestRig()
-	dc := rig.dc
 	tCore := rig.core
-	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
-	tCore.wallets[tDCR.ID] = dcrWallet
-	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
-	dcrWallet.Unlock(rig.crypter, time.Hour)
+	for i := 0; i < 5; i++ {
+		_, dbOrder, _, _ := makeLimitOrder(rig.dc, true, tDCR.LotSize, tBTC.RateStep)
+		rig.db.orders = append(rig.db.orders, dbOrder)
+	}
+	filter := new(OrderFilter)
 
-	fundCoinDcrID := encode.RandomBytes(36)
-	fundCoinDcr := &tCoin{id: fundCoinDcrID}
+	checkPage := func(tag string, page *OrdersPage, offset, n, nextOffset int) {
+		t.Helper()
+		if page.Total != len(rig.db.orders) {
+			t.Fatalf("%s: wrong total. wanted %d, got %d", tag, len(rig.db.orders), page.Total)
+		}
+		if page.Offset != offset || len(page.Orders) != n || page.NextOffset != nextOffset {
+			t.Fatalf("%s: wrong page. wanted offset %d, %d orders, next offset %d, got %d, %d, %d",
+				tag, offset, n, nextOffset, page.Offset, len(page.Orders), page.NextOffset)
+		}
+		for i, ord := range page.Orders {
+			wantID := rig.db.orders[offset+i].Order.ID()
+			if ord.ID.String() != wantID.String() {
+				t.Fatalf("%s: wrong order at index %d", tag, i)
+			}
+		}
+	}
 
-	btcWallet, _ := newTWallet(tBTC.ID)
-	tCore.wallets[tBTC.ID] = btcWallet
-	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
-	btcWallet.Unlock(rig.crypter, time.Hour)
+	page, err := tCore.OrdersPage(filter, 0, 2)
+	if err != nil {
+		t.Fatalf("OrdersPage error: %v", err)
+	}
+	checkPage("first", page, 0, 2, 2)
 
-	// fundCoinBID := encode.RandomBytes(36)
+	page, _ = tCore.OrdersPage(filter, 2, 2)
+	checkPage("middle", page, 2, 2, 4)
+
+	// The last page has no next offset.
+	page, _ = tCore.OrdersPage(filter, 4, 2)
+	checkPage("last", page, 4, 1, 0)
+
+	// An empty page past the end is not an error.
+	page, err = tCore.OrdersPage(filter, 10, 2)
+	if err != nil {
+		t.Fatalf("OrdersPage error past the end: %v", err)
+	}
+	if page.Orders == nil {
+		t.Fatalf("nil orders past the end")
+	}
+	checkPage("past the end", page, 10, 0, 0)
+
+	if _, err = tCore.OrdersPage(filter, -1, 2); err == nil {
+		t.Fatalf("no error for negative offset")
+	}
+
+	rig.db.ordersErr = tErr
+	if _, err = tCore.OrdersPage(filter, 0, 2); err == nil {
+		t.Fatalf("no error for DB error")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[tBTC.ID] = btcWallet
+
+	lo, dbOrder, preImg, _ := makeLimitOrder(dc, true, 2*tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+
+	// Bad order ID
+	_, err := tCore.Matches(oid[:4])
+	if err == nil {
+		t.Fatalf("no error for bad order ID")
+	}
+
+	// Unknown order
+	_, err = tCore.Matches(oid[:])
+	if err == nil {
+		t.Fatalf("no error for unknown order")
+	}
+
+	// Inactive order with no matches.
+	rig.db.orderOrders = map[order.OrderID]*db.MetaOrder{oid: dbOrder}
+	settlements, err := tCore.Matches(oid[:])
+	if err != nil {
+		t.Fatalf("Matches error: %v", err)
+	}
+	if settlements == nil || len(settlements) != 0 {
+		t.Fatalf("expected an empty list, got %v", settlements)
+	}
+
+	// Inactive order with a completed match.
+	mid := ordertest.RandomMatchID()
+	rig.db.matchesForOID = []*db.MetaMatch{{
+		MetaData: &db.MatchMetaData{},
+		Match: &order.UserMatch{
+			OrderID: oid,
+			MatchID: mid,
+			Status:  order.MatchComplete,
+			Side:    order.Maker,
+		},
+	}}
+	settlements, err = tCore.Matches(oid[:])
+	if err != nil {
+		t.Fatalf("Matches error: %v", err)
+	}
+	if len(settlements) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(settlements))
+	}
+	ms := settlements[0]
+	if ms.Active || ms.SwapConfs != -1 || ms.CounterSwapConfs != -1 {
+		t.Fatalf("wrong settlement for inactive match: %+v", ms)
+	}
+
+	// Active match with both swaps broadcast.
+	walletSet, err := tCore.walletSet(dc, tDCR.ID, tBTC.ID, true)
+	if err != nil {
+		t.Fatalf("walletSet error: %v", err)
+	}
+	mkt := dc.market(tDcrBtcMktName)
+	tracker := newTrackedTrade(dbOrder, preImg, dc, mkt.EpochLen,
+		rig.core.lockTimeTaker, rig.core.lockTimeMaker,
+		rig.db, rig.queue, walletSet, nil, rig.core.notify)
+	rig.dc.trades[oid] = tracker
+	swapID := encode.RandomBytes(36)
+	match := &matchTracker{
+		id: mid,
+		MetaMatch: db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Proof: db.MatchProof{
+					MakerSwap: swapID,
+				},
+			},
+			Match: &order.UserMatch{
+				OrderID: oid,
+				MatchID: mid,
+				Status:  order.TakerSwapCast,
+				Side:    order.Maker,
+			},
+		},
+		counterSwap: &tAuditInfo{coin: &tCoin{id: encode.RandomBytes(36), confs: 2}},
+	}
+	tracker.matches[mid] = match
+	tDcrWallet.confs = 3
+	settlements, err = tCore.Matches(oid[:])
+	if err != nil {
+		t.Fatalf("Matches error: %v", err)
+	}
+	if len(settlements) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(settlements))
+	}
+	ms = settlements[0]
+	if !ms.Active || !bytes.Equal(ms.Swap, swapID) {
+		t.Fatalf("wrong settlement for active match: %+v", ms)
+	}
+	if ms.SwapConfs != 3 || ms.SwapConfsReq != tDCR.SwapConf {
+		t.Fatalf("wrong swap confirmations %d / %d", ms.SwapConfs, ms.SwapConfsReq)
+	}
+	if ms.CounterSwapConfs != 2 || ms.CounterConfsReq != tBTC.SwapConf {
+		t.Fatalf("wrong counter-swap confirmations %d / %d", ms.CounterSwapConfs, ms.CounterConfsReq)
+	}
+
+	// Unknown confirmations on wallet error.
+	tDcrWallet.confsErr = tErr
+	settlements, _ = tCore.Matches(oid[:])
+	if settlements[0].SwapConfs != -1 {
+		t.Fatalf("expected unknown swap confirmations, got %d", settlements[0].SwapConfs)
+	}
+	tDcrWallet.confsErr = nil
+
+	// No confirmations are checked once the match is complete.
+	match.SetStatus(order.MatchComplete)
+	settlements, _ = tCore.Matches(oid[:])
+	if settlements[0].SwapConfs != -1 || settlements[0].CounterSwapConfs != -1 {
+		t.Fatalf("confirmations checked for completed match")
+	}
+}
+
+func TestHandleRevokeMatchMsg(t *testing.T) {
+	rig := newTestRig()
+	dc := rig.dc
+	tCore := rig.core
+	dcrWallet, tDcrWallet := newTWallet(tDCR.ID)
+	tCore.wallets[tDCR.ID] = dcrWallet
+	dcrWallet.address = "DsVmA7aqqWeKWy461hXjytbZbgCqbB8g2dq"
+	dcrWallet.Unlock(rig.crypter, time.Hour)
+
+	fundCoinDcrID := encode.RandomBytes(36)
+	fundCoinDcr := &tCoin{id: fundCoinDcrID}
+
+	btcWallet, _ := newTWallet(tBTC.ID)
+	tCore.wallets[tBTC.ID] = btcWallet
+	btcWallet.address = "12DXGkvxFjuq5btXYkwWfBZaz1rVwFgini"
+	btcWallet.Unlock(rig.crypter, time.Hour)
+
+	// fundCoinBID := encode.RandomBytes(36)
 	// fundCoinB := &tCoin{id: fundCoinBID}
 
 	matchSize := 4 * tDCR.LotSize
@@ -3093,7 +3788,7 @@ func TestRefunds(t *testing.T) {
 			t.Fatalf("%s's swap not refundable", match.Match.Side)
 		}
 		// Check refund.
-		amtRefunded, err := tracker.refundMatches([]*matchTracker{match})
+		amtRefunded, err := tracker.refundMatches([]*matchTracker{match}, defaultRetryPolicy)
 		if err != nil {
 			t.Fatalf("unexpected refund error %v", err)
 		}
@@ -3106,7 +3801,7 @@ func TestRefunds(t *testing.T) {
 			t.Fatalf("%s's swap refundable after being refunded", match.Match.Side)
 		}
 		// Expect refund re-attempt to not refund any coin.
-		amtRefunded, err = tracker.refundMatches([]*matchTracker{match})
+		amtRefunded, err = tracker.refundMatches([]*matchTracker{match}, defaultRetryPolicy)
 		if err != nil {
 			t.Fatalf("unexpected refund error %v", err)
 		}
@@ -3200,9 +3895,29 @@ func TestRefunds(t *testing.T) {
 
 	// Attempt refund.
 	tDcrWallet.refundCoin = encode.RandomBytes(36)
-	tDcrWallet.refundErr = nil
 	tBtcWallet.refundCoin = nil
 	tBtcWallet.refundErr = fmt.Errorf("unexpected call to btcWallet.Refund")
+
+	// A failed refund is retried after the backoff if the policy allows it.
+	tDcrWallet.refundErr = tErr
+	retryPolicy := RetryPolicy{
+		MaxRedeemAttempts: 1,
+		MaxRefundAttempts: 2,
+		Backoff:           time.Minute,
+		MaxBackoff:        time.Hour,
+	}
+	_, err = tracker.refundMatches([]*matchTracker{match}, retryPolicy)
+	if err == nil {
+		t.Fatalf("no error for failed refund")
+	}
+	if match.refundErr != nil {
+		t.Fatalf("refund failed with attempts remaining")
+	}
+	if tracker.isRefundable(match) {
+		t.Fatalf("match refundable before retry backoff")
+	}
+	match.retryAfter = time.Time{}
+	tDcrWallet.refundErr = nil
 	checkRefund(tracker, match, matchSize)
 
 	// TAKER REFUND, NO MAKER REDEEM
@@ -3799,12 +4514,31 @@ func TestHandleEpochOrderMsg(t *testing.T) {
 		t.Fatal("[handleEpochOrderMsg] expected a non-existent orderbook error")
 	}
 
-	rig.dc.books[tDcrBtcMktName] = newBookie(tLogger, func() {})
+	book := newBookie(tLogger, func() {})
+	rig.dc.books[tDcrBtcMktName] = book
+	book.mtx.Lock()
+	feed := book.feed()
+	book.mtx.Unlock()
+	// The failed attempt above already advanced the epoch.
+	rig.dc.epoch[tDcrBtcMktName] = 0
 
 	err = handleEpochOrderMsg(rig.core, rig.dc, req)
 	if err != nil {
 		t.Fatalf("[handleEpochOrderMsg] unexpected error: %v", err)
 	}
+
+	// The first epoch order of an epoch should be preceded by a new epoch
+	// update.
+	for _, action := range []string{NewEpochAction, EpochOrderAction} {
+		select {
+		case u := <-feed.C:
+			if u.Action != action {
+				t.Fatalf("expected action %q, got %q", action, u.Action)
+			}
+		default:
+			t.Fatalf("no %q update received", action)
+		}
+	}
 }
 
 func makeMatchProof(preimages []order.Preimage, commitments []order.Commitment) (msgjson.Bytes, msgjson.Bytes, error) {
@@ -3861,9 +4595,15 @@ func TestHandleMatchProofMsg(t *testing.T) {
 		t.Fatal("[handleMatchProofMsg] expected a non-existent orderbook error")
 	}
 
-	rig.dc.books[tDcrBtcMktName] = newBookie(tLogger, func() {})
+	book := newBookie(tLogger, func() {})
+	rig.dc.books[tDcrBtcMktName] = book
+	book.mtx.Lock()
+	feed := book.feed()
+	book.mtx.Unlock()
+	// The failed attempt above already advanced the epoch.
+	rig.dc.epoch[tDcrBtcMktName] = 0
 
-	err = rig.dc.books[tDcrBtcMktName].Enqueue(eo)
+	err = book.Enqueue(eo)
 	if err != nil {
 		t.Fatalf("[Enqueue] unexpected error: %v", err)
 	}
@@ -3872,6 +4612,34 @@ func TestHandleMatchProofMsg(t *testing.T) {
 	if err != nil {
 		t.Fatalf("[handleMatchProofMsg] unexpected error: %v", err)
 	}
+
+	// The match results should be followed by an update for the next epoch.
+	select {
+	case u := <-feed.C:
+		if u.Action != MatchProofAction {
+			t.Fatalf("expected action %q, got %q", MatchProofAction, u.Action)
+		}
+		mp, ok := u.Payload.(*MatchProofUpdate)
+		if !ok {
+			t.Fatalf("wrong payload type %T", u.Payload)
+		}
+		if mp.Epoch != 1 || mp.Preimages != 1 || len(mp.Misses) != 0 {
+			t.Fatalf("wrong match proof update: %+v", mp)
+		}
+	default:
+		t.Fatalf("no match proof update received")
+	}
+	select {
+	case u := <-feed.C:
+		if u.Action != NewEpochAction {
+			t.Fatalf("expected action %q, got %q", NewEpochAction, u.Action)
+		}
+		if eu := u.Payload.(*EpochUpdate); eu.Epoch != 2 {
+			t.Fatalf("expected epoch 2, got %d", eu.Epoch)
+		}
+	default:
+		t.Fatalf("no new epoch update received")
+	}
 }
 
 func TestLogout(t *testing.T) {
@@ -3951,6 +4719,223 @@ func TestLogout(t *testing.T) {
 	ensureErr("lock wallet")
 }
 
+func TestDisconnectDEX(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	ord := &order.LimitOrder{P: order.Prefix{ServerTime: time.Now()}}
+	rig.dc.trades[ord.ID()] = &trackedTrade{
+		Order:  ord,
+		preImg: newPreimage(),
+		dc:     rig.dc,
+		metaData: &db.OrderMetaData{
+			Status: order.OrderStatusBooked,
+		},
+		matches: make(map[order.MatchID]*matchTracker),
+	}
+
+	book := newBookie(tLogger, func() {})
+	rig.dc.books[tDcrBtcMktName] = book
+	feed := book.feed()
+
+	connected := func() bool {
+		tCore.connMtx.RLock()
+		defer tCore.connMtx.RUnlock()
+		_, found := tCore.conns[tDexHost]
+		return found
+	}
+	ensureErr := func(tag string, forget, force bool) {
+		t.Helper()
+		if err := tCore.DisconnectDEX(tPW, tDexHost, forget, force); err == nil {
+			t.Fatalf("%s: no error", tag)
+		}
+		if !connected() {
+			t.Fatalf("%s: disconnected after error", tag)
+		}
+	}
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	ensureErr("password", false, true)
+	rig.crypter.recryptErr = nil
+
+	// Unknown DEX.
+	if err := tCore.DisconnectDEX(tPW, "unknown.dex:7232", false, false); err == nil {
+		t.Fatalf("no error for unknown DEX")
+	}
+
+	// Active orders error.
+	ensureErr("active orders", true, false)
+	if len(rig.db.accts) == 0 {
+		t.Fatalf("account disabled with active orders")
+	}
+
+	// Account retrieval error.
+	rig.db.acctErr = tErr
+	ensureErr("account", true, true)
+	rig.db.acctErr = nil
+
+	// Forced disconnect and forget.
+	if err := tCore.DisconnectDEX(tPW, tDexHost, true, true); err != nil {
+		t.Fatalf("DisconnectDEX error: %v", err)
+	}
+	if connected() {
+		t.Fatalf("still connected")
+	}
+	if len(rig.db.accts) != 0 {
+		t.Fatalf("account not disabled")
+	}
+	if len(rig.dc.books) != 0 {
+		t.Fatalf("books not removed")
+	}
+	select {
+	case _, ok := <-feed.C:
+		if ok {
+			t.Fatalf("unexpected update on closed feed")
+		}
+	default:
+		t.Fatalf("feed not closed")
+	}
+	// Closing the feed after it was closed by Core is a no-op.
+	feed.Close()
+}
+
+// tReconnectingConn is a connection that is reconnecting when it is told to
+// disconnect, so it does not return from Disconnect until handleReconnect does.
+type tReconnectingConn struct {
+	core *Core
+}
+
+func (conn *tReconnectingConn) Connect(ctx context.Context) (*sync.WaitGroup, error) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		conn.core.handleReconnect(tDexHost)
+	}()
+	return &wg, nil
+}
+
+func TestDisconnectDEXDuringReconnect(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	rig.dc.connMaster = dex.NewConnectionMaster(&tReconnectingConn{core: tCore})
+	if err := rig.dc.connMaster.Connect(tCtx); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tCore.DisconnectDEX(tPW, tDexHost, false, false)
+	}()
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatalf("DisconnectDEX error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("DisconnectDEX deadlocked with handleReconnect")
+	}
+
+	// The connMtx is not left locked.
+	tCore.connMtx.Lock()
+	_, found := tCore.conns[tDexHost]
+	tCore.connMtx.Unlock()
+	if found {
+		t.Fatalf("still connected")
+	}
+}
+
+func TestSyncBookSince(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	booky := newBookie(tLogger, func() {})
+	rig.dc.books[tDcrBtcMktName] = booky
+
+	sendEpochs := func(n int) {
+		for i := 0; i < n; i++ {
+			booky.send(&BookUpdate{
+				Action:   NewEpochAction,
+				Host:     tDexHost,
+				MarketID: tDcrBtcMktName,
+				Payload:  &EpochUpdate{Epoch: uint64(i)},
+			})
+		}
+	}
+	sync := func(since uint64) (bool, []*BookUpdate) {
+		t.Helper()
+		feed, replayed, err := tCore.SyncBookSince(tDexHost, tDCR.ID, tBTC.ID, since)
+		if err != nil {
+			t.Fatalf("SyncBookSince error: %v", err)
+		}
+		defer feed.Close()
+		var updates []*BookUpdate
+		for {
+			select {
+			case u := <-feed.C:
+				updates = append(updates, u)
+			default:
+				return replayed, updates
+			}
+		}
+	}
+
+	// A new subscriber gets the book, with the sequence number of the last
+	// update.
+	sendEpochs(3)
+	replayed, updates := sync(0)
+	if replayed || len(updates) != 1 || updates[0].Action != FreshBookAction {
+		t.Fatalf("expected only the book, got replayed = %v, %d updates", replayed, len(updates))
+	}
+	bookSeq := updates[0].Seq
+	if bookSeq != booky.seq {
+		t.Fatalf("wrong book sequence number. wanted %d, got %d", booky.seq, bookSeq)
+	}
+
+	// Missed updates are replayed in order.
+	sendEpochs(2)
+	replayed, updates = sync(bookSeq)
+	if !replayed || len(updates) != 2 {
+		t.Fatalf("expected 2 replayed updates, got replayed = %v, %d updates", replayed, len(updates))
+	}
+	if updates[0].Seq != bookSeq+1 || updates[1].Seq != bookSeq+2 {
+		t.Fatalf("wrong replayed sequence numbers %d, %d", updates[0].Seq, updates[1].Seq)
+	}
+
+	// Nothing missed.
+	replayed, updates = sync(booky.seq)
+	if !replayed || len(updates) != 0 {
+		t.Fatalf("expected an empty replay, got replayed = %v, %d updates", replayed, len(updates))
+	}
+
+	// A gap larger than the history falls back to the book.
+	sendEpochs(bookReplayLimit)
+	replayed, updates = sync(bookSeq)
+	if replayed || len(updates) != 1 || updates[0].Action != FreshBookAction {
+		t.Fatalf("expected the book for a large gap, got replayed = %v, %d updates", replayed, len(updates))
+	}
+
+	// The oldest update in the history can still be followed.
+	replayed, updates = sync(booky.historyBase)
+	if !replayed || len(updates) != bookReplayLimit {
+		t.Fatalf("expected %d replayed updates, got replayed = %v, %d updates",
+			bookReplayLimit, replayed, len(updates))
+	}
+
+	// An unknown sequence number, e.g. from a book that was replaced, falls
+	// back to the book.
+	replayed, updates = sync(booky.seq + 1)
+	if replayed || len(updates) != 1 || updates[0].Action != FreshBookAction {
+		t.Fatalf("expected the book for an unknown sequence number, got replayed = %v, %d updates", replayed, len(updates))
+	}
+
+	// Unknown DEX.
+	if _, _, err := tCore.SyncBookSince("unknown.dex:7232", tDCR.ID, tBTC.ID, bookSeq); err == nil {
+		t.Fatalf("no error for unknown DEX")
+	}
+}
+
 func TestSetEpoch(t *testing.T) {
 	rig := newTestRig()
 	dc := rig.dc
@@ -4585,43 +5570,181 @@ func TestReconfigureWallet(t *testing.T) {
 	}
 }
 
-func TestSetWalletPassword(t *testing.T) {
+func TestChangeAppPass(t *testing.T) {
 	rig := newTestRig()
 	tCore := rig.core
-	rig.db.wallet = &db.Wallet{
-		EncryptedPW: []byte("abc"),
+	newPW := []byte("newpass")
+	var newCrypter encrypt.Crypter
+	tCore.newCrypter = func(pw []byte) encrypt.Crypter {
+		newCrypter = encrypt.NewCrypter(pw)
+		return newCrypter
 	}
-	newPW := []byte("def")
-	var assetID uint32 = 54321
 
-	// Password error
+	acctKey := rig.acct.encKey
+	rig.db.accts = []*db.AccountInfo{{Host: tDexHost, EncKey: acctKey}}
+	dcrWallet, _ := newTWallet(tDCR.ID)
+	dcrWallet.encPW = []byte("dcrpass")
+	dcrWallet.lockTime = time.Now().Add(time.Hour)
+	tCore.wallets[tDCR.ID] = dcrWallet
+	btcWallet, _ := newTWallet(tBTC.ID) // no password
+	tCore.wallets[tBTC.ID] = btcWallet
+	rig.db.wallets = []*db.Wallet{
+		{AssetID: tDCR.ID, EncryptedPW: dcrWallet.encPW},
+		{AssetID: tBTC.ID},
+	}
+
+	// Wrong password
 	rig.crypter.recryptErr = tErr
-	err := tCore.SetWalletPassword(tPW, assetID, newPW)
-	if !errorHasCode(err, authErr) {
-		t.Fatalf("wrong error for password error: %v", err)
+	err := tCore.ChangeAppPass(tPW, newPW)
+	if !errorHasCode(err, passwordErr) {
+		t.Fatalf("wrong error for wrong password: %v", err)
 	}
 	rig.crypter.recryptErr = nil
 
-	// Missing wallet error
-	err = tCore.SetWalletPassword(tPW, assetID, newPW)
-	if !errorHasCode(err, missingWalletErr) {
-		t.Fatalf("wrong error for missing wallet: %v", err)
+	// Empty new password
+	if err := tCore.ChangeAppPass(tPW, nil); err == nil {
+		t.Fatalf("no error for empty new password")
 	}
 
-	xyzWallet, tXyzWallet := newTWallet(assetID)
-	tCore.wallets[assetID] = xyzWallet
-
-	// Connection error
-	xyzWallet.hookedUp = false
-	tXyzWallet.connectErr = tErr
-	err = tCore.SetWalletPassword(tPW, assetID, newPW)
-	if !errorHasCode(err, connectionErr) {
-		t.Fatalf("wrong error for connection error: %v", err)
+	// Decryption error
+	rig.crypter.decryptErr = tErr
+	err = tCore.ChangeAppPass(tPW, newPW)
+	if !errorHasCode(err, encryptionErr) {
+		t.Fatalf("wrong error for decryption error: %v", err)
 	}
-	xyzWallet.hookedUp = true
-	tXyzWallet.connectErr = nil
+	rig.crypter.decryptErr = nil
 
-	// Unlock error
+	// DB error leaves the credentials unchanged.
+	rig.db.recryptErr = tErr
+	err = tCore.ChangeAppPass(tPW, newPW)
+	if !errorHasCode(err, dbErr) {
+		t.Fatalf("wrong error for DB error: %v", err)
+	}
+	if !bytes.Equal(rig.acct.encKey, acctKey) || !bytes.Equal(dcrWallet.encPW, []byte("dcrpass")) {
+		t.Fatalf("credentials changed after DB error")
+	}
+	rig.db.recryptErr = nil
+
+	// Success
+	err = tCore.ChangeAppPass(tPW, newPW)
+	if err != nil {
+		t.Fatalf("ChangeAppPass error: %v", err)
+	}
+	if !bytes.Equal(rig.db.recryptKeyParams, newCrypter.Serialize()) {
+		t.Fatalf("new key params not stored")
+	}
+	if len(rig.db.recryptWalletPWs) != 1 {
+		t.Fatalf("expected 1 wallet password, got %d", len(rig.db.recryptWalletPWs))
+	}
+	checkRecrypted := func(name string, stored, inMemory, want []byte) {
+		t.Helper()
+		if !bytes.Equal(stored, inMemory) {
+			t.Fatalf("%s: stored and in-memory credentials differ", name)
+		}
+		b, err := newCrypter.Decrypt(inMemory)
+		if err != nil {
+			t.Fatalf("%s: error decrypting with the new password: %v", name, err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Fatalf("%s: wrong decrypted value", name)
+		}
+	}
+	checkRecrypted("account key", rig.db.recryptAcctKeys[tDexHost], rig.acct.encKey, acctKey)
+	checkRecrypted("wallet password", rig.db.recryptWalletPWs[tDCR.ID], dcrWallet.encPW, []byte("dcrpass"))
+
+	// The account and wallet remain unlocked.
+	if rig.acct.locked() {
+		t.Fatalf("account locked after password change")
+	}
+	if !dcrWallet.unlocked() {
+		t.Fatalf("wallet locked after password change")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "dexc.db")
+	rig.db.backupData = []byte("backup")
+
+	// App password error
+	rig.crypter.recryptErr = tErr
+	_, _, err = tCore.Backup(tPW, dst, false)
+	if !errorHasCode(err, passwordErr) {
+		t.Fatalf("wrong error for password error: %v", err)
+	}
+	rig.crypter.recryptErr = nil
+
+	// No path
+	_, _, err = tCore.Backup(tPW, "", false)
+	if err == nil {
+		t.Fatalf("no error for empty path")
+	}
+
+	// DB error
+	rig.db.backupErr = tErr
+	_, _, err = tCore.Backup(tPW, dst, false)
+	if !errorHasCode(err, dbErr) {
+		t.Fatalf("wrong error for DB error: %v", err)
+	}
+	rig.db.backupErr = nil
+
+	// Success
+	path, checksum, err := tCore.Backup(tPW, dst, false)
+	if err != nil {
+		t.Fatalf("Backup error: %v", err)
+	}
+	if path != dst {
+		t.Fatalf("wrong path. wanted %s, got %s", dst, path)
+	}
+	wantSum := sha256.Sum256(rig.db.backupData)
+	if !bytes.Equal(checksum, wantSum[:]) {
+		t.Fatalf("wrong checksum")
+	}
+}
+
+func TestSetWalletPassword(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+	rig.db.wallet = &db.Wallet{
+		EncryptedPW: []byte("abc"),
+	}
+	newPW := []byte("def")
+	var assetID uint32 = 54321
+
+	// Password error
+	rig.crypter.recryptErr = tErr
+	err := tCore.SetWalletPassword(tPW, assetID, newPW)
+	if !errorHasCode(err, authErr) {
+		t.Fatalf("wrong error for password error: %v", err)
+	}
+	rig.crypter.recryptErr = nil
+
+	// Missing wallet error
+	err = tCore.SetWalletPassword(tPW, assetID, newPW)
+	if !errorHasCode(err, missingWalletErr) {
+		t.Fatalf("wrong error for missing wallet: %v", err)
+	}
+
+	xyzWallet, tXyzWallet := newTWallet(assetID)
+	tCore.wallets[assetID] = xyzWallet
+
+	// Connection error
+	xyzWallet.hookedUp = false
+	tXyzWallet.connectErr = tErr
+	err = tCore.SetWalletPassword(tPW, assetID, newPW)
+	if !errorHasCode(err, connectionErr) {
+		t.Fatalf("wrong error for connection error: %v", err)
+	}
+	xyzWallet.hookedUp = true
+	tXyzWallet.connectErr = nil
+
+	// Unlock error
 	tXyzWallet.unlockErr = tErr
 	err = tCore.SetWalletPassword(tPW, assetID, newPW)
 	if !errorHasCode(err, authErr) {
@@ -5237,3 +6360,692 @@ func TestMatchStatusResolution(t *testing.T) {
 		}
 	}
 }
+
+func TestSwapCosts(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	qty := tDCR.LotSize * 2
+	lo := &order.LimitOrder{
+		P: order.Prefix{
+			OrderType:  order.LimitOrderType,
+			BaseAsset:  tDCR.ID,
+			QuoteAsset: tBTC.ID,
+			ClientTime: time.Now(),
+			ServerTime: time.Now(),
+			Commit:     ordertest.RandomCommitment(),
+		},
+		T: order.Trade{
+			Quantity: qty,
+			Sell:     true,
+		},
+		Rate: tBTC.RateStep * 1000,
+	}
+	oid := lo.ID()
+
+	const swapFees, redeemFees = 5001, 1001
+	rig.db.orderOrders[oid] = &db.MetaOrder{
+		MetaData: &db.OrderMetaData{
+			Status:             order.OrderStatusExecuted,
+			Host:               tDexHost,
+			SwapFeesPaid:       swapFees,
+			RedemptionFeesPaid: redeemFees,
+		},
+		Order: lo,
+	}
+
+	const feeRate = 8
+	newMatch := func() *db.MetaMatch {
+		return &db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				Status: order.MatchComplete,
+				Proof: db.MatchProof{
+					MakerSwap:   encode.RandomBytes(36),
+					MakerRedeem: encode.RandomBytes(36),
+				},
+				DEX:   tDexHost,
+				Base:  tDCR.ID,
+				Quote: tBTC.ID,
+			},
+			Match: &order.UserMatch{
+				OrderID:     oid,
+				MatchID:     ordertest.RandomMatchID(),
+				Quantity:    tDCR.LotSize,
+				Rate:        lo.Rate,
+				Address:     ordertest.RandomAddress(),
+				Status:      order.MatchComplete,
+				Side:        order.Maker,
+				FeeRateSwap: feeRate,
+			},
+		}
+	}
+	rig.db.matchesForOID = []*db.MetaMatch{newMatch(), newMatch()}
+
+	report, err := tCore.SwapCosts(oid[:])
+	if err != nil {
+		t.Fatalf("SwapCosts error: %v", err)
+	}
+	if report.FromID != tDCR.ID || report.ToID != tBTC.ID {
+		t.Fatalf("wrong assets. wanted %d -> %d, got %d -> %d", tDCR.ID, tBTC.ID, report.FromID, report.ToID)
+	}
+	if len(report.Matches) != 2 {
+		t.Fatalf("expected 2 match costs, got %d", len(report.Matches))
+	}
+
+	estSwap := tDCR.SwapSize * feeRate
+	estRedeem := tBTC.SwapSize * tBTC.MaxFeeRate
+	if report.Est.Swap != 2*estSwap || report.Est.Redemption != 2*estRedeem {
+		t.Fatalf("wrong estimate totals. wanted %d/%d, got %d/%d", 2*estSwap, 2*estRedeem,
+			report.Est.Swap, report.Est.Redemption)
+	}
+	if report.Actual.Swap != swapFees || report.Actual.Redemption != redeemFees {
+		t.Fatalf("wrong actual totals. wanted %d/%d, got %d/%d", swapFees, redeemFees,
+			report.Actual.Swap, report.Actual.Redemption)
+	}
+
+	// The odd remainder goes to the first match.
+	expActualSwap := []uint64{swapFees/2 + 1, swapFees / 2}
+	expActualRedeem := []uint64{redeemFees/2 + 1, redeemFees / 2}
+	for i, cost := range report.Matches {
+		if cost.EstSwap != estSwap || cost.EstRedemption != estRedeem {
+			t.Fatalf("match %d: wrong estimates %d/%d", i, cost.EstSwap, cost.EstRedemption)
+		}
+		if cost.ActualSwap != expActualSwap[i] || cost.ActualRedemption != expActualRedeem[i] {
+			t.Fatalf("match %d: wrong actual fees. wanted %d/%d, got %d/%d", i, expActualSwap[i],
+				expActualRedeem[i], cost.ActualSwap, cost.ActualRedemption)
+		}
+		if cost.SwapDiff != int64(expActualSwap[i])-int64(estSwap) {
+			t.Fatalf("match %d: wrong swap diff %d", i, cost.SwapDiff)
+		}
+		if cost.RedemptionDiff != int64(expActualRedeem[i])-int64(estRedeem) {
+			t.Fatalf("match %d: wrong redemption diff %d", i, cost.RedemptionDiff)
+		}
+	}
+
+	// Bad order ID length.
+	_, err = tCore.SwapCosts(oid[:4])
+	if err == nil {
+		t.Fatalf("no error for bad order ID")
+	}
+}
+
+func TestPreOrder(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	var lots uint64 = 5
+	rate := tBTC.RateStep * 1000
+	form := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     tDCR.LotSize * lots,
+		Rate:    rate,
+	}
+
+	est, err := tCore.PreOrder(form)
+	if err != nil {
+		t.Fatalf("PreOrder error: %v", err)
+	}
+	if est.MarketID != tDcrBtcMktName || est.FromID != tDCR.ID || est.ToID != tBTC.ID {
+		t.Fatalf("wrong market or assets: %s, %d -> %d", est.MarketID, est.FromID, est.ToID)
+	}
+	if est.Lots != lots || est.LotSize != tDCR.LotSize || est.MinOrder != tDCR.LotSize || est.RateStep != tBTC.RateStep {
+		t.Fatalf("wrong lot info: %+v", est)
+	}
+	if est.Fees.Swap != lots*tDCR.SwapSize*tDCR.MaxFeeRate {
+		t.Fatalf("wrong swap fee estimate %d", est.Fees.Swap)
+	}
+	if est.Fees.Redemption != lots*tBTC.SwapSize*tBTC.MaxFeeRate {
+		t.Fatalf("wrong redemption fee estimate %d", est.Fees.Redemption)
+	}
+
+	// A buy swaps the quote asset.
+	form.Sell = false
+	est, err = tCore.PreOrder(form)
+	if err != nil {
+		t.Fatalf("PreOrder buy error: %v", err)
+	}
+	if est.FromID != tBTC.ID || est.Fees.Swap != lots*tBTC.SwapSize*tBTC.MaxFeeRate {
+		t.Fatalf("wrong buy estimate: from %d, swap fees %d", est.FromID, est.Fees.Swap)
+	}
+	form.Sell = true
+
+	ensureErr := func(tag string) {
+		t.Helper()
+		_, err := tCore.PreOrder(form)
+		if err == nil {
+			t.Fatalf("%s: no error", tag)
+		}
+	}
+
+	// Not a lot multiple.
+	form.Qty = tDCR.LotSize*lots + 1
+	ensureErr("not a lot multiple")
+	form.Qty = tDCR.LotSize - 1
+	ensureErr("less than a lot")
+	form.Qty = tDCR.LotSize * lots
+
+	// Market buys are in units of the quote asset and are not checked against
+	// the lot size. Without a book, a single lot is assumed.
+	form.IsLimit, form.Sell = false, false
+	form.Qty = 1
+	est, err = tCore.PreOrder(form)
+	if err != nil {
+		t.Fatalf("PreOrder market buy error: %v", err)
+	}
+	if est.Lots != 1 {
+		t.Fatalf("expected 1 lot for market buy, got %d", est.Lots)
+	}
+	form.IsLimit, form.Sell = true, true
+	form.Qty = tDCR.LotSize * lots
+
+	// Bad rates.
+	form.Rate = 0
+	ensureErr("zero rate")
+	form.Rate = rate + 1
+	ensureErr("not a rate step multiple")
+	form.Rate = rate
+
+	// Unknown market.
+	form.Quote = 12345
+	ensureErr("unknown market")
+	form.Quote = tBTC.ID
+
+	// Unknown DEX.
+	form.Host = "unknown.dex"
+	ensureErr("unknown dex")
+}
+
+func TestMarketInfo(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	mkt, err := tCore.MarketInfo(tDexHost, tDCR.ID, tBTC.ID)
+	if err != nil {
+		t.Fatalf("MarketInfo error: %v", err)
+	}
+	if mkt.Name != tDcrBtcMktName || mkt.BaseSymbol != tDCR.Symbol || mkt.QuoteSymbol != tBTC.Symbol {
+		t.Fatalf("wrong market %+v", mkt)
+	}
+	if mkt.LotSize != tDCR.LotSize || mkt.RateStep != tBTC.RateStep || mkt.EpochLen != 60000 || mkt.MarketBuyBuffer != 1.1 {
+		t.Fatalf("wrong market parameters %+v", mkt)
+	}
+	if !mkt.Running || mkt.FinalEpoch != 0 {
+		t.Fatalf("market not running %+v", mkt)
+	}
+
+	// A market with a past final epoch is suspended.
+	rig.dc.cfgMtx.Lock()
+	rig.dc.cfg.Markets[0].FinalEpoch = 13
+	rig.dc.cfgMtx.Unlock()
+	mkt, err = tCore.MarketInfo(tDexHost, tDCR.ID, tBTC.ID)
+	if err != nil {
+		t.Fatalf("MarketInfo error for suspended market: %v", err)
+	}
+	if mkt.Running || mkt.FinalEpoch != 13 {
+		t.Fatalf("market not suspended %+v", mkt)
+	}
+
+	// Unknown market.
+	_, err = tCore.MarketInfo(tDexHost, tBTC.ID, tDCR.ID)
+	if !IsUnknownMarket(err) {
+		t.Fatalf("expected an unknown market error, got %v", err)
+	}
+
+	// Unknown DEX.
+	_, err = tCore.MarketInfo("unknown.dex:7232", tDCR.ID, tBTC.ID)
+	if err == nil || IsUnknownMarket(err) {
+		t.Fatalf("expected an unknown DEX error, got %v", err)
+	}
+}
+
+func TestFeesPaid(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	checkFees := func(fees *FeesPaid, assetID uint32, reg, swap, redeem uint64) {
+		t.Helper()
+		af := fees.Assets[assetID]
+		if af == nil {
+			t.Fatalf("no fees reported for asset %d", assetID)
+		}
+		if af.Registration != reg || af.Swap != swap || af.Redemption != redeem {
+			t.Fatalf("wrong %s fees. wanted %d/%d/%d, got %d/%d/%d", af.Symbol,
+				reg, swap, redeem, af.Registration, af.Swap, af.Redemption)
+		}
+	}
+
+	// No activity reports zeros for every asset.
+	fees, err := tCore.FeesPaid(tDexHost, 0)
+	if err != nil {
+		t.Fatalf("FeesPaid error: %v", err)
+	}
+	if fees.Host != tDexHost || fees.Since != 0 || len(fees.Assets) != 2 {
+		t.Fatalf("wrong fees summary %+v", fees)
+	}
+	checkFees(fees, tDCR.ID, 0, 0, 0)
+	checkFees(fees, tBTC.ID, 0, 0, 0)
+
+	// A sell pays swap fees in the base asset and redemption fees in the
+	// quote asset, and a buy the reverse.
+	rig.acct.authMtx.Lock()
+	rig.acct.isPaid = true
+	rig.acct.authMtx.Unlock()
+	_, sell, _, _ := makeLimitOrder(rig.dc, true, tDCR.LotSize, tBTC.RateStep)
+	sell.MetaData.SwapFeesPaid = 100
+	sell.MetaData.RedemptionFeesPaid = 50
+	buyLO, buy, _, _ := makeLimitOrder(rig.dc, false, tDCR.LotSize, tBTC.RateStep)
+	buyLO.Sell = false // makeLimitOrder always sells
+	buy.MetaData.SwapFeesPaid = 30
+	buy.MetaData.RedemptionFeesPaid = 20
+	rig.db.orders = []*db.MetaOrder{sell, buy}
+
+	fees, err = tCore.FeesPaid(tDexHost, 0)
+	if err != nil {
+		t.Fatalf("FeesPaid error: %v", err)
+	}
+	checkFees(fees, tDCR.ID, tFee, 100, 20)
+	checkFees(fees, tBTC.ID, 0, 30, 50)
+
+	// The registration fee is only reported for all-time.
+	fees, err = tCore.FeesPaid(tDexHost, 1)
+	if err != nil {
+		t.Fatalf("FeesPaid error with since time: %v", err)
+	}
+	checkFees(fees, tDCR.ID, 0, 100, 20)
+
+	// Negative since time.
+	_, err = tCore.FeesPaid(tDexHost, -1)
+	if err == nil {
+		t.Fatalf("no error for negative since time")
+	}
+
+	// DB error.
+	rig.db.ordersErr = tErr
+	_, err = tCore.FeesPaid(tDexHost, 0)
+	if err == nil {
+		t.Fatalf("no error for orders retrieval error")
+	}
+	rig.db.ordersErr = nil
+
+	// Unknown DEX.
+	_, err = tCore.FeesPaid("unknown.dex:7232", 0)
+	if err == nil {
+		t.Fatalf("no error for unknown DEX")
+	}
+}
+
+func TestTradeSettings(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	if s := tCore.Settings(); s.MaxFeeRate != 0 || s.MaxOrderLots != 0 {
+		t.Fatalf("expected no limits by default, got %+v", s)
+	}
+
+	var lots uint64 = 5
+	form := &TradeForm{
+		Host:    tDexHost,
+		IsLimit: true,
+		Sell:    true,
+		Base:    tDCR.ID,
+		Quote:   tBTC.ID,
+		Qty:     tDCR.LotSize * lots,
+		Rate:    tBTC.RateStep * 1000,
+	}
+	ensurePreOrder := func(tag string, wantErr bool) {
+		t.Helper()
+		_, err := tCore.PreOrder(form)
+		if (err != nil) != wantErr {
+			t.Fatalf("%s: wanted error = %t, got %v", tag, wantErr, err)
+		}
+	}
+	set := func(settings *TradeSettings) {
+		t.Helper()
+		if err := tCore.SetSettings(settings); err != nil {
+			t.Fatalf("SetSettings error: %v", err)
+		}
+	}
+
+	set(&TradeSettings{MaxFeeRate: tDCR.MaxFeeRate, MaxOrderLots: lots})
+	ensurePreOrder("at limits", false)
+
+	set(&TradeSettings{MaxFeeRate: tDCR.MaxFeeRate - 1})
+	ensurePreOrder("fee rate limit", true)
+	// A buy swaps BTC, which has a lower max fee rate.
+	form.Sell = false
+	ensurePreOrder("fee rate limit for buy", false)
+	form.Sell = true
+
+	set(&TradeSettings{MaxOrderLots: lots - 1})
+	ensurePreOrder("lot limit", true)
+
+	// The settings are saved and loaded.
+	saved := &TradeSettings{MaxFeeRate: 100, MaxOrderLots: 10}
+	set(saved)
+	rig.db.existValues = map[string]bool{tradeSettingsKey: true}
+	tCore.settings = TradeSettings{}
+	if err := tCore.loadSettings(); err != nil {
+		t.Fatalf("loadSettings error: %v", err)
+	}
+	if s := tCore.Settings(); *s != *saved {
+		t.Fatalf("wrong loaded settings. wanted %+v, got %+v", saved, s)
+	}
+
+	// A DB error leaves the settings unchanged.
+	rig.db.storeErr = tErr
+	if err := tCore.SetSettings(&TradeSettings{}); err == nil {
+		t.Fatalf("no error for DB error")
+	}
+	if s := tCore.Settings(); *s != *saved {
+		t.Fatalf("settings changed after DB error: %+v", s)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	if *tCore.RetryPolicy() != defaultRetryPolicy {
+		t.Fatalf("expected default retry policy, got %+v", tCore.RetryPolicy())
+	}
+
+	good := RetryPolicy{
+		MaxRedeemAttempts: 5,
+		MaxRefundAttempts: 3,
+		Backoff:           time.Second * 30,
+		MaxBackoff:        time.Minute * 10,
+	}
+	tests := []struct {
+		name    string
+		mod     func(p *RetryPolicy)
+		wantErr bool
+	}{{
+		name: "ok",
+		mod:  func(p *RetryPolicy) {},
+	}, {
+		name:    "zero redeem attempts",
+		mod:     func(p *RetryPolicy) { p.MaxRedeemAttempts = 0 },
+		wantErr: true,
+	}, {
+		name:    "too many refund attempts",
+		mod:     func(p *RetryPolicy) { p.MaxRefundAttempts = maxRetryAttempts + 1 },
+		wantErr: true,
+	}, {
+		name:    "backoff too short",
+		mod:     func(p *RetryPolicy) { p.Backoff = time.Millisecond },
+		wantErr: true,
+	}, {
+		name:    "max backoff less than backoff",
+		mod:     func(p *RetryPolicy) { p.MaxBackoff = p.Backoff - 1 },
+		wantErr: true,
+	}, {
+		name:    "max backoff too long",
+		mod:     func(p *RetryPolicy) { p.MaxBackoff = maxRetryBackoff + 1 },
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		policy := good
+		test.mod(&policy)
+		err := tCore.SetRetryPolicy(&policy)
+		if test.wantErr {
+			if !errorHasCode(err, retryPolicyErr) {
+				t.Fatalf("%s: expected retryPolicyErr, got %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+
+	// The last good policy should stick.
+	if *tCore.RetryPolicy() != good {
+		t.Fatalf("wrong retry policy. wanted %+v, got %+v", good, tCore.RetryPolicy())
+	}
+
+	// The backoff doubles up to the max.
+	for failures, want := range map[uint32]time.Duration{
+		1: 30 * time.Second,
+		2: time.Minute,
+		3: 2 * time.Minute,
+		6: 10 * time.Minute,
+	} {
+		if d := good.delay(failures); d != want {
+			t.Fatalf("wrong delay after %d failures. wanted %v, got %v", failures, want, d)
+		}
+	}
+}
+
+func TestSignedTradeReport(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	lo, dbOrder, _, _ := makeLimitOrder(rig.dc, true, tDCR.LotSize, tBTC.RateStep)
+	oid := lo.ID()
+	rig.db.orderOrders[oid] = dbOrder
+	swapCoin, redeemCoin := encode.RandomBytes(36), encode.RandomBytes(36)
+	rig.db.matchesForOID = []*db.MetaMatch{{
+		MetaData: &db.MatchMetaData{
+			Status: order.MatchComplete,
+			Proof: db.MatchProof{
+				MakerSwap:   swapCoin,
+				MakerRedeem: redeemCoin,
+			},
+			DEX:   tDexHost,
+			Base:  tDCR.ID,
+			Quote: tBTC.ID,
+			Stamp: encode.UnixMilliU(time.Now()),
+		},
+		Match: &order.UserMatch{
+			OrderID:  oid,
+			MatchID:  ordertest.RandomMatchID(),
+			Quantity: tDCR.LotSize,
+			Rate:     tBTC.RateStep,
+			Address:  ordertest.RandomAddress(),
+			Status:   order.MatchComplete,
+			Side:     order.Maker,
+		},
+	}}
+
+	// Lock the account to make sure the report is signed with the key
+	// decrypted with the app password.
+	rig.acct.lock()
+	signed, err := tCore.SignedTradeReport(tPW, oid[:])
+	if err != nil {
+		t.Fatalf("SignedTradeReport error: %v", err)
+	}
+	if !rig.acct.locked() {
+		t.Fatalf("account unlocked by SignedTradeReport")
+	}
+
+	_, pubKey := secp256k1.PrivKeyFromBytes(rig.acct.encKey)
+	if !bytes.Equal(signed.PubKey, pubKey.SerializeCompressed()) {
+		t.Fatalf("wrong public key in report")
+	}
+	if err := signed.Verify(); err != nil {
+		t.Fatalf("signature verification failed: %v", err)
+	}
+
+	report := new(TradeReport)
+	if err := json.Unmarshal(signed.Report, report); err != nil {
+		t.Fatalf("error decoding report: %v", err)
+	}
+	if report.Host != tDexHost || !bytes.Equal(report.Order.ID, oid[:]) {
+		t.Fatalf("wrong report host or order ID")
+	}
+	if len(report.Order.Matches) != 1 {
+		t.Fatalf("expected 1 match in report, got %d", len(report.Order.Matches))
+	}
+	match := report.Order.Matches[0]
+	if !bytes.Equal(match.Swap, swapCoin) || !bytes.Equal(match.Redeem, redeemCoin) {
+		t.Fatalf("wrong coin IDs in report")
+	}
+
+	// Re-indenting the report must not invalidate the signature.
+	var indented bytes.Buffer
+	json.Indent(&indented, signed.Report, "", "  ")
+	signed.Report = indented.Bytes()
+	if err := signed.Verify(); err != nil {
+		t.Fatalf("signature verification failed for indented report: %v", err)
+	}
+
+	// A modified report does not verify.
+	signed.Report = bytes.Replace(signed.Report, []byte(tDexHost), []byte("otherdex.tld"), 1)
+	if signed.Verify() == nil {
+		t.Fatalf("no error verifying modified report")
+	}
+
+	// Nor does a report checked against another key.
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+	signed.Report = indented.Bytes()
+	signed.PubKey = otherKey.PubKey().SerializeCompressed()
+	if signed.Verify() == nil {
+		t.Fatalf("no error verifying report with the wrong public key")
+	}
+
+	// Password error.
+	rig.crypter.recryptErr = tErr
+	_, err = tCore.SignedTradeReport(tPW, oid[:])
+	if !errorHasCode(err, passwordErr) {
+		t.Fatalf("expected password error, got %v", err)
+	}
+	rig.crypter.recryptErr = nil
+
+	// Unknown order.
+	rig.db.orderErr = tErr
+	_, err = tCore.SignedTradeReport(tPW, oid[:])
+	if !errorHasCode(err, unknownOrderErr) {
+		t.Fatalf("expected unknown order error, got %v", err)
+	}
+}
+
+func TestCandles(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	// No trade history.
+	candles, err := tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, "1h", 10)
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if candles == nil || len(candles) != 0 {
+		t.Fatalf("expected an empty, non-nil slice, got %v", candles)
+	}
+
+	_, dbOrder, _, _ := makeLimitOrder(rig.dc, true, tDCR.LotSize, tBTC.RateStep)
+	rig.db.orders = []*db.MetaOrder{dbOrder}
+	hour := uint64(time.Hour / time.Millisecond)
+	start := encode.UnixMilliU(time.Now().Truncate(time.Hour)) - 2*hour
+	newMatch := func(stamp, rate, qty uint64, addr string) *db.MetaMatch {
+		return &db.MetaMatch{
+			MetaData: &db.MatchMetaData{
+				DEX:   tDexHost,
+				Base:  tDCR.ID,
+				Quote: tBTC.ID,
+				Stamp: stamp,
+			},
+			Match: &order.UserMatch{
+				OrderID:  dbOrder.Order.ID(),
+				MatchID:  ordertest.RandomMatchID(),
+				Quantity: qty,
+				Rate:     rate,
+				Address:  addr,
+			},
+		}
+	}
+	addr := ordertest.RandomAddress()
+	// Out of order, with a cancel match that must be ignored.
+	rig.db.matchesForOID = []*db.MetaMatch{
+		newMatch(start+hour+1, 7, 1, addr),
+		newMatch(start+30, 4, 2, addr),
+		newMatch(start+10, 5, 1, addr),
+		newMatch(start+20, 8, 3, addr),
+		newMatch(start+40, 100, 5, ""),
+		newMatch(start+50, 3, 1, addr),
+	}
+
+	candles, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, "1h", 10)
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+	want := Candle{StartStamp: start, Open: 5, High: 8, Low: 3, Close: 3, Volume: 7}
+	if *candles[0] != want {
+		t.Fatalf("wrong first candle. wanted %+v, got %+v", want, *candles[0])
+	}
+	want = Candle{StartStamp: start + hour, Open: 7, High: 7, Low: 7, Close: 7, Volume: 1}
+	if *candles[1] != want {
+		t.Fatalf("wrong second candle. wanted %+v, got %+v", want, *candles[1])
+	}
+
+	// count keeps the most recent candles.
+	candles, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, "1h", 1)
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if len(candles) != 1 || candles[0].StartStamp != start+hour {
+		t.Fatalf("expected only the newest candle, got %v", candles)
+	}
+
+	// A daily bin holds all of the matches.
+	candles, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, "1d", 0)
+	if err != nil {
+		t.Fatalf("Candles error: %v", err)
+	}
+	if len(candles) > 2 || candles[len(candles)-1].Close != 7 {
+		t.Fatalf("unexpected daily candles %v", candles)
+	}
+
+	// Unsupported bin size.
+	if _, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, "2h", 10); err == nil {
+		t.Fatalf("no error for unsupported bin size")
+	}
+
+	// DB error.
+	rig.db.ordersErr = tErr
+	if _, err = tCore.Candles(tDexHost, tDCR.ID, tBTC.ID, "1h", 10); err == nil {
+		t.Fatalf("no error for DB error")
+	}
+}
+
+func TestStoredNotifications(t *testing.T) {
+	rig := newTestRig()
+	tCore := rig.core
+
+	// No notifications.
+	notes, err := tCore.Notifications(10)
+	if err != nil {
+		t.Fatalf("Notifications error: %v", err)
+	}
+	if notes == nil || len(notes) != 0 {
+		t.Fatalf("expected an empty, non-nil slice, got %v", notes)
+	}
+	if rig.db.notesN != 10 {
+		t.Fatalf("wanted 10 notifications requested, got %d", rig.db.notesN)
+	}
+
+	// The number is capped.
+	note := db.NewNotification(NoteTypeOrder, "subject", "details", db.WarningLevel)
+	rig.db.notes = []*db.Notification{&note}
+	notes, err = tCore.Notifications(MaxNotifications + 1)
+	if err != nil {
+		t.Fatalf("Notifications error: %v", err)
+	}
+	if len(notes) != 1 || notes[0] != &note {
+		t.Fatalf("wrong notifications returned")
+	}
+	if rig.db.notesN != MaxNotifications {
+		t.Fatalf("wanted %d notifications requested, got %d", MaxNotifications, rig.db.notesN)
+	}
+
+	rig.db.notesErr = tErr
+	if _, err = tCore.Notifications(10); !errors.Is(err, tErr) {
+		t.Fatalf("expected the DB error, got %v", err)
+	}
+}
@@ -31,6 +31,11 @@ const (
 	encryptionErr
 	marketErr
 	addressParseErr
+	retryPolicyErr
+	settingsErr
+	loginRequiredErr
+	insufficientFundsErr
+	unknownMarketErr
 )
 
 // Error is an error message and an error code.
@@ -65,3 +70,38 @@ func errorHasCode(err error, code int) bool {
 	var e *Error
 	return errors.As(err, &e) && e.code == code
 }
+
+// IsLoginRequired reports whether the error is from an action that requires a
+// logged in DEX account while the account is locked, e.g. after Logout.
+func IsLoginRequired(err error) bool {
+	return errorHasCode(err, loginRequiredErr)
+}
+
+// IsWalletLocked reports whether the error is from a wallet that could not be
+// unlocked.
+func IsWalletLocked(err error) bool {
+	return errorHasCode(err, walletAuthErr)
+}
+
+// IsWalletNotFound reports whether the error is from an action requiring a
+// wallet that has not been created.
+func IsWalletNotFound(err error) bool {
+	return errorHasCode(err, missingWalletErr)
+}
+
+// IsInsufficientFunds reports whether the error is from a wallet that does not
+// have enough available funds for the action.
+func IsInsufficientFunds(err error) bool {
+	return errorHasCode(err, insufficientFundsErr)
+}
+
+// IsUnknownMarket reports whether the error is from a market that the DEX does
+// not have.
+func IsUnknownMarket(err error) bool {
+	return errorHasCode(err, unknownMarketErr)
+}
+
+// IsPasswordError reports whether the error is from an incorrect app password.
+func IsPasswordError(err error) bool {
+	return errorHasCode(err, passwordErr)
+}
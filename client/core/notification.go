@@ -77,6 +77,27 @@ func (c *Core) AckNotes(ids []dex.Bytes) {
 	}
 }
 
+// MaxNotifications is the most notifications that Notifications will return.
+const MaxNotifications = 1000
+
+// Notifications returns up to n of the most recent stored notifications, newest
+// first. Only notifications with severity Success or higher are stored. If n is
+// zero or more than MaxNotifications, MaxNotifications is used. The returned
+// slice is empty but non-nil if there are no notifications.
+func (c *Core) Notifications(n int) ([]*db.Notification, error) {
+	if n <= 0 || n > MaxNotifications {
+		n = MaxNotifications
+	}
+	notes, err := c.db.NotificationsN(n)
+	if err != nil {
+		return nil, fmt.Errorf("error reading notifications: %w", err)
+	}
+	if notes == nil {
+		notes = make([]*db.Notification, 0)
+	}
+	return notes, nil
+}
+
 // Notification is an interface for a user notification. Notification is
 // satisfied by db.Notification, so concrete types can embed the db type.
 type Notification interface {
@@ -41,6 +41,13 @@ type matchTracker struct {
 	trade       *order.Trade
 	counterSwap asset.AuditInfo
 
+	// redeemFailures and refundFailures count the failed redemption and
+	// refund broadcasts for this match. Another attempt will not be made
+	// before retryAfter.
+	redeemFailures uint32
+	refundFailures uint32
+	retryAfter     time.Time
+
 	// cancelRedemptionSearch should be set when taker starts searching for
 	// maker's redemption. Required to cancel a find redemption attempt if
 	// taker successfully executes a refund.
@@ -177,6 +184,63 @@ func (t *trackedTrade) coreOrderInternal() *Order {
 	return corder
 }
 
+// matchSettlements returns the settlement state of the tracked trade's matches,
+// including the confirmations of any unredeemed swap contracts.
+func (t *trackedTrade) matchSettlements() []*MatchSettlement {
+	type confCheck struct {
+		settlement  *MatchSettlement
+		swap        dex.Bytes
+		counterSwap asset.Coin
+	}
+	t.mtx.RLock()
+	checks := make([]*confCheck, 0, len(t.matches))
+	for _, match := range t.matches {
+		ms := &MatchSettlement{
+			Match:            matchFromMetaMatch(&match.MetaMatch),
+			Active:           true,
+			SwapConfs:        -1,
+			CounterSwapConfs: -1,
+		}
+		check := &confCheck{settlement: ms}
+		if match.Match.Status < order.MatchComplete && !ms.Revoked && len(ms.Refund) == 0 {
+			check.swap = ms.Swap
+			if match.counterSwap != nil {
+				check.counterSwap = match.counterSwap.Coin()
+			}
+		}
+		checks = append(checks, check)
+	}
+	t.mtx.RUnlock()
+
+	// Query the wallets without holding the mutex.
+	settlements := make([]*MatchSettlement, 0, len(checks))
+	for _, check := range checks {
+		ms := check.settlement
+		if len(check.swap) > 0 {
+			ms.SwapConfsReq = t.wallets.fromAsset.SwapConf
+			confs, err := t.wallets.fromWallet.Confirmations(check.swap)
+			if err != nil {
+				t.dc.log.Errorf("Failed to get confirmations of swap %s (%s) for match %s, order %v: %v",
+					coinIDString(t.wallets.fromAsset.ID, check.swap), t.wallets.fromAsset.Symbol, ms.MatchID, t.UID(), err)
+			} else {
+				ms.SwapConfs = int64(confs)
+			}
+		}
+		if check.counterSwap != nil {
+			ms.CounterConfsReq = t.wallets.toAsset.SwapConf
+			confs, err := check.counterSwap.Confirmations()
+			if err != nil {
+				t.dc.log.Errorf("Failed to get confirmations of the counter-party's swap %s (%s) for match %s, order %v: %v",
+					check.counterSwap, t.wallets.toAsset.Symbol, ms.MatchID, t.UID(), err)
+			} else {
+				ms.CounterSwapConfs = int64(confs)
+			}
+		}
+		settlements = append(settlements, ms)
+	}
+	return settlements
+}
+
 // token is a shortened representation of the order ID.
 func (t *trackedTrade) token() string {
 	id := t.ID()
@@ -710,6 +774,10 @@ func (t *trackedTrade) isRedeemable(match *matchTracker) bool {
 			match.id, match.failErr, proof.RefundCoin)
 		return false
 	}
+	if time.Now().Before(match.retryAfter) {
+		t.dc.log.Tracef("Match %v not redeemable: retrying after %v", match.id, match.retryAfter)
+		return false
+	}
 
 	wallet := t.wallets.toWallet
 	if !wallet.unlocked() {
@@ -753,6 +821,10 @@ func (t *trackedTrade) isRefundable(match *matchTracker) bool {
 			match.id, match.refundErr, proof.RefundCoin)
 		return false
 	}
+	if time.Now().Before(match.retryAfter) {
+		t.dc.log.Tracef("Match %v not refundable: retrying after %v", match.id, match.retryAfter)
+		return false
+	}
 
 	wallet := t.wallets.fromWallet
 	if !wallet.unlocked() {
@@ -945,7 +1017,7 @@ func (c *Core) tick(t *trackedTrade) (assetMap, error) {
 	}
 
 	if len(refunds) > 0 {
-		refunded, err := t.refundMatches(refunds)
+		refunded, err := t.refundMatches(refunds, c.retryPolicyInternal())
 		corder := t.coreOrderInternal()
 		details := fmt.Sprintf("Refunded %.8f %s on order %s",
 			float64(refunded)/conversionFactor, unbip(fromID), t.token())
@@ -1309,11 +1381,20 @@ func (c *Core) redeemMatches(t *trackedTrade, matches []*matchTracker) error {
 	// Send the transaction.
 	redeemWallet, redeemAsset := t.wallets.toWallet, t.wallets.toAsset // this is our redeem
 	coinIDs, outCoin, fees, err := redeemWallet.Redeem(redemptions)
-	// If an error was encountered, fail all of the matches. A failed match will
-	// not run again on during ticks.
+	// If an error was encountered, schedule a retry for each match according
+	// to the retry policy, failing the matches that have no attempts left. A
+	// failed match will not run again on during ticks.
 	if err != nil {
+		policy := c.retryPolicyInternal()
 		for _, match := range matches {
-			match.failErr = err
+			match.redeemFailures++
+			if match.redeemFailures >= policy.MaxRedeemAttempts {
+				match.failErr = err
+				continue
+			}
+			match.retryAfter = time.Now().Add(policy.delay(match.redeemFailures))
+			c.log.Warnf("Redeem attempt %d of %d failed for match %v. Retrying after %v.",
+				match.redeemFailures, policy.MaxRedeemAttempts, match.id, match.retryAfter)
 		}
 		return errs.addErr(err)
 	}
@@ -1485,11 +1566,12 @@ func (t *trackedTrade) findMakersRedemption(match *matchTracker) {
 	}()
 }
 
-// refundMatches will send refund transactions for the specified matches.
+// refundMatches will send refund transactions for the specified matches. Failed
+// refunds are retried according to the RetryPolicy.
 //
 // This method modifies match fields and MUST be called with the trackedTrade
 // mutex lock held for writes.
-func (t *trackedTrade) refundMatches(matches []*matchTracker) (uint64, error) {
+func (t *trackedTrade) refundMatches(matches []*matchTracker, policy RetryPolicy) (uint64, error) {
 	errs := newErrorSet("refundMatches: order %s - ", t.ID())
 
 	refundWallet, refundAsset := t.wallets.fromWallet, t.wallets.fromAsset // refunding to our wallet
@@ -1527,7 +1609,15 @@ func (t *trackedTrade) refundMatches(matches []*matchTracker) (uint64, error) {
 
 		refundCoin, err := refundWallet.Refund(swapCoinID, contractToRefund)
 		if err != nil {
-			match.refundErr = err
+			match.refundFailures++
+			// A spent contract cannot be refunded, so don't retry.
+			if err == asset.CoinNotFoundError || match.refundFailures >= policy.MaxRefundAttempts {
+				match.refundErr = err
+			} else {
+				match.retryAfter = time.Now().Add(policy.delay(match.refundFailures))
+				t.dc.log.Warnf("Refund attempt %d of %d failed for match %v. Retrying after %v.",
+					match.refundFailures, policy.MaxRefundAttempts, match.id, match.retryAfter)
+			}
 			if err == asset.CoinNotFoundError {
 				// Could not find the contract coin, which means it has been spent.
 				// We should have already started FindRedemption for this contract,
@@ -4,10 +4,14 @@
 package core
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/db"
@@ -86,6 +90,30 @@ type WalletState struct {
 	Address   string         `json:"address"`
 	Units     string         `json:"units"`
 	Encrypted bool           `json:"encrypted"`
+	// ConventionalUnit and ConversionFactor are from the asset's WalletInfo,
+	// for converting amounts in Units to the conventional unit.
+	ConventionalUnit string `json:"conventionalUnit"`
+	ConversionFactor uint64 `json:"conversionFactor"`
+	// SyncStatus is the sync progress of the wallet's blockchain node. It is
+	// omitted for wallets that do not report their sync progress.
+	SyncStatus *asset.SyncStatus `json:"syncStatus,omitempty"`
+	// Peers is the network connection status of the wallet's blockchain node,
+	// as of when the wallet connected or last reported a new block. It is
+	// omitted for wallets that do not report their peers, and for wallets that
+	// are not connected.
+	Peers *PeerStatus `json:"peers,omitempty"`
+	// Rescanning is true while a rescan started with RescanWallet is running.
+	// The wallet is not reported as synced until the rescan is complete.
+	Rescanning bool `json:"rescanning"`
+}
+
+// PeerStatus is the network connection status of a wallet's blockchain node. A
+// node that is not syncing may have no peers.
+type PeerStatus struct {
+	// Connected is false if the node could not be reached to count its peers.
+	Connected bool `json:"connected"`
+	// Count is the number of network peers of the node.
+	Count uint32 `json:"count"`
 }
 
 // User is information about the user's wallets and DEX accounts.
@@ -161,6 +189,18 @@ func matchFromMetaMatch(metaMatch *db.MetaMatch) *Match {
 	return match
 }
 
+// MatchSettlement is the settlement state of a match. The confirmation counts
+// of the swap contracts are only checked while the match is being tracked and
+// has not completed. A count of -1 means the confirmations are unknown.
+type MatchSettlement struct {
+	*Match
+	Active           bool   `json:"active"`
+	SwapConfs        int64  `json:"swapConfs"`
+	SwapConfsReq     uint32 `json:"swapConfsRequired,omitempty"`
+	CounterSwapConfs int64  `json:"counterSwapConfs"`
+	CounterConfsReq  uint32 `json:"counterSwapConfsRequired,omitempty"`
+}
+
 // Order is core's general type for an order. An order may be a market, limit,
 // or cancel order. Some fields are only relevant to particular order types.
 type Order struct {
@@ -194,6 +234,159 @@ type FeeBreakdown struct {
 	Redemption uint64 `json:"redemption"`
 }
 
+// TradeReport is a record of a trade, including the order and all of its
+// matches with their swap and redemption coin IDs and timestamps.
+type TradeReport struct {
+	Host      string    `json:"host"`
+	AccountID dex.Bytes `json:"accountID"`
+	Order     *Order    `json:"order"`
+	Stamp     uint64    `json:"stamp"`
+}
+
+// SignedReport is a TradeReport signed with the client's account key for the
+// DEX. The signature is over the SHA-256 hash of the compact JSON encoding of
+// the report, so the report may be re-indented without invalidating it.
+type SignedReport struct {
+	Report json.RawMessage `json:"report"`
+	PubKey dex.Bytes       `json:"pubkey"`
+	Sig    dex.Bytes       `json:"sig"`
+}
+
+// Verify checks that the report was signed by the private key for PubKey.
+func (r *SignedReport) Verify() error {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, r.Report); err != nil {
+		return fmt.Errorf("invalid report JSON: %w", err)
+	}
+	hash := sha256.Sum256(compact.Bytes())
+	_, err := checkSigS256(hash[:], r.PubKey, r.Sig)
+	return err
+}
+
+// RetryPolicy controls how redemption and refund broadcasts that fail are
+// retried. After a failed broadcast, the next attempt waits Backoff, doubling
+// with each subsequent failure up to MaxBackoff. Once MaxRedeemAttempts or
+// MaxRefundAttempts broadcasts have failed, the match is no longer retried.
+type RetryPolicy struct {
+	MaxRedeemAttempts uint32        `json:"maxRedeemAttempts"`
+	MaxRefundAttempts uint32        `json:"maxRefundAttempts"`
+	Backoff           time.Duration `json:"backoff"`
+	MaxBackoff        time.Duration `json:"maxBackoff"`
+}
+
+// Limits for the RetryPolicy fields.
+const (
+	maxRetryAttempts = 20
+	minRetryBackoff  = time.Second
+	maxRetryBackoff  = 24 * time.Hour
+)
+
+// defaultRetryPolicy makes a single broadcast attempt, failing the match on
+// error.
+var defaultRetryPolicy = RetryPolicy{
+	MaxRedeemAttempts: 1,
+	MaxRefundAttempts: 1,
+	Backoff:           time.Minute,
+	MaxBackoff:        time.Hour,
+}
+
+// validate checks that the RetryPolicy's fields are within range.
+func (p *RetryPolicy) validate() error {
+	if p.MaxRedeemAttempts < 1 || p.MaxRedeemAttempts > maxRetryAttempts {
+		return fmt.Errorf("max redeem attempts must be between 1 and %d, got %d",
+			maxRetryAttempts, p.MaxRedeemAttempts)
+	}
+	if p.MaxRefundAttempts < 1 || p.MaxRefundAttempts > maxRetryAttempts {
+		return fmt.Errorf("max refund attempts must be between 1 and %d, got %d",
+			maxRetryAttempts, p.MaxRefundAttempts)
+	}
+	if p.Backoff < minRetryBackoff || p.Backoff > maxRetryBackoff {
+		return fmt.Errorf("backoff must be between %v and %v, got %v",
+			minRetryBackoff, maxRetryBackoff, p.Backoff)
+	}
+	if p.MaxBackoff < p.Backoff || p.MaxBackoff > maxRetryBackoff {
+		return fmt.Errorf("max backoff must be between the backoff (%v) and %v, got %v",
+			p.Backoff, maxRetryBackoff, p.MaxBackoff)
+	}
+	return nil
+}
+
+// delay is the wait before the next attempt after the specified number of
+// failed attempts.
+func (p *RetryPolicy) delay(failures uint32) time.Duration {
+	d := p.Backoff
+	for i := uint32(1); i < failures && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// TradeSettings are client-side limits that are checked before an order is
+// submitted. A zero value disables a limit.
+type TradeSettings struct {
+	// MaxFeeRate is the highest swap fee rate, in atoms per byte, that the
+	// client will agree to pay. Orders are rejected if the DEX's max fee rate
+	// for the asset being swapped is higher.
+	MaxFeeRate uint64 `json:"maxFeeRate"`
+	// MaxOrderLots is the largest order, in lots, that may be placed.
+	MaxOrderLots uint64 `json:"maxOrderLots"`
+}
+
+// check checks that an order for the number of lots, swapping the asset,
+// respects the settings.
+func (s *TradeSettings) check(fromAsset *dex.Asset, lots uint64) error {
+	if s.MaxFeeRate > 0 && fromAsset.MaxFeeRate > s.MaxFeeRate {
+		return fmt.Errorf("the DEX's max fee rate for %s of %d exceeds the configured limit of %d",
+			fromAsset.Symbol, fromAsset.MaxFeeRate, s.MaxFeeRate)
+	}
+	if s.MaxOrderLots > 0 && lots > s.MaxOrderLots {
+		return fmt.Errorf("order of %d lots exceeds the configured limit of %d lots",
+			lots, s.MaxOrderLots)
+	}
+	return nil
+}
+
+// MatchCost is the estimated and actual transaction fees for a single match.
+// The Diff fields are actual minus estimated, so a positive value means more
+// was paid than expected.
+type MatchCost struct {
+	MatchID          dex.Bytes `json:"matchID"`
+	EstSwap          uint64    `json:"estSwap"`
+	ActualSwap       uint64    `json:"actualSwap"`
+	SwapDiff         int64     `json:"swapDiff"`
+	EstRedemption    uint64    `json:"estRedemption"`
+	ActualRedemption uint64    `json:"actualRedemption"`
+	RedemptionDiff   int64     `json:"redemptionDiff"`
+}
+
+// OrderEstimate is the result of PreOrder. It describes the lot requirements
+// of the market and the worst-case fees for a prospective order.
+type OrderEstimate struct {
+	Host     string        `json:"host"`
+	MarketID string        `json:"marketID"`
+	FromID   uint32        `json:"fromID"`
+	ToID     uint32        `json:"toID"`
+	LotSize  uint64        `json:"lotSize"`
+	RateStep uint64        `json:"rateStep"`
+	MinOrder uint64        `json:"minOrder"` // in units of the base asset
+	Lots     uint64        `json:"lots"`
+	Fees     *FeeBreakdown `json:"estimatedFees"`
+}
+
+// SwapCostReport reconciles the estimated swap and redemption fees for an
+// order's matches against the fees that were actually paid.
+type SwapCostReport struct {
+	OrderID dex.Bytes     `json:"orderID"`
+	FromID  uint32        `json:"fromID"`
+	ToID    uint32        `json:"toID"`
+	Matches []*MatchCost  `json:"matches"`
+	Est     *FeeBreakdown `json:"estimated"`
+	Actual  *FeeBreakdown `json:"actual"`
+}
+
 // coreOrderFromTrade constructs an *Order from the supplied limit or market
 // order and associated metadata.
 func coreOrderFromTrade(ord order.Order, metaData *db.OrderMetaData) *Order {
@@ -274,6 +467,41 @@ func (m *Market) marketName() string {
 	return marketName(m.BaseID, m.QuoteID)
 }
 
+// MarketInfo is the configuration and status of a single market, which is
+// what is needed to build an order form for the market.
+type MarketInfo struct {
+	Host            string  `json:"host"`
+	Name            string  `json:"name"`
+	BaseID          uint32  `json:"baseid"`
+	BaseSymbol      string  `json:"basesymbol"`
+	QuoteID         uint32  `json:"quoteid"`
+	QuoteSymbol     string  `json:"quotesymbol"`
+	LotSize         uint64  `json:"lotsize"`
+	RateStep        uint64  `json:"ratestep"`
+	EpochLen        uint64  `json:"epochlen"`
+	MarketBuyBuffer float64 `json:"buybuffer"`
+	Running         bool    `json:"running"`
+	StartEpoch      uint64  `json:"startepoch"`
+	FinalEpoch      uint64  `json:"finalepoch,omitempty"`
+}
+
+// AssetFees is the total of each type of fee paid in a single asset, in the
+// asset's smallest unit.
+type AssetFees struct {
+	Symbol       string `json:"symbol"`
+	Registration uint64 `json:"registration"`
+	Swap         uint64 `json:"swap"`
+	Redemption   uint64 `json:"redemption"`
+}
+
+// FeesPaid is a summary of the fees paid at a DEX, by asset ID, since a time.
+// A Since of zero is all-time.
+type FeesPaid struct {
+	Host   string                `json:"host"`
+	Since  int64                 `json:"since"`
+	Assets map[uint32]*AssetFees `json:"assets"`
+}
+
 // Exchange represents a single DEX with any number of markets.
 type Exchange struct {
 	Host          string                `json:"host"`
@@ -327,18 +555,46 @@ type MarketOrderBook struct {
 	Book  *OrderBook `json:"book"`
 }
 
+// EpochUpdate is used as the BookUpdate's Payload with the NewEpochAction.
+type EpochUpdate struct {
+	Epoch uint64 `json:"epoch"`
+}
+
+// MatchProofUpdate is used as the BookUpdate's Payload with the
+// MatchProofAction. It summarizes the server's match_proof for a completed
+// epoch. Misses are the tokens of the orders whose preimages were not revealed.
+type MatchProofUpdate struct {
+	Epoch     uint64    `json:"epoch"`
+	Preimages int       `json:"preimages"`
+	Misses    []string  `json:"misses"`
+	CSum      dex.Bytes `json:"csum"`
+	Seed      dex.Bytes `json:"seed"`
+}
+
+// Book feed actions. Order updates use the FreshBookAction, BookOrderAction,
+// EpochOrderAction, and UnbookOrderAction. Epoch updates are sent on the same
+// feed with the NewEpochAction when the market moves to a new epoch, and with
+// the MatchProofAction when the match results for an epoch are received, so
+// that consumers can filter them by action.
 const (
 	FreshBookAction   = "book"
 	BookOrderAction   = "book_order"
 	EpochOrderAction  = "epoch_order"
 	UnbookOrderAction = "unbook_order"
+	NewEpochAction    = "epoch"
+	MatchProofAction  = "match_proof"
 )
 
-// BookUpdate is an order book update.
+// BookUpdate is an order book update. Seq is the update's sequence number,
+// which increases with each update of a book. A book sent with the
+// FreshBookAction has the sequence number of the last update it reflects, so a
+// subscriber that loses its feed can request only the updates that followed
+// with SyncBookSince.
 type BookUpdate struct {
 	Action   string      `json:"action"`
 	Host     string      `json:"host"`
 	MarketID string      `json:"marketID"`
+	Seq      uint64      `json:"seq"`
 	Payload  interface{} `json:"payload"`
 }
 
@@ -494,6 +750,20 @@ func (a *dexAccount) markFeePaid() {
 	a.authMtx.Unlock()
 }
 
+// privateKey decrypts and returns the account private key without unlocking
+// the account.
+func (a *dexAccount) privateKey(crypter encrypt.Crypter) (*secp256k1.PrivateKey, error) {
+	a.keyMtx.RLock()
+	encKey := a.encKey
+	a.keyMtx.RUnlock()
+	keyB, err := crypter.Decrypt(encKey)
+	if err != nil {
+		return nil, err
+	}
+	privKey, _ := secp256k1.PrivKeyFromBytes(keyB)
+	return privKey, nil
+}
+
 // sign uses the account private key to sign the message. If the account is
 // locked, an error will be returned.
 func (a *dexAccount) sign(msg []byte) ([]byte, error) {
@@ -582,9 +852,40 @@ type OrderFilter struct {
 	Offset   dex.Bytes           `json:"offset"`
 	Hosts    []string            `json:"hosts"`
 	Assets   []uint32            `json:"assets"`
+	Market   *OrderFilterMarket  `json:"market,omitempty"`
 	Statuses []order.OrderStatus `json:"statuses"`
 }
 
+// OrdersPage is a page of the user's orders, as returned by Core.OrdersPage.
+type OrdersPage struct {
+	Orders []*Order `json:"orders"`
+	// Total is the number of orders passing the filter, on all pages.
+	Total int `json:"total"`
+	// Offset is the index of the first order on this page.
+	Offset int `json:"offset"`
+	// NextOffset is the offset of the next page. It is zero if this is the
+	// last page.
+	NextOffset int `json:"nextOffset,omitempty"`
+}
+
+// OrderFilterMarket is the market for an OrderFilter.
+type OrderFilterMarket struct {
+	Base  uint32 `json:"baseID"`
+	Quote uint32 `json:"quoteID"`
+}
+
+// Candle is the price and volume summary of the trades in a market over a
+// single bin of time. Rates are in atoms of the quote asset per 10^8 atoms of
+// the base asset, and the volume is in atoms of the base asset.
+type Candle struct {
+	StartStamp uint64 `json:"startStamp"`
+	Open       uint64 `json:"open"`
+	High       uint64 `json:"high"`
+	Low        uint64 `json:"low"`
+	Close      uint64 `json:"close"`
+	Volume     uint64 `json:"volume"`
+}
+
 // assetMap tracks a series of assets and provides methods for registering an
 // asset and merging with another assetMap.
 type assetMap map[uint32]struct{}
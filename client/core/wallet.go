@@ -26,6 +26,14 @@ type xcWallet struct {
 	encPW     []byte
 	address   string
 	dbID      []byte
+	// syncStatus is the last sync progress reported by a wallet that
+	// implements asset.SyncReporter.
+	syncStatus *asset.SyncStatus
+	// peers is the last peer status of a wallet that implements
+	// asset.PeerReporter.
+	peers *PeerStatus
+	// rescanning is true while a rescan started by Core.RescanWallet runs.
+	rescanning bool
 }
 
 // Unlock unlocks the wallet.
@@ -73,6 +81,13 @@ func (w *xcWallet) state() *WalletState {
 	w.mtx.RLock()
 	defer w.mtx.RUnlock()
 	winfo := w.Info()
+	syncStatus := w.syncStatus
+	if w.rescanning && syncStatus != nil && syncStatus.Synced {
+		// The wallet is not synced until the rescan is done.
+		ss := *syncStatus
+		ss.Synced = false
+		syncStatus = &ss
+	}
 	return &WalletState{
 		Symbol:    unbip(w.AssetID),
 		AssetID:   w.AssetID,
@@ -82,6 +97,12 @@ func (w *xcWallet) state() *WalletState {
 		Address:   w.address,
 		Units:     winfo.Units,
 		Encrypted: len(w.encPW) > 0,
+
+		ConventionalUnit: winfo.ConventionalUnit,
+		ConversionFactor: winfo.ConversionFactor,
+		SyncStatus:       syncStatus,
+		Peers:            w.peers,
+		Rescanning:       w.rescanning,
 	}
 }
 
@@ -99,6 +120,57 @@ func (w *xcWallet) setAddress(addr string) {
 	w.mtx.Unlock()
 }
 
+// startRescan marks the wallet as rescanning. It returns false if a rescan is
+// already in progress.
+func (w *xcWallet) startRescan() bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.rescanning {
+		return false
+	}
+	w.rescanning = true
+	return true
+}
+
+// endRescan clears the rescanning flag set by startRescan.
+func (w *xcWallet) endRescan() {
+	w.mtx.Lock()
+	w.rescanning = false
+	w.mtx.Unlock()
+}
+
+// refreshSyncStatus updates the sync progress of a wallet that implements
+// asset.SyncReporter. The sync progress is cleared if it cannot be retrieved.
+func (w *xcWallet) refreshSyncStatus() error {
+	reporter, ok := w.Wallet.(asset.SyncReporter)
+	if !ok {
+		return nil
+	}
+	ss, err := reporter.SyncStatus()
+	w.mtx.Lock()
+	w.syncStatus = ss
+	w.mtx.Unlock()
+	return err
+}
+
+// refreshPeers updates the peer status of a connected wallet that implements
+// asset.PeerReporter. If the peers cannot be counted, the node is reported as
+// not connected and the error is returned.
+func (w *xcWallet) refreshPeers() error {
+	reporter, ok := w.Wallet.(asset.PeerReporter)
+	if !ok || !w.connected() {
+		return nil
+	}
+	count, err := reporter.PeerCount()
+	w.mtx.Lock()
+	w.peers = &PeerStatus{
+		Connected: err == nil,
+		Count:     count,
+	}
+	w.mtx.Unlock()
+	return err
+}
+
 // connected is true if the wallet has already been connected.
 func (w *xcWallet) connected() bool {
 	w.mtx.RLock()
@@ -116,6 +188,10 @@ func (w *xcWallet) Connect(ctx context.Context) error {
 	w.mtx.Lock()
 	w.hookedUp = true
 	w.mtx.Unlock()
+	// Not all wallets report sync progress or peers, and a wallet may be
+	// unable to report them until its node is up, so errors are not fatal here.
+	_ = w.refreshSyncStatus()
+	_ = w.refreshPeers()
 	return nil
 }
 
@@ -125,5 +201,7 @@ func (w *xcWallet) Disconnect() {
 	w.connector.Disconnect()
 	w.mtx.Lock()
 	w.hookedUp = false
+	w.syncStatus = nil
+	w.peers = nil
 	w.mtx.Unlock()
 }
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -573,6 +574,94 @@ func (fs filterSet) check(oidB []byte, oBkt *bbolt.Bucket) bool {
 // Orders fetches a slice of orders, sorted by descending time, and filtered
 // with the provided OrderFilter. Orders does not return cancel orders.
 func (db *BoltDB) Orders(orderFilter *db.OrderFilter) (ords []*dexdb.MetaOrder, err error) {
+	filters := db.orderFilters(orderFilter)
+
+	if !orderFilter.Offset.IsZero() {
+		offsetOID := orderFilter.Offset[:]
+		var offsetBucket *bbolt.Bucket
+		var stampB []byte
+		err := db.ordersView(func(master *bbolt.Bucket) error {
+			offsetBucket = master.Bucket(offsetOID)
+			if offsetBucket == nil {
+				return fmt.Errorf("order %s not found", offsetOID)
+			}
+			stampB = getCopy(offsetBucket, updateTimeKey)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, func(oidB []byte, oBkt *bbolt.Bucket) bool {
+			comp := bytes.Compare(oBkt.Get(updateTimeKey), stampB)
+			return comp < 0 || (comp == 0 && bytes.Compare(offsetOID, oidB) < 0)
+		})
+	}
+
+	return db.newestOrders(orderFilter.N, filters.check)
+}
+
+// OrdersPage fetches up to limit orders that pass the filter, skipping the
+// first offset, sorted by descending submission time, with ties broken by
+// descending order ID. The filter's N and Offset are ignored. Unlike Orders,
+// the sort order does not change when an order is updated, so consecutive
+// pages neither skip nor repeat orders unless new orders are submitted. The
+// total number of orders passing the filter is also returned. OrdersPage does
+// not return cancel orders.
+func (db *BoltDB) OrdersPage(orderFilter *db.OrderFilter, offset, limit int) (ords []*dexdb.MetaOrder, total int, err error) {
+	if offset < 0 || limit < 0 {
+		return nil, 0, fmt.Errorf("negative offset or limit")
+	}
+	filters := db.orderFilters(orderFilter)
+	err = db.ordersView(func(master *bbolt.Bucket) error {
+		var pairs []*keyTimePair
+		err := master.ForEach(func(oidB, _ []byte) error {
+			oBkt := master.Bucket(oidB)
+			if oBkt == nil || !filters.check(oidB, oBkt) {
+				return nil
+			}
+			ord, err := order.DecodeOrder(oBkt.Get(orderKey))
+			if err != nil {
+				return fmt.Errorf("error decoding order %x: %w", oidB, err)
+			}
+			pairs = append(pairs, &keyTimePair{
+				k: append([]byte(nil), oidB...),
+				t: uint64(ord.Time()),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			t1, t2 := pairs[i].t, pairs[j].t
+			return t1 > t2 || (t1 == t2 && bytes.Compare(pairs[i].k, pairs[j].k) == 1)
+		})
+		total = len(pairs)
+		if offset >= total {
+			return nil
+		}
+		pairs = pairs[offset:]
+		if limit > 0 && limit < len(pairs) {
+			pairs = pairs[:limit]
+		}
+		ords = make([]*dexdb.MetaOrder, 0, len(pairs))
+		for _, pair := range pairs {
+			o, err := decodeOrderBucket(pair.k, master.Bucket(pair.k))
+			if err != nil {
+				return err
+			}
+			ords = append(ords, o)
+		}
+		return nil
+	})
+	return ords, total, err
+}
+
+// orderFilters builds the filterSet for the OrderFilter's hosts, assets,
+// market, and statuses, as well as a filter that excludes cancel orders. The
+// filter's N and Offset are not considered.
+func (db *BoltDB) orderFilters(orderFilter *db.OrderFilter) filterSet {
 	// Default filter is just to exclude cancel orders.
 	filters := filterSet{
 		func(oidB []byte, oBkt *bbolt.Bucket) bool {
@@ -606,6 +695,13 @@ func (db *BoltDB) Orders(orderFilter *db.OrderFilter) (ords []*dexdb.MetaOrder,
 		})
 	}
 
+	if orderFilter.Market != nil {
+		filters = append(filters, func(_ []byte, oBkt *bbolt.Bucket) bool {
+			return intCoder.Uint32(oBkt.Get(baseKey)) == orderFilter.Market.Base &&
+				intCoder.Uint32(oBkt.Get(quoteKey)) == orderFilter.Market.Quote
+		})
+	}
+
 	if len(orderFilter.Statuses) > 0 {
 		filters = append(filters, func(_ []byte, oBkt *bbolt.Bucket) bool {
 			status := order.OrderStatus(intCoder.Uint16(oBkt.Get(statusKey)))
@@ -618,29 +714,7 @@ func (db *BoltDB) Orders(orderFilter *db.OrderFilter) (ords []*dexdb.MetaOrder,
 		})
 	}
 
-	if !orderFilter.Offset.IsZero() {
-		offsetOID := orderFilter.Offset[:]
-		var offsetBucket *bbolt.Bucket
-		var stampB []byte
-		err := db.ordersView(func(master *bbolt.Bucket) error {
-			offsetBucket = master.Bucket(offsetOID)
-			if offsetBucket == nil {
-				return fmt.Errorf("order %s not found", offsetOID)
-			}
-			stampB = getCopy(offsetBucket, updateTimeKey)
-			return nil
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		filters = append(filters, func(oidB []byte, oBkt *bbolt.Bucket) bool {
-			comp := bytes.Compare(oBkt.Get(updateTimeKey), stampB)
-			return comp < 0 || (comp == 0 && bytes.Compare(offsetOID, oidB) < 0)
-		})
-	}
-
-	return db.newestOrders(orderFilter.N, filters.check)
+	return filters
 }
 
 // decodeOrderBucket decodes the order's *bbolt.Bucket into a *MetaOrder.
@@ -973,6 +1047,71 @@ func (db *BoltDB) SetWalletPassword(wid []byte, newPW []byte) error {
 	})
 }
 
+// Recrypt stores the value in the general-use bucket at the specified key, and
+// sets the encrypted private key of each specified account and the encrypted
+// password of each specified wallet, all in a single transaction. If any
+// account or wallet is not known, nothing is stored.
+func (db *BoltDB) Recrypt(k string, v []byte, acctKeys map[string][]byte, walletPWs map[uint32][]byte) error {
+	if len(k) == 0 {
+		return fmt.Errorf("cannot store with empty key")
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(appBucket)
+		if bucket == nil {
+			return fmt.Errorf("failed to open %s bucket", string(appBucket))
+		}
+		err := bucket.Put([]byte(k), v)
+		if err != nil {
+			return err
+		}
+
+		accts := tx.Bucket(accountsBucket)
+		if accts == nil {
+			return fmt.Errorf("failed to open %s bucket", string(accountsBucket))
+		}
+		for host, encKey := range acctKeys {
+			acct := accts.Bucket([]byte(host))
+			if acct == nil {
+				return fmt.Errorf("account not found for %s", host)
+			}
+			acctB := getCopy(acct, accountKey)
+			if acctB == nil {
+				return fmt.Errorf("empty account found for %s", host)
+			}
+			acctInfo, err := dexdb.DecodeAccountInfo(acctB)
+			if err != nil {
+				return err
+			}
+			acctInfo.EncKey = encKey
+			err = acct.Put(accountKey, acctInfo.Encode())
+			if err != nil {
+				return fmt.Errorf("accountKey put error: %v", err)
+			}
+		}
+
+		wallets := tx.Bucket(walletsBucket)
+		if wallets == nil {
+			return fmt.Errorf("failed to open %s bucket", string(walletsBucket))
+		}
+		for assetID, encPW := range walletPWs {
+			wBkt := wallets.Bucket(uint32Bytes(assetID))
+			if wBkt == nil {
+				return fmt.Errorf("wallet for asset %d not known", assetID)
+			}
+			wallet, err := makeWallet(wBkt)
+			if err != nil {
+				return err
+			}
+			wallet.EncryptedPW = encPW
+			err = wBkt.Put(walletKey, wallet.Encode())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // UpdateBalance updates balance in the wallet bucket.
 func (db *BoltDB) UpdateBalance(wid []byte, bal *db.Balance) error {
 	return db.walletsUpdate(func(master *bbolt.Bucket) error {
@@ -1161,10 +1300,75 @@ func (db *BoltDB) Backup() error {
 	}
 
 	path := filepath.Join(dir, filepath.Base(db.Path()))
+	return db.BackupTo(path, true)
+}
+
+// BackupTo makes a copy of the database at the specified path. An existing
+// file is only overwritten if overwrite is true, and the database file itself
+// is never overwritten. The copy is made within a read transaction, so it is a
+// consistent snapshot of the database even while writes are ongoing.
+func (db *BoltDB) BackupTo(dst string, overwrite bool) error {
+	if dstInfo, err := os.Stat(dst); err == nil {
+		dbInfo, err := os.Stat(db.Path())
+		if err != nil {
+			return fmt.Errorf("unable to check database file: %w", err)
+		}
+		if os.SameFile(dstInfo, dbInfo) {
+			return fmt.Errorf("backup file %s is the database file", dst)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("unable to check backup file: %w", err)
+	}
+
+	if !overwrite {
+		// O_EXCL fails if the file was created since it was checked above.
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			if os.IsExist(err) {
+				return fmt.Errorf("backup file %s already exists", dst)
+			}
+			return fmt.Errorf("unable to create backup file: %w", err)
+		}
+		if err = db.writeBackup(f); err != nil {
+			os.Remove(dst)
+			return err
+		}
+		return nil
+	}
+
+	// Write to a temporary file in the same directory and rename it, so that
+	// an existing backup is replaced only by a complete one.
+	f, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary backup file: %w", err)
+	}
+	if err = db.writeBackup(f); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), dst); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("unable to move backup file into place: %w", err)
+	}
+	return nil
+}
+
+// writeBackup writes a copy of the database to f, and closes it.
+func (db *BoltDB) writeBackup(f *os.File) error {
 	err := db.View(func(tx *bbolt.Tx) error {
-		return tx.CopyFile(path, 0600)
+		_, err := tx.WriteTo(f)
+		return err
 	})
-	return err
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write backup file: %w", err)
+	}
+	return nil
 }
 
 // bucketPutter enables chained calls to (*bbolt.Bucket).Put with error
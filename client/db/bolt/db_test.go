@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -82,6 +83,70 @@ func TestBackup(t *testing.T) {
 	}
 }
 
+func TestBackupTo(t *testing.T) {
+	db := newTestDB(t)
+
+	path := filepath.Join(filepath.Dir(db.Path()), "backupto.db")
+	err := db.BackupTo(path, false)
+	if err != nil {
+		t.Fatalf("unable to backup database: %v", err)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		t.Fatalf("backup file does not exist: %v", err)
+	}
+
+	// The backup should be a usable database.
+	bkp, err := NewDB(path, tLogger)
+	if err != nil {
+		t.Fatalf("error opening backup: %v", err)
+	}
+	bkp.(*BoltDB).Close()
+
+	// Refuse to overwrite without the overwrite flag.
+	err = db.BackupTo(path, false)
+	if err == nil {
+		t.Fatalf("no error overwriting backup without overwrite flag")
+	}
+
+	err = db.BackupTo(path, true)
+	if err != nil {
+		t.Fatalf("unable to overwrite backup: %v", err)
+	}
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("error reading backup directory: %v", err)
+	}
+	for _, fi := range files {
+		if strings.Contains(fi.Name(), ".tmp") {
+			t.Fatalf("temporary backup file %s left behind", fi.Name())
+		}
+	}
+
+	// Never overwrite the database itself, including through a link.
+	dbSize := func() int64 {
+		fi, err := os.Stat(db.Path())
+		if err != nil {
+			t.Fatalf("error checking database file: %v", err)
+		}
+		return fi.Size()
+	}
+	size := dbSize()
+	if err = db.BackupTo(db.Path(), true); err == nil {
+		t.Fatalf("no error backing up to the database file")
+	}
+	link := filepath.Join(filepath.Dir(db.Path()), "dblink.db")
+	if err = os.Symlink(db.Path(), link); err != nil {
+		t.Fatalf("error creating link: %v", err)
+	}
+	if err = db.BackupTo(link, true); err == nil {
+		t.Fatalf("no error backing up to a link to the database file")
+	}
+	if dbSize() != size {
+		t.Fatalf("database file modified")
+	}
+}
+
 func TestStore(t *testing.T) {
 	k := "some random key"
 	boltdb := newTestDB(t)
@@ -329,6 +394,77 @@ func TestWallets(t *testing.T) {
 
 }
 
+func TestRecrypt(t *testing.T) {
+	boltdb := newTestDB(t)
+	const k = "keyParams"
+	if err := boltdb.Store(k, randBytes(50)); err != nil {
+		t.Fatalf("error storing value: %v", err)
+	}
+	acct := dbtest.RandomAccountInfo()
+	if err := boltdb.CreateAccount(acct); err != nil {
+		t.Fatalf("error creating account: %v", err)
+	}
+	w := dbtest.RandomWallet()
+	if err := boltdb.UpdateWallet(w); err != nil {
+		t.Fatalf("error creating wallet: %v", err)
+	}
+
+	check := func(wantV, wantEncKey, wantPW []byte) {
+		t.Helper()
+		v, err := boltdb.Get(k)
+		if err != nil {
+			t.Fatalf("error getting value: %v", err)
+		}
+		if !bytes.Equal(v, wantV) {
+			t.Fatalf("wrong value. wanted %x, got %x", wantV, v)
+		}
+		reAcct, err := boltdb.Account(acct.Host)
+		if err != nil {
+			t.Fatalf("error getting account: %v", err)
+		}
+		if !bytes.Equal(reAcct.EncKey, wantEncKey) {
+			t.Fatalf("wrong account key. wanted %x, got %x", wantEncKey, reAcct.EncKey)
+		}
+		if !bytes.Equal(reAcct.DEXPubKey.SerializeCompressed(), acct.DEXPubKey.SerializeCompressed()) {
+			t.Fatalf("account DEX pubkey changed")
+		}
+		reW, err := boltdb.Wallet(w.ID())
+		if err != nil {
+			t.Fatalf("error getting wallet: %v", err)
+		}
+		if !bytes.Equal(reW.EncryptedPW, wantPW) {
+			t.Fatalf("wrong wallet password. wanted %x, got %x", wantPW, reW.EncryptedPW)
+		}
+	}
+
+	v, encKey, encPW := randBytes(50), randBytes(32), randBytes(32)
+	err := boltdb.Recrypt(k, v, map[string][]byte{acct.Host: encKey}, map[uint32][]byte{w.AssetID: encPW})
+	if err != nil {
+		t.Fatalf("Recrypt error: %v", err)
+	}
+	check(v, encKey, encPW)
+
+	// Nothing is stored if any account is unknown.
+	err = boltdb.Recrypt(k, randBytes(50), map[string][]byte{
+		acct.Host:      randBytes(32),
+		"unknown.host": randBytes(32),
+	}, map[uint32][]byte{w.AssetID: randBytes(32)})
+	if err == nil {
+		t.Fatalf("no error for unknown account")
+	}
+	check(v, encKey, encPW)
+
+	// Or if any wallet is unknown.
+	err = boltdb.Recrypt(k, randBytes(50), nil, map[uint32][]byte{
+		w.AssetID:     randBytes(32),
+		w.AssetID + 1: randBytes(32),
+	})
+	if err == nil {
+		t.Fatalf("no error for unknown wallet")
+	}
+	check(v, encKey, encPW)
+}
+
 func randOrderForMarket(base, quote uint32) order.Order {
 	switch rand.Intn(3) {
 	case 0:
@@ -680,6 +816,14 @@ func TestOrderFilters(t *testing.T) {
 			},
 			expected: []int{5, 3, 2, 0},
 		},
+		{
+			name: "market",
+			filter: &db.OrderFilter{
+				N:      orderCount,
+				Market: &db.OrderFilterMarket{Base: asset3, Quote: asset1},
+			},
+			expected: []int{5, 2},
+		},
 		// Open filter with last order as Offset should return all but that
 		// order, since order 5 is lexicographically after order 4.
 		{
@@ -716,6 +860,99 @@ func TestOrderFilters(t *testing.T) {
 	}
 }
 
+func TestOrdersPage(t *testing.T) {
+	boltdb := newTestDB(t)
+
+	host1 := "somehost.co"
+	host2 := "anotherhost.org"
+	makeOrder := func(host string, stamp int64) *db.MetaOrder {
+		mord := &db.MetaOrder{
+			MetaData: &db.OrderMetaData{
+				Status: order.OrderStatusExecuted,
+				Host:   host,
+				Proof:  db.OrderProof{DEXSig: randBytes(73)},
+			},
+			Order: &order.LimitOrder{
+				P: order.Prefix{
+					BaseAsset:  1,
+					QuoteAsset: 2,
+					ServerTime: encode.UnixTimeMilli(stamp),
+				},
+			},
+		}
+		if err := boltdb.UpdateOrder(mord); err != nil {
+			t.Fatalf("error inserting order: %v", err)
+		}
+		return mord
+	}
+
+	// Inserted out of submission time order.
+	orders := []*db.MetaOrder{
+		makeOrder(host1, 3),
+		makeOrder(host2, 1),
+		makeOrder(host1, 4),
+		makeOrder(host2, 2),
+		makeOrder(host1, 0),
+	}
+	checkPage := func(tag string, ords []*db.MetaOrder, expected []int) {
+		t.Helper()
+		if len(ords) != len(expected) {
+			t.Fatalf("%s: wrong number of orders. wanted %d, got %d", tag, len(expected), len(ords))
+		}
+		for i, j := range expected {
+			if ords[i].Order.ID() != orders[j].Order.ID() {
+				t.Fatalf("%s: index %d wrong ID. wanted %s, got %s", tag, i, orders[j].Order.ID(), ords[i].Order.ID())
+			}
+		}
+	}
+
+	filter := new(db.OrderFilter)
+	ords, total, err := boltdb.OrdersPage(filter, 0, 2)
+	if err != nil {
+		t.Fatalf("OrdersPage error: %v", err)
+	}
+	if total != len(orders) {
+		t.Fatalf("wrong total. wanted %d, got %d", len(orders), total)
+	}
+	checkPage("page 1", ords, []int{2, 0})
+
+	// Updating an order must not shift the pages.
+	orders[3].MetaData.Status = order.OrderStatusRevoked
+	if err := boltdb.UpdateOrder(orders[3]); err != nil {
+		t.Fatalf("error updating order: %v", err)
+	}
+	ords, _, err = boltdb.OrdersPage(filter, 2, 2)
+	if err != nil {
+		t.Fatalf("OrdersPage error: %v", err)
+	}
+	checkPage("page 2", ords, []int{3, 1})
+
+	ords, _, _ = boltdb.OrdersPage(filter, 4, 2)
+	checkPage("page 3", ords, []int{4})
+
+	// Past the end.
+	ords, total, _ = boltdb.OrdersPage(filter, 10, 2)
+	if total != len(orders) {
+		t.Fatalf("wrong total past the end. wanted %d, got %d", len(orders), total)
+	}
+	checkPage("past the end", ords, nil)
+
+	// No limit.
+	ords, _, _ = boltdb.OrdersPage(filter, 1, 0)
+	checkPage("no limit", ords, []int{0, 3, 1, 4})
+
+	// Filtered total.
+	ords, total, _ = boltdb.OrdersPage(&db.OrderFilter{Hosts: []string{host2}}, 0, 1)
+	if total != 2 {
+		t.Fatalf("wrong filtered total. wanted 2, got %d", total)
+	}
+	checkPage("filtered", ords, []int{3})
+
+	if _, _, err := boltdb.OrdersPage(filter, -1, 2); err == nil {
+		t.Fatalf("no error for negative offset")
+	}
+}
+
 func TestOrderChange(t *testing.T) {
 	boltdb := newTestDB(t)
 	// Create an account to use.
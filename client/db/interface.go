@@ -51,6 +51,11 @@ type DB interface {
 	// Orders fetches a slice of orders, sorted by descending time, and filtered
 	// with the provided OrderFilter.
 	Orders(*OrderFilter) ([]*MetaOrder, error)
+	// OrdersPage fetches up to limit orders that pass the filter, skipping the
+	// first offset, sorted by descending submission time. The filter's N and
+	// Offset are ignored. The total number of orders passing the filter is
+	// also returned.
+	OrdersPage(filter *OrderFilter, offset, limit int) ([]*MetaOrder, int, error)
 	// ActiveDEXOrders retrieves orders for a particular dex, specified by its
 	// URL.
 	ActiveDEXOrders(dex string) ([]*MetaOrder, error)
@@ -87,6 +92,12 @@ type DB interface {
 	UpdateWallet(wallet *Wallet) error
 	// SetWalletPassword sets the encrypted password for the wallet.
 	SetWalletPassword(wid []byte, newPW []byte) error
+	// Recrypt stores the value v for the key k, e.g. the key parameters for a
+	// new app password, along with the account private keys and wallet
+	// passwords re-encrypted with the new key, in a single atomic update.
+	// acctKeys are the encrypted account keys by DEX host, and walletPWs are
+	// the encrypted wallet passwords by asset ID.
+	Recrypt(k string, v []byte, acctKeys map[string][]byte, walletPWs map[uint32][]byte) error
 	// UpdateBalance updates a wallet's balance.
 	UpdateBalance(wid []byte, balance *Balance) error
 	// Wallets lists all saved wallets.
@@ -95,6 +106,10 @@ type DB interface {
 	Wallet(wid []byte) (*Wallet, error)
 	// Backup makes a copy of the database.
 	Backup() error
+	// BackupTo makes a copy of the database at the specified path. An
+	// existing file is only overwritten if overwrite is true. The database
+	// file itself is never overwritten.
+	BackupTo(dst string, overwrite bool) error
 	// SaveNotification saves the notification.
 	SaveNotification(*Notification) error
 	// NotificationsN reads out the N most recent notifications.
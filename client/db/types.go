@@ -767,11 +767,21 @@ type OrderFilter struct {
 	// Assets is a list of BIP IDs for acceptable assets. A zero-length Assets
 	// means all assets are accepted.
 	Assets []uint32
+	// Market limits results to a specific market. A nil Market means all
+	// markets are accepted.
+	Market *OrderFilterMarket
 	// Statuses is a list of acceptable statuses. A zero-length Statuses means
 	// all statuses are accepted.
 	Statuses []order.OrderStatus
 }
 
+// OrderFilterMarket is the market for an OrderFilter, specified by the BIP IDs
+// of the base and quote assets.
+type OrderFilterMarket struct {
+	Base  uint32
+	Quote uint32
+}
+
 // noteKeySize must be <= 32.
 const noteKeySize = 8
 
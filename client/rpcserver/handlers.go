@@ -4,39 +4,81 @@
 package rpcserver
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/order"
+	"github.com/decred/slog"
 )
 
 // routes
 const (
-	cancelRoute      = "cancel"
-	closeWalletRoute = "closewallet"
-	exchangesRoute   = "exchanges"
-	helpRoute        = "help"
-	initRoute        = "init"
-	loginRoute       = "login"
-	logoutRoute      = "logout"
-	myOrdersRoute    = "myorders"
-	newWalletRoute   = "newwallet"
-	openWalletRoute  = "openwallet"
-	orderBookRoute   = "orderbook"
-	getFeeRoute      = "getfee"
-	registerRoute    = "register"
-	tradeRoute       = "trade"
-	versionRoute     = "version"
-	walletsRoute     = "wallets"
-	withdrawRoute    = "withdraw"
-	marketsRoute     = "markets"
+	addressRoute          = "address"
+	backupRoute           = "backup"
+	cancelRoute           = "cancel"
+	candlesRoute          = "candles"
+	changeAppPassRoute    = "changeapppass"
+	closeWalletRoute      = "closewallet"
+	closeAllWalletsRoute  = "closeallwallets"
+	connStatsRoute        = "connstats"
+	connStatusRoute       = "connstatus"
+	disconnectDEXRoute    = "disconnectdex"
+	exchangesRoute        = "exchanges"
+	feeRateRoute          = "feerate"
+	feesPaidRoute         = "feespaid"
+	getDEXConfigRoute     = "getdexconfig"
+	getRetryPolicyRoute   = "getretrypolicy"
+	getSettingsRoute      = "getsettings"
+	getNotificationsRoute = "getnotifications"
+	helpRoute             = "help"
+	initRoute             = "init"
+	loginRoute            = "login"
+	logLevelRoute         = "loglevel"
+	logoutRoute           = "logout"
+	marketRoute           = "market"
+	matchesRoute          = "matches"
+	myOrdersRoute         = "myorders"
+	newWalletRoute        = "newwallet"
+	openWalletRoute       = "openwallet"
+	openAllWalletsRoute   = "openallwallets"
+	orderBookRoute        = "orderbook"
+	ordersRoute           = "orders"
+	pingRoute             = "ping"
+	preOrderRoute         = "preorder"
+	rawRoute              = "raw"
+	getFeeRoute           = "getfee"
+	registerRoute         = "register"
+	rescanWalletRoute     = "rescanwallet"
+	reconfigWalletRoute   = "reconfigwallet"
+	setRetryPolicyRoute   = "setretrypolicy"
+	setSettingsRoute      = "setsettings"
+	shutdownRoute         = "shutdown"
+	supportedAssetsRoute  = "supportedassets"
+	swapCostsRoute        = "swapcosts"
+	tradeRoute            = "trade"
+	tradeReportRoute      = "tradereport"
+	validateAddressRoute  = "validateaddress"
+	versionRoute          = "version"
+	walletsRoute          = "wallets"
+	walletStateRoute      = "walletstate"
+	withdrawRoute         = "withdraw"
+	wsClientsRoute        = "wsclients"
+	wsDisconnectRoute     = "wsdisconnect"
+	marketsRoute          = "markets"
 )
 
 const (
@@ -44,10 +86,20 @@ const (
 	walletCreatedStr  = "%s wallet created and unlocked"
 	walletLockedStr   = "%s wallet locked"
 	walletUnlockedStr = "%s wallet unlocked"
+	walletReconfigStr = "%s wallet reconfigured"
+	appPassChangedStr = "app password changed"
 	canceledOrderStr  = "canceled order %s"
 	logoutStr         = "goodbye"
+	disconnectedStr   = "disconnected from %s"
+	wsDisconnectedStr = "websocket client %d disconnected"
+	shutdownStr       = "shutting down"
+	rescanStartedStr  = "%s wallet rescan started"
 )
 
+// regFeeAssetID is the ID of the asset that registration fees are paid in,
+// DCR.
+const regFeeAssetID = 42
+
 // createResponse creates a msgjson response payload.
 func createResponse(op string, res interface{}, resErr *msgjson.Error) *msgjson.ResponsePayload {
 	encodedRes, err := json.Marshal(res)
@@ -67,35 +119,120 @@ func usage(route string, err error) *msgjson.ResponsePayload {
 	return createResponse(route, nil, resErr)
 }
 
+// errCode maps core errors that a client may want to handle specifically onto
+// their msgjson error codes, e.g. msgjson.RPCLoginRequiredError if the DEX
+// account is locked and the client must login again. Otherwise, the route's
+// generic code is returned.
+func errCode(err error, code int) int {
+	switch {
+	case core.IsLoginRequired(err):
+		return msgjson.RPCLoginRequiredError
+	case core.IsPasswordError(err):
+		return msgjson.RPCPasswordError
+	case core.IsWalletNotFound(err):
+		return msgjson.RPCWalletNotFoundError
+	case core.IsWalletLocked(err):
+		return msgjson.RPCWalletLockedError
+	case core.IsInsufficientFunds(err):
+		return msgjson.RPCInsufficientFundsError
+	case core.IsUnknownMarket(err):
+		return msgjson.UnknownMarketError
+	}
+	return code
+}
+
 // routes maps routes to a handler function.
 var routes = map[string]func(s *RPCServer, params *RawParams) *msgjson.ResponsePayload{
-	cancelRoute:      handleCancel,
-	closeWalletRoute: handleCloseWallet,
-	exchangesRoute:   handleExchanges,
-	helpRoute:        handleHelp,
-	initRoute:        handleInit,
-	loginRoute:       handleLogin,
-	logoutRoute:      handleLogout,
-	myOrdersRoute:    handleMyOrders,
-	newWalletRoute:   handleNewWallet,
-	openWalletRoute:  handleOpenWallet,
-	orderBookRoute:   handleOrderBook,
-	getFeeRoute:      handleGetFee,
-	registerRoute:    handleRegister,
-	tradeRoute:       handleTrade,
-	versionRoute:     handleVersion,
-	walletsRoute:     handleWallets,
-	withdrawRoute:    handleWithdraw,
+	addressRoute:          handleAddress,
+	backupRoute:           handleBackup,
+	cancelRoute:           handleCancel,
+	candlesRoute:          handleCandles,
+	changeAppPassRoute:    handleChangeAppPass,
+	closeWalletRoute:      handleCloseWallet,
+	closeAllWalletsRoute:  handleCloseAllWallets,
+	connStatsRoute:        handleConnStats,
+	connStatusRoute:       handleConnStatus,
+	disconnectDEXRoute:    handleDisconnectDEX,
+	exchangesRoute:        handleExchanges,
+	feeRateRoute:          handleFeeRate,
+	feesPaidRoute:         handleFeesPaid,
+	getDEXConfigRoute:     handleGetDEXConfig,
+	getRetryPolicyRoute:   handleGetRetryPolicy,
+	getSettingsRoute:      handleGetSettings,
+	getNotificationsRoute: handleGetNotifications,
+	helpRoute:             handleHelp,
+	initRoute:             handleInit,
+	loginRoute:            handleLogin,
+	logLevelRoute:         handleLogLevel,
+	logoutRoute:           handleLogout,
+	marketRoute:           handleMarket,
+	matchesRoute:          handleMatches,
+	myOrdersRoute:         handleMyOrders,
+	ordersRoute:           handleOrders,
+	newWalletRoute:        handleNewWallet,
+	openWalletRoute:       handleOpenWallet,
+	openAllWalletsRoute:   handleOpenAllWallets,
+	orderBookRoute:        handleOrderBook,
+	pingRoute:             handlePing,
+	rawRoute:              handleRaw,
+	getFeeRoute:           handleGetFee,
+	registerRoute:         handleRegister,
+	rescanWalletRoute:     handleRescanWallet,
+	reconfigWalletRoute:   handleReconfigWallet,
+	setRetryPolicyRoute:   handleSetRetryPolicy,
+	setSettingsRoute:      handleSetSettings,
+	shutdownRoute:         handleShutdown,
+	supportedAssetsRoute:  handleSupportedAssets,
+	swapCostsRoute:        handleSwapCosts,
+	tradeRoute:            handleTrade,
+	preOrderRoute:         handlePreOrder,
+	tradeReportRoute:      handleTradeReport,
+	validateAddressRoute:  handleValidateAddress,
+	versionRoute:          handleVersion,
+	walletsRoute:          handleWallets,
+	walletStateRoute:      handleWalletState,
+	withdrawRoute:         handleWithdraw,
+	wsClientsRoute:        handleWSClients,
+	wsDisconnectRoute:     handleWSDisconnect,
+}
+
+// routeNames are the sorted names of the routes, as reported by the version
+// route. They are listed in init since handleVersion is itself in routes.
+var routeNames []string
+
+func init() {
+	routeNames = make([]string, 0, len(routes))
+	for route := range routes {
+		routeNames = append(routeNames, route)
+	}
+	sort.Strings(routeNames)
 }
 
+// adminRoutes are the routes that require the admin scope, which is granted by
+// the admin token.
+var adminRoutes = map[string]bool{
+	logLevelRoute:     true,
+	rawRoute:          true,
+	wsClientsRoute:    true,
+	wsDisconnectRoute: true,
+}
+
+// wsDisconnectReason is sent to a websocket client disconnected by the
+// wsdisconnect route.
+const wsDisconnectReason = "disconnected by the operator"
+
 // handleHelp handles requests for help. Returns general help for all commands
 // if no arguments are passed or verbose help if the passed argument is a known
-// command.
+// command. If structured help is requested, the routeHelp for the command, or
+// for every route if no command is passed, is returned instead.
 func handleHelp(_ *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	form, err := parseHelpArgs(params)
 	if err != nil {
 		return usage(helpRoute, err)
 	}
+	if form.structured {
+		return structuredHelp(form)
+	}
 	res := ""
 	if form.helpWith == "" {
 		// List all commands if no arguments.
@@ -112,32 +249,114 @@ func handleHelp(_ *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(helpRoute, &res, nil)
 }
 
+// structuredHelp creates the help route's response when structured help is
+// requested. Password arguments are omitted unless requested.
+func structuredHelp(form *helpForm) *msgjson.ResponsePayload {
+	help := func(route string) *routeHelp {
+		rh := *routeHelps[route]
+		if !form.includePasswords {
+			rh.PWArgs = nil
+		}
+		return &rh
+	}
+	if form.helpWith == "" {
+		res := make([]*routeHelp, 0, len(routeHelps))
+		for _, r := range sortHelpKeys() {
+			res = append(res, help(r))
+		}
+		return createResponse(helpRoute, res, nil)
+	}
+	if _, exists := routeHelps[form.helpWith]; !exists {
+		resErr := msgjson.NewError(msgjson.RPCUnknownRoute,
+			fmt.Sprintf("%v: %s", errUnknownCmd, form.helpWith))
+		return createResponse(helpRoute, nil, resErr)
+	}
+	return createResponse(helpRoute, help(form.helpWith), nil)
+}
+
 // handleInit handles requests for init. *msgjson.ResponsePayload.Error is empty
 // if successful.
 func handleInit(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
-	appPass, err := parseInitArgs(params)
+	appPass, err := parseInitArgs(params, s.envPassPrefix)
 	if err != nil {
 		return usage(initRoute, err)
 	}
 	defer appPass.Clear()
 	if err := s.core.InitializeClient(appPass); err != nil {
 		errMsg := fmt.Sprintf("unable to initialize client: %v", err)
-		resErr := msgjson.NewError(msgjson.RPCInitError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCInitError), errMsg)
 		return createResponse(initRoute, nil, resErr)
 	}
 	res := initializedStr
 	return createResponse(initRoute, &res, nil)
 }
 
+// handleChangeAppPass handles requests for changeapppass.
+// *msgjson.ResponsePayload.Error is empty if successful. Requires the current
+// and new app passwords. An error with code RPCPasswordError is returned if the
+// current password is incorrect.
+func handleChangeAppPass(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	oldPass, newPass, err := parseChangeAppPassArgs(params)
+	if err != nil {
+		return usage(changeAppPassRoute, err)
+	}
+	defer oldPass.Clear()
+	defer newPass.Clear()
+	if err := s.core.ChangeAppPass(oldPass, newPass); err != nil {
+		errMsg := fmt.Sprintf("unable to change app password: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCChangeAppPassError), errMsg)
+		return createResponse(changeAppPassRoute, nil, resErr)
+	}
+	res := appPassChangedStr
+	return createResponse(changeAppPassRoute, &res, nil)
+}
+
+// handleBackup handles requests for backup. *msgjson.ResponsePayload.Error is
+// empty if successful. Requires the app password. The database is copied to
+// the specified path on the client's machine.
+func handleBackup(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseBackupArgs(params)
+	if err != nil {
+		return usage(backupRoute, err)
+	}
+	defer form.appPass.Clear()
+	path, checksum, err := s.core.Backup(form.appPass, form.path, form.overwrite)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to backup database: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCBackupError), errMsg)
+		return createResponse(backupRoute, nil, resErr)
+	}
+	res := &backupResponse{
+		Path:     path,
+		Checksum: dex.Bytes(checksum).String(),
+	}
+	return createResponse(backupRoute, res, nil)
+}
+
 // handleVersion handles requests for version. It takes no arguments and returns
-// the semver.
-func handleVersion(_ *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+// the RPC semver and, if known, the client application's version.
+func handleVersion(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
 	res := &versionResponse{
-		Major: rpcSemverMajor,
-		Minor: rpcSemverMinor,
-		Patch: rpcSemverPatch,
+		Major:  rpcSemverMajor,
+		Minor:  rpcSemverMinor,
+		Patch:  rpcSemverPatch,
+		Routes: routeNames,
+		Capabilities: &capabilities{
+			Batch:   true,
+			TLS:     s.certs != nil,
+			MTLS:    len(s.clientCAFingerprints) > 0,
+			Metrics: s.metrics != nil,
+			Admin:   s.hasAdmin,
+			Raw:     s.unsafeRaw && s.hasAdmin,
+		},
 	}
-	return createResponse(versionRoute, res.String(), nil)
+	if s.appVersion != "" {
+		res.App = &appVersion{
+			Version:   s.appVersion,
+			GoVersion: runtime.Version(),
+		}
+	}
+	return createResponse(versionRoute, res, nil)
 }
 
 // handleNewWallet handles requests for newwallet.
@@ -145,7 +364,7 @@ func handleVersion(_ *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
 // msgjson.RPCWalletExistsError if a wallet for the assetID already exists.
 // Wallet will be unlocked if successful.
 func handleNewWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
-	form, err := parseNewWalletArgs(params)
+	form, err := parseNewWalletArgs(params, s.envPassPrefix)
 	if err != nil {
 		return usage(newWalletRoute, err)
 	}
@@ -170,7 +389,7 @@ func handleNewWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	if err != nil {
 		errMsg := fmt.Sprintf("error creating %s wallet: %v",
 			dex.BipIDSymbol(form.assetID), err)
-		resErr := msgjson.NewError(msgjson.RPCCreateWalletError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCCreateWalletError), errMsg)
 		return createResponse(newWalletRoute, nil, resErr)
 	}
 
@@ -186,11 +405,34 @@ func handleNewWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(newWalletRoute, &res, nil)
 }
 
+// handleReconfigWallet handles requests for reconfigwallet.
+// *msgjson.ResponsePayload.Error is empty if successful. Requires the app
+// password. The wallet is reloaded with the new settings, which are only saved
+// if the reloaded wallet connects. Otherwise, the existing wallet is unchanged.
+func handleReconfigWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseReconfigWalletArgs(params)
+	if err != nil {
+		return usage(reconfigWalletRoute, err)
+	}
+	defer form.appPass.Clear()
+
+	err = s.core.ReconfigureWallet(form.appPass, form.assetID, form.config)
+	if err != nil {
+		errMsg := fmt.Sprintf("error reconfiguring %s wallet: %v",
+			dex.BipIDSymbol(form.assetID), err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCReconfigWalletError), errMsg)
+		return createResponse(reconfigWalletRoute, nil, resErr)
+	}
+
+	res := fmt.Sprintf(walletReconfigStr, dex.BipIDSymbol(form.assetID))
+	return createResponse(reconfigWalletRoute, &res, nil)
+}
+
 // handleOpenWallet handles requests for openWallet.
 // *msgjson.ResponsePayload.Error is empty if successful. Requires the app
 // password. Opens the wallet.
 func handleOpenWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
-	form, err := parseOpenWalletArgs(params)
+	form, err := parseOpenWalletArgs(params, s.envPassPrefix)
 	if err != nil {
 		return usage(openWalletRoute, err)
 	}
@@ -200,7 +442,7 @@ func handleOpenWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload
 	if err != nil {
 		errMsg := fmt.Sprintf("error unlocking %s wallet: %v",
 			dex.BipIDSymbol(form.assetID), err)
-		resErr := msgjson.NewError(msgjson.RPCOpenWalletError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCOpenWalletError), errMsg)
 		return createResponse(openWalletRoute, nil, resErr)
 	}
 
@@ -218,7 +460,7 @@ func handleCloseWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload
 	if err := s.core.CloseWallet(assetID); err != nil {
 		errMsg := fmt.Sprintf("unable to close wallet %s: %v",
 			dex.BipIDSymbol(assetID), err)
-		resErr := msgjson.NewError(msgjson.RPCCloseWalletError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCCloseWalletError), errMsg)
 		return createResponse(closeWalletRoute, nil, resErr)
 	}
 
@@ -226,12 +468,167 @@ func handleCloseWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload
 	return createResponse(closeWalletRoute, &res, nil)
 }
 
+// handleOpenAllWallets handles requests for openallwallets.
+// *msgjson.ResponsePayload.Error is empty unless the arguments or the
+// password are wrong. Attempts to open every wallet, returning the result for
+// each. A wallet that fails to open does not prevent opening the others.
+func handleOpenAllWallets(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	appPass, err := parseOpenAllWalletsArgs(params)
+	if err != nil {
+		return usage(openAllWalletsRoute, err)
+	}
+	defer appPass.Clear()
+
+	res := make(map[string]*walletResult)
+	for _, w := range s.core.Wallets() {
+		err := s.core.OpenWallet(w.AssetID, appPass)
+		if core.IsPasswordError(err) {
+			// No wallet can be opened with the wrong password.
+			resErr := msgjson.NewError(msgjson.RPCPasswordError, fmt.Sprintf("error opening wallets: %v", err))
+			return createResponse(openAllWalletsRoute, nil, resErr)
+		}
+		res[w.Symbol] = newWalletResult(w.AssetID, err)
+	}
+	return createResponse(openAllWalletsRoute, res, nil)
+}
+
+// handleCloseAllWallets handles requests for closeallwallets.
+// *msgjson.ResponsePayload.Error is empty unless the arguments are wrong.
+// Attempts to close every wallet, returning the result for each. A wallet that
+// fails to close, e.g. with active swaps, does not prevent closing the others.
+func handleCloseAllWallets(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	if err := checkNArgs(params, []int{0}, []int{0}); err != nil {
+		return usage(closeAllWalletsRoute, err)
+	}
+	res := make(map[string]*walletResult)
+	for _, w := range s.core.Wallets() {
+		res[w.Symbol] = newWalletResult(w.AssetID, s.core.CloseWallet(w.AssetID))
+	}
+	return createResponse(closeAllWalletsRoute, res, nil)
+}
+
 // handleWallets handles requests for wallets. Returns a list of wallet details.
 func handleWallets(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
 	walletsStates := s.core.Wallets()
 	return createResponse(walletsRoute, walletsStates, nil)
 }
 
+// handleSupportedAssets handles requests for supportedassets. It takes no
+// arguments and returns every asset supported by this build, ordered by asset
+// ID, including those with no wallet.
+func handleSupportedAssets(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	supported := s.core.SupportedAssets()
+	res := make([]*supportedAssetResult, 0, len(supported))
+	for _, a := range supported {
+		r := &supportedAssetResult{
+			ID:        a.ID,
+			Symbol:    a.Symbol,
+			Driver:    a.Info != nil,
+			HasWallet: a.Wallet != nil,
+		}
+		if a.Info != nil {
+			r.Name = a.Info.Name
+		}
+		if a.Wallet != nil {
+			r.Open = a.Wallet.Open
+			r.Running = a.Wallet.Running
+		}
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return createResponse(supportedAssetsRoute, res, nil)
+}
+
+// handleWalletState handles requests for walletstate.
+// *msgjson.ResponsePayload.Error is empty if successful. Requires an asset ID
+// and returns the state of that asset's wallet.
+func handleWalletState(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	assetID, err := parseWalletStateArgs(params)
+	if err != nil {
+		return usage(walletStateRoute, err)
+	}
+	state := s.core.WalletState(assetID)
+	if state == nil {
+		errMsg := fmt.Sprintf("no %s wallet", dex.BipIDSymbol(assetID))
+		resErr := msgjson.NewError(msgjson.RPCWalletNotFoundError, errMsg)
+		return createResponse(walletStateRoute, nil, resErr)
+	}
+	return createResponse(walletStateRoute, state, nil)
+}
+
+// handleRescanWallet handles requests for rescanwallet.
+// *msgjson.ResponsePayload.Error is empty if the rescan was started.
+func handleRescanWallet(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	assetID, err := parseRescanWalletArgs(params)
+	if err != nil {
+		return usage(rescanWalletRoute, err)
+	}
+	if err := s.core.RescanWallet(assetID); err != nil {
+		errMsg := fmt.Sprintf("unable to rescan %s wallet: %v",
+			dex.BipIDSymbol(assetID), err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCRescanWalletError), errMsg)
+		return createResponse(rescanWalletRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(rescanStartedStr, dex.BipIDSymbol(assetID))
+	return createResponse(rescanWalletRoute, &res, nil)
+}
+
+// handleFeeRate handles requests for feerate. *msgjson.ResponsePayload.Error is
+// empty if successful. Returns the wallet's current network fee rate estimate.
+func handleFeeRate(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	assetID, err := parseFeeRateArgs(params)
+	if err != nil {
+		return usage(feeRateRoute, err)
+	}
+	feeRate, err := s.core.FeeRate(assetID)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to get %s fee rate: %v",
+			dex.BipIDSymbol(assetID), err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCFeeRateError), errMsg)
+		return createResponse(feeRateRoute, nil, resErr)
+	}
+	res := &feeRateResponse{FeeRate: feeRate}
+	if winfo, err := asset.Info(assetID); err == nil {
+		res.Units = winfo.Units + "/byte"
+	}
+	return createResponse(feeRateRoute, res, nil)
+}
+
+// handleAddress handles requests for address. *msgjson.ResponsePayload.Error
+// is empty if successful. Returns a receiving address from the wallet.
+func handleAddress(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	assetID, err := parseAddressArgs(params)
+	if err != nil {
+		return usage(addressRoute, err)
+	}
+	addr, err := s.core.NewDepositAddress(assetID)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to get %s address: %v",
+			dex.BipIDSymbol(assetID), err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCAddressError), errMsg)
+		return createResponse(addressRoute, nil, resErr)
+	}
+	return createResponse(addressRoute, &addr, nil)
+}
+
+// handleValidateAddress handles requests for validateaddress.
+// *msgjson.ResponsePayload.Error is empty if the address could be checked.
+// Returns whether the address is well-formed for the asset's network.
+func handleValidateAddress(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseValidateAddressArgs(params)
+	if err != nil {
+		return usage(validateAddressRoute, err)
+	}
+	valid, err := s.core.ValidateAddress(form.assetID, form.address)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to validate %s address: %v",
+			dex.BipIDSymbol(form.assetID), err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCValidateAddressError), errMsg)
+		return createResponse(validateAddressRoute, nil, resErr)
+	}
+	return createResponse(validateAddressRoute, &valid, nil)
+}
+
 // handleGetFee handles requests for getfee.
 // *msgjson.ResponsePayload.Error is empty if successful. Requires the address
 // of a dex and returns the dex fee.
@@ -248,13 +645,36 @@ func handleGetFee(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	res := &getFeeResponse{
 		Fee: fee,
 	}
+	// The conversion info is only available if the asset is registered.
+	if winfo, err := asset.Info(regFeeAssetID); err == nil && winfo.ConversionFactor > 0 {
+		res.Conventional = float64(fee) / float64(winfo.ConversionFactor)
+		res.Units = winfo.Units
+		res.ConventionalUnit = winfo.ConventionalUnit
+		res.ConversionFactor = winfo.ConversionFactor
+	}
 	return createResponse(getFeeRoute, res, nil)
 }
 
+// handleGetDEXConfig handles requests for getdexconfig.
+// *msgjson.ResponsePayload.Error is empty if successful. Requires the address
+// of a dex and returns its markets and assets.
+func handleGetDEXConfig(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	host, cert, err := parseGetDEXConfigArgs(params)
+	if err != nil {
+		return usage(getDEXConfigRoute, err)
+	}
+	exchange, err := s.core.DEXConfig(host, cert)
+	if err != nil {
+		resErr := msgjson.NewError(msgjson.RPCDEXConfigError, err.Error())
+		return createResponse(getDEXConfigRoute, nil, resErr)
+	}
+	return createResponse(getDEXConfigRoute, exchange, nil)
+}
+
 // handleRegister handles requests for register. *msgjson.ResponsePayload.Error
 // is empty if successful.
 func handleRegister(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
-	form, err := parseRegisterArgs(params)
+	form, err := parseRegisterArgs(params, s.envPassPrefix)
 	if err != nil {
 		return usage(registerRoute, err)
 	}
@@ -272,7 +692,7 @@ func handleRegister(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	}
 	res, err := s.core.Register(form)
 	if err != nil {
-		resErr := &msgjson.Error{Code: msgjson.RPCRegisterError, Message: err.Error()}
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCRegisterError), err.Error())
 		return createResponse(registerRoute, nil, resErr)
 	}
 	return createResponse(registerRoute, res, nil)
@@ -281,14 +701,18 @@ func handleRegister(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 // handleExchanges handles requests for exchangess. It takes no arguments and
 // returns a map of exchanges.
 func handleExchanges(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
-	// Convert something to a map[string]interface{}.
+	// Convert something to a map[string]interface{}. Numbers are decoded as
+	// json.Number rather than float64 so that atom amounts, e.g. the lot size
+	// and fees, are not rounded when re-encoded.
 	convM := func(in interface{}) map[string]interface{} {
 		var m map[string]interface{}
 		b, err := json.Marshal(in)
 		if err != nil {
 			panic(err)
 		}
-		if err = json.Unmarshal(b, &m); err != nil {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err = dec.Decode(&m); err != nil {
 			panic(err)
 		}
 		return m
@@ -327,7 +751,7 @@ func handleExchanges(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
 
 // handleLogin sets up the dex connection and returns core.LoginResult.
 func handleLogin(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
-	appPass, err := parseLoginArgs(params)
+	appPass, err := parseLoginArgs(params, s.envPassPrefix)
 	if err != nil {
 		return usage(loginRoute, err)
 	}
@@ -335,7 +759,7 @@ func handleLogin(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	res, err := s.core.Login(appPass)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to login: %v", err)
-		resErr := msgjson.NewError(msgjson.RPCLoginError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCLoginError), errMsg)
 		return createResponse(loginRoute, nil, resErr)
 	}
 	return createResponse(loginRoute, &res, nil)
@@ -352,7 +776,7 @@ func handleTrade(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	res, err := s.core.Trade(form.appPass, form.srvForm)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to trade: %v", err)
-		resErr := msgjson.NewError(msgjson.RPCTradeError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCTradeError), errMsg)
 		return createResponse(tradeRoute, nil, resErr)
 	}
 	tradeRes := &tradeResponse{
@@ -363,6 +787,22 @@ func handleTrade(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(tradeRoute, &tradeRes, nil)
 }
 
+// handlePreOrder handles requests for preorder. *msgjson.ResponsePayload.Error
+// is empty if successful.
+func handlePreOrder(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parsePreOrderArgs(params)
+	if err != nil {
+		return usage(preOrderRoute, err)
+	}
+	est, err := s.core.PreOrder(form)
+	if err != nil {
+		errMsg := fmt.Sprintf("invalid order: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCPreOrderError), errMsg)
+		return createResponse(preOrderRoute, nil, resErr)
+	}
+	return createResponse(preOrderRoute, est, nil)
+}
+
 // handleCancel handles requests for cancel. *msgjson.ResponsePayload.Error is
 // empty if successful.
 func handleCancel(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
@@ -373,7 +813,7 @@ func handleCancel(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	defer form.appPass.Clear()
 	if err := s.core.Cancel(form.appPass, form.orderID); err != nil {
 		errMsg := fmt.Sprintf("unable to cancel order %q: %v", form.orderID, err)
-		resErr := msgjson.NewError(msgjson.RPCCancelError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCCancelError), errMsg)
 		return createResponse(cancelRoute, nil, resErr)
 	}
 	res := fmt.Sprintf(canceledOrderStr, form.orderID)
@@ -391,25 +831,130 @@ func handleWithdraw(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	coin, err := s.core.Withdraw(form.appPass, form.assetID, form.value, form.address)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to withdraw: %v", err)
-		resErr := msgjson.NewError(msgjson.RPCWithdrawError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCWithdrawError), errMsg)
 		return createResponse(withdrawRoute, nil, resErr)
 	}
 	res := coin.String()
 	return createResponse(withdrawRoute, &res, nil)
 }
 
+// handleWSClients handles requests for wsclients. It lists the connected
+// websocket clients. Requires the admin scope.
+func handleWSClients(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(wsClientsRoute, s.wsServer.Clients(), nil)
+}
+
+// handleWSDisconnect handles requests for wsdisconnect. It disconnects the
+// websocket client with the ID, stopping its market feeds. Requires the admin
+// scope. *msgjson.ResponsePayload.Error is empty if successful.
+func handleWSDisconnect(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	id, err := parseWSDisconnectArgs(params)
+	if err != nil {
+		return usage(wsDisconnectRoute, err)
+	}
+	if !s.wsServer.DisconnectClient(id, wsDisconnectReason) {
+		errMsg := fmt.Sprintf("no websocket client with ID %d", id)
+		resErr := msgjson.NewError(msgjson.RPCWSClientError, errMsg)
+		return createResponse(wsDisconnectRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(wsDisconnectedStr, id)
+	return createResponse(wsDisconnectRoute, &res, nil)
+}
+
+// handleLogLevel handles requests for loglevel. It sets the level of every
+// logger in the application with Config.SetLogLevel, or of the RPC server's
+// logger if it is not set, so that verbosity can be changed without a
+// restart. Requires the admin scope. Returns the previous and new levels.
+func handleLogLevel(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	lvl, err := parseLogLevelArgs(params)
+	if err != nil {
+		return usage(logLevelRoute, err)
+	}
+	setLevel := s.setLogLevel
+	if setLevel == nil {
+		setLevel = func(lvl slog.Level) slog.Level {
+			prev := log.Level()
+			log.SetLevel(lvl)
+			return prev
+		}
+	}
+	log.Infof("Changing the log level to %s", logLevelName(lvl))
+	res := &logLevelResponse{
+		Previous: logLevelName(setLevel(lvl)),
+		Current:  logLevelName(lvl),
+	}
+	return createResponse(logLevelRoute, res, nil)
+}
+
+// handleRaw sends a request to a DEX server and returns the server's response
+// payload as the result. The route is disabled unless Config.UnsafeRaw is set.
+func handleRaw(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	if !s.unsafeRaw {
+		resErr := msgjson.NewError(msgjson.RPCRawError, "the raw route is disabled")
+		return createResponse(rawRoute, nil, resErr)
+	}
+	form, err := parseRawArgs(params)
+	if err != nil {
+		return usage(rawRoute, err)
+	}
+	log.Warnf("Relaying raw %q request to %s. Core's checks are bypassed.", form.route, form.host)
+	resp, err := s.core.SendRaw(form.host, form.route, form.payload)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to send raw request: %v", err)
+		resErr := msgjson.NewError(msgjson.RPCRawError, errMsg)
+		return createResponse(rawRoute, nil, resErr)
+	}
+	return createResponse(rawRoute, resp, nil)
+}
+
 // handleLogout logs out the DEX client. *msgjson.ResponsePayload.Error is empty
 // if successful.
 func handleLogout(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
 	if err := s.core.Logout(); err != nil {
 		errMsg := fmt.Sprintf("unable to logout: %v", err)
-		resErr := msgjson.NewError(msgjson.RPCLogoutError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCLogoutError), errMsg)
 		return createResponse(logoutRoute, nil, resErr)
 	}
 	res := logoutStr
 	return createResponse(logoutRoute, &res, nil)
 }
 
+// handleDisconnectDEX handles requests for disconnectdex.
+// *msgjson.ResponsePayload.Error is empty if successful.
+func handleDisconnectDEX(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseDisconnectDEXArgs(params)
+	if err != nil {
+		return usage(disconnectDEXRoute, err)
+	}
+	defer form.appPass.Clear()
+	if err := s.core.DisconnectDEX(form.appPass, form.addr, form.forget, form.force); err != nil {
+		errMsg := fmt.Sprintf("unable to disconnect from %s: %v", form.addr, err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCDisconnectDEXError), errMsg)
+		return createResponse(disconnectDEXRoute, nil, resErr)
+	}
+	res := fmt.Sprintf(disconnectedStr, form.addr)
+	return createResponse(disconnectDEXRoute, &res, nil)
+}
+
+// handleShutdown stops the application. The response is sent before the RPC
+// server shuts down. *msgjson.ResponsePayload.Error is empty if successful.
+func handleShutdown(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	if s.shutdown == nil {
+		resErr := msgjson.NewError(msgjson.RPCShutdownError, "shutdown is not supported")
+		return createResponse(shutdownRoute, nil, resErr)
+	}
+	if !atomic.CompareAndSwapUint32(&s.shuttingDown, 0, 1) {
+		resErr := msgjson.NewError(msgjson.RPCShutdownError, "already shutting down")
+		return createResponse(shutdownRoute, nil, resErr)
+	}
+	log.Infof("Shutdown requested via RPC")
+	// In-flight requests are drained when the server shuts down, so this
+	// response is still sent.
+	go s.shutdown()
+	res := shutdownStr
+	return createResponse(shutdownRoute, &res, nil)
+}
+
 // truncateOrderBook truncates book to the top nOrders of buys and sells.
 func truncateOrderBook(book *core.OrderBook, nOrders uint64) {
 	truncFn := func(orders []*core.MiniOrder) []*core.MiniOrder {
@@ -428,6 +973,29 @@ func truncateOrderBook(book *core.OrderBook, nOrders uint64) {
 	book.Sells = truncFn(book.Sells)
 }
 
+// truncateBookLevels truncates book to the orders at the top nLevels rates of
+// buys and sells. The orders are sorted best rate first. Epoch orders are not
+// truncated.
+func truncateBookLevels(book *core.OrderBook, nLevels uint64) {
+	truncFn := func(orders []*core.MiniOrder) []*core.MiniOrder {
+		var levels uint64
+		for i, ord := range orders {
+			if i == 0 || ord.Rate != orders[i-1].Rate {
+				levels++
+			}
+			if levels > nLevels {
+				for j := i; j < len(orders); j++ {
+					orders[j] = nil
+				}
+				return orders[:i]
+			}
+		}
+		return orders
+	}
+	book.Buys = truncFn(book.Buys)
+	book.Sells = truncFn(book.Sells)
+}
+
 // handleOrderBook handles requests for orderbook.
 // *msgjson.ResponsePayload.Error is empty if successful.
 func handleOrderBook(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
@@ -438,15 +1006,99 @@ func handleOrderBook(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	book, err := s.core.Book(form.host, form.base, form.quote)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to retrieve order book: %v", err)
-		resErr := msgjson.NewError(msgjson.RPCOrderBookError, errMsg)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCOrderBookError), errMsg)
 		return createResponse(orderBookRoute, nil, resErr)
 	}
+	if form.levels > 0 {
+		truncateBookLevels(book, form.levels)
+	}
 	if form.nOrders > 0 {
 		truncateOrderBook(book, form.nOrders)
 	}
 	return createResponse(orderBookRoute, book, nil)
 }
 
+// handleMarket handles requests for market. *msgjson.ResponsePayload.Error is
+// empty if successful.
+func handleMarket(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseMarketArgs(params)
+	if err != nil {
+		return usage(marketRoute, err)
+	}
+	mkt, err := s.core.MarketInfo(form.host, form.base, form.quote)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve market: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCMarketError), errMsg)
+		return createResponse(marketRoute, nil, resErr)
+	}
+	return createResponse(marketRoute, mkt, nil)
+}
+
+// handleFeesPaid handles requests for feespaid. *msgjson.ResponsePayload.Error
+// is empty if successful.
+func handleFeesPaid(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseFeesPaidArgs(params)
+	if err != nil {
+		return usage(feesPaidRoute, err)
+	}
+	fees, err := s.core.FeesPaid(form.host, form.since)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve fees paid: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCFeesPaidError), errMsg)
+		return createResponse(feesPaidRoute, nil, resErr)
+	}
+	return createResponse(feesPaidRoute, fees, nil)
+}
+
+// handleCandles handles requests for candles. *msgjson.ResponsePayload.Error
+// is empty if successful.
+func handleCandles(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseCandlesArgs(params)
+	if err != nil {
+		return usage(candlesRoute, err)
+	}
+	candles, err := s.core.Candles(form.host, form.base, form.quote, form.binSize, form.count)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve candles: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCCandlesError), errMsg)
+		return createResponse(candlesRoute, nil, resErr)
+	}
+	return createResponse(candlesRoute, candles, nil)
+}
+
+// handleGetNotifications handles requests for getnotifications.
+// *msgjson.ResponsePayload.Error is empty if successful. Returns the most recent
+// notifications with at least the requested severity, newest first.
+func handleGetNotifications(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseGetNotificationsArgs(params)
+	if err != nil {
+		return usage(getNotificationsRoute, err)
+	}
+	// Only notifications with severity Success or higher are stored. With a
+	// higher minimum, search as many as possible for n matches.
+	fetch := form.n
+	if form.minSeverity > db.Success {
+		fetch = core.MaxNotifications
+	}
+	notes, err := s.core.Notifications(fetch)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to retrieve notifications: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCNotificationsError), errMsg)
+		return createResponse(getNotificationsRoute, nil, resErr)
+	}
+	res := make([]*db.Notification, 0, len(notes))
+	for _, note := range notes {
+		if note.Severity() < form.minSeverity {
+			continue
+		}
+		res = append(res, note)
+		if len(res) == form.n {
+			break
+		}
+	}
+	return createResponse(getNotificationsRoute, res, nil)
+}
+
 // parseCoreOrder converts a *core.Order into a *myOrder.
 func parseCoreOrder(co *core.Order, b, q uint32) *myOrder {
 	// settled calculates how much of the order has been finalized.
@@ -513,6 +1165,133 @@ func handleMyOrders(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
 	return createResponse(myOrdersRoute, myOrders, nil)
 }
 
+// handleOrders handles requests for orders. *msgjson.ResponsePayload.Error is
+// empty if successful.
+func handleOrders(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseOrdersArgs(params)
+	if err != nil {
+		return usage(ordersRoute, err)
+	}
+	page, err := s.core.OrdersPage(form.filter, form.offset, form.limit)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to get orders: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCOrdersError), errMsg)
+		return createResponse(ordersRoute, nil, resErr)
+	}
+	return createResponse(ordersRoute, page, nil)
+}
+
+// handleSwapCosts handles requests for swapcosts. *msgjson.ResponsePayload.Error
+// is empty if successful.
+func handleSwapCosts(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	oid, err := parseSwapCostsArgs(params)
+	if err != nil {
+		return usage(swapCostsRoute, err)
+	}
+	report, err := s.core.SwapCosts(oid)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to get swap costs for order %s: %v", oid, err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCSwapCostsError), errMsg)
+		return createResponse(swapCostsRoute, nil, resErr)
+	}
+	return createResponse(swapCostsRoute, report, nil)
+}
+
+// handleMatches handles requests for matches. *msgjson.ResponsePayload.Error is
+// empty if successful.
+func handleMatches(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	oid, err := parseMatchesArgs(params)
+	if err != nil {
+		return usage(matchesRoute, err)
+	}
+	matches, err := s.core.Matches(oid)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to get matches for order %s: %v", oid, err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCMatchesError), errMsg)
+		return createResponse(matchesRoute, nil, resErr)
+	}
+	return createResponse(matchesRoute, matches, nil)
+}
+
+// handleTradeReport handles requests for tradereport.
+// *msgjson.ResponsePayload.Error is empty if successful.
+func handleTradeReport(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	form, err := parseTradeReportArgs(params)
+	if err != nil {
+		return usage(tradeReportRoute, err)
+	}
+	defer form.appPass.Clear()
+	report, err := s.core.SignedTradeReport(form.appPass, form.orderID)
+	if err != nil {
+		errMsg := fmt.Sprintf("unable to create trade report for order %s: %v", form.orderID, err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCTradeReportError), errMsg)
+		return createResponse(tradeReportRoute, nil, resErr)
+	}
+	return createResponse(tradeReportRoute, report, nil)
+}
+
+// handleConnStats handles requests for connstats. It takes no arguments and
+// returns the connection statistics for each DEX.
+func handleConnStats(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(connStatsRoute, s.core.ConnStats(), nil)
+}
+
+// handleConnStatus handles requests for connstatus. It takes no arguments and
+// returns the connection state for each DEX.
+func handleConnStatus(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(connStatusRoute, s.core.ConnStatus(), nil)
+}
+
+// handlePing handles requests for ping. It is answered without involving the
+// DEX servers or wallets. *msgjson.ResponsePayload.Error is always empty.
+func handlePing(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(pingRoute, &pongResponse{Time: encode.UnixMilliU(time.Now())}, nil)
+}
+
+// handleGetRetryPolicy handles requests for getretrypolicy.
+// *msgjson.ResponsePayload.Error is empty if successful.
+func handleGetRetryPolicy(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(getRetryPolicyRoute, newRetryPolicyResponse(s.core.RetryPolicy()), nil)
+}
+
+// handleSetRetryPolicy handles requests for setretrypolicy.
+// *msgjson.ResponsePayload.Error is empty if successful. Returns the new
+// policy.
+func handleSetRetryPolicy(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	policy, err := parseSetRetryPolicyArgs(params)
+	if err != nil {
+		return usage(setRetryPolicyRoute, err)
+	}
+	if err := s.core.SetRetryPolicy(policy); err != nil {
+		errMsg := fmt.Sprintf("unable to set retry policy: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCRetryPolicyError), errMsg)
+		return createResponse(setRetryPolicyRoute, nil, resErr)
+	}
+	return createResponse(setRetryPolicyRoute, newRetryPolicyResponse(s.core.RetryPolicy()), nil)
+}
+
+// handleGetSettings handles requests for getsettings. It takes no arguments and
+// returns the trading limits.
+func handleGetSettings(s *RPCServer, _ *RawParams) *msgjson.ResponsePayload {
+	return createResponse(getSettingsRoute, s.core.Settings(), nil)
+}
+
+// handleSetSettings handles requests for setsettings.
+// *msgjson.ResponsePayload.Error is empty if successful. Returns the new
+// settings.
+func handleSetSettings(s *RPCServer, params *RawParams) *msgjson.ResponsePayload {
+	settings, err := parseSetSettingsArgs(params)
+	if err != nil {
+		return usage(setSettingsRoute, err)
+	}
+	if err := s.core.SetSettings(settings); err != nil {
+		errMsg := fmt.Sprintf("unable to set settings: %v", err)
+		resErr := msgjson.NewError(errCode(err, msgjson.RPCSettingsError), errMsg)
+		return createResponse(setSettingsRoute, nil, resErr)
+	}
+	return createResponse(setSettingsRoute, s.core.Settings(), nil)
+}
+
 // format concatenates thing and tail. If thing is empty, returns an empty
 // string.
 func format(thing, tail string) string {
@@ -581,6 +1360,84 @@ type helpMsg struct {
 	pwArgsShort, argsShort, cmdSummary, pwArgsLong, argsLong, returns string
 }
 
+// argLineRegexp matches the first line of an argument's breakdown in a
+// helpMsg, e.g. "cert (string): Optional. The TLS certificate path."
+var argLineRegexp = regexp.MustCompile(`^(\w+) \(([^)]+)\):\s*(.*)$`)
+
+// routeHelps is the structured help for every route, parsed from helpMsgs so
+// that the two cannot diverge.
+var routeHelps = parseHelpMsgs(helpMsgs)
+
+// parseHelpMsgs creates the structured help for every route in msgs.
+func parseHelpMsgs(msgs map[string]helpMsg) map[string]*routeHelp {
+	helps := make(map[string]*routeHelp, len(msgs))
+	for route, msg := range msgs {
+		_, optional := shortArgs(msg.pwArgsShort + " " + msg.argsShort)
+		helps[route] = &routeHelp{
+			Route:   route,
+			Summary: msg.cmdSummary,
+			PWArgs:  parseArgsHelp(msg.pwArgsLong, optional),
+			Args:    parseArgsHelp(msg.argsLong, optional),
+			Returns: strings.TrimSpace(strings.TrimPrefix(msg.returns, "Returns:")),
+		}
+	}
+	return helps
+}
+
+// shortArgs returns the argument names in a helpMsg's example input, and the
+// set of those that are optional, i.e. in parentheses.
+func shortArgs(short string) ([]string, map[string]bool) {
+	short = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(short)
+	var names []string
+	optional := make(map[string]bool)
+	var depth int
+	for _, field := range strings.Fields(short) {
+		switch field {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			name := strings.Trim(field, `"`)
+			names = append(names, name)
+			if depth > 0 {
+				optional[name] = true
+			}
+		}
+	}
+	return names, optional
+}
+
+// parseArgsHelp parses an arguments breakdown of a helpMsg. The first line is
+// a header. Each argument starts a line, and any following lines that do not
+// start an argument continue its description.
+func parseArgsHelp(long string, optional map[string]bool) []*argHelp {
+	args := make([]*argHelp, 0)
+	lines := strings.Split(long, "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m := argLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			if len(args) > 0 {
+				args[len(args)-1].Description += " " + line
+			}
+			continue
+		}
+		desc := m[3]
+		isOptional := optional[m[1]] || strings.HasPrefix(desc, "Optional.")
+		args = append(args, &argHelp{
+			Name:        m[1],
+			Type:        m[2],
+			Required:    !isOptional,
+			Description: strings.TrimSpace(strings.TrimPrefix(desc, "Optional.")),
+		})
+	}
+	return args
+}
+
 // helpMsgs are a map of routes to help messages. They are broken down into six
 // sections.
 // In descending order:
@@ -594,40 +1451,159 @@ type helpMsg struct {
 // 6. An extensive breakdown of the returned values.
 var helpMsgs = map[string]helpMsg{
 	helpRoute: {
-		pwArgsShort: ``,                           // password args example input
-		argsShort:   `("cmd") (includePasswords)`, // args example input
-		cmdSummary:  `Print a help message.`,      // command explanation
-		pwArgsLong:  ``,                           // password args breakdown
+		pwArgsShort: ``,                                        // password args example input
+		argsShort:   `("cmd") (includePasswords) (structured)`, // args example input
+		cmdSummary:  `Print a help message.`,                   // command explanation
+		pwArgsLong:  ``,                                        // password args breakdown
 		argsLong: `Args:
-    cmd (string): Optional. The command to print help for.
+    cmd (string): Optional. The command to print help for. Use "" to get
+      structured help for every route.
     includePasswords (bool): Optional. Default is false. Whether to include
-      password arguments in the returned help.`, // args breakdown
+      password arguments in the returned help.
+    structured (bool): Optional. Default is false. Whether to return
+      structured help, for clients that render usage themselves, instead of
+      the help message.`, // args breakdown
 		returns: `Returns:
-    string: The help message for command.`, // returns breakdown
+    string: The help message for command.
+
+    If structured, obj: The command's help, or an array of help for all routes
+    if no command is passed.
+    {
+      "route" (string): The route.
+      "summary" (string): A description of the route.
+      "pwArgs" (array): The route's password arguments, in order. Omitted
+        unless includePasswords.
+      [
+        {
+          "name" (string): The argument name.
+          "type" (string): The argument type, e.g. string, int, or bool.
+          "required" (bool): Whether the argument is required.
+          "description" (string): A description of the argument.
+        },...
+      ]
+      "args" (array): The route's other arguments, in order, formatted as pwArgs.
+      "returns" (string): A description of the returned value.
+    }`,
+	},
+	pingRoute: {
+		cmdSummary: `Check that the DEX client is responsive and measure the round trip
+    time. The request is answered immediately, without contacting any DEX or
+    wallet. Also available over websocket.`,
+		returns: `Returns:
+  obj: The pong.
+  {
+    "time" (int): The client's current time in milliseconds since the epoch.
+  }`,
 	},
 	versionRoute: {
-		cmdSummary: `Print the DEX client rpcserver version.`,
+		cmdSummary: `Print the DEX client rpcserver version, routes, and capabilities.`,
 		returns: `Returns:
-    string: The DEX client rpcserver version.`,
+  obj: The RPC version and, if known, the client application version.
+  {
+    "major" (int): The RPC major version.
+    "minor" (int): The RPC minor version.
+    "patch" (int): The RPC patch version.
+    "app" (obj): The client application version. Omitted if unknown.
+    {
+      "version" (string): The client's semantic version.
+      "goVersion" (string): The Go version the client was built with.
+    }
+    "routes" (array): The names of the routes this server supports, sorted.
+      Admin routes are listed even if the admin token is not configured.
+    "capabilities" (obj): The optional features of this server.
+    {
+      "batch" (bool): Whether a JSON array of requests is accepted.
+      "tls" (bool): Whether the server uses TLS.
+      "mtls" (bool): Whether client certificates are required.
+      "metrics" (bool): Whether the /metrics endpoint is enabled.
+      "admin" (bool): Whether the admin token is configured, which admin
+        routes require.
+      "raw" (bool): Whether the raw route is enabled.
+    }
+  }`,
 	},
 	initRoute: {
 		pwArgsShort: `"appPass"`,
 		cmdSummary:  `Initialize the client.`,
 		pwArgsLong: `Password Args:
-    appPass (string): The DEX client password.`,
+    appPass (string): The DEX client password.
+      Or env:VARNAME to use the value of the environment variable VARNAME of
+      the RPC server's process, if allowed by the server's configuration. A
+      literal password beginning with env: must be preceded by a backslash.`,
 		returns: `Returns:
     string: The message "` + initializedStr + `"`,
+	},
+	changeAppPassRoute: {
+		pwArgsShort: `"appPass" "newAppPass"`,
+		cmdSummary: `Change the client password. Account keys and wallet passwords are
+    re-encrypted with the new password. Open wallets remain open.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The current DEX client password.
+    newAppPass (string): The new DEX client password.`,
+		returns: `Returns:
+    string: The message "` + appPassChangedStr + `". An error with code ` + strconv.Itoa(msgjson.RPCPasswordError) + ` is
+      returned if appPass is incorrect.`,
+	},
+	backupRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"path" (overwrite)`,
+		cmdSummary: `Write a copy of the client database to a file on the client's
+    machine. Account keys and wallet passwords in the backup remain encrypted
+    with the app password. The copy is a consistent snapshot of the database.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    path (string): The path of the backup file. Relative paths are relative to
+      the client's working directory.
+    overwrite (bool): Optional. Whether to overwrite an existing file at path.
+      Default is false.`,
+		returns: `Returns:
+    obj: The backup result.
+    {
+      "path" (string): The absolute path of the backup file.
+      "checksum" (string): The hex-encoded SHA-256 checksum of the backup file.
+    }`,
 	},
 	getFeeRoute: {
 		argsShort:  `"dex" ("cert")`,
 		cmdSummary: `Get dex registration fee.`,
 		argsLong: `Args:
     dex (string): The dex address to get fee for.
-    cert (string): Optional. The TLS certificate path.`,
+    cert (string): Optional. The TLS certificate path, or the PEM-encoded
+      certificate itself.`,
 		returns: `Returns:
     obj: The getFee result.
     {
-      "fee" (int): The DEX registration fee.
+      "fee" (int): The DEX registration fee, in the fee asset's smallest
+        unit.
+      "conventional" (float): The fee in the conventional unit.
+      "units" (string): The smallest unit, e.g. atoms.
+      "conventionalUnit" (string): The conventional unit, e.g. DCR.
+      "conversionFactor" (int): The number of units per conventional unit.
+    }`,
+	},
+	getDEXConfigRoute: {
+		argsShort: `"dex" ("cert")`,
+		cmdSummary: `Get the markets and assets of a dex. The dex does not need to
+    be registered.`,
+		argsLong: `Args:
+    dex (string): The dex address to get the configuration for.
+    cert (string): Optional. The TLS certificate path, or the PEM-encoded
+      certificate itself.`,
+		returns: `Returns:
+    obj: The getdexconfig result.
+    {
+      "host" (string): The dex host.
+      "markets" (obj): The dex's markets, keyed by market name. See the
+        exchanges route for the fields.
+      "assets" (obj): The dex's assets, keyed by asset ID. See the exchanges
+        route for the fields.
+      "feePending" (bool): Whether a registration fee payment is pending.
+      "connected" (bool): Whether the dex is connected.
+      "confsrequired" (int): The number of confirmations needed for the
+        registration fee payment.
+      "confs" (int): The current number of confirmations for the registration
+        fee payment. This is only present during the registration process.
     }`,
 	},
 	newWalletRoute: {
@@ -636,24 +1612,57 @@ var helpMsgs = map[string]helpMsg{
 		cmdSummary:  `Connect to a new wallet.`,
 		pwArgsLong: `Password Args:
     appPass (string): The DEX client password.
-    walletPass (string): The wallet's password. Leave the password empty for wallets without a password set.`,
+      Or env:VARNAME to use the value of the environment variable VARNAME of
+      the RPC server's process, if allowed by the server's configuration. A
+      literal password beginning with env: must be preceded by a backslash.
+    walletPass (string): The wallet's password. Leave the password empty for wallets without a password set.
+      Or env:VARNAME to use the value of the environment variable VARNAME of
+      the RPC server's process, if allowed by the server's configuration. A
+      literal password beginning with env: must be preceded by a backslash.`,
 		argsLong: `Args:
     assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
       See https://github.com/satoshilabs/slips/blob/master/slip-0044.md
-     path (string): Optional. The path to a configuration file.
-     settings (string): A JSON-encoded string->string mapping of additional
-       configuration settings. These settings take precedence over any settings
-       parsed from file. e.g. '{"account":"default"}' for Decred accounts, and
+     path (string): Optional. The path to a configuration file. May be empty
+       if the settings are provided inline.
+     settings (string): Optional. Any number of inline configuration settings,
+       each either a single key=value setting, e.g. rpcuser=user, or a
+       JSON-encoded string->string mapping of settings. These settings take
+       precedence over any settings parsed from file, and later settings over
+       earlier ones. e.g. '{"account":"default"}' for Decred accounts, and
        '{"walletname":""}' for the default Bitcoin wallet where bitcoind's listwallets RPC gives possible walletnames.`,
 		returns: `Returns:
     string: The message "` + fmt.Sprintf(walletCreatedStr, "[coin symbol]") + `"`,
+	},
+	reconfigWalletRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `assetID "path" ("settings")`,
+		cmdSummary: `Replace the configuration of an existing wallet, e.g. to change the
+    connection to its node. The new settings are saved only if the wallet
+    connects with them. Otherwise, the existing wallet and settings are kept.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md
+    path (string): The path to a configuration file. May be empty if settings
+      are provided.
+    settings (string): Optional. Any number of inline configuration settings,
+      each either a single key=value setting, e.g. rpcuser=user, or a
+      JSON-encoded string->string mapping of settings. These settings take
+      precedence over any settings parsed from file, and later settings over
+      earlier ones.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(walletReconfigStr, "[coin symbol]") + `"`,
 	},
 	openWalletRoute: {
 		pwArgsShort: `"appPass"`,
 		argsShort:   `assetID`,
 		cmdSummary:  `Open an existing wallet.`,
 		pwArgsLong: `Password Args:
-    appPass (string): The DEX client password.`,
+    appPass (string): The DEX client password.
+      Or env:VARNAME to use the value of the environment variable VARNAME of
+      the RPC server's process, if allowed by the server's configuration. A
+      literal password beginning with env: must be preceded by a backslash.`,
 		argsLong: `Args:
     assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
       See https://github.com/satoshilabs/slips/blob/master/slip-0044.md`,
@@ -668,6 +1677,35 @@ var helpMsgs = map[string]helpMsg{
       See https://github.com/satoshilabs/slips/blob/master/slip-0044.md`,
 		returns: `Returns:
     string: The message "` + fmt.Sprintf(walletLockedStr, "[coin symbol]") + `"`,
+	},
+	openAllWalletsRoute: {
+		pwArgsShort: `"appPass"`,
+		cmdSummary: `Open all existing wallets, e.g. after login. A wallet that fails
+    to open does not prevent opening the others.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		returns: `Returns:
+    obj: The result for each wallet.
+    {
+      "[coin symbol]": {
+        "assetID" (int): The asset's BIP-44 registered coin index.
+        "ok" (bool): Whether the wallet was opened.
+        "error" (string): The reason the wallet was not opened. Omitted if ok.
+      },...
+    }`,
+	},
+	closeAllWalletsRoute: {
+		cmdSummary: `Close all open wallets. A wallet that fails to close, e.g. with
+    active swaps, does not prevent closing the others.`,
+		returns: `Returns:
+    obj: The result for each wallet.
+    {
+      "[coin symbol]": {
+        "assetID" (int): The asset's BIP-44 registered coin index.
+        "ok" (bool): Whether the wallet was closed.
+        "error" (string): The reason the wallet was not closed. Omitted if ok.
+      },...
+    }`,
 	},
 	walletsRoute: {
 		cmdSummary: `List all wallets.`,
@@ -690,8 +1728,128 @@ var helpMsgs = map[string]helpMsg{
         "address" (string): A wallet address.
         "feerate" (int): The fee rate.
         "units" (string): Unit of measure for amounts.
+        "conventionalUnit" (string): The conventional unit, e.g. DCR.
+        "conversionFactor" (int): The number of units per conventional unit.
+        "syncStatus" (obj): Optional. The sync progress of the wallet's
+          blockchain node. Omitted if the wallet does not report it. {
+          "synced" (bool): Whether the node is synced and the wallet usable.
+          "height" (int): The number of blocks scanned.
+          "target" (int): The block height being synced to.
+          "progress" (float): The percentage of the target scanned.
+        }
+        "peers" (obj): Optional. The network connection status of the
+          wallet's blockchain node. Omitted if the wallet does not report it
+          or is not connected. {
+          "connected" (bool): Whether the node could be reached.
+          "count" (int): The number of network peers. A node with no peers
+            cannot sync.
+        }
+        "rescanning" (bool): Whether a rescan started with rescanwallet is
+          running. The wallet is not reported as synced until it is done.
+      },...
+    ]`,
+	},
+	supportedAssetsRoute: {
+		cmdSummary: `List the assets supported by this client build, whether or not they
+    have a wallet. Use this to find the assets that a wallet can be created
+    for with newwallet.`,
+		returns: `Returns:
+    array: The supported assets, ordered by asset ID.
+    [
+      {
+        "id" (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+        "symbol" (string): The coin symbol.
+        "name" (string): The asset's display name.
+        "driver" (bool): Whether a wallet driver for the asset is available.
+        "hasWallet" (bool): Whether a wallet has been created for the asset.
+        "open" (bool): Whether the wallet is unlocked.
+        "running" (bool): Whether the wallet is running.
       },...
     ]`,
+	},
+	walletStateRoute: {
+		argsShort:  `assetID`,
+		cmdSummary: `Get the state of one wallet.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md`,
+		returns: `Returns:
+    obj: The wallet's state. An error with code ` + strconv.Itoa(msgjson.RPCWalletNotFoundError) + ` is returned if
+      there is no wallet for the asset.
+    {
+      "symbol" (string): The coin symbol.
+      "assetID" (int): The asset's BIP-44 registered coin index.
+      "open" (bool): Whether the wallet is unlocked.
+      "running" (bool): Whether the wallet is running.
+      "balance" (obj): {
+        "available" (int): The balance available for funding orders.
+        "immature" (int): Balance that requires confirmations before use.
+        "locked" (int): The total locked balance.
+        "stamp" (string): Time stamp.
+      }
+      "address" (string): A wallet address.
+      "units" (string): Unit of measure for amounts.
+      "encrypted" (bool): Whether the wallet password is stored encrypted.
+      "conventionalUnit" (string): The conventional unit, e.g. DCR.
+      "conversionFactor" (int): The number of units per conventional unit.
+      "syncStatus" (obj): Optional. The sync progress of the wallet's
+        blockchain node. Omitted if the wallet does not report it. {
+        "synced" (bool): Whether the node is synced and the wallet usable.
+        "height" (int): The number of blocks scanned.
+        "target" (int): The block height being synced to.
+        "progress" (float): The percentage of the target scanned.
+      }
+      "peers" (obj): Optional. The network connection status of the wallet's
+        blockchain node. Omitted if the wallet does not report it or is not
+        connected. {
+        "connected" (bool): Whether the node could be reached.
+        "count" (int): The number of network peers. A node with no peers
+          cannot sync.
+      }
+      "rescanning" (bool): Whether a rescan started with rescanwallet is
+        running. The wallet is not reported as synced until it is done.
+    }`,
+	},
+	rescanWalletRoute: {
+		argsShort: `assetID`,
+		cmdSummary: `Rescan the blockchain for a wallet's transactions, e.g. if the wallet
+    is missing transactions. The rescan runs in the background, and may take
+    a long time. Its progress is reported by walletstate. Only one rescan of a
+    wallet may run at a time.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(rescanStartedStr, "[coin symbol]") + `"
+      An error is returned if the wallet is not connected, does not support
+      rescanning, or is already rescanning.`,
+	},
+	feeRateRoute: {
+		argsShort:  `assetID`,
+		cmdSummary: `Get a wallet's current network fee rate estimate.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md`,
+		returns: `Returns:
+    obj: The fee rate estimate. An error is returned if the wallet is not
+      connected or is unable to provide an estimate.
+    {
+      "feeRate" (int): The fee rate in the asset's smallest unit per byte.
+      "units" (string): The fee rate's unit of measure, e.g. atoms/byte.
+    }`,
+	},
+	addressRoute: {
+		argsShort: `assetID`,
+		cmdSummary: `Get a receiving address from a wallet, e.g. to deposit funds. The
+    wallet must be open. Whether the address is new depends on the asset: the
+    BTC and DCR wallets return a new address on each request, while other
+    wallets may return the same address. Addresses are not cached.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md`,
+		returns: `Returns:
+    string: The address. An error with code ` + strconv.Itoa(msgjson.RPCWalletNotFoundError) + ` is returned if there
+      is no wallet for the asset, or with code ` + strconv.Itoa(msgjson.RPCWalletLockedError) + ` if the wallet is not open.`,
 	},
 	registerRoute: {
 		pwArgsShort: `"appPass"`,
@@ -699,11 +1857,15 @@ var helpMsgs = map[string]helpMsg{
 		cmdSummary: `Register for DEX. An ok response does not mean that registration is complete.
 Registration is complete after the fee transaction has been confirmed.`,
 		pwArgsLong: `Password Args:
-    appPass (string): The DEX client password.`,
+    appPass (string): The DEX client password.
+      Or env:VARNAME to use the value of the environment variable VARNAME of
+      the RPC server's process, if allowed by the server's configuration. A
+      literal password beginning with env: must be preceded by a backslash.`,
 		argsLong: `Args:
     addr (string): The DEX address to register for.
     fee (int): The DEX fee.
-    cert (string): Optional. The TLS certificate path.`,
+    cert (string): Optional. The TLS certificate path, or the PEM-encoded
+      certificate itself.`,
 		returns: `Returns:
     {
       "feeID" (string): The fee transactions's txid and output index.
@@ -745,13 +1907,51 @@ Registration is complete after the fee transaction has been confirmed.`,
         "confs" (int): The current number of confirmations for the registration
           fee payment. This is only present during the registration process.
       },...
+    }`,
+	},
+	connStatsRoute: {
+		cmdSummary: `Show the websocket connection statistics for each DEX. The
+    counters include all reconnects since the client started.`,
+		returns: `Returns:
+    obj: The statistics for each DEX.
+    {
+      "[DEX host]": {
+        "reconnects" (int): The number of successful reconnects.
+        "bytesRead" (int): The bytes read from the network.
+        "bytesWritten" (int): The bytes written to the network.
+        "queuedMessages" (int): Received messages waiting to be processed.
+        "droppedMessages" (int): Received messages dropped because too many
+          were waiting to be processed.
+        "lastConnect" (string): The time of the last successful connect.
+        "compressed" (bool): Whether the server accepted permessage-deflate
+          compression on the last successful connect.
+      },...
+    }`,
+	},
+	connStatusRoute: {
+		cmdSummary: `Show the websocket connection state for each DEX, including
+    whether a reconnect is in progress and the last connection error.`,
+		returns: `Returns:
+    obj: The connection state for each DEX.
+    {
+      "[DEX host]": {
+        "connected" (bool): Whether the connection is up.
+        "reconnecting" (bool): Whether a reconnect is in progress or
+          scheduled.
+        "lastError" (string): The most recent error that took the
+          connection down or caused a connection attempt to fail. Omitted if
+          there has been no error.
+      },...
     }`,
 	},
 	loginRoute: {
 		pwArgsShort: `"appPass"`,
 		cmdSummary:  `Attempt to login to all registered DEX servers.`,
 		pwArgsLong: `Password Args:
-    appPass (string): The dex client password.`,
+    appPass (string): The dex client password.
+      Or env:VARNAME to use the value of the environment variable VARNAME of
+      the RPC server's process, if allowed by the server's configuration. A
+      literal password beginning with env: must be preceded by a backslash.`,
 		returns: `Returns:
     obj: A map of notifications and dexes.
     {
@@ -803,6 +2003,40 @@ Registration is complete after the fee transaction has been confirmed.`,
       "sig" (string): The DEX's signature of the order information.
       "stamp" (int): The time the order was signed in milliseconds since 00:00:00
         Jan 1 1970.
+    }`,
+	},
+	preOrderRoute: {
+		argsShort:  `"host" isLimit sell base quote qty rate`,
+		cmdSummary: `Check an order against the market's lot size and rate step and estimate its fees before trading.`,
+		argsLong: `Args:
+    host (string): The DEX to trade on.
+    isLimit (bool): Whether the order is a limit order.
+    sell (bool): Whether the order is selling.
+    base (int): The BIP-44 coin index for the market's base asset.
+    quote (int): The BIP-44 coin index for the market's quote asset.
+    qty (int): The number of units to buy/sell. Must be a multiple of the lot
+      size, except for market buys, which are in units of the quote asset.
+    rate (int): The atoms quote asset to pay/accept per unit base asset. Must
+      be a multiple of the rate step. Ignored for market orders.`,
+		returns: `Returns:
+    obj: The order estimate. An error describing the problem and the nearest
+      valid values is returned if the quantity or rate would be rejected.
+    {
+      "host" (string): The DEX address.
+      "marketID" (string): The market's ID.
+      "fromID" (int): The BIP-44 coin index of the asset being swapped.
+      "toID" (int): The BIP-44 coin index of the asset being redeemed.
+      "lotSize" (int): The market's lot size in units of the base asset.
+      "rateStep" (int): The market's rate step.
+      "minOrder" (int): The minimum order quantity in units of the base asset.
+      "lots" (int): The number of lots in the order. Estimated from the book
+        for market buys.
+      "estimatedFees" (obj): The worst-case fees, in units of each asset's
+        smallest denomination, if each lot is matched separately.
+      {
+        "swap" (int): The swap fees.
+        "redemption" (int): The redemption fees.
+      }
     }`,
 	},
 	cancelRoute: {
@@ -831,14 +2065,182 @@ Registration is complete after the fee transaction has been confirmed.`,
     address (string): The address to which withdrawn funds are sent.`,
 		returns: `Returns:
     string: "[coin ID]"`,
+	},
+	validateAddressRoute: {
+		argsShort: `assetID "address"`,
+		cmdSummary: `Check whether an address is well-formed for an asset on the client's
+    network, e.g. before a withdraw. Nothing is sent, and a wallet is not
+    required. An address that is valid is not necessarily one of the wallet's
+    own addresses.`,
+		argsLong: `Args:
+    assetID (int): The asset's BIP-44 registered coin index. e.g. 42 for DCR.
+      See https://github.com/satoshilabs/slips/blob/master/slip-0044.md
+    address (string): The address to check.`,
+		returns: `Returns:
+    bool: Whether the address is valid. An error is returned only if the asset
+      is not supported.`,
 	},
 	logoutRoute: {
-		cmdSummary: `Logout the DEX client.`,
+		cmdSummary: `Logout the DEX client. Routes that require a logged in account,
+    such as trade and cancel, return an error with code ` + strconv.Itoa(msgjson.RPCLoginRequiredError) + `
+    until login is called again.`,
 		returns: `Returns:
     string: The message "` + logoutStr + `"`,
+	},
+	disconnectDEXRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"dex" (forget) (force)`,
+		cmdSummary: `Disconnect from a DEX and stop its order book feeds. The account
+    is optionally forgotten, so that the DEX is not connected on the next
+    start. Active orders with the DEX prevent disconnecting unless force is
+    true.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    dex (string): The DEX address.
+    forget (bool): Optional. Disable the account with the DEX. Registering
+      again is required to trade on the DEX. Default is false.
+    force (bool): Optional. Disconnect even if there are active orders with
+      the DEX. The trades will not progress while disconnected. Default is
+      false.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(disconnectedStr, "dex.example.com:7232") + `"`,
+	},
+	wsClientsRoute: {
+		cmdSummary: `List the connected websocket clients. Requires the admin token.`,
+		returns: `Returns:
+    array: The clients, ordered by ID. An error with code ` + strconv.Itoa(msgjson.RPCAdminRequiredError) + ` is
+      returned without the admin token.
+    [
+      {
+        "id" (int): The client ID.
+        "ip" (string): The client's remote IP address.
+        "feeds" (int): The number of markets the client is subscribed to.
+      },...
+    ]`,
+	},
+	wsDisconnectRoute: {
+		argsShort: `id`,
+		cmdSummary: `Disconnect a websocket client and stop its market feeds. Other
+    clients are not affected. Requires the admin token.`,
+		argsLong: `Args:
+    id (int): The client ID, from wsclients.`,
+		returns: `Returns:
+    string: The message "` + fmt.Sprintf(wsDisconnectedStr, 1) + `" for client 1. An error with code
+      ` + strconv.Itoa(msgjson.RPCWSClientError) + ` is returned if there is no client with the ID, or with
+      code ` + strconv.Itoa(msgjson.RPCAdminRequiredError) + ` without the admin token.`,
+	},
+	logLevelRoute: {
+		argsShort: `"level"`,
+		cmdSummary: `Set the log level of every subsystem without a restart, e.g. to
+    capture an intermittent issue and then dial logging back down. Levels set
+    for individual subsystems are replaced. Requires the admin token.`,
+		argsLong: `Args:
+    level (string): The new level, "trace", "debug", "info", "warn", or
+      "error".`,
+		returns: `Returns:
+  obj: The log levels. An error with code ` + strconv.Itoa(msgjson.RPCAdminRequiredError) + ` is returned without
+    the admin token.
+  {
+    "previous" (string): The application's level before the change.
+    "current" (string): The new level.
+  }`,
+	},
+	rawRoute: {
+		argsShort: `"host" "route" ("payload")`,
+		cmdSummary: `Send a request to a DEX server and return its response. This is for
+    debugging and for server routes without an RPC route. It bypasses all of
+    the client's checks and state tracking, so requests that change the
+    account's state on the server, such as orders and swap steps, can leave
+    the client out of sync. Requires the admin token and the unsafe raw
+    option, and every use is logged.`,
+		argsLong: `Args:
+    host (string): The DEX address.
+    route (string): The server route, e.g. "config".
+    payload (string): Optional. The JSON request payload.`,
+		returns: `Returns:
+    obj: The server's response payload.
+    {
+      "result" (any): The result, if successful.
+      "error" (obj): The server's error, if any.
+      {
+        "code" (int): The error code.
+        "message" (string): The error message.
+      }
+    }
+    An error with code ` + strconv.Itoa(msgjson.RPCRawError) + ` is returned if the route is disabled or
+    the request could not be sent, or with code ` + strconv.Itoa(msgjson.RPCAdminRequiredError) + ` without the
+    admin token.`,
+	},
+	shutdownRoute: {
+		cmdSummary: `Shut down the DEX client. DEX connections and wallets are closed. Swaps
+    of active matches are not completed while the client is down, which may
+    result in failed swaps and account penalization.`,
+		returns: `Returns:
+    string: The message "` + shutdownStr + `"`,
+	},
+	marketRoute: {
+		argsShort:  `"host" base quote`,
+		cmdSummary: `Get the parameters and status of one market, e.g. for an order form.`,
+		argsLong: `Args:
+    host (string): The DEX address.
+    base (int): The BIP-44 coin index for the market's base asset.
+    quote (int): The BIP-44 coin index for the market's quote asset.`,
+		returns: `Returns:
+  obj: The market. An error with code ` + strconv.Itoa(msgjson.UnknownMarketError) + ` is returned if the DEX does not have
+    the market.
+  {
+    "host" (string): The DEX host.
+    "name" (string): The market name, e.g. "dcr_btc".
+    "baseid" (int): The base asset ID.
+    "basesymbol" (string): The base asset ticker symbol.
+    "quoteid" (int): The quote asset ID.
+    "quotesymbol" (string): The quote asset ticker symbol.
+    "lotsize" (int): The lot size in atoms of the base asset. Order quantities
+      must be a multiple of it.
+    "ratestep" (int): The rate step in atoms of the quote asset. Limit order
+      rates must be a multiple of it.
+    "epochlen" (int): The epoch duration in milliseconds.
+    "buybuffer" (float): The market buy buffer. A market buy must be for at
+      least this many lots at the current rate.
+    "running" (bool): Whether the market is running, as opposed to suspended
+      or not yet started.
+    "startepoch" (int): The epoch index at which the market started or will
+      start.
+    "finalepoch" (int): The epoch index after which the market is scheduled to
+      suspend. Omitted if no suspension is scheduled.
+  }`,
+	},
+	feesPaidRoute: {
+		argsShort:  `"host" (since)`,
+		cmdSummary: `Summarize the fees paid at a DEX by asset and type, e.g. for accounting.`,
+		argsLong: `Args:
+    host (string): The DEX address.
+    since (int): Optional. Only count fees for orders placed at or after this
+      UNIX time, in seconds. Default is 0, for all-time.`,
+		returns: `Returns:
+  obj: The fees paid. Fees are in units of the asset's smallest denomination.
+    Every asset supported by the DEX is listed, with zeros if no fees were
+    paid in it.
+  {
+    "host" (string): The DEX host.
+    "since" (int): The since time, in seconds.
+    "assets" (obj): The fees paid in each asset.
+    {
+      "[assetID]": {
+        "symbol" (string): The asset's ticker symbol.
+        "registration" (int): The registration fee. The amount paid is not
+          recorded, so this is the DEX's current fee if the account is paid.
+          It is only reported for all-time.
+        "swap" (int): The swap fees paid for orders selling the asset.
+        "redemption" (int): The redemption fees paid for orders buying the
+          asset.
+      },...
+    }
+  }`,
 	},
 	orderBookRoute: {
-		argsShort:  `"host" base quote (nOrders)`,
+		argsShort:  `"host" base quote (nOrders) (levels)`,
 		cmdSummary: `Retrieve all orders for a market.`,
 		argsLong: `Args:
     host (string): The DEX to retrieve the order book from.
@@ -846,7 +2248,11 @@ Registration is complete after the fee transaction has been confirmed.`,
     quote (int): The BIP-44 coin index for the market's quote asset.
     nOrders (int): Optional. Default is 0, which returns all orders. The number
       of orders from the top of buys and sells to return. Epoch orders are not
-      truncated.`,
+      truncated.
+    levels (int): Optional. Default is 0, which returns all price levels. The
+      number of distinct rates from the top of buys and sells to return, with
+      all of the orders at those rates. Epoch orders are not truncated. If
+      nOrders is also set, the lower limit applies.`,
 		returns: `Returns:
     obj: A map of orders.
     {
@@ -881,6 +2287,64 @@ Registration is complete after the fee transaction has been confirmed.`,
         },...
       ],
     }`,
+	},
+	ordersRoute: {
+		argsShort: `("status") (limit) (offset) ("host") (base) (quote)`,
+		cmdSummary: `Fetch a page of the user's orders, most recently submitted first,
+    including their matches. Orders are sorted by submission time, so pages do
+    not shift as orders are updated. Use the returned nextOffset as the offset
+    to fetch the next page. With no arguments, the ` + strconv.Itoa(defaultOrdersN) + ` most recent active
+    orders are returned.`,
+		argsLong: `Args:
+    status (string): Optional. "active" (default) for epoch and booked
+      orders, "all", or one of "epoch", "booked", "executed", "canceled", or
+      "revoked".
+    limit (int): Optional. The maximum number of orders to return. Default ` + strconv.Itoa(defaultOrdersN) + `,
+      maximum ` + strconv.Itoa(maxOrdersN) + `.
+    offset (int): Optional. The number of orders to skip. Default 0.
+    host (string): Optional. The DEX to show orders from. An empty string
+      matches all DEXes.
+    base (int): Optional. The BIP-44 coin index for the market's base asset.
+    quote (int): Optional. The BIP-44 coin index for the market's quote asset.`,
+		returns: `Returns:
+  obj: The page of orders.
+  {
+    "orders" (array): The orders on this page.
+    [
+      {
+        "host" (string): The DEX address.
+        "baseID" (int): The market's base asset BIP-44 coin index.
+        "baseSymbol" (string): The market's base asset ticker.
+        "quoteID" (int): The market's quote asset BIP-44 coin index.
+        "quoteSymbol" (string): The market's quote asset ticker.
+        "market" (string): The market's name. e.g. "dcr_btc".
+        "type" (int): The type of order. 1 for limit, 2 for market.
+        "id" (string): The order's unique hex ID.
+        "stamp" (int): Time the order was made in milliseconds since 00:00:00
+          Jan 1 1970.
+        "sig" (string): The hex order signature.
+        "status" (int): The status of the order. 1 for epoch, 2 for booked, 3
+          for executed, 4 for canceled, and 5 for revoked.
+        "epoch" (int): The order's epoch.
+        "qty" (int): The amount being traded.
+        "sell" (bool): Whether this order is selling.
+        "filled" (int): The order quantity that has matched.
+        "matches" (array): The order's matches, with their swap, redemption,
+          and refund coin IDs.
+        "cancelling" (bool): Whether this order is in the process of cancelling.
+        "canceled" (bool): Whether this order has been canceled.
+        "feesPaid" (obj): The swap and redemption fees paid.
+        "fundingCoins" (array): The hex IDs of the coins funding the order.
+        "rate" (int): The exchange rate limit. Limit orders only.
+        "tif" (int): The time in force. Limit orders only. 0 for immediate, 1
+          for standing.
+      },...
+    ]
+    "total" (int): The number of orders matching the filter, on all pages.
+    "offset" (int): The offset of this page.
+    "nextOffset" (int): The offset of the next page. Omitted if this is the
+      last page.
+  }`,
 	},
 	myOrdersRoute: {
 		argsShort: `("host") (base) (quote)`,
@@ -917,4 +2381,221 @@ Registration is complete after the fee transaction has been confirmed.`,
     },...
   ]`,
 	},
+	getRetryPolicyRoute: {
+		cmdSummary: `Show the policy for retrying failed redemption and refund
+    broadcasts.`,
+		returns: `Returns:
+  obj: The retry policy.
+  {
+    "maxRedeemAttempts" (int): The redemption broadcast attempts per match.
+    "maxRefundAttempts" (int): The refund broadcast attempts per match.
+    "backoff" (int): Seconds to wait before the first retry.
+    "maxBackoff" (int): The most seconds to wait between retries.
+  }`,
+	},
+	setRetryPolicyRoute: {
+		argsShort: `maxRedeemAttempts maxRefundAttempts backoff maxBackoff`,
+		cmdSummary: `Set the policy for retrying failed redemption and refund
+    broadcasts. The wait before each retry starts at backoff and doubles with
+    every failure, up to maxBackoff. A match is failed once its attempts are
+    exhausted. The policy is not saved across restarts.`,
+		argsLong: `Args:
+    maxRedeemAttempts (int): The redemption broadcast attempts per match,
+      1 to 20.
+    maxRefundAttempts (int): The refund broadcast attempts per match, 1 to 20.
+    backoff (int): Seconds to wait before the first retry, 1 to 86400.
+    maxBackoff (int): The most seconds to wait between retries, backoff to
+      86400.`,
+		returns: `Returns:
+  obj: The retry policy.
+  {
+    "maxRedeemAttempts" (int): The redemption broadcast attempts per match.
+    "maxRefundAttempts" (int): The refund broadcast attempts per match.
+    "backoff" (int): Seconds to wait before the first retry.
+    "maxBackoff" (int): The most seconds to wait between retries.
+  }`,
+	},
+	getSettingsRoute: {
+		cmdSummary: `Show the trading limits that are checked before an order is
+    submitted.`,
+		returns: `Returns:
+  obj: The trade settings. A zero value means there is no limit.
+  {
+    "maxFeeRate" (int): The highest swap fee rate, in atoms per byte, that
+      the client will agree to pay.
+    "maxOrderLots" (int): The largest order, in lots.
+  }`,
+	},
+	setSettingsRoute: {
+		argsShort: `maxFeeRate maxOrderLots`,
+		cmdSummary: `Set the trading limits that are checked before an order is
+    submitted. Orders on a market where the DEX's max fee rate for the asset
+    being swapped exceeds maxFeeRate, or that are larger than maxOrderLots,
+    are rejected by the trade and preorder routes. The settings are saved.`,
+		argsLong: `Args:
+    maxFeeRate (int): The highest swap fee rate, in atoms per byte, that the
+      client will agree to pay. 0 for no limit.
+    maxOrderLots (int): The largest order, in lots. 0 for no limit.`,
+		returns: `Returns:
+  obj: The trade settings in effect.
+  {
+    "maxFeeRate" (int): The highest swap fee rate, in atoms per byte.
+    "maxOrderLots" (int): The largest order, in lots.
+  }`,
+	},
+	tradeReportRoute: {
+		pwArgsShort: `"appPass"`,
+		argsShort:   `"orderID"`,
+		cmdSummary: `Create a record of an order and its matches, signed with the
+    account key for the order's DEX. The record may be given to a counterparty
+    or DEX operator to settle a dispute.`,
+		pwArgsLong: `Password Args:
+    appPass (string): The DEX client password.`,
+		argsLong: `Args:
+    orderID (string): The hex ID of the order.`,
+		returns: `Returns:
+  obj: The signed report.
+  {
+    "report" (obj): The trade report.
+    {
+      "host" (string): The DEX address.
+      "accountID" (string): The hex account ID.
+      "order" (obj): The order, including its matches with their swap and
+        redemption coin IDs and timestamps.
+      "stamp" (int): Time the report was made in milliseconds since 00:00:00
+        Jan 1 1970.
+    },
+    "pubkey" (string): The hex compressed secp256k1 account public key.
+    "sig" (string): The hex DER signature of the SHA-256 hash of the compact
+      JSON encoding of the report.
+  }`,
+	},
+	matchesRoute: {
+		argsShort: `"orderID"`,
+		cmdSummary: `Show the settlement state of an order's matches, including the
+    swap, redemption, and refund coin IDs and the confirmations of swaps that
+    are still in progress. Use this to monitor swaps that may be stuck.`,
+		argsLong: `Args:
+    orderID (string): The hex ID of the order.`,
+		returns: `Returns:
+  array: The order's matches. Empty if the order has no matches.
+  [
+    {
+      "matchID" (string): The match's hex ID.
+      "status" (int): The match status. 0 for newly matched, 1 for maker swap
+        cast, 2 for taker swap cast, 3 for maker redeemed, and 4 for
+        complete.
+      "revoked" (bool): Whether the match was revoked by the server.
+      "rate" (int): The match rate.
+      "qty" (int): The match quantity.
+      "side" (int): The user's side of the match. 0 for maker, 1 for taker.
+      "feeRate" (int): The swap fee rate.
+      "swap" (string): The hex ID of the user's swap coin.
+      "counterSwap" (string): The hex ID of the counter-party's swap coin.
+      "redeem" (string): The hex ID of the user's redemption coin.
+      "counterRedeem" (string): The hex ID of the counter-party's
+        redemption coin.
+      "refund" (string): The hex ID of the user's refund coin.
+      "stamp" (int): The match time in milliseconds since the epoch.
+      "isCancel" (bool): Whether this is a cancel order match.
+      "active" (bool): Whether the match is being tracked by the client.
+      "swapConfs" (int): The confirmations of the user's swap. -1 if unknown
+        or not checked.
+      "swapConfsRequired" (int): The confirmations the swap requires.
+      "counterSwapConfs" (int): The confirmations of the counter-party's
+        swap. -1 if unknown or not checked.
+      "counterSwapConfsRequired" (int): The confirmations the counter-party's
+        swap requires.
+    },...
+  ]`,
+	},
+	swapCostsRoute: {
+		argsShort:  `"orderID"`,
+		cmdSummary: `Compare the estimated and actual swap and redemption fees for an order.`,
+		argsLong: `Args:
+    orderID (string): The hex ID of the order.`,
+		returns: `Returns:
+  obj: The swap cost report.
+  {
+    "orderID" (string): The order's hex ID.
+    "fromID" (int): The BIP-44 coin index of the asset being swapped.
+    "toID" (int): The BIP-44 coin index of the asset being redeemed.
+    "matches" (array): Per-match fees, in units of the asset's smallest
+      denomination. Actual fees are paid per transaction, so they are split
+      evenly between the matches that share a transaction.
+    [
+      {
+        "matchID" (string): The match's hex ID.
+        "estSwap" (int): The estimated swap fee.
+        "actualSwap" (int): The swap fee actually paid.
+        "swapDiff" (int): actualSwap minus estSwap.
+        "estRedemption" (int): The estimated redemption fee.
+        "actualRedemption" (int): The redemption fee actually paid.
+        "redemptionDiff" (int): actualRedemption minus estRedemption.
+      },...
+    ],
+    "estimated" (obj): The order's total estimated fees.
+    {
+      "swap" (int): The total estimated swap fees.
+      "redemption" (int): The total estimated redemption fees.
+    },
+    "actual" (obj): The order's total fees actually paid.
+    {
+      "swap" (int): The total swap fees paid.
+      "redemption" (int): The total redemption fees paid.
+    }
+  }`,
+	},
+	getNotificationsRoute: {
+		argsShort: `(n) ("severity")`,
+		cmdSummary: `Retrieve the most recent notifications, e.g. after reconnecting. Only
+    notifications with a severity of success or higher are stored.`,
+		argsLong: `Args:
+    n (int): Optional. Default is ` + strconv.Itoa(defaultNotifications) + `. The number of notifications to return,
+      at most ` + strconv.Itoa(core.MaxNotifications) + `.
+    severity (string): Optional. Default is "success". The minimum severity of
+      the notifications. One of "success", "warning" or "error".`,
+		returns: `Returns:
+    array: The notifications, newest first. Empty if there are none.
+    [
+      {
+        "type" (string): The notification type, e.g. "order" or "balance".
+        "subject" (string): A short description of the notification.
+        "details" (string): The notification details.
+        "severity" (int): The severity. 3 is success, 4 is warning, and 5 is
+          error.
+        "stamp" (int): The time of the notification in milliseconds since the
+          UNIX epoch.
+        "acked" (bool): Whether the notification has been acknowledged.
+        "id" (string): The notification ID.
+      },...
+    ]`,
+	},
+	candlesRoute: {
+		argsShort: `"host" base quote "binSize" (count)`,
+		cmdSummary: `Retrieve price and volume candles for a market. The server does not
+    provide a trade history, so the candles are built from this client's own
+    matches on the market. Bins with no matches are omitted.`,
+		argsLong: `Args:
+    host (string): The DEX the market is on.
+    base (int): The BIP-44 coin index for the market's base asset.
+    quote (int): The BIP-44 coin index for the market's quote asset.
+    binSize (string): The duration of each candle. One of "5m", "1h" or "1d".
+    count (int): Optional. Default is ` + strconv.Itoa(defaultCandles) + `. The number of the most recent
+      candles to return, at most ` + strconv.Itoa(core.MaxCandles) + `.`,
+		returns: `Returns:
+    array: The candles, oldest first. Empty if there are no matches.
+    [
+      {
+        "startStamp" (int): The start of the candle's bin in milliseconds
+          since the UNIX epoch.
+        "open" (int): The rate of the first match, in atoms of the quote
+          asset per 10^8 atoms of the base asset.
+        "high" (int): The highest match rate.
+        "low" (int): The lowest match rate.
+        "close" (int): The rate of the last match.
+        "volume" (int): The matched quantity, in atoms of the base asset.
+      },...
+    ]`,
+	},
 }
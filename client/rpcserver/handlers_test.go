@@ -4,19 +4,29 @@
 package rpcserver
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/comms"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/client/websocket"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
+	"decred.org/dcrdex/dex/order"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+	"github.com/decred/slog"
 )
 
 func verifyResponse(payload *msgjson.ResponsePayload, res interface{}, wantErrCode int) error {
@@ -81,6 +91,44 @@ func TestHelpMsgs(t *testing.T) {
 	}
 }
 
+func TestRouteHelps(t *testing.T) {
+	if len(routeHelps) != len(routes) {
+		t.Fatal("routes and routeHelps have different number of routes")
+	}
+	names := func(args []*argHelp) []string {
+		var s []string
+		for _, arg := range args {
+			s = append(s, arg.Name)
+		}
+		return s
+	}
+	for route := range routes {
+		rh, exists := routeHelps[route]
+		if !exists {
+			t.Fatalf("%v exists in routes but not in routeHelps", route)
+		}
+		if rh.Route != route || rh.Summary == "" {
+			t.Fatalf("%s: bad route help %+v", route, rh)
+		}
+		// The breakdowns must describe the arguments of the example inputs,
+		// in the same order.
+		msg := helpMsgs[route]
+		wantPW, _ := shortArgs(msg.pwArgsShort)
+		if got := names(rh.PWArgs); !reflect.DeepEqual(got, wantPW) {
+			t.Errorf("%s: password args %v do not match example input %v", route, got, wantPW)
+		}
+		wantArgs, _ := shortArgs(msg.argsShort)
+		if got := names(rh.Args); !reflect.DeepEqual(got, wantArgs) {
+			t.Errorf("%s: args %v do not match example input %v", route, got, wantArgs)
+		}
+		for _, arg := range append(rh.PWArgs, rh.Args...) {
+			if arg.Type == "" || arg.Description == "" {
+				t.Errorf("%s: incomplete help for arg %s", route, arg.Name)
+			}
+		}
+	}
+}
+
 func TestListCommands(t *testing.T) {
 	// no passwords
 	res := ListCommands(false)
@@ -167,6 +215,10 @@ func TestHandleHelp(t *testing.T) {
 		name:        "bad params",
 		params:      &RawParams{Args: []string{"version", "blue"}},
 		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad structured param",
+		params:      &RawParams{Args: []string{"version", "true", "blue"}},
+		wantErrCode: msgjson.RPCArgumentsError,
 	}}
 	for _, test := range tests {
 		payload := handleHelp(nil, test.params)
@@ -177,12 +229,131 @@ func TestHandleHelp(t *testing.T) {
 	}
 }
 
+func TestHandleStructuredHelp(t *testing.T) {
+	// All routes.
+	payload := handleHelp(nil, &RawParams{Args: []string{"", "false", "true"}})
+	var all []*routeHelp
+	if err := verifyResponse(payload, &all, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(routes) {
+		t.Fatalf("wanted help for %d routes, got %d", len(routes), len(all))
+	}
+	for _, rh := range all {
+		if len(rh.PWArgs) != 0 {
+			t.Fatalf("password args included in help for %s", rh.Route)
+		}
+	}
+
+	// One route.
+	payload = handleHelp(nil, &RawParams{Args: []string{newWalletRoute, "true", "true"}})
+	res := new(routeHelp)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if res.Route != newWalletRoute || len(res.PWArgs) != 2 || len(res.Args) != 3 {
+		t.Fatalf("wrong help for %s: %+v", newWalletRoute, res)
+	}
+	assetID, path := res.Args[0], res.Args[1]
+	if assetID.Name != "assetID" || assetID.Type != "int" || !assetID.Required {
+		t.Fatalf("wrong assetID arg help: %+v", assetID)
+	}
+	if path.Name != "path" || path.Required || strings.HasPrefix(path.Description, "Optional.") {
+		t.Fatalf("wrong path arg help: %+v", path)
+	}
+	if !strings.Contains(assetID.Description, "slip-0044") {
+		t.Fatalf("continuation line missing from description: %q", assetID.Description)
+	}
+
+	// The shared routeHelps are not modified when password args are omitted.
+	payload = handleHelp(nil, &RawParams{Args: []string{newWalletRoute, "false", "true"}})
+	res = new(routeHelp)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.PWArgs) != 0 || len(routeHelps[newWalletRoute].PWArgs) != 2 {
+		t.Fatalf("wrong password args for %s: %+v", newWalletRoute, res)
+	}
+
+	// Unknown route.
+	payload = handleHelp(nil, &RawParams{Args: []string{"nope", "false", "true"}})
+	if err := verifyResponse(payload, res, msgjson.RPCUnknownRoute); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestHandleVersion(t *testing.T) {
-	payload := handleVersion(nil, nil)
-	res := ""
-	if err := verifyResponse(payload, &res, -1); err != nil {
+	payload := handleVersion(&RPCServer{}, nil)
+	res := new(versionResponse)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if res.Major != rpcSemverMajor || res.Minor != rpcSemverMinor || res.Patch != rpcSemverPatch {
+		t.Fatalf("wrong RPC version %s", res)
+	}
+	if res.App != nil {
+		t.Fatalf("unexpected app version with no app version configured")
+	}
+
+	payload = handleVersion(&RPCServer{appVersion: "1.2.3-pre+dev"}, nil)
+	res = new(versionResponse)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if res.App == nil || res.App.Version != "1.2.3-pre+dev" {
+		t.Fatalf("app version not reported")
+	}
+
+	// Every route is listed, in order.
+	if len(res.Routes) != len(routes) || !sort.StringsAreSorted(res.Routes) {
+		t.Fatalf("wrong routes %v", res.Routes)
+	}
+	for _, route := range res.Routes {
+		if routes[route] == nil {
+			t.Fatalf("unknown route %q listed", route)
+		}
+	}
+	caps := res.Capabilities
+	if caps == nil || !caps.Batch || caps.TLS || caps.MTLS || caps.Metrics || caps.Admin || caps.Raw {
+		t.Fatalf("wrong capabilities for the default server %+v", caps)
+	}
+
+	// The optional features are reported as configured. The raw route also
+	// needs the admin token.
+	s := &RPCServer{
+		certs:                new(certHolder),
+		clientCAFingerprints: []string{"ab"},
+		metrics:              newRPCMetrics(""),
+		unsafeRaw:            true,
+	}
+	res = new(versionResponse)
+	if err := verifyResponse(handleVersion(s, nil), res, -1); err != nil {
+		t.Fatal(err)
+	}
+	caps = res.Capabilities
+	if !caps.TLS || !caps.MTLS || !caps.Metrics || caps.Admin || caps.Raw {
+		t.Fatalf("wrong capabilities %+v", caps)
+	}
+	s.hasAdmin = true
+	res = new(versionResponse)
+	if err := verifyResponse(handleVersion(s, nil), res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Capabilities.Admin || !res.Capabilities.Raw {
+		t.Fatalf("admin and raw not reported %+v", res.Capabilities)
+	}
+}
+
+func TestHandlePing(t *testing.T) {
+	before := encode.UnixMilliU(time.Now())
+	payload := handlePing(&RPCServer{}, nil)
+	res := new(pongResponse)
+	if err := verifyResponse(payload, res, -1); err != nil {
 		t.Fatal(err)
 	}
+	if res.Time < before || res.Time > encode.UnixMilliU(time.Now()) {
+		t.Fatalf("wrong pong time %d", res.Time)
+	}
 }
 
 func TestHandleGetFee(t *testing.T) {
@@ -195,7 +366,7 @@ func TestHandleGetFee(t *testing.T) {
 	}{{
 		name:        "ok",
 		params:      &RawParams{Args: []string{"dex", "cert"}},
-		regFee:      5,
+		regFee:      5e8,
 		wantErrCode: -1,
 	}, {
 		name:        "core.getFee error",
@@ -222,6 +393,70 @@ func TestHandleGetFee(t *testing.T) {
 			t.Fatalf("wanted registration fee %d but got %d for test %s",
 				test.regFee, res.Fee, test.name)
 		}
+		if test.wantErrCode == -1 && (res.Conventional != 5 || res.ConventionalUnit != "DCR" ||
+			res.Units != "atoms" || res.ConversionFactor != 1e8) {
+			t.Fatalf("wrong conversion info %+v for test %s", res, test.name)
+		}
+	}
+}
+
+func TestHandleGetDEXConfig(t *testing.T) {
+	exchange := &core.Exchange{
+		Host: "dex.example.com:7232",
+		Markets: map[string]*core.Market{
+			"dcr_btc": {Name: "dcr_btc", BaseID: 42, QuoteID: 0},
+		},
+		Assets: map[uint32]*dex.Asset{
+			42: {ID: 42, Symbol: "dcr"},
+			0:  {ID: 0, Symbol: "btc"},
+		},
+		ConfsRequired: 4,
+	}
+	tests := []struct {
+		name         string
+		params       *RawParams
+		dexConfigErr error
+		wantErrCode  int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"dex.example.com:7232", "cert"}},
+		wantErrCode: -1,
+	}, {
+		name:        "ok no cert",
+		params:      &RawParams{Args: []string{"dex.example.com:7232"}},
+		wantErrCode: -1,
+	}, {
+		name:         "core.DEXConfig error",
+		params:       &RawParams{Args: []string{"dex.example.com:7232"}},
+		dexConfigErr: errors.New("unreachable"),
+		wantErrCode:  msgjson.RPCDEXConfigError,
+	}, {
+		name:        "bad address",
+		params:      &RawParams{Args: []string{"dex.example.com:"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			dexConfig:    exchange,
+			dexConfigErr: test.dexConfigErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleGetDEXConfig(r, test.params)
+		res := new(core.Exchange)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if res.Host != exchange.Host || len(res.Markets) != 1 || len(res.Assets) != 2 ||
+			res.ConfsRequired != exchange.ConfsRequired {
+			t.Fatalf("%s: wrong result %+v", test.name, res)
+		}
 	}
 }
 
@@ -257,6 +492,101 @@ func TestHandleInit(t *testing.T) {
 	}
 }
 
+func TestHandleChangeAppPass(t *testing.T) {
+	pw, newPW := encode.PassBytes("password123"), encode.PassBytes("password456")
+	tests := []struct {
+		name             string
+		params           *RawParams
+		changeAppPassErr error
+		wantErrCode      int
+	}{{
+		name:        "ok",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw, newPW}},
+		wantErrCode: -1,
+	}, {
+		name:             "core.ChangeAppPass error",
+		params:           &RawParams{PWArgs: []encode.PassBytes{pw, newPW}},
+		changeAppPassErr: errors.New("error"),
+		wantErrCode:      msgjson.RPCChangeAppPassError,
+	}, {
+		name:        "empty new password",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw, {}}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{changeAppPassErr: test.changeAppPassErr}
+		r := &RPCServer{core: tc}
+		payload := handleChangeAppPass(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func TestHandleBackup(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	checksum := encode.RandomBytes(32)
+	tests := []struct {
+		name          string
+		params        *RawParams
+		backupErr     error
+		wantOverwrite bool
+		wantErrCode   int
+	}{{
+		name:        "ok",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw}, Args: []string{"/tmp/dexc.db"}},
+		wantErrCode: -1,
+	}, {
+		name:          "ok overwrite",
+		params:        &RawParams{PWArgs: []encode.PassBytes{pw}, Args: []string{"/tmp/dexc.db", "true"}},
+		wantOverwrite: true,
+		wantErrCode:   -1,
+	}, {
+		name:        "core.Backup error",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw}, Args: []string{"/tmp/dexc.db"}},
+		backupErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCBackupError,
+	}, {
+		name:        "empty path",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw}, Args: []string{""}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad overwrite",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw}, Args: []string{"/tmp/dexc.db", "yes please"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{PWArgs: []encode.PassBytes{pw}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{backupChecksum: checksum, backupErr: test.backupErr}
+		r := &RPCServer{core: tc}
+		payload := handleBackup(r, test.params)
+		res := new(backupResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if tc.backupOverwrite != test.wantOverwrite {
+			t.Fatalf("%s: wrong overwrite flag", test.name)
+		}
+		if res.Path != test.params.Args[0] {
+			t.Fatalf("%s: wrong path %q", test.name, res.Path)
+		}
+		if res.Checksum != dex.Bytes(checksum).String() {
+			t.Fatalf("%s: wrong checksum %q", test.name, res.Checksum)
+		}
+	}
+}
+
 func TestHandleNewWallet(t *testing.T) {
 	pw := encode.PassBytes("password123")
 	params := &RawParams{
@@ -337,6 +667,68 @@ func TestHandleNewWallet(t *testing.T) {
 	}
 }
 
+func TestHandleReconfigWallet(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{pw},
+		Args: []string{
+			"42",
+			"username=tacotime\nrpclisten=127.0.0.1:19557",
+			`{"username":"burritotime"}`,
+		},
+	}
+	tests := []struct {
+		name              string
+		params            *RawParams
+		reconfigWalletErr error
+		wantErrCode       int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:              "core.ReconfigureWallet error",
+		params:            params,
+		reconfigWalletErr: errors.New("error"),
+		wantErrCode:       msgjson.RPCReconfigWalletError,
+	}, {
+		name: "no settings",
+		params: &RawParams{
+			PWArgs: []encode.PassBytes{pw},
+			Args:   []string{"42"},
+		},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name: "bad JSON error",
+		params: &RawParams{
+			PWArgs: []encode.PassBytes{pw},
+			Args:   []string{"42", "", `{"username":  burritotime"}`},
+		},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{reconfigWalletErr: test.reconfigWalletErr}
+		r := &RPCServer{core: tc}
+		payload := handleReconfigWallet(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		// The JSON settings override the file settings.
+		cfg := tc.reconfigSettings
+		if cfg["username"] != "burritotime" || cfg["rpclisten"] != "127.0.0.1:19557" {
+			t.Fatalf("%s: settings not parsed correctly: %v", test.name, cfg)
+		}
+	}
+}
+
 func TestHandleOpenWallet(t *testing.T) {
 	pw := encode.PassBytes("password123")
 	params := &RawParams{
@@ -375,6 +767,64 @@ func TestHandleOpenWallet(t *testing.T) {
 	}
 }
 
+func TestHandleOpenCloseAllWallets(t *testing.T) {
+	wallets := []*core.WalletState{
+		{Symbol: "btc", AssetID: 0},
+		{Symbol: "dcr", AssetID: 42},
+	}
+	pw := encode.PassBytes("abc")
+	wantResults := func(name string, res map[string]*walletResult, failed uint32) {
+		t.Helper()
+		if len(res) != len(wallets) {
+			t.Fatalf("%s: wanted %d results, got %d", name, len(wallets), len(res))
+		}
+		for _, w := range wallets {
+			wr := res[w.Symbol]
+			if wr == nil || wr.AssetID != w.AssetID {
+				t.Fatalf("%s: missing or wrong result for %s", name, w.Symbol)
+			}
+			if wantOK := w.AssetID != failed; wr.OK != wantOK || (wr.Error == "") != wantOK {
+				t.Fatalf("%s: %s result ok = %v, error = %q", name, w.Symbol, wr.OK, wr.Error)
+			}
+		}
+	}
+
+	// One wallet failing does not prevent opening the other.
+	tc := &TCore{
+		wallets:        wallets,
+		openWalletErrs: map[uint32]error{0: errors.New("error")},
+	}
+	r := &RPCServer{core: tc, wsServer: wsServer}
+	payload := handleOpenAllWallets(r, &RawParams{PWArgs: []encode.PassBytes{pw}})
+	var res map[string]*walletResult
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	wantResults("openallwallets", res, 0)
+
+	payload = handleOpenAllWallets(r, &RawParams{})
+	if err := verifyResponse(payload, &res, msgjson.RPCArgumentsError); err != nil {
+		t.Fatal(err)
+	}
+
+	tc = &TCore{
+		wallets:         wallets,
+		closeWalletErrs: map[uint32]error{42: errors.New("active swaps")},
+	}
+	r = &RPCServer{core: tc, wsServer: wsServer}
+	payload = handleCloseAllWallets(r, &RawParams{})
+	res = nil
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	wantResults("closeallwallets", res, 42)
+
+	payload = handleCloseAllWallets(r, &RawParams{Args: []string{"42"}})
+	if err := verifyResponse(payload, &res, msgjson.RPCArgumentsError); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestHandleCloseWallet(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -416,51 +866,455 @@ func TestHandleWallets(t *testing.T) {
 	}
 }
 
-func TestHandleRegister(t *testing.T) {
-	pw := encode.PassBytes("password123")
-	params := &RawParams{
-		PWArgs: []encode.PassBytes{pw},
-		Args: []string{
-			"dex:1234",
-			"1000",
-			"cert",
+func TestHandleSupportedAssets(t *testing.T) {
+	tc := &TCore{supportedAssets: map[uint32]*core.SupportedAsset{
+		42: {
+			ID:     42,
+			Symbol: "dcr",
+			Info:   &asset.WalletInfo{Name: "Decred"},
+			Wallet: &core.WalletState{Symbol: "dcr", AssetID: 42, Open: true, Running: true},
 		},
+		0: {
+			ID:     0,
+			Symbol: "btc",
+			Info:   &asset.WalletInfo{Name: "Bitcoin"},
+		},
+	}}
+	r := &RPCServer{core: tc}
+	payload := handleSupportedAssets(r, nil)
+	var res []*supportedAssetResult
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	want := []*supportedAssetResult{
+		{ID: 0, Symbol: "btc", Name: "Bitcoin", Driver: true},
+		{ID: 42, Symbol: "dcr", Name: "Decred", Driver: true, HasWallet: true, Open: true, Running: true},
 	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("expected %v, got %v", spew.Sdump(want), spew.Sdump(res))
+	}
+}
+
+func TestHandleWSClients(t *testing.T) {
+	r := &RPCServer{core: &TCore{}, wsServer: wsServer}
+	payload := handleWSClients(r, nil)
+	var res []*websocket.ClientInfo
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if res == nil || len(res) != 0 {
+		t.Fatalf("expected an empty client list, got %v", res)
+	}
+}
+
+func TestHandleWSDisconnect(t *testing.T) {
 	tests := []struct {
-		name                   string
-		params                 *RawParams
-		regFee                 uint64
-		getFeeErr, registerErr error
-		wantErrCode            int
+		name        string
+		params      *RawParams
+		wantErrCode int
 	}{{
-		name:        "ok",
-		params:      params,
-		regFee:      1000,
-		wantErrCode: -1,
-	}, {
-		name:        "fee different",
-		params:      params,
-		regFee:      100,
-		wantErrCode: msgjson.RPCRegisterError,
-	}, {
-		name:        "core.Register error",
-		params:      params,
-		regFee:      1000,
-		registerErr: errors.New("error"),
-		wantErrCode: msgjson.RPCRegisterError,
+		name:        "unknown client",
+		params:      &RawParams{Args: []string{"12"}},
+		wantErrCode: msgjson.RPCWSClientError,
 	}, {
-		name:        "core.GetFee error",
-		params:      params,
-		getFeeErr:   errors.New("error"),
-		wantErrCode: msgjson.RPCGetFeeError,
+		name:        "bad ID",
+		params:      &RawParams{Args: []string{"-1"}},
+		wantErrCode: msgjson.RPCArgumentsError,
 	}, {
-		name:        "bad params",
+		name:        "no ID",
 		params:      &RawParams{},
 		wantErrCode: msgjson.RPCArgumentsError,
 	}}
 	for _, test := range tests {
-		tc := &TCore{
-			registerErr: test.registerErr,
+		r := &RPCServer{core: &TCore{}, wsServer: wsServer}
+		payload := handleWSDisconnect(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func TestHandleLogLevel(t *testing.T) {
+	defer func(l dex.Logger) { log = l }(log)
+	log = dex.NewLogger("TEST", dex.LevelDebug, new(bytes.Buffer))
+
+	tests := []struct {
+		name         string
+		params       *RawParams
+		wantPrevious string
+		wantCurrent  string
+		wantErrCode  int
+	}{{
+		name:         "ok",
+		params:       &RawParams{Args: []string{"trace"}},
+		wantPrevious: "debug",
+		wantCurrent:  "trace",
+		wantErrCode:  -1,
+	}, {
+		name:         "ok upper case",
+		params:       &RawParams{Args: []string{"WARN"}},
+		wantPrevious: "trace",
+		wantCurrent:  "warn",
+		wantErrCode:  -1,
+	}, {
+		name:        "unknown level",
+		params:      &RawParams{Args: []string{"verbose"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "off not accepted",
+		params:      &RawParams{Args: []string{"off"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "no level",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		r := &RPCServer{core: &TCore{}}
+		payload := handleLogLevel(r, test.params)
+		res := new(logLevelResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if res.Previous != test.wantPrevious || res.Current != test.wantCurrent {
+			t.Fatalf("%s: wrong levels %+v", test.name, res)
+		}
+		if logLevelName(log.Level()) != test.wantCurrent {
+			t.Fatalf("%s: level not set, got %s", test.name, logLevelName(log.Level()))
+		}
+	}
+	// A failed request does not change the level.
+	if log.Level() != dex.LevelWarn {
+		t.Fatalf("level changed by a failed request to %s", logLevelName(log.Level()))
+	}
+
+	// With Config.SetLogLevel, the application's level is set and reported.
+	appLevel := dex.LevelInfo
+	r := &RPCServer{core: &TCore{}, setLogLevel: func(lvl slog.Level) slog.Level {
+		prev := appLevel
+		appLevel = lvl
+		return prev
+	}}
+	payload := handleLogLevel(r, &RawParams{Args: []string{"error"}})
+	res := new(logLevelResponse)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatalf("SetLogLevel: %v", err)
+	}
+	if res.Previous != "info" || res.Current != "error" || appLevel != dex.LevelError {
+		t.Fatalf("SetLogLevel: wrong levels %+v, application level %s", res, appLevel)
+	}
+}
+
+func TestHandleRaw(t *testing.T) {
+	serverResp := &msgjson.ResponsePayload{Result: json.RawMessage(`{"a":1}`)}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		disabled    bool
+		rawErr      error
+		wantPayload string
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"dex.tld:7232", "config", `{"x":1}`}},
+		wantPayload: `{"x":1}`,
+		wantErrCode: -1,
+	}, {
+		name:        "ok no payload",
+		params:      &RawParams{Args: []string{"dex.tld:7232", "config"}},
+		wantErrCode: -1,
+	}, {
+		name:        "disabled",
+		params:      &RawParams{Args: []string{"dex.tld:7232", "config"}},
+		disabled:    true,
+		wantErrCode: msgjson.RPCRawError,
+	}, {
+		name:        "core error",
+		params:      &RawParams{Args: []string{"dex.tld:7232", "config"}},
+		rawErr:      errors.New("error"),
+		wantErrCode: msgjson.RPCRawError,
+	}, {
+		name:        "invalid payload",
+		params:      &RawParams{Args: []string{"dex.tld:7232", "config", `{"x":`}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "no route",
+		params:      &RawParams{Args: []string{"dex.tld:7232"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{rawResp: serverResp, rawErr: test.rawErr}
+		r := &RPCServer{core: tc, unsafeRaw: !test.disabled}
+		payload := handleRaw(r, test.params)
+		res := new(msgjson.ResponsePayload)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if string(res.Result) != string(serverResp.Result) {
+			t.Fatalf("%s: wrong result %s", test.name, res.Result)
+		}
+		if string(tc.rawPayload) != test.wantPayload {
+			t.Fatalf("%s: wrong payload sent %s", test.name, tc.rawPayload)
+		}
+	}
+}
+
+func TestHandleWalletState(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      *RawParams
+		walletState *core.WalletState
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"42"}},
+		walletState: &core.WalletState{Symbol: "dcr", AssetID: 42, Open: true, Running: true},
+		wantErrCode: -1,
+	}, {
+		name:        "no wallet",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: msgjson.RPCWalletNotFoundError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{walletState: test.walletState}
+		r := &RPCServer{core: tc}
+		payload := handleWalletState(r, test.params)
+		res := new(core.WalletState)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+		if test.wantErrCode == -1 && *res != *test.walletState {
+			t.Fatalf("%s: wanted %+v, got %+v", test.name, test.walletState, res)
+		}
+	}
+}
+
+func TestHandleFeeRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      *RawParams
+		feeRateErr  error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: -1,
+	}, {
+		name:        "core.FeeRate error",
+		params:      &RawParams{Args: []string{"42"}},
+		feeRateErr:  errors.New("no estimate"),
+		wantErrCode: msgjson.RPCFeeRateError,
+	}, {
+		name:        "unknown asset",
+		params:      &RawParams{Args: []string{"12345"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			feeRate:    22,
+			feeRateErr: test.feeRateErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleFeeRate(r, test.params)
+		res := new(feeRateResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if res.FeeRate != 22 || res.Units != "atoms/byte" {
+			t.Fatalf("%s: unexpected response %+v", test.name, res)
+		}
+	}
+}
+
+func TestHandleAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      *RawParams
+		addrErr     error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: -1,
+	}, {
+		name:        "core.NewDepositAddress error",
+		params:      &RawParams{Args: []string{"42"}},
+		addrErr:     errors.New("no address"),
+		wantErrCode: msgjson.RPCAddressError,
+	}, {
+		name:        "unknown asset",
+		params:      &RawParams{Args: []string{"12345"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			depositAddr:    "DsAddr",
+			depositAddrErr: test.addrErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleAddress(r, test.params)
+		var res string
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != "DsAddr" {
+			t.Fatalf("%s: wrong address %q", test.name, res)
+		}
+	}
+}
+
+func TestHandleRescanWallet(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      *RawParams
+		rescanErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: -1,
+	}, {
+		name:        "core.RescanWallet error",
+		params:      &RawParams{Args: []string{"42"}},
+		rescanErr:   errors.New("already rescanning"),
+		wantErrCode: msgjson.RPCRescanWalletError,
+	}, {
+		name:        "unknown asset",
+		params:      &RawParams{Args: []string{"12345"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{rescanErr: test.rescanErr}
+		r := &RPCServer{core: tc}
+		payload := handleRescanWallet(r, test.params)
+		var res string
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && res != fmt.Sprintf(rescanStartedStr, "dcr") {
+			t.Fatalf("%s: wrong response %q", test.name, res)
+		}
+	}
+}
+
+func TestHandleValidateAddress(t *testing.T) {
+	tests := []struct {
+		name            string
+		params          *RawParams
+		valid           bool
+		validateAddrErr error
+		wantErrCode     int
+	}{{
+		name:        "ok valid",
+		params:      &RawParams{Args: []string{"42", "DsAddr"}},
+		valid:       true,
+		wantErrCode: -1,
+	}, {
+		name:        "ok invalid",
+		params:      &RawParams{Args: []string{"42", "DsAddr"}},
+		wantErrCode: -1,
+	}, {
+		name:            "core.ValidateAddress error",
+		params:          &RawParams{Args: []string{"42", "DsAddr"}},
+		validateAddrErr: errors.New("no validator"),
+		wantErrCode:     msgjson.RPCValidateAddressError,
+	}, {
+		name:        "unknown asset",
+		params:      &RawParams{Args: []string{"12345", "DsAddr"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "no address",
+		params:      &RawParams{Args: []string{"42"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			validAddr:       test.valid,
+			validateAddrErr: test.validateAddrErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleValidateAddress(r, test.params)
+		var valid bool
+		if err := verifyResponse(payload, &valid, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && valid != test.valid {
+			t.Fatalf("%s: wanted valid = %v, got %v", test.name, test.valid, valid)
+		}
+	}
+}
+
+func TestHandleRegister(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{pw},
+		Args: []string{
+			"dex:1234",
+			"1000",
+			"cert",
+		},
+	}
+	tests := []struct {
+		name                   string
+		params                 *RawParams
+		regFee                 uint64
+		getFeeErr, registerErr error
+		wantErrCode            int
+	}{{
+		name:        "ok",
+		params:      params,
+		regFee:      1000,
+		wantErrCode: -1,
+	}, {
+		name:        "fee different",
+		params:      params,
+		regFee:      100,
+		wantErrCode: msgjson.RPCRegisterError,
+	}, {
+		name:        "core.Register error",
+		params:      params,
+		regFee:      1000,
+		registerErr: errors.New("error"),
+		wantErrCode: msgjson.RPCRegisterError,
+	}, {
+		name:        "core.GetFee error",
+		params:      params,
+		getFeeErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCGetFeeError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			registerErr: test.registerErr,
 			regFee:      test.regFee,
 			getFeeErr:   test.getFeeErr,
 		}
@@ -599,6 +1453,81 @@ func TestHandleExchanges(t *testing.T) {
 	}
 }
 
+func TestHandleExchangesLargeAmounts(t *testing.T) {
+	// 2^53 + 1 cannot be represented by a float64.
+	const bigAmt uint64 = 1<<53 + 1
+	exchanges := map[string]*core.Exchange{
+		"dex": {
+			Host:    "dex",
+			Markets: map[string]*core.Market{},
+			Assets: map[uint32]*dex.Asset{
+				42: {ID: 42, Symbol: "dcr", LotSize: bigAmt, MaxFeeRate: bigAmt},
+			},
+		},
+	}
+	tc := &TCore{exchanges: exchanges}
+	r := &RPCServer{core: tc}
+	payload := handleExchanges(r, nil)
+	var res map[string]*core.Exchange
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	a := res["dex"].Assets[42]
+	if a.LotSize != bigAmt || a.MaxFeeRate != bigAmt {
+		t.Fatalf("amounts not preserved. wanted %d, got lot size %d, max fee rate %d",
+			bigAmt, a.LotSize, a.MaxFeeRate)
+	}
+}
+
+func TestHandleConnStats(t *testing.T) {
+	stats := map[string]comms.ConnStats{
+		"dex.example.com:7232": {
+			Reconnects:     2,
+			BytesRead:      1000,
+			BytesWritten:   100,
+			QueuedMessages: 3,
+			LastConnect:    time.Unix(1600000000, 0).UTC(),
+		},
+	}
+	tc := &TCore{connStats: stats}
+	r := &RPCServer{core: tc}
+	payload := handleConnStats(r, nil)
+	var res map[string]comms.ConnStats
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, stats) {
+		t.Fatalf("expected %v but got %v", spew.Sdump(stats), spew.Sdump(res))
+	}
+}
+
+func TestHandleConnStatus(t *testing.T) {
+	tc := &TCore{connStatus: map[string]comms.ConnStatus{
+		"dex.example.com:7232": {Connected: true},
+		"dex2.example.com:7232": {
+			Reconnecting: true,
+			LastError:    errors.New("dial timeout"),
+		},
+	}}
+	r := &RPCServer{core: tc}
+	payload := handleConnStatus(r, nil)
+	var res map[string]map[string]interface{}
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]interface{}{
+		"dex.example.com:7232": {"connected": true, "reconnecting": false},
+		"dex2.example.com:7232": {
+			"connected":    false,
+			"reconnecting": true,
+			"lastError":    "dial timeout",
+		},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("expected %v but got %v", want, res)
+	}
+}
+
 func TestHandleLogin(t *testing.T) {
 	params := &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("abc")}}
 	tests := []struct {
@@ -677,6 +1606,64 @@ func TestHandleTrade(t *testing.T) {
 	}
 }
 
+func TestHandlePreOrder(t *testing.T) {
+	params := &RawParams{
+		Args: []string{
+			"1.2.3.4:3000", // 0. DEX
+			"true",         // 1. IsLimit
+			"true",         // 2. Sell
+			"42",           // 3. Base
+			"0",            // 4. Quote
+			"100000000",    // 5. Qty
+			"1000",         // 6. Rate
+		}}
+	est := &core.OrderEstimate{
+		Host:     "1.2.3.4:3000",
+		MarketID: "dcr_btc",
+		FromID:   42,
+		LotSize:  1e8,
+		RateStep: 1000,
+		MinOrder: 1e8,
+		Lots:     1,
+		Fees:     &core.FeeBreakdown{Swap: 4000, Redemption: 1000},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		preOrderErr error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:        "core.PreOrder error",
+		params:      params,
+		preOrderErr: errors.New("error"),
+		wantErrCode: msgjson.RPCPreOrderError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: params.Args[:6]},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad qty",
+		params:      &RawParams{Args: append(append([]string{}, params.Args[:5]...), "abc", "1000")},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{orderEstimate: est, preOrderErr: test.preOrderErr}
+		r := &RPCServer{core: tc}
+		payload := handlePreOrder(r, test.params)
+		res := new(core.OrderEstimate)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && !reflect.DeepEqual(res, est) {
+			t.Fatalf("%s: expected %v, got %v", test.name, spew.Sdump(est), spew.Sdump(res))
+		}
+	}
+}
+
 func TestHandleCancel(t *testing.T) {
 	params := &RawParams{
 		PWArgs: []encode.PassBytes{encode.PassBytes("abc")},
@@ -796,10 +1783,164 @@ func TestHandleLogout(t *testing.T) {
 			logoutErr: test.logoutErr,
 		}
 		r := &RPCServer{core: tc}
-		payload := handleLogout(r, nil)
-		res := ""
-		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
-			t.Fatal(err)
+		payload := handleLogout(r, nil)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandleDisconnectDEX(t *testing.T) {
+	pw := []encode.PassBytes{encode.PassBytes("abc")}
+	tests := []struct {
+		name          string
+		params        *RawParams
+		disconnectErr error
+		wantForget    bool
+		wantForce     bool
+		wantErrCode   int
+	}{{
+		name:        "ok",
+		params:      &RawParams{PWArgs: pw, Args: []string{"dex.example.com:7232"}},
+		wantErrCode: -1,
+	}, {
+		name:        "ok forget and force",
+		params:      &RawParams{PWArgs: pw, Args: []string{"dex.example.com:7232", "true", "1"}},
+		wantForget:  true,
+		wantForce:   true,
+		wantErrCode: -1,
+	}, {
+		name:          "core.DisconnectDEX error",
+		params:        &RawParams{PWArgs: pw, Args: []string{"dex.example.com:7232", "true"}},
+		disconnectErr: errors.New("cannot disconnect with active orders"),
+		wantForget:    true,
+		wantErrCode:   msgjson.RPCDisconnectDEXError,
+	}, {
+		name:        "bad forget",
+		params:      &RawParams{PWArgs: pw, Args: []string{"dex.example.com:7232", "yes"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad address",
+		params:      &RawParams{PWArgs: pw, Args: []string{"ftp://dex.example.com"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "no password",
+		params:      &RawParams{Args: []string{"dex.example.com:7232"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{disconnectErr: test.disconnectErr}
+		r := &RPCServer{core: tc}
+		payload := handleDisconnectDEX(r, test.params)
+		res := ""
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == msgjson.RPCArgumentsError {
+			continue
+		}
+		if tc.disconnectForget != test.wantForget || tc.disconnectForce != test.wantForce {
+			t.Fatalf("%s: wanted forget = %v, force = %v, got %v, %v", test.name,
+				test.wantForget, test.wantForce, tc.disconnectForget, tc.disconnectForce)
+		}
+	}
+}
+
+func TestHandleMarket(t *testing.T) {
+	params := &RawParams{Args: []string{"dex", "42", "0"}}
+	tests := []struct {
+		name          string
+		params        *RawParams
+		marketInfo    *core.MarketInfo
+		marketInfoErr error
+		wantErrCode   int
+	}{{
+		name:        "ok",
+		params:      params,
+		marketInfo:  &core.MarketInfo{Name: "dcr_btc", LotSize: 1e8, RateStep: 100, Running: true},
+		wantErrCode: -1,
+	}, {
+		name:          "core.MarketInfo error",
+		params:        params,
+		marketInfoErr: errors.New("error"),
+		wantErrCode:   msgjson.RPCMarketError,
+	}, {
+		name:        "bad base",
+		params:      &RawParams{Args: []string{"dex", "dcr", "0"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "missing quote",
+		params:      &RawParams{Args: []string{"dex", "42"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{marketInfo: test.marketInfo, marketInfoErr: test.marketInfoErr}
+		r := &RPCServer{core: tc}
+		payload := handleMarket(r, test.params)
+		res := new(core.MarketInfo)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && !reflect.DeepEqual(res, test.marketInfo) {
+			t.Fatalf("%s: wrong market %+v", test.name, res)
+		}
+	}
+}
+
+func TestHandleFeesPaid(t *testing.T) {
+	fees := &core.FeesPaid{
+		Host: "dex",
+		Assets: map[uint32]*core.AssetFees{
+			0:  {Symbol: "btc", Swap: 3000},
+			42: {Symbol: "dcr", Registration: 1e8, Redemption: 2000},
+		},
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		feesPaidErr error
+		wantSince   int64
+		wantErrCode int
+	}{{
+		name:        "ok all-time",
+		params:      &RawParams{Args: []string{"dex"}},
+		wantErrCode: -1,
+	}, {
+		name:        "ok since",
+		params:      &RawParams{Args: []string{"dex", "1600000000"}},
+		wantSince:   1600000000,
+		wantErrCode: -1,
+	}, {
+		name:        "core.FeesPaid error",
+		params:      &RawParams{Args: []string{"dex"}},
+		feesPaidErr: errors.New("error"),
+		wantErrCode: msgjson.RPCFeesPaidError,
+	}, {
+		name:        "bad since",
+		params:      &RawParams{Args: []string{"dex", "-1"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "no host",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{feesPaid: fees, feesPaidErr: test.feesPaidErr}
+		r := &RPCServer{core: tc}
+		payload := handleFeesPaid(r, test.params)
+		res := new(core.FeesPaid)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if tc.feesPaidSince != test.wantSince {
+			t.Fatalf("%s: wrong since time. wanted %d, got %d", test.name, test.wantSince, tc.feesPaidSince)
+		}
+		if !reflect.DeepEqual(res, fees) {
+			t.Fatalf("%s: wrong fees %+v", test.name, res)
 		}
 	}
 }
@@ -847,6 +1988,175 @@ func TestHandleOrderBook(t *testing.T) {
 	}
 }
 
+func TestHandleCandles(t *testing.T) {
+	params := &RawParams{Args: []string{"dex", "42", "0", "1h"}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		candles     []*core.Candle
+		candlesErr  error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		candles:     []*core.Candle{{StartStamp: 1, Open: 2, High: 3, Low: 1, Close: 2, Volume: 5}},
+		wantErrCode: -1,
+	}, {
+		name:        "ok no candles",
+		params:      params,
+		candles:     []*core.Candle{},
+		wantErrCode: -1,
+	}, {
+		name:        "core.Candles error",
+		params:      params,
+		candlesErr:  errors.New("error"),
+		wantErrCode: msgjson.RPCCandlesError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"dex", "42", "0", "1w"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			candles:    test.candles,
+			candlesErr: test.candlesErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleCandles(r, test.params)
+		var res []*core.Candle
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode == -1 && len(res) != len(test.candles) {
+			t.Fatalf("%s: wanted %d candles, got %d", test.name, len(test.candles), len(res))
+		}
+	}
+}
+
+func TestHandleGetNotifications(t *testing.T) {
+	newNote := func(subject string, severity db.Severity) *db.Notification {
+		note := db.NewNotification(core.NoteTypeOrder, subject, "details", severity)
+		return &note
+	}
+	// Newest first, as from Core.
+	notes := []*db.Notification{
+		newNote("a", db.Success),
+		newNote("b", db.ErrorLevel),
+		newNote("c", db.WarningLevel),
+		newNote("d", db.Success),
+		newNote("e", db.ErrorLevel),
+	}
+	tests := []struct {
+		name         string
+		params       *RawParams
+		notes        []*db.Notification
+		notesErr     error
+		wantN        int
+		wantSubjects string
+		wantErrCode  int
+	}{{
+		name:         "ok",
+		params:       &RawParams{Args: []string{"3"}},
+		notes:        notes[:3],
+		wantN:        3,
+		wantSubjects: "abc",
+		wantErrCode:  -1,
+	}, {
+		name:         "ok errors only",
+		params:       &RawParams{Args: []string{"1", "error"}},
+		notes:        notes,
+		wantN:        core.MaxNotifications,
+		wantSubjects: "b",
+		wantErrCode:  -1,
+	}, {
+		name:         "ok warnings and errors",
+		params:       &RawParams{Args: []string{"5", "warning"}},
+		notes:        notes,
+		wantN:        core.MaxNotifications,
+		wantSubjects: "bce",
+		wantErrCode:  -1,
+	}, {
+		name:        "ok none",
+		params:      &RawParams{},
+		notes:       []*db.Notification{},
+		wantN:       defaultNotifications,
+		wantErrCode: -1,
+	}, {
+		name:        "core.Notifications error",
+		params:      &RawParams{},
+		notesErr:    errors.New("error"),
+		wantErrCode: msgjson.RPCNotificationsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"5", "poke"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			notes:    test.notes,
+			notesErr: test.notesErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleGetNotifications(r, test.params)
+		var res []*db.Notification
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if tc.notesN != test.wantN {
+			t.Fatalf("%s: wanted %d notifications requested, got %d", test.name, test.wantN, tc.notesN)
+		}
+		if res == nil {
+			t.Fatalf("%s: null notifications", test.name)
+		}
+		var subjects string
+		for _, note := range res {
+			subjects += note.Subject()
+		}
+		if subjects != test.wantSubjects {
+			t.Fatalf("%s: wanted notifications %q, got %q", test.name, test.wantSubjects, subjects)
+		}
+	}
+}
+
+func TestHandleShutdown(t *testing.T) {
+	// Not supported without a shutdown function.
+	r := &RPCServer{core: new(TCore)}
+	payload := handleShutdown(r, nil)
+	res := ""
+	if err := verifyResponse(payload, &res, msgjson.RPCShutdownError); err != nil {
+		t.Fatal(err)
+	}
+
+	shutdowns := make(chan struct{}, 2)
+	r.shutdown = func() { shutdowns <- struct{}{} }
+	payload = handleShutdown(r, nil)
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+	if res != shutdownStr {
+		t.Fatalf("wrong response %q", res)
+	}
+	select {
+	case <-shutdowns:
+	case <-time.After(time.Second):
+		t.Fatalf("shutdown not called")
+	}
+
+	// A second request is an error and does not call shutdown again.
+	payload = handleShutdown(r, nil)
+	if err := verifyResponse(payload, &res, msgjson.RPCShutdownError); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-shutdowns:
+		t.Fatalf("shutdown called twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 func TestTruncateOrderBook(t *testing.T) {
 	lowRate := 1.0
 	medRate := 1.5
@@ -893,6 +2203,48 @@ func TestTruncateOrderBook(t *testing.T) {
 	}
 }
 
+func TestTruncateBookLevels(t *testing.T) {
+	newBook := func() *core.OrderBook {
+		return &core.OrderBook{
+			Buys: []*core.MiniOrder{
+				{Rate: 2, Qty: 1},
+				{Rate: 2, Qty: 2},
+				{Rate: 1.5, Qty: 3},
+				{Rate: 1, Qty: 4},
+			},
+			Sells: []*core.MiniOrder{
+				{Rate: 2.5, Qty: 1},
+				{Rate: 3, Qty: 2},
+				{Rate: 3, Qty: 3},
+			},
+			Epoch: []*core.MiniOrder{
+				{Rate: 1, Qty: 5},
+				{Rate: 4, Qty: 6},
+			},
+		}
+	}
+	tests := []struct {
+		levels              uint64
+		wantBuys, wantSells int
+	}{
+		{levels: 1, wantBuys: 2, wantSells: 1},
+		{levels: 2, wantBuys: 3, wantSells: 3},
+		{levels: 3, wantBuys: 4, wantSells: 3},
+		{levels: 10, wantBuys: 4, wantSells: 3},
+	}
+	for _, test := range tests {
+		book := newBook()
+		truncateBookLevels(book, test.levels)
+		if len(book.Buys) != test.wantBuys || len(book.Sells) != test.wantSells {
+			t.Fatalf("levels %d: wanted %d buys and %d sells, got %d and %d",
+				test.levels, test.wantBuys, test.wantSells, len(book.Buys), len(book.Sells))
+		}
+		if len(book.Epoch) != 2 {
+			t.Fatalf("levels %d: epoch orders truncated", test.levels)
+		}
+	}
+}
+
 func TestHandleMyOrders(t *testing.T) {
 	var exchangesIn map[string]*core.Exchange
 	if err := json.Unmarshal([]byte(exchangeIn), &exchangesIn); err != nil {
@@ -939,6 +2291,56 @@ func TestHandleMyOrders(t *testing.T) {
 	}
 }
 
+func TestHandleOrders(t *testing.T) {
+	page := &core.OrdersPage{
+		Orders:     []*core.Order{{Host: "127.0.0.1:7232"}},
+		Total:      3,
+		Offset:     1,
+		NextOffset: 2,
+	}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		ordersErr   error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"executed", "1", "1"}},
+		wantErrCode: -1,
+	}, {
+		name:        "core.OrdersPage error",
+		params:      &RawParams{},
+		ordersErr:   errors.New("error"),
+		wantErrCode: msgjson.RPCOrdersError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"all", "0", "0"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{
+			ordersPage: page,
+			ordersErr:  test.ordersErr,
+		}
+		r := &RPCServer{core: tc}
+		payload := handleOrders(r, test.params)
+		res := new(core.OrdersPage)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if len(res.Orders) != 1 || res.Total != 3 || res.Offset != 1 || res.NextOffset != 2 {
+			t.Fatalf("%s: wrong page returned: %+v", test.name, res)
+		}
+		if tc.ordersPageOffset != 1 || tc.ordersPageLimit != 1 ||
+			len(tc.ordersFilter.Statuses) != 1 || tc.ordersFilter.Statuses[0] != order.OrderStatusExecuted {
+			t.Fatalf("%s: wrong arguments passed to core", test.name)
+		}
+	}
+}
+
 func TestParseCoreOrder(t *testing.T) {
 	co := `{
     "canceled": false,
@@ -1007,3 +2409,276 @@ func TestParseCoreOrder(t *testing.T) {
 		t.Fatalf("expected %v but got %v", spew.Sdump(myOrder), spew.Sdump(res))
 	}
 }
+
+func TestHandleMatches(t *testing.T) {
+	params := &RawParams{Args: []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"}}
+	matches := []*core.MatchSettlement{{
+		Match: &core.Match{
+			MatchID: dex.Bytes{0x01},
+			Status:  order.MakerSwapCast,
+			Side:    order.Maker,
+			Swap:    dex.Bytes{0x02},
+		},
+		Active:           true,
+		SwapConfs:        1,
+		SwapConfsReq:     2,
+		CounterSwapConfs: -1,
+	}}
+	tests := []struct {
+		name        string
+		params      *RawParams
+		matches     []*core.MatchSettlement
+		matchesErr  error
+		wantErrCode int
+	}{{
+		name:        "ok",
+		params:      params,
+		matches:     matches,
+		wantErrCode: -1,
+	}, {
+		name:        "ok no matches",
+		params:      params,
+		matches:     []*core.MatchSettlement{},
+		wantErrCode: -1,
+	}, {
+		name:        "core.Matches error",
+		params:      params,
+		matchesErr:  errors.New("error"),
+		wantErrCode: msgjson.RPCMatchesError,
+	}, {
+		name:        "bad order ID",
+		params:      &RawParams{Args: []string{"fb94fe"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{matches: test.matches, matchesErr: test.matchesErr}
+		r := &RPCServer{core: tc}
+		payload := handleMatches(r, test.params)
+		var res []*core.MatchSettlement
+		if err := verifyResponse(payload, &res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		// Empty coin IDs do not survive the round trip as nil, so compare the
+		// encodings.
+		want, _ := json.Marshal(test.matches)
+		got, _ := json.Marshal(res)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: expected %s, got %s", test.name, want, got)
+		}
+	}
+}
+
+func TestHandleSwapCosts(t *testing.T) {
+	params := &RawParams{Args: []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"}}
+	report := &core.SwapCostReport{
+		OrderID: dex.Bytes{0x01},
+		FromID:  42,
+		ToID:    0,
+		Matches: []*core.MatchCost{{
+			MatchID:          dex.Bytes{0x02},
+			EstSwap:          4000,
+			ActualSwap:       4200,
+			SwapDiff:         200,
+			EstRedemption:    1000,
+			ActualRedemption: 900,
+			RedemptionDiff:   -100,
+		}},
+		Est:    &core.FeeBreakdown{Swap: 4000, Redemption: 1000},
+		Actual: &core.FeeBreakdown{Swap: 4200, Redemption: 900},
+	}
+	tests := []struct {
+		name         string
+		params       *RawParams
+		swapCostsErr error
+		wantErrCode  int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:         "core.SwapCosts error",
+		params:       params,
+		swapCostsErr: errors.New("error"),
+		wantErrCode:  msgjson.RPCSwapCostsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{swapCosts: report, swapCostsErr: test.swapCostsErr}
+		r := &RPCServer{core: tc}
+		payload := handleSwapCosts(r, test.params)
+		res := new(core.SwapCostReport)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatal(err)
+		}
+		if test.wantErrCode == -1 && !reflect.DeepEqual(res, report) {
+			t.Fatalf("%s: expected %v, got %v", test.name, spew.Sdump(report), spew.Sdump(res))
+		}
+	}
+}
+
+func TestHandleRetryPolicy(t *testing.T) {
+	tc := &TCore{retryPolicy: &core.RetryPolicy{
+		MaxRedeemAttempts: 1,
+		MaxRefundAttempts: 1,
+		Backoff:           time.Minute,
+		MaxBackoff:        time.Hour,
+	}}
+	r := &RPCServer{core: tc}
+
+	tests := []struct {
+		name              string
+		params            *RawParams
+		setRetryPolicyErr error
+		wantErrCode       int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"5", "3", "30", "600"}},
+		wantErrCode: -1,
+	}, {
+		name:              "core.SetRetryPolicy error",
+		params:            &RawParams{Args: []string{"0", "3", "30", "600"}},
+		setRetryPolicyErr: errors.New("error"),
+		wantErrCode:       msgjson.RPCRetryPolicyError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"5", "3", "30"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc.setRetryPolicyErr = test.setRetryPolicyErr
+		payload := handleSetRetryPolicy(r, test.params)
+		res := new(retryPolicyResponse)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+	}
+
+	// Read back the policy set by the ok test.
+	payload := handleGetRetryPolicy(r, nil)
+	res := new(retryPolicyResponse)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	want := &retryPolicyResponse{
+		MaxRedeemAttempts: 5,
+		MaxRefundAttempts: 3,
+		Backoff:           30,
+		MaxBackoff:        600,
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("wrong retry policy. wanted %+v, got %+v", want, res)
+	}
+}
+
+func TestHandleSettings(t *testing.T) {
+	tc := &TCore{settings: new(core.TradeSettings)}
+	r := &RPCServer{core: tc}
+
+	tests := []struct {
+		name           string
+		params         *RawParams
+		setSettingsErr error
+		wantErrCode    int
+	}{{
+		name:        "ok",
+		params:      &RawParams{Args: []string{"20", "50"}},
+		wantErrCode: -1,
+	}, {
+		name:           "core.SetSettings error",
+		params:         &RawParams{Args: []string{"1", "1"}},
+		setSettingsErr: errors.New("error"),
+		wantErrCode:    msgjson.RPCSettingsError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{Args: []string{"20"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}, {
+		name:        "negative limit",
+		params:      &RawParams{Args: []string{"20", "-1"}},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc.setSettingsErr = test.setSettingsErr
+		payload := handleSetSettings(r, test.params)
+		res := new(core.TradeSettings)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+	}
+
+	// Read back the settings set by the ok test.
+	payload := handleGetSettings(r, nil)
+	res := new(core.TradeSettings)
+	if err := verifyResponse(payload, res, -1); err != nil {
+		t.Fatal(err)
+	}
+	want := &core.TradeSettings{MaxFeeRate: 20, MaxOrderLots: 50}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("wrong settings. wanted %+v, got %+v", want, res)
+	}
+}
+
+func TestHandleTradeReport(t *testing.T) {
+	// Sign a stub report with a stub account key.
+	privKey, _ := secp256k1.GeneratePrivateKey()
+	report := []byte(`{"host":"somedex.tld","accountID":"00","order":null,"stamp":1}`)
+	hash := sha256.Sum256(report)
+	sig, _ := privKey.Sign(hash[:])
+	signed := &core.SignedReport{
+		Report: report,
+		PubKey: privKey.PubKey().SerializeCompressed(),
+		Sig:    sig.Serialize(),
+	}
+
+	params := &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("abc")},
+		Args:   []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+	}
+	tests := []struct {
+		name            string
+		params          *RawParams
+		signedReportErr error
+		wantErrCode     int
+	}{{
+		name:        "ok",
+		params:      params,
+		wantErrCode: -1,
+	}, {
+		name:            "core.SignedTradeReport error",
+		params:          params,
+		signedReportErr: errors.New("error"),
+		wantErrCode:     msgjson.RPCTradeReportError,
+	}, {
+		name:        "bad params",
+		params:      &RawParams{},
+		wantErrCode: msgjson.RPCArgumentsError,
+	}}
+	for _, test := range tests {
+		tc := &TCore{signedReport: signed, signedReportErr: test.signedReportErr}
+		r := &RPCServer{core: tc}
+		payload := handleTradeReport(r, test.params)
+		res := new(core.SignedReport)
+		if err := verifyResponse(payload, res, test.wantErrCode); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.wantErrCode != -1 {
+			continue
+		}
+		if err := res.Verify(); err != nil {
+			t.Fatalf("%s: report signature not verified: %v", test.name, err)
+		}
+		if !bytes.Equal(res.PubKey, signed.PubKey) {
+			t.Fatalf("%s: wrong public key", test.name)
+		}
+	}
+}
@@ -5,40 +5,127 @@
 package rpcserver
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/comms"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/client/websocket"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/msgjson"
 	"github.com/decred/dcrd/certgen"
+	"github.com/decred/slog"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 )
 
 const (
-	// rpcTimeoutSeconds is the number of seconds a connection to the
-	// RPC server is allowed to stay open without authenticating before it
-	// is closed.
+	// rpcTimeoutSeconds is the default number of seconds a connection to the
+	// RPC server is allowed to spend reading a request or writing a response.
+	// See Config.ReadTimeout and Config.WriteTimeout.
 	rpcTimeoutSeconds = 10
 
-	// RPC version
+	// defaultDrainTimeout is the default Config.DrainTimeout.
+	defaultDrainTimeout = 5 * time.Second
+
+	// defaultRequestLogLevel is the default Config.RequestLogLevel.
+	defaultRequestLogLevel = dex.LevelDebug
+
+	// certCheckInterval is how often the TLS key pair files are checked for
+	// changes.
+	certCheckInterval = time.Minute
+
+	// defaultCertOrg and defaultCertValidity are the organization and validity
+	// period of a generated TLS certificate if not set in the Config.
+	defaultCertOrg      = "dcrdex autogenerated cert"
+	defaultCertValidity = 10 * 365 * 24 * time.Hour
+
+	// gzipMinSize is the smallest JSON response body that is gzip compressed
+	// for clients that accept it. Smaller bodies are not worth the overhead.
+	gzipMinSize = 1024
+
+	// unixAddrPrefix is the Config.Addr prefix for a unix socket path.
+	unixAddrPrefix = "unix://"
+
+	// certFingerprintRate and certFingerprintBurst limit the requests per
+	// second from each IP address to the unauthenticated /certfingerprint
+	// endpoint, independent of Config.RateLimit.
+	certFingerprintRate  = 1
+	certFingerprintBurst = 5
+
+	// maxRateLimitBuckets is the most IP addresses a rateLimiter tracks at
+	// once. While it is reached, requests from addresses that are not tracked
+	// share a single bucket until idle buckets are pruned.
+	maxRateLimitBuckets = 10000
+
+	// rateLimitPruneInterval is how often a rateLimiter forgets the buckets
+	// that have been idle long enough to refill completely.
+	rateLimitPruneInterval = 30 * time.Second
+
+	// HMACTimestampHeader and HMACSignatureHeader are the request headers
+	// carrying the Unix time in seconds and the signature of a request signed
+	// with Config.HMACSecret. See SignRequest.
+	HMACTimestampHeader = "X-Timestamp"
+	HMACSignatureHeader = "X-Signature"
+
+	// hmacMaxSkew is how far a signed request's timestamp may be from the
+	// server's clock. Older requests are rejected, and each signature is
+	// remembered for twice this long so that a request cannot be replayed.
+	hmacMaxSkew = 30 * time.Second
+
+	// hmacMaxBodySize is the largest request body that is read to verify a
+	// signature. Larger signed requests are rejected.
+	hmacMaxBodySize = 1 << 20
+
+	// authRealm is the realm of the WWW-Authenticate challenge.
+	authRealm = "dex RPC"
+
+	// Config.AuthChallenge values.
+	authChallengeBasic  = "basic"
+	authChallengeDigest = "digest"
+	authChallengeNone   = "none"
+
+	// digestNonceLifetime is how long a digest auth nonce is accepted. A
+	// request with an expired nonce is challenged again with stale=true so
+	// that the client can retry with a new nonce without prompting the user.
+	digestNonceLifetime = 5 * time.Minute
+
+	// RPC version. This is the version of the RPC protocol, i.e. the set of
+	// routes and their arguments and results, not of the client application.
+	// The minor version is bumped when routes are added and the major version
+	// when existing routes change incompatibly.
 	rpcSemverMajor = 0
-	rpcSemverMinor = 0
+	rpcSemverMinor = 2
 	rpcSemverPatch = 0
 )
 
@@ -56,19 +143,46 @@ type clientCore interface {
 	AssetBalance(assetID uint32) (*core.WalletBalance, error)
 	Book(host string, base, quote uint32) (orderBook *core.OrderBook, err error)
 	Cancel(appPass []byte, orderID dex.Bytes) error
+	ChangeAppPass(appPass, newAppPass []byte) error
+	Backup(appPass []byte, path string, overwrite bool) (string, []byte, error)
+	Candles(host string, base, quote uint32, binSize string, count int) ([]*core.Candle, error)
 	CloseWallet(assetID uint32) error
+	ConnStats() map[string]comms.ConnStats
+	ConnStatus() map[string]comms.ConnStatus
 	CreateWallet(appPass, walletPass []byte, form *core.WalletForm) error
+	DEXConfig(addr, cert string) (*core.Exchange, error)
+	DisconnectDEX(appPass []byte, addr string, forget, force bool) error
 	Exchanges() (exchanges map[string]*core.Exchange)
+	FeeRate(assetID uint32) (uint64, error)
+	NewDepositAddress(assetID uint32) (string, error)
+	RescanWallet(assetID uint32) error
+	ValidateAddress(assetID uint32, address string) (bool, error)
 	InitializeClient(appPass []byte) error
 	Login(appPass []byte) (*core.LoginResult, error)
+	Notifications(n int) ([]*db.Notification, error)
+	LoggedIn() bool
 	Logout() error
 	OpenWallet(assetID uint32, appPass []byte) error
+	ReconfigureWallet(appPass []byte, assetID uint32, settings map[string]string) error
 	GetFee(addr, cert string) (fee uint64, err error)
 	Register(form *core.RegisterForm) (*core.RegisterResult, error)
+	RetryPolicy() *core.RetryPolicy
+	SetRetryPolicy(policy *core.RetryPolicy) error
+	Settings() *core.TradeSettings
+	SetSettings(settings *core.TradeSettings) error
+	SignedTradeReport(appPass []byte, orderID dex.Bytes) (*core.SignedReport, error)
+	SwapCosts(orderID dex.Bytes) (*core.SwapCostReport, error)
+	Matches(orderID dex.Bytes) ([]*core.MatchSettlement, error)
+	SupportedAssets() map[uint32]*core.SupportedAsset
+	OrdersPage(filter *core.OrderFilter, offset, limit int) (*core.OrdersPage, error)
 	Trade(appPass []byte, form *core.TradeForm) (order *core.Order, err error)
+	PreOrder(form *core.TradeForm) (*core.OrderEstimate, error)
+	MarketInfo(dex string, base, quote uint32) (*core.MarketInfo, error)
+	FeesPaid(dex string, sinceUnix int64) (*core.FeesPaid, error)
 	Wallets() (walletsStates []*core.WalletState)
 	WalletState(assetID uint32) *core.WalletState
 	Withdraw(appPass []byte, assetID uint32, value uint64, addr string) (asset.Coin, error)
+	SendRaw(dex, route string, payload json.RawMessage) (*msgjson.ResponsePayload, error)
 }
 
 // RPCServer is a single-client http and websocket server enabling a JSON
@@ -80,18 +194,71 @@ type RPCServer struct {
 	addr      string
 	tlsConfig *tls.Config
 	srv       *http.Server
-	authSHA   [32]byte
 	wg        sync.WaitGroup
+	// authSHA and tokenSHA are the SHA-256 hashes of the accepted basic auth
+	// and bearer token Authorization headers. Each is only checked if its
+	// credential was configured.
+	authSHA  [32]byte
+	tokenSHA [32]byte
+	hasBasic bool
+	hasToken bool
+	// adminSHA is the SHA-256 hash of the bearer token Authorization header
+	// that grants the admin scope. It is only checked if hasAdmin is set.
+	adminSHA [32]byte
+	hasAdmin bool
+	// hmacSecret is the key for verifying signed requests. Signatures are not
+	// checked if it is empty. sigMtx guards seenSigs, the expiration of each
+	// signature accepted within the skew window, which is used to reject a
+	// replayed request.
+	hmacSecret []byte
+	sigMtx     sync.Mutex
+	seenSigs   map[string]time.Time
+	// authChallenge is the Config.AuthChallenge, and digest verifies digest
+	// auth if it is authChallengeDigest.
+	authChallenge string
+	digest        *digestAuth
+	// unsafeRaw enables the raw route.
+	unsafeRaw bool
+	// envPassPrefix is the Config.EnvPassPrefix.
+	envPassPrefix string
+	// setLogLevel is the Config.SetLogLevel.
+	setLogLevel func(slog.Level) slog.Level
+	// clientCAFingerprints are the fingerprints of the certificates in the
+	// Config.ClientCAs file.
+	clientCAFingerprints []string
+	// appVersion is the version of the application serving RPC requests, as
+	// provided by the main binary.
+	appVersion string
+	// drainTimeout is how long in-flight requests have to finish on shutdown.
+	drainTimeout time.Duration
+	// metrics counts requests for the /metrics endpoint. It is nil if metrics
+	// are disabled.
+	metrics *rpcMetrics
+	// reqLogLevel is the level at which handled requests are logged.
+	reqLogLevel slog.Level
+	// certs is the TLS key pair, which is reloaded when its files change. It
+	// is nil if TLS is disabled.
+	certs *certHolder
+	// shutdown stops the application for the shutdown route. shuttingDown is
+	// set atomically when it is called so that it is only called once.
+	shutdown     func()
+	shuttingDown uint32
+	// ready is called with the listening address when Connect has started
+	// the server.
+	ready func(addr string)
+	// rateLimiters are pruned of idle buckets while the server is running.
+	rateLimiters []*rateLimiter
 }
 
-// genCertPair generates a key/cert pair to the paths provided.
-func genCertPair(certFile, keyFile string) error {
+// genCertPair generates a key/cert pair to the paths provided. The certificate
+// is valid for localhost, the host name, and the host's interface addresses,
+// plus any altNames, which may be host names or IP addresses.
+func genCertPair(certFile, keyFile, org string, validity time.Duration, altNames []string) error {
 	log.Infof("Generating TLS certificates...")
 
-	org := "dcrdex autogenerated cert"
-	validUntil := time.Now().Add(10 * 365 * 24 * time.Hour)
+	validUntil := time.Now().Add(validity)
 	cert, key, err := certgen.NewTLSCertPair(elliptic.P521(), org,
-		validUntil, nil)
+		validUntil, altNames)
 	if err != nil {
 		return err
 	}
@@ -109,21 +276,328 @@ func genCertPair(certFile, keyFile string) error {
 	return nil
 }
 
+// loadTLSConfig creates the server's TLS configuration, generating the key pair
+// if necessary. The key pair is served from the returned certHolder so that it
+// can be replaced without restarting the server. minVersion is the minimum TLS
+// version accepted from clients.
+func loadTLSConfig(cfg *Config, minVersion uint16) (*tls.Config, *certHolder, error) {
+	// Find or create the key pair.
+	keyExists := fileExists(cfg.Key)
+	certExists := fileExists(cfg.Cert)
+	if certExists == !keyExists {
+		return nil, nil, fmt.Errorf("missing cert pair file")
+	}
+	if !keyExists && !certExists {
+		if cfg.DisableAutoCert {
+			return nil, nil, fmt.Errorf("cert file %s and key file %s do not exist "+
+				"and automatic generation is disabled", cfg.Cert, cfg.Key)
+		}
+		org, validity := cfg.CertOrg, cfg.CertValidity
+		if org == "" {
+			org = defaultCertOrg
+		}
+		if validity == 0 {
+			validity = defaultCertValidity
+		}
+		err := genCertPair(cfg.Cert, cfg.Key, org, validity, certAltNames(cfg))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	certs := &certHolder{certFile: cfg.Cert, keyFile: cfg.Key}
+	if err := certs.reload(); err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.getCertificate,
+		MinVersion:     minVersion,
+	}
+	if cfg.ClientCAs != "" {
+		pool, err := loadCertPool(cfg.ClientCAs)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, certs, nil
+}
+
+// certAltNames returns the host names and IP addresses for a generated
+// certificate in addition to those added by certgen. The Addr host is included
+// unless it is a unix socket or an unspecified address such as 0.0.0.0.
+func certAltNames(cfg *Config) []string {
+	altNames := append([]string(nil), cfg.AltNames...)
+	if _, isUnix := unixSocketPath(cfg.Addr); isUnix {
+		return altNames
+	}
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil || host == "" {
+		return altNames
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return altNames
+	}
+	return append(altNames, host)
+}
+
+// certHolder holds the server's TLS key pair, which can be swapped while the
+// server is running, e.g. when the certificate is renewed by an external tool.
+type certHolder struct {
+	certFile, keyFile string
+	cert              atomic.Value // *tls.Certificate
+
+	mtx sync.Mutex
+	// certMod and keyMod are the modification times of the files when they
+	// were last loaded, whether or not the pair was valid.
+	certMod, keyMod time.Time
+}
+
+// getCertificate returns the current key pair. It is used as the
+// tls.Config.GetCertificate function.
+func (h *certHolder) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return h.cert.Load().(*tls.Certificate), nil
+}
+
+// fingerprint is the certFingerprint of the current certificate.
+func (h *certHolder) fingerprint() string {
+	return certFingerprint(h.cert.Load().(*tls.Certificate).Certificate[0])
+}
+
+// certFingerprint is the hex-encoded SHA-256 hash of a DER-encoded
+// certificate, which is what a client pins.
+func certFingerprint(der []byte) string {
+	h := sha256.Sum256(der)
+	return hex.EncodeToString(h[:])
+}
+
+// pemFingerprints returns the certFingerprint of each certificate in a PEM
+// file.
+func pemFingerprints(path string) ([]string, error) {
+	pemCerts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fps []string
+	for {
+		var block *pem.Block
+		block, pemCerts = pem.Decode(pemCerts)
+		if block == nil {
+			return fps, nil
+		}
+		if block.Type == "CERTIFICATE" {
+			fps = append(fps, certFingerprint(block.Bytes))
+		}
+	}
+}
+
+// modTimes returns the modification times of the cert and key files.
+func (h *certHolder) modTimes() (certMod, keyMod time.Time, err error) {
+	fi, err := os.Stat(h.certFile)
+	if err != nil {
+		return
+	}
+	certMod = fi.ModTime()
+	fi, err = os.Stat(h.keyFile)
+	if err != nil {
+		return
+	}
+	return certMod, fi.ModTime(), nil
+}
+
+// reload loads and validates the key pair from file. The current pair is only
+// replaced if the new pair is valid.
+func (h *certHolder) reload() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.load()
+}
+
+// load loads the key pair. The mtx must be locked.
+func (h *certHolder) load() error {
+	certMod, keyMod, err := h.modTimes()
+	if err != nil {
+		return err
+	}
+	h.certMod, h.keyMod = certMod, keyMod
+	// LoadX509KeyPair checks that the private key matches the certificate.
+	keypair, err := tls.LoadX509KeyPair(h.certFile, h.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(keypair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("error parsing certificate: %v", err)
+	}
+	if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate is not valid until %v or has expired at %v",
+			leaf.NotBefore, leaf.NotAfter)
+	}
+	keypair.Leaf = leaf
+	h.cert.Store(&keypair)
+	return nil
+}
+
+// reloadIfChanged reloads the key pair if either file was modified since it
+// was last loaded. A pair that fails to load is not retried until one of the
+// files changes again, so a certificate written before its key is picked up
+// once the key is written.
+func (h *certHolder) reloadIfChanged() (bool, error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	certMod, keyMod, err := h.modTimes()
+	if err != nil {
+		return false, err
+	}
+	if certMod.Equal(h.certMod) && keyMod.Equal(h.keyMod) {
+		return false, nil
+	}
+	return true, h.load()
+}
+
+// watchCert reloads the TLS key pair when its files change until ctx is
+// canceled.
+func (s *RPCServer) watchCert(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := s.certs.reloadIfChanged()
+			if err != nil {
+				log.Errorf("Error reloading TLS certificate: %v", err)
+			} else if changed {
+				log.Infof("Reloaded TLS certificate %s with SHA-256 fingerprint %s",
+					s.certs.certFile, s.certs.fingerprint())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ReloadCert reloads the TLS key pair from the configured files. The pair
+// is validated first, and the server continues to use the current pair if
+// the new one is invalid. The files are also checked for changes
+// periodically, so ReloadCert is only needed to apply a change immediately.
+func (s *RPCServer) ReloadCert() error {
+	if s.certs == nil {
+		return fmt.Errorf("TLS is disabled")
+	}
+	return s.certs.reload()
+}
+
+// unixSocketPath returns the socket path if addr has the unixAddrPrefix.
+func unixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, unixAddrPrefix), true
+}
+
+// listen creates the server's listener. A unix socket is only accessible by
+// the current user, and a stale socket file left by an unclean shutdown is
+// replaced. The socket file is removed when the listener is closed.
+func (s *RPCServer) listen() (net.Listener, error) {
+	path, isUnix := unixSocketPath(s.addr)
+	if !isUnix {
+		listener, err := tls.Listen("tcp", s.addr, s.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		// Update the listening address in case a :0 was provided.
+		s.addr = listener.Addr().String()
+		return listener, nil
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("unable to remove stale socket file: %v", err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("unable to set socket file permissions: %v", err)
+	}
+	if s.tlsConfig == nil {
+		return listener, nil
+	}
+	return tls.NewListener(listener, s.tlsConfig), nil
+}
+
+// loadCertPool reads the PEM encoded certificates in the named file into a new
+// x509.CertPool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pemCerts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CAs file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, fmt.Errorf("no valid certificates found in %s", path)
+	}
+	return pool, nil
+}
+
 // writeJSON marshals the provided interface and writes the bytes to the
 // ResponseWriter. The response code is assumed to be StatusOK.
-func writeJSON(w http.ResponseWriter, thing interface{}) {
-	writeJSONWithStatus(w, thing, http.StatusOK)
+func writeJSON(w http.ResponseWriter, r *http.Request, thing interface{}) {
+	writeJSONWithStatus(w, r, thing, http.StatusOK)
 }
 
 // writeJSONWithStatus marshals the provided interface and writes the bytes to the
-// ResponseWriter with the specified response code.
-func writeJSONWithStatus(w http.ResponseWriter, thing interface{}, code int) {
+// ResponseWriter with the specified response code. The body is gzip compressed
+// if the request accepts it and the body is at least gzipMinSize bytes.
+func writeJSONWithStatus(w http.ResponseWriter, r *http.Request, thing interface{}, code int) {
+	b, err := json.Marshal(thing)
+	if err != nil {
+		log.Errorf("JSON encode error: %v", err)
+		http.Error(w, "JSON encode error", http.StatusInternalServerError)
+		return
+	}
+	b = append(b, '\n')
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(b) < gzipMinSize || !acceptsGzip(r) {
+		w.WriteHeader(code)
+		if _, err := w.Write(b); err != nil {
+			log.Errorf("Error writing JSON response: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
 	w.WriteHeader(code)
-	encoder := json.NewEncoder(w)
-	if err := encoder.Encode(thing); err != nil {
-		log.Errorf("JSON encode error: %v", err)
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b); err != nil {
+		log.Errorf("Error writing gzipped JSON response: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Errorf("Error closing gzipped JSON response: %v", err)
+	}
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding header includes
+// gzip without a zero quality value.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(enc, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "gzip") {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
 	}
+	return false
 }
 
 // handleJSON handles all https json requests.
@@ -135,12 +609,26 @@ func (s *RPCServer) handleJSON(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	r.Close = true
 
+	// Requests without a Content-Type are accepted for backward compatibility.
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "application/json" {
+			writeRequestError(w, r, msgjson.NewError(msgjson.RPCParseError,
+				fmt.Sprintf("unsupported Content-Type %q, expected application/json", ct)))
+			return
+		}
+	}
+
 	body, err := ioutil.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
 		http.Error(w, "error reading request body", http.StatusBadRequest)
 		return
 	}
+	// A JSON array is a batch of requests.
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		s.handleBatch(w, r, trimmed)
+		return
+	}
 	req := new(msgjson.Message)
 	err = json.Unmarshal(body, req)
 	if err != nil {
@@ -151,13 +639,152 @@ func (s *RPCServer) handleJSON(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Responses not accepted", http.StatusMethodNotAllowed)
 		return
 	}
-	s.parseHTTPRequest(w, req)
+	// The response echoes the ID, so a request without one is not handled.
+	if req.ID == 0 {
+		writeRequestError(w, r, msgjson.NewError(msgjson.RPCParseError, "request id cannot be zero"))
+		return
+	}
+	s.parseHTTPRequest(w, r, req)
+}
+
+// writeRequestError writes a response with the error and no ID for a request
+// that was rejected before it was handled, such as one that could not be
+// decoded or that has no ID, which msgjson.NewResponse requires.
+func writeRequestError(w http.ResponseWriter, r *http.Request, msgErr *msgjson.Error) {
+	encPayload, _ := json.Marshal(&msgjson.ResponsePayload{Error: msgErr})
+	resp := &msgjson.Message{Type: msgjson.Response, Payload: encPayload}
+	writeJSONWithStatus(w, r, resp, http.StatusBadRequest)
 }
 
 // Config holds variables neede to create a new RPC Server.
 type Config struct {
 	Core                        clientCore
 	Addr, User, Pass, Cert, Key string
+	// Token, if set, is accepted as a bearer token in the Authorization
+	// header. Basic auth with User and Pass is still accepted if Pass is set.
+	Token string
+	// HMACSecret, if set, is the key for requests signed with an HMAC of their
+	// method, path, body, and a timestamp in the X-Signature and X-Timestamp
+	// headers, which avoids sending a reusable credential with every request.
+	// Requests with a stale timestamp or a signature that was already used
+	// are rejected. A request is accepted if it passes any
+	// of the configured authentication methods. See SignRequest.
+	HMACSecret string
+	// AdminToken, if set, is a bearer token that grants the admin scope, which
+	// is required for admin routes such as wsclients and wsdisconnect. It is
+	// also accepted for every other route. Admin routes are unavailable if
+	// AdminToken is empty.
+	AdminToken string
+	// AuthChallenge is the WWW-Authenticate challenge sent with a 401
+	// Unauthorized response, "basic", "digest", or "none". Defaults to "basic"
+	// if empty. "none" omits the header so that browsers do not prompt for
+	// credentials, which programmatic clients do not need. "digest" challenges
+	// for HTTP Digest auth (RFC 7616) with SHA-256 and a server nonce, which
+	// proves knowledge of User and Pass without sending the password and
+	// limits the replay of a captured request. It requires Pass. Basic auth
+	// is still accepted with "digest".
+	AuthChallenge string
+	// UnsafeRaw enables the raw route, which sends an arbitrary request to a
+	// DEX server, bypassing Core's checks. The route also requires the admin
+	// scope, so AdminToken must be set to use it. Every use is logged.
+	UnsafeRaw bool
+	// EnvPassPrefix, if set, allows the password arguments of the init,
+	// login, newwallet, openwallet, and register routes to be given as
+	// env:VARNAME, which is replaced with the value of the environment
+	// variable VARNAME of the server's process. Only variables whose names
+	// begin with EnvPassPrefix, e.g. DEXC_, may be used, so that clients
+	// cannot read or probe the rest of the environment. If empty, such
+	// arguments are literal passwords.
+	EnvPassPrefix string
+	// SetLogLevel, if set, is used by the loglevel route to set the level of
+	// every logger in the application. It returns the previous application
+	// level. If nil, the loglevel route only sets the RPC server's logger.
+	SetLogLevel func(slog.Level) (prev slog.Level)
+	// AppVersion is the version of the client application, which is reported
+	// alongside the RPC version by the version route. Optional.
+	AppVersion string
+	// ClientCAs is the path to a PEM file of CA certificates. If set, clients
+	// must present a certificate signed by one of these CAs. If neither Pass
+	// nor Token is set, a verified client certificate alone is sufficient.
+	ClientCAs string
+	// ReadTimeout and WriteTimeout are the maximum durations for reading an
+	// entire HTTP request and writing its response. Each defaults to 10
+	// seconds if zero. They do not apply to websocket connections, which
+	// clear the deadlines when upgraded.
+	ReadTimeout, WriteTimeout time.Duration
+	// UnixNoTLS disables TLS when Addr is a unix socket, specified as
+	// unix:///path/to/socket. Access to the socket is restricted to the
+	// current user. Authentication with Pass or Token is still required.
+	UnixNoTLS bool
+	// WSAuthTimeout is how long a websocket client has after the connection
+	// is upgraded to send a request that is handled successfully, e.g. ping,
+	// before it is disconnected, so that idle sockets do not linger. It is
+	// disabled if zero.
+	WSAuthTimeout time.Duration
+	// DrainTimeout is how long in-flight requests are given to finish on
+	// shutdown before websocket clients are disconnected. Defaults to 5
+	// seconds if zero.
+	DrainTimeout time.Duration
+	// RateLimit is the sustained number of HTTP requests per second allowed
+	// from each remote IP address, or IPv6 /64 network. A websocket connection
+	// counts as a single request. Rate limiting is disabled if zero.
+	RateLimit float64
+	// RateBurst is the number of requests that may be made at once before
+	// RateLimit applies. Defaults to RateLimit, rounded up, if zero.
+	RateBurst int
+	// Metrics enables the /metrics endpoint, which reports request counts by
+	// route and error code, and the number of websocket clients and market
+	// syncers in the Prometheus text format.
+	Metrics bool
+	// MetricsToken, if set, is required as a bearer token to scrape /metrics.
+	// Otherwise, /metrics does not require authentication.
+	MetricsToken string
+	// AllowedOrigins are the origins, e.g. https://example.com:8080, of the
+	// browser pages that may open a websocket connection. Same-origin requests
+	// and requests without an Origin header, such as from non-browser clients,
+	// are always allowed. If empty, pages served from localhost or a loopback
+	// IP address are also allowed. "*" allows any origin.
+	AllowedOrigins []string
+	// CertOrg, CertValidity, and AltNames are used to generate the TLS key
+	// pair if the Cert and Key files do not exist. They do not affect an
+	// existing pair. CertOrg is the certificate's organization, and defaults
+	// to "dcrdex autogenerated cert". CertValidity is how long the certificate
+	// is valid, and defaults to 10 years. AltNames are host names and IP
+	// addresses the certificate is valid for in addition to localhost, the
+	// host name, the host's interface addresses, and the Addr host, e.g. the
+	// names clients use to reach the server through a proxy or DNS.
+	CertOrg      string
+	CertValidity time.Duration
+	AltNames     []string
+	// DisableAutoCert requires the Cert and Key files to exist. New returns
+	// an error if they do not, rather than generating a new pair, so that a
+	// missing key pair is not silently replaced by a throwaway one.
+	DisableAutoCert bool
+	// AllowIPs are the IP addresses and CIDR ranges, e.g. 192.168.1.0/24,
+	// from which requests are accepted. Requests from any other address are
+	// rejected with 403 Forbidden before authentication. The address is the
+	// TCP peer's, so the X-Forwarded-For and X-Real-IP headers, which a client
+	// can set to anything, are not trusted. If empty, all addresses are
+	// allowed. AllowIPs cannot be used with a unix socket.
+	AllowIPs []string
+	// RequestLogLevel is the level, e.g. debug, at which every handled request
+	// is logged with its route, remote IP address, duration, and error code.
+	// Requests that fail are logged at warn, or at RequestLogLevel if higher.
+	// "off" disables request logging. Defaults to debug if empty.
+	RequestLogLevel string
+	// MinTLSVersion is the minimum TLS version, "1.2" or "1.3", accepted from
+	// clients. Defaults to 1.2 if empty. It has no effect with UnixNoTLS.
+	MinTLSVersion string
+	// Shutdown is called by the shutdown route to stop the application, e.g.
+	// by canceling the contexts passed to Connect and core.Run. If nil, the
+	// shutdown route returns an error.
+	Shutdown func()
+	// Ready, if set, is called by Connect with the listening address once the
+	// listener is bound and the server is about to serve requests, e.g. so
+	// that a caller starting the server in a goroutine can wait for it rather
+	// than sleeping. The address is the one bound, which differs from Addr if
+	// Addr has a :0 port.
+	Ready func(addr string)
 }
 
 // SetLogger sets the logger for the RPCServer package.
@@ -168,66 +795,192 @@ func SetLogger(logger dex.Logger) {
 // New is the constructor for an RPCServer.
 func New(cfg *Config) (*RPCServer, error) {
 
-	if cfg.Pass == "" {
-		return nil, fmt.Errorf("missing RPC password")
+	if cfg.Pass == "" && cfg.Token == "" && cfg.HMACSecret == "" && cfg.ClientCAs == "" {
+		return nil, fmt.Errorf("missing RPC password, token, HMAC secret, or client CAs")
 	}
 
-	// Find or create the key pair.
-	keyExists := fileExists(cfg.Key)
-	certExists := fileExists(cfg.Cert)
-	if certExists == !keyExists {
-		return nil, fmt.Errorf("missing cert pair file")
+	if cfg.CertValidity < 0 {
+		return nil, fmt.Errorf("negative certificate validity %v", cfg.CertValidity)
 	}
-	if !keyExists && !certExists {
-		err := genCertPair(cfg.Cert, cfg.Key)
-		if err != nil {
-			return nil, err
+
+	if cfg.WSAuthTimeout < 0 {
+		return nil, fmt.Errorf("negative websocket auth timeout %v", cfg.WSAuthTimeout)
+	}
+
+	authChallenge := strings.ToLower(cfg.AuthChallenge)
+	switch authChallenge {
+	case "":
+		authChallenge = authChallengeBasic
+	case authChallengeBasic, authChallengeNone:
+	case authChallengeDigest:
+		if cfg.Pass == "" {
+			return nil, fmt.Errorf("digest auth requires an RPC password")
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth challenge %q", cfg.AuthChallenge)
+	}
+
+	reqLogLevel := defaultRequestLogLevel
+	if cfg.RequestLogLevel != "" {
+		var ok bool
+		reqLogLevel, ok = slog.LevelFromString(cfg.RequestLogLevel)
+		if !ok {
+			return nil, fmt.Errorf("unknown request log level %q", cfg.RequestLogLevel)
 		}
 	}
-	keypair, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+
+	minTLSVersion, err := comms.ParseTLSVersion(cfg.MinTLSVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	// Prepare the TLS configuration.
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{keypair},
-		MinVersion:   tls.VersionTLS12,
+	checkOrigin, err := newOriginCheck(cfg.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+
+	allowIPs, err := newIPAllowList(cfg.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	if _, isUnix := unixSocketPath(cfg.Addr); isUnix && len(allowIPs) > 0 {
+		return nil, fmt.Errorf("an IP allowlist cannot be used with a unix socket address")
+	}
+
+	var tlsConfig *tls.Config
+	var certs *certHolder
+	if cfg.UnixNoTLS {
+		if _, isUnix := unixSocketPath(cfg.Addr); !isUnix {
+			return nil, fmt.Errorf("TLS can only be disabled for a unix socket address")
+		}
+		if cfg.ClientCAs != "" {
+			return nil, fmt.Errorf("client CAs cannot be used without TLS")
+		}
+	} else {
+		tlsConfig, certs, err = loadTLSConfig(cfg, minTLSVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	readTimeout, writeTimeout := cfg.ReadTimeout, cfg.WriteTimeout
+	if readTimeout == 0 {
+		readTimeout = rpcTimeoutSeconds * time.Second
+	}
+	if writeTimeout == 0 {
+		writeTimeout = rpcTimeoutSeconds * time.Second
 	}
 
 	// Create an HTTP router.
 	mux := chi.NewRouter()
 	httpServer := &http.Server{
 		Handler:      mux,
-		ReadTimeout:  rpcTimeoutSeconds * time.Second, // slow requests should not hold connections opened
-		WriteTimeout: rpcTimeoutSeconds * time.Second, // hung responses must die
+		ReadTimeout:  readTimeout,  // slow requests should not hold connections opened
+		WriteTimeout: writeTimeout, // hung responses must die
+	}
+
+	drainTimeout := cfg.DrainTimeout
+	if drainTimeout == 0 {
+		drainTimeout = defaultDrainTimeout
 	}
 
 	// Make the server.
 	s := &RPCServer{
-		core:      cfg.Core,
-		mux:       mux,
-		srv:       httpServer,
-		addr:      cfg.Addr,
-		tlsConfig: tlsConfig,
-		wsServer:  websocket.New(cfg.Core, log.SubLogger("WS")),
+		core:         cfg.Core,
+		mux:          mux,
+		srv:          httpServer,
+		addr:         cfg.Addr,
+		tlsConfig:    tlsConfig,
+		certs:        certs,
+		wsServer:     websocket.New(cfg.Core, log.SubLogger("WS")),
+		appVersion:   cfg.AppVersion,
+		drainTimeout: drainTimeout,
+		reqLogLevel:  reqLogLevel,
+		shutdown:     cfg.Shutdown,
+		ready:        cfg.Ready,
+
+		authChallenge: authChallenge,
+		unsafeRaw:     cfg.UnsafeRaw,
+		envPassPrefix: cfg.EnvPassPrefix,
+		setLogLevel:   cfg.SetLogLevel,
 	}
 
-	// Create authSHA to verify requests against.
-	login := cfg.User + ":" + cfg.Pass
-	auth := "Basic " +
-		base64.StdEncoding.EncodeToString([]byte(login))
-	s.authSHA = sha256.Sum256([]byte(auth))
+	s.wsServer.SetOriginCheck(checkOrigin)
+	s.wsServer.SetAuthTimeout(cfg.WSAuthTimeout)
+
+	// Create authSHA and tokenSHA to verify requests against.
+	if cfg.Pass != "" {
+		login := cfg.User + ":" + cfg.Pass
+		auth := "Basic " +
+			base64.StdEncoding.EncodeToString([]byte(login))
+		s.authSHA = sha256.Sum256([]byte(auth))
+		s.hasBasic = true
+		if authChallenge == authChallengeDigest {
+			s.digest, err = newDigestAuth(cfg.User, cfg.Pass)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cfg.Token != "" {
+		s.tokenSHA = sha256.Sum256([]byte("Bearer " + cfg.Token))
+		s.hasToken = true
+	}
+	if cfg.HMACSecret != "" {
+		s.hmacSecret = []byte(cfg.HMACSecret)
+		s.seenSigs = make(map[string]time.Time)
+	}
+	if cfg.AdminToken != "" {
+		if cfg.AdminToken == cfg.Token {
+			return nil, fmt.Errorf("the admin token must differ from the token")
+		}
+		s.adminSHA = sha256.Sum256([]byte("Bearer " + cfg.AdminToken))
+		s.hasAdmin = true
+	}
 
 	// Middleware
 	mux.Use(middleware.Recoverer)
+	mux.Use(recordPeerIP)
 	mux.Use(middleware.RealIP)
-	mux.Use(s.authMiddleware)
+	if len(allowIPs) > 0 {
+		mux.Use(allowIPs.middleware)
+	}
+	if cfg.RateLimit > 0 {
+		burst := cfg.RateBurst
+		if burst <= 0 {
+			burst = int(math.Ceil(cfg.RateLimit))
+		}
+		rl := newRateLimiter(cfg.RateLimit, burst)
+		s.rateLimiters = append(s.rateLimiters, rl)
+		mux.Use(rl.middleware)
+	}
+
+	// The health check does not require authentication.
+	mux.Get("/health", s.handleHealth)
+
+	// Neither does the certificate fingerprint, which clients need before they
+	// can pin the certificate, but it is rate limited.
+	if certs != nil {
+		if cfg.ClientCAs != "" {
+			s.clientCAFingerprints, err = pemFingerprints(cfg.ClientCAs)
+			if err != nil {
+				return nil, fmt.Errorf("error reading client CAs file: %v", err)
+			}
+		}
+		rl := newRateLimiter(certFingerprintRate, certFingerprintBurst)
+		s.rateLimiters = append(s.rateLimiters, rl)
+		mux.With(rl.middleware).Get("/certfingerprint", s.handleCertFingerprint)
+	}
+
+	if cfg.Metrics {
+		s.metrics = newRPCMetrics(cfg.MetricsToken)
+		mux.With(s.metrics.authMiddleware).Get("/metrics", s.handleMetrics)
+	}
 
 	// The WebSocket handler is mounted on /ws in Connect.
 
 	// HTTPS endpoint
-	mux.Post("/", s.handleJSON)
+	mux.With(s.authMiddleware).Post("/", s.handleJSON)
 
 	return s, nil
 }
@@ -235,47 +988,152 @@ func New(cfg *Config) (*RPCServer, error) {
 // Connect starts the RPC server. Satisfies the dex.Connector interface.
 func (s *RPCServer) Connect(ctx context.Context) (*sync.WaitGroup, error) {
 	// Create listener.
-	listener, err := tls.Listen("tcp", s.addr, s.tlsConfig)
+	listener, err := s.listen()
 	if err != nil {
 		return nil, fmt.Errorf("can't listen on %s. rpc server quitting: %v", s.addr, err)
 	}
-	// Update the listening address in case a :0 was provided.
-	s.addr = listener.Addr().String()
+	s.serve(ctx, listener)
+	log.Infof("RPC server listening on %s", s.addr)
+	if s.certs != nil {
+		log.Infof("RPC server TLS certificate SHA-256 fingerprint: %s", s.certs.fingerprint())
+	}
+	if s.ready != nil {
+		s.ready(s.addr)
+	}
+	return &s.wg, nil
+}
+
+// serve starts serving requests from listener in a goroutine tracked by s.wg,
+// and shuts down the server, including any websocket connections, when ctx is
+// canceled.
+func (s *RPCServer) serve(ctx context.Context, listener net.Listener) {
+	// The websocket clients' context outlives ctx so that they can be drained
+	// on shutdown.
+	wsCtx, wsCancel := context.WithCancel(context.Background())
 
-	// Close the listener on context cancellation.
+	// On context cancellation, stop accepting connections and allow in-flight
+	// requests up to drainTimeout to finish.
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer wsCancel()
 		<-ctx.Done()
 
-		if err := s.srv.Shutdown(context.Background()); err != nil {
-			// Error from closing listeners:
-			log.Errorf("HTTP server Shutdown: %v", err)
+		drainCtx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+		defer cancel()
+		if err := s.srv.Shutdown(drainCtx); err != nil {
+			log.Warnf("HTTP server Shutdown: %v", err)
+			s.srv.Close()
 		}
+		// Drain the websocket clients since http.(*Server).Shutdown does not
+		// deal with hijacked websocket connections.
+		s.wsServer.Drain(drainCtx, "RPC server shutting down")
+		log.Infof("RPC server off")
 	}()
 
 	// Configure the websocket handler before starting the server.
-	s.mux.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
-		s.wsServer.HandleConnect(ctx, w, r)
+	s.mux.With(s.authMiddleware).Get("/ws", func(w http.ResponseWriter, r *http.Request) {
+		s.wsServer.HandleConnect(wsCtx, w, r)
 	})
 
+	if s.certs != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.watchCert(ctx)
+		}()
+	}
+
+	for _, rl := range s.rateLimiters {
+		s.wg.Add(1)
+		go func(rl *rateLimiter) {
+			defer s.wg.Done()
+			rl.run(ctx)
+		}(rl)
+	}
+
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 		if err := s.srv.Serve(listener); err != http.ErrServerClosed {
 			log.Warnf("unexpected (http.Server).Serve error: %v", err)
 		}
-		// Disconnect the websocket clients since http.(*Server).Shutdown does
-		// not deal with hijacked websocket connections.
-		s.wsServer.Shutdown()
-		log.Infof("RPC server off")
 	}()
-	log.Infof("RPC server listening on %s", s.addr)
-	return &s.wg, nil
+}
+
+// handleHealth responds to liveness probes with the logged in state of the
+// client. No credentials are required, so nothing else is revealed.
+func (s *RPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, &healthResponse{
+		OK:       true,
+		LoggedIn: s.core.LoggedIn(),
+	})
+}
+
+// handleCertFingerprint responds with the fingerprint of the active TLS
+// certificate, and of the client CAs if client certificates are required. No
+// credentials are required.
+func (s *RPCServer) handleCertFingerprint(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, &certFingerprintResponse{
+		Cert:      s.certs.fingerprint(),
+		ClientCAs: s.clientCAFingerprints,
+	})
 }
 
 // handleRequest sends the request to the correct handler function if able.
-func (s *RPCServer) handleRequest(req *msgjson.Message) *msgjson.ResponsePayload {
+// The request from the remote IP address ip is logged once it is handled.
+func (s *RPCServer) handleRequest(req *msgjson.Message, ip string, admin bool) *msgjson.ResponsePayload {
+	start := time.Now()
+	payload := s.routeRequest(req, admin)
+	if s.metrics != nil {
+		s.metrics.observe(req.Route, payload)
+	}
+	s.logRequest(req, ip, time.Since(start), payload)
+	return payload
+}
+
+// logRequest logs a handled request on a single line. Requests that failed are
+// logged at warn, or at the request log level if higher. The argument values
+// of routes that take passwords are not logged, since they may include other
+// secrets, and password arguments are never logged.
+func (s *RPCServer) logRequest(req *msgjson.Message, ip string, d time.Duration, payload *msgjson.ResponsePayload) {
+	lvl := s.reqLogLevel
+	code := "ok"
+	if payload.Error != nil {
+		code = strconv.Itoa(payload.Error.Code)
+		if lvl < dex.LevelWarn {
+			lvl = dex.LevelWarn
+		}
+	}
+	if s.reqLogLevel == dex.LevelOff || log.Level() > lvl {
+		return
+	}
+	line := fmt.Sprintf("RPC request route=%q ip=%s duration=%v code=%s", req.Route, ip, d, code)
+	if help, found := helpMsgs[req.Route]; found && help.pwArgsShort == "" {
+		params := new(RawParams)
+		if err := req.Unmarshal(params); err == nil && len(params.Args) > 0 {
+			line += fmt.Sprintf(" args=%q", params.Args)
+		}
+	}
+	switch lvl {
+	case dex.LevelTrace:
+		log.Trace(line)
+	case dex.LevelDebug:
+		log.Debug(line)
+	case dex.LevelInfo:
+		log.Info(line)
+	case dex.LevelWarn:
+		log.Warn(line)
+	case dex.LevelError:
+		log.Error(line)
+	default:
+		log.Critical(line)
+	}
+}
+
+// routeRequest passes the request to the handler for its route. Admin routes
+// are only handled if the request has the admin scope.
+func (s *RPCServer) routeRequest(req *msgjson.Message, admin bool) *msgjson.ResponsePayload {
 	payload := new(msgjson.ResponsePayload)
 	if req.Route == "" {
 		log.Debugf("route not specified")
@@ -291,6 +1149,13 @@ func (s *RPCServer) handleRequest(req *msgjson.Message) *msgjson.ResponsePayload
 		return payload
 	}
 
+	if adminRoutes[req.Route] && !admin {
+		log.Debugf("%s route requested without the admin scope", req.Route)
+		payload.Error = msgjson.NewError(msgjson.RPCAdminRequiredError,
+			"the "+req.Route+" route requires the admin token")
+		return payload
+	}
+
 	params := new(RawParams)
 	err := req.Unmarshal(params)
 	if err != nil {
@@ -302,10 +1167,71 @@ func (s *RPCServer) handleRequest(req *msgjson.Message) *msgjson.ResponsePayload
 	return h(s, params)
 }
 
+// handleBatch handles a JSON array of requests, writing an array of responses
+// in the same order. An entry that cannot be decoded or is not a request gets
+// an error response, and does not affect the other entries.
+func (s *RPCServer) handleBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var rawReqs []json.RawMessage
+	if err := json.Unmarshal(body, &rawReqs); err != nil {
+		http.Error(w, "JSON decode error", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(rawReqs) == 0 {
+		http.Error(w, "empty batch", http.StatusUnprocessableEntity)
+		return
+	}
+	resps := make([]*msgjson.Message, 0, len(rawReqs))
+	// Responses are correlated with requests by ID, so an entry without an ID
+	// or with the ID of an earlier entry is not handled.
+	ids := make(map[uint64]bool, len(rawReqs))
+	for _, rawReq := range rawReqs {
+		var payload *msgjson.ResponsePayload
+		req := new(msgjson.Message)
+		if err := json.Unmarshal(rawReq, req); err != nil {
+			payload = &msgjson.ResponsePayload{
+				Error: msgjson.NewError(msgjson.RPCParseError, "JSON decode error"),
+			}
+		} else if req.Type != msgjson.Request {
+			payload = &msgjson.ResponsePayload{
+				Error: msgjson.NewError(msgjson.UnknownMessageType, "responses not accepted"),
+			}
+		} else if req.ID == 0 {
+			payload = &msgjson.ResponsePayload{
+				Error: msgjson.NewError(msgjson.RPCParseError, "request id cannot be zero"),
+			}
+		} else if ids[req.ID] {
+			log.Warnf("Ignoring %s request with duplicate ID %d in batch from %s",
+				req.Route, req.ID, remoteIP(r))
+			payload = &msgjson.ResponsePayload{
+				Error: msgjson.NewError(msgjson.InvalidRequestError,
+					fmt.Sprintf("duplicate request id %d", req.ID)),
+			}
+		} else {
+			ids[req.ID] = true
+			payload = s.handleRequest(req, remoteIP(r), hasAdminScope(r))
+		}
+		// msgjson.NewResponse is not used since an entry that could not be
+		// decoded has no ID.
+		encPayload, err := json.Marshal(payload)
+		if err != nil {
+			log.Errorf("handleBatch: error encoding response: %v", err)
+			encPayload, _ = json.Marshal(&msgjson.ResponsePayload{
+				Error: msgjson.NewError(msgjson.RPCInternal, "error encoding response"),
+			})
+		}
+		resps = append(resps, &msgjson.Message{
+			Type:    msgjson.Response,
+			ID:      req.ID,
+			Payload: encPayload,
+		})
+	}
+	writeJSON(w, r, resps)
+}
+
 // parseHTTPRequest parses the msgjson message in the request body, creates a
 // response message, and writes it to the http.ResponseWriter.
-func (s *RPCServer) parseHTTPRequest(w http.ResponseWriter, req *msgjson.Message) {
-	payload := s.handleRequest(req)
+func (s *RPCServer) parseHTTPRequest(w http.ResponseWriter, r *http.Request, req *msgjson.Message) {
+	payload := s.handleRequest(req, remoteIP(r), hasAdminScope(r))
 	resp, err := msgjson.NewResponse(req.ID, payload.Result, payload.Error)
 	if err != nil {
 		msg := fmt.Sprintf("error encoding response: %v", err)
@@ -313,25 +1239,64 @@ func (s *RPCServer) parseHTTPRequest(w http.ResponseWriter, req *msgjson.Message
 		log.Errorf("parseHTTPRequest: NewResponse failed: %s", msg)
 		return
 	}
-	writeJSON(w, resp)
+	writeJSON(w, r, resp)
 }
 
-// authMiddleware checks incoming requests for authentication.
+// adminScopeKey is the request context key that is set for requests
+// authenticated with the admin token.
+type adminScopeKey struct{}
+
+// hasAdminScope checks if the request was authenticated with the admin token.
+func hasAdminScope(r *http.Request) bool {
+	admin, _ := r.Context().Value(adminScopeKey{}).(bool)
+	return admin
+}
+
+// authMiddleware checks incoming requests for authentication. Requests
+// authenticated with the admin token are given the admin scope.
 func (s *RPCServer) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fail := func() {
-			log.Warnf("authentication failure from ip: %s", r.RemoteAddr)
-			w.Header().Add("WWW-Authenticate", `Basic realm="dex RPC"`)
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		if s.hasAdmin {
+			if auth := r.Header["Authorization"]; len(auth) > 0 {
+				authSHA := sha256.Sum256([]byte(auth[0]))
+				if subtle.ConstantTimeCompare(s.adminSHA[:], authSHA[:]) == 1 {
+					log.Debugf("authenticated admin with ip: %s", r.RemoteAddr)
+					ctx := context.WithValue(r.Context(), adminScopeKey{}, true)
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
 		}
-		auth := r.Header["Authorization"]
-		if len(auth) == 0 {
-			fail()
+		// With no password, token, or HMAC secret configured, New has required
+		// client certificates, which were already verified in the TLS
+		// handshake.
+		if !s.hasBasic && !s.hasToken && len(s.hmacSecret) == 0 {
+			next.ServeHTTP(w, r)
 			return
 		}
-		authSHA := sha256.Sum256([]byte(auth[0]))
-		if subtle.ConstantTimeCompare(s.authSHA[:], authSHA[:]) != 1 {
-			fail()
+		var basicOK, tokenOK, digestOK, stale bool
+		if auth := r.Header["Authorization"]; len(auth) > 0 {
+			authSHA := sha256.Sum256([]byte(auth[0]))
+			// Check both credentials regardless of which is supplied so that
+			// the time taken does not reveal which are configured.
+			basicOK = subtle.ConstantTimeCompare(s.authSHA[:], authSHA[:]) == 1 && s.hasBasic
+			tokenOK = subtle.ConstantTimeCompare(s.tokenSHA[:], authSHA[:]) == 1 && s.hasToken
+			if s.digest != nil && !basicOK && !tokenOK {
+				digestOK, stale = s.digest.verify(r.Method, r.URL.RequestURI(), auth[0])
+			}
+		}
+		if !basicOK && !tokenOK && !digestOK && !s.verifySignature(r) {
+			// An expired digest nonce is expected, so only log other failures.
+			if !stale {
+				log.Warnf("authentication failure from ip: %s", r.RemoteAddr)
+			}
+			switch s.authChallenge {
+			case authChallengeBasic:
+				w.Header().Add("WWW-Authenticate", `Basic realm="`+authRealm+`"`)
+			case authChallengeDigest:
+				w.Header().Add("WWW-Authenticate", s.digest.challenge(stale))
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
 		log.Debugf("authenticated user with ip: %s", r.RemoteAddr)
@@ -339,8 +1304,606 @@ func (s *RPCServer) authMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// hmacSum is the HMAC-SHA256, keyed with the secret, of the request method,
+// path, timestamp in Unix seconds, and body, separated by newlines. An empty
+// path is signed as "/".
+func hmacSum(secret []byte, method, path string, timestamp int64, body []byte) []byte {
+	if path == "" {
+		path = "/"
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + path + "\n" + strconv.FormatInt(timestamp, 10) + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// HMACSignature is the hex-encoded signature of a request with the method, URL
+// path, and body, made at the timestamp in Unix seconds, for an RPC server
+// configured with the HMACSecret.
+func HMACSignature(secret, method, path string, timestamp int64, body []byte) string {
+	return hex.EncodeToString(hmacSum([]byte(secret), method, path, timestamp, body))
+}
+
+// SignRequest signs an HTTP request to an RPC server configured with the
+// HMACSecret, setting the X-Timestamp and X-Signature headers for the current
+// time. The body must be the request body that will be sent, which is empty for
+// a websocket connection request.
+func SignRequest(r *http.Request, secret string, body []byte) {
+	timestamp := time.Now().Unix()
+	r.Header.Set(HMACTimestampHeader, strconv.FormatInt(timestamp, 10))
+	r.Header.Set(HMACSignatureHeader, HMACSignature(secret, r.Method, r.URL.Path, timestamp, body))
+}
+
+// verifySignature checks the request's HMAC signature, if an HMACSecret is
+// configured and the request is signed. The body is read to check the
+// signature and replaced so that the handler can read it again. A valid
+// signature is only accepted once.
+func (s *RPCServer) verifySignature(r *http.Request) bool {
+	if len(s.hmacSecret) == 0 {
+		return false
+	}
+	sig, err := hex.DecodeString(r.Header.Get(HMACSignatureHeader))
+	if err != nil || len(sig) != sha256.Size {
+		return false
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(HMACTimestampHeader), 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := time.Since(time.Unix(timestamp, 0)); skew > hmacMaxSkew || skew < -hmacMaxSkew {
+		log.Debugf("signed request from ip %s has a stale timestamp, %v off", r.RemoteAddr, skew)
+		return false
+	}
+	var body []byte
+	if r.Body != nil {
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, hmacMaxBodySize+1))
+		r.Body.Close()
+		if err != nil || len(body) > hmacMaxBodySize {
+			return false
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if !hmac.Equal(hmacSum(s.hmacSecret, r.Method, r.URL.Path, timestamp, body), sig) {
+		return false
+	}
+	if !s.firstSignatureUse(sig) {
+		log.Warnf("rejected replayed signed request from ip %s", r.RemoteAddr)
+		return false
+	}
+	return true
+}
+
+// firstSignatureUse records a valid signature and reports whether it was not
+// already used. A signature is remembered until its timestamp can no longer be
+// within hmacMaxSkew, after which a replay is rejected as stale instead.
+func (s *RPCServer) firstSignatureUse(sig []byte) bool {
+	now := time.Now()
+	s.sigMtx.Lock()
+	defer s.sigMtx.Unlock()
+	for k, expires := range s.seenSigs {
+		if now.After(expires) {
+			delete(s.seenSigs, k)
+		}
+	}
+	k := string(sig)
+	if _, found := s.seenSigs[k]; found {
+		return false
+	}
+	s.seenSigs[k] = now.Add(2 * hmacMaxSkew)
+	return true
+}
+
+// digestAuth verifies HTTP Digest auth (RFC 7616) with the SHA-256 algorithm
+// and qop=auth. Nonces are not stored when issued. Each is a timestamp and its
+// HMAC with a random key, and it expires after digestNonceLifetime. The highest
+// nonce count used with each nonce is recorded so that a request cannot be
+// replayed while its nonce is valid.
+type digestAuth struct {
+	user string
+	ha1  string // hex SHA-256 of user:realm:pass
+	key  []byte
+
+	mtx    sync.Mutex
+	counts map[string]*digestNonceCount
+}
+
+// digestNonceCount is the highest nonce count used with a nonce, which is
+// forgotten when the nonce expires.
+type digestNonceCount struct {
+	nc      uint64
+	expires time.Time
+}
+
+// newDigestAuth is the constructor for a *digestAuth.
+func newDigestAuth(user, pass string) (*digestAuth, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error generating digest nonce key: %w", err)
+	}
+	return &digestAuth{
+		user:   user,
+		ha1:    sha256Hex(user + ":" + authRealm + ":" + pass),
+		key:    key,
+		counts: make(map[string]*digestNonceCount),
+	}, nil
+}
+
+// sha256Hex is the hex-encoded SHA-256 hash of s.
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// nonceMAC is the HMAC of the nonce timestamp in Unix seconds.
+func (d *digestAuth) nonceMAC(stamp int64) []byte {
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write([]byte(strconv.FormatInt(stamp, 10)))
+	return mac.Sum(nil)
+}
+
+// newNonce creates a nonce for the current time.
+func (d *digestAuth) newNonce() string {
+	stamp := time.Now().Unix()
+	return strconv.FormatInt(stamp, 10) + "-" + hex.EncodeToString(d.nonceMAC(stamp))
+}
+
+// nonceExpiration checks that the nonce was issued by this server and returns
+// when it expires.
+func (d *digestAuth) nonceExpiration(nonce string) (time.Time, bool) {
+	parts := strings.Split(nonce, "-")
+	if len(parts) != 2 {
+		return time.Time{}, false
+	}
+	stamp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	mac, err := hex.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(mac, d.nonceMAC(stamp)) {
+		return time.Time{}, false
+	}
+	return time.Unix(stamp, 0).Add(digestNonceLifetime), true
+}
+
+// challenge is the WWW-Authenticate header value with a new nonce. stale
+// indicates that the request's credentials were valid but its nonce expired.
+func (d *digestAuth) challenge(stale bool) string {
+	c := fmt.Sprintf(`Digest realm="%s", qop="auth", algorithm=SHA-256, nonce="%s"`,
+		authRealm, d.newNonce())
+	if stale {
+		c += ", stale=true"
+	}
+	return c
+}
+
+// verify checks the Authorization header of a request with the method and
+// request URI. stale is true if the response is correct but the nonce has
+// expired.
+func (d *digestAuth) verify(method, uri, auth string) (ok, stale bool) {
+	const prefix = "Digest "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false, false
+	}
+	params := parseAuthParams(auth[len(prefix):])
+	if params["username"] != d.user || params["realm"] != authRealm ||
+		params["uri"] != uri || params["qop"] != "auth" ||
+		!strings.EqualFold(params["algorithm"], "SHA-256") {
+		return false, false
+	}
+	nonce, ncStr, cnonce := params["nonce"], params["nc"], params["cnonce"]
+	nc, err := strconv.ParseUint(ncStr, 16, 64)
+	if err != nil || cnonce == "" {
+		return false, false
+	}
+	expires, valid := d.nonceExpiration(nonce)
+	if !valid {
+		return false, false
+	}
+	ha2 := sha256Hex(method + ":" + uri)
+	want := sha256Hex(strings.Join([]string{d.ha1, nonce, ncStr, cnonce, "auth", ha2}, ":"))
+	if subtle.ConstantTimeCompare([]byte(want), []byte(params["response"])) != 1 {
+		return false, false
+	}
+	now := time.Now()
+	if now.After(expires) {
+		return false, true
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	for n, c := range d.counts {
+		if now.After(c.expires) {
+			delete(d.counts, n)
+		}
+	}
+	count := d.counts[nonce]
+	if count == nil {
+		count = &digestNonceCount{expires: expires}
+		d.counts[nonce] = count
+	}
+	if nc <= count.nc {
+		log.Warnf("replayed digest nonce count %d", nc)
+		return false, false
+	}
+	count.nc = nc
+	return true, false
+}
+
+// parseAuthParams parses the comma-separated key=value pairs of an
+// Authorization header, with the scheme removed. Values may be quoted. Keys are
+// lower-cased.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			val = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = val
+	}
+}
+
+// remoteIP is the request's remote IP address. middleware.RealIP sets
+// RemoteAddr without a port.
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// peerIPKey is the request context key for the IP address of the TCP peer.
+type peerIPKey struct{}
+
+// recordPeerIP is middleware that records the TCP peer's IP address in the
+// request context. It must precede middleware.RealIP, which replaces
+// RemoteAddr with an address from the request headers.
+func recordPeerIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerIPKey{}, remoteIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// peerIP is the IP address of the TCP peer recorded by recordPeerIP. Unlike
+// remoteIP after middleware.RealIP, it cannot be chosen by the client.
+func peerIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(peerIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// ipAllowList is the set of networks from which requests are accepted.
+type ipAllowList []*net.IPNet
+
+// newIPAllowList parses the IP addresses and CIDR ranges. A single address is
+// treated as a network of just that address.
+func newIPAllowList(entries []string) (ipAllowList, error) {
+	allowed := make(ipAllowList, 0, len(entries))
+	for _, entry := range entries {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			allowed = append(allowed, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid allowed IP address or CIDR range %q", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		allowed = append(allowed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return allowed, nil
+}
+
+// allows checks if ip is in one of the allowed networks.
+func (l ipAllowList) allows(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range l {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware rejects requests from TCP peer IP addresses that are not allowed
+// with 403 Forbidden.
+func (l ipAllowList) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := peerIP(r)
+		if !l.allows(net.ParseIP(ip)) {
+			log.Warnf("Rejected request from disallowed IP address %s", ip)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter is a token bucket rate limiter for HTTP requests, keyed by the
+// TCP peer IP address, or by the /64 network of an IPv6 address, since a
+// single host is commonly assigned a whole /64. At most maxRateLimitBuckets
+// keys are tracked.
+type rateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mtx     sync.Mutex
+	buckets map[string]*tokenBucket
+	// overflow is shared by the keys that are not tracked while
+	// maxRateLimitBuckets are.
+	overflow *tokenBucket
+}
+
+// tokenBucket is the state of a single IP address's bucket.
+type tokenBucket struct {
+	tokens float64
+	stamp  time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// rateLimitKey is the bucket key for an IP address: the /64 network of an
+// IPv6 address, or the address itself.
+func rateLimitKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ip
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// allow takes a token from the bucket for ip if one is available. If not, the
+// time until one will be available is returned.
+func (rl *rateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	key := rateLimitKey(ip)
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+
+	b, found := rl.buckets[key]
+	if !found {
+		if len(rl.buckets) < maxRateLimitBuckets {
+			b = &tokenBucket{tokens: rl.burst, stamp: now}
+			rl.buckets[key] = b
+		} else {
+			if rl.overflow == nil {
+				log.Warnf("rate limiting %d IP addresses. Others share a bucket until idle ones are pruned.",
+					maxRateLimitBuckets)
+				rl.overflow = &tokenBucket{tokens: rl.burst, stamp: now}
+			}
+			b = rl.overflow
+		}
+	}
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.stamp).Seconds()*rl.rate)
+	b.stamp = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune forgets the buckets that have been idle long enough to refill
+// completely, which are no different from new ones.
+func (rl *rateLimiter) prune(now time.Time) {
+	fillTime := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	for k, b := range rl.buckets {
+		if now.Sub(b.stamp) > fillTime {
+			delete(rl.buckets, k)
+		}
+	}
+	if rl.overflow != nil && now.Sub(rl.overflow.stamp) > fillTime {
+		rl.overflow = nil
+	}
+}
+
+// run prunes idle buckets every rateLimitPruneInterval until ctx is canceled.
+func (rl *rateLimiter) run(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			rl.prune(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// middleware responds with 429 Too Many Requests and a Retry-After header if
+// the TCP peer IP address is over the limit. The proxy headers are not
+// trusted, so a client cannot get a fresh bucket by changing them.
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := peerIP(r)
+		ok, wait := rl.allow(ip, time.Now())
+		if !ok {
+			log.Debugf("rate limit exceeded for ip: %s", ip)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newOriginCheck creates the function that decides whether a websocket request
+// may be upgraded based on its Origin header. See Config.AllowedOrigins.
+func newOriginCheck(allowedOrigins []string) (func(r *http.Request) bool, error) {
+	var allowAny bool
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAny = true
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid allowed origin %q", origin)
+		}
+		allowed[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		if allowAny || strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		if len(allowedOrigins) == 0 {
+			host := u.Hostname()
+			if strings.EqualFold(host, "localhost") {
+				return true
+			}
+			ip := net.ParseIP(host)
+			return ip != nil && ip.IsLoopback()
+		}
+		return allowed[strings.ToLower(u.Scheme+"://"+u.Host)]
+	}, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	_, err := os.Stat(name)
 	return !os.IsNotExist(err)
 }
+
+// rpcMetrics counts the requests handled by route and error code.
+type rpcMetrics struct {
+	tokenSHA [32]byte
+	hasToken bool
+
+	mtx      sync.Mutex
+	requests map[requestLabels]uint64
+}
+
+// requestLabels are the labels of the requests counter.
+type requestLabels struct {
+	route string
+	code  string
+}
+
+func newRPCMetrics(token string) *rpcMetrics {
+	m := &rpcMetrics{
+		requests: make(map[requestLabels]uint64),
+	}
+	if token != "" {
+		m.tokenSHA = sha256.Sum256([]byte("Bearer " + token))
+		m.hasToken = true
+	}
+	return m
+}
+
+// observe counts a request and its result. Unknown routes share a single label
+// so that clients cannot create an unbounded number of series.
+func (m *rpcMetrics) observe(route string, payload *msgjson.ResponsePayload) {
+	if _, found := routes[route]; !found {
+		route = "unknown"
+	}
+	code := "ok"
+	if payload.Error != nil {
+		code = strconv.Itoa(payload.Error.Code)
+	}
+	m.mtx.Lock()
+	m.requests[requestLabels{route, code}]++
+	m.mtx.Unlock()
+}
+
+// authMiddleware requires the metrics token, if one is configured.
+func (m *rpcMetrics) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.hasToken {
+			authSHA := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+			if subtle.ConstantTimeCompare(m.tokenSHA[:], authSHA[:]) != 1 {
+				log.Warnf("metrics authentication failure from ip: %s", r.RemoteAddr)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleMetrics writes the metrics in the Prometheus text exposition format.
+func (s *RPCServer) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	m := s.metrics
+	m.mtx.Lock()
+	labels := make([]requestLabels, 0, len(m.requests))
+	counts := make(map[requestLabels]uint64, len(m.requests))
+	for l, n := range m.requests {
+		labels = append(labels, l)
+		counts[l] = n
+	}
+	m.mtx.Unlock()
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].route != labels[j].route {
+			return labels[i].route < labels[j].route
+		}
+		return labels[i].code < labels[j].code
+	})
+
+	clients, syncers := s.wsServer.Stats()
+
+	var b bytes.Buffer
+	b.WriteString("# HELP dexc_rpc_requests_total RPC requests handled, by route and error code.\n")
+	b.WriteString("# TYPE dexc_rpc_requests_total counter\n")
+	for _, l := range labels {
+		fmt.Fprintf(&b, "dexc_rpc_requests_total{route=%q,code=%q} %d\n", l.route, l.code, counts[l])
+	}
+	b.WriteString("# HELP dexc_rpc_websocket_clients Connected websocket clients.\n")
+	b.WriteString("# TYPE dexc_rpc_websocket_clients gauge\n")
+	fmt.Fprintf(&b, "dexc_rpc_websocket_clients %d\n", clients)
+	b.WriteString("# HELP dexc_rpc_market_syncers Websocket clients receiving order book updates.\n")
+	b.WriteString("# TYPE dexc_rpc_market_syncers gauge\n")
+	fmt.Fprintf(&b, "dexc_rpc_market_syncers %d\n", syncers)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
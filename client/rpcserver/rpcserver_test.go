@@ -7,24 +7,65 @@ package rpcserver
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"decred.org/dcrdex/client/asset"
+	"decred.org/dcrdex/client/comms"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
+	ws "github.com/gorilla/websocket"
 )
 
+// tDriver is a wallet driver registered so that the test asset IDs are
+// supported.
+type tDriver struct{}
+
+func (tDriver) Setup(*asset.WalletConfig, dex.Logger, dex.Network) (asset.Wallet, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (tDriver) DecodeCoinID(coinID []byte) (string, error) {
+	return fmt.Sprintf("%x", coinID), nil
+}
+func (tDriver) Info() *asset.WalletInfo {
+	return &asset.WalletInfo{
+		Units:            "atoms",
+		ConventionalUnit: "DCR",
+		ConversionFactor: 1e8,
+	}
+}
+
 func init() {
 	log = dex.StdOutLogger("TEST", dex.LevelTrace)
+	asset.Register(0, tDriver{})  // btc
+	asset.Register(42, tDriver{}) // dcr
 }
 
 var (
@@ -34,13 +75,30 @@ var (
 type TCore struct {
 	regFee              uint64
 	getFeeErr           error
+	dexConfig           *core.Exchange
+	dexConfigErr        error
+	feeRate             uint64
+	feeRateErr          error
+	depositAddr         string
+	depositAddrErr      error
+	rescanErr           error
+	validAddr           bool
+	validateAddrErr     error
+	reconfigWalletErr   error
+	reconfigSettings    map[string]string
+	changeAppPassErr    error
+	backupChecksum      []byte
+	backupErr           error
+	backupOverwrite     bool
 	balanceErr          error
 	syncErr             error
 	createWalletErr     error
 	newWalletForm       *core.WalletForm
 	openWalletErr       error
+	openWalletErrs      map[uint32]error
 	walletState         *core.WalletState
 	closeWalletErr      error
+	closeWalletErrs     map[uint32]error
 	wallets             []*core.WalletState
 	initializeClientErr error
 	registerResult      *core.RegisterResult
@@ -50,12 +108,49 @@ type TCore struct {
 	loginResult         *core.LoginResult
 	order               *core.Order
 	tradeErr            error
+	orderEstimate       *core.OrderEstimate
+	preOrderErr         error
+	marketInfo          *core.MarketInfo
+	marketInfoErr       error
+	feesPaid            *core.FeesPaid
+	feesPaidErr         error
+	feesPaidSince       int64
+	settings            *core.TradeSettings
+	setSettingsErr      error
 	cancelErr           error
 	coin                asset.Coin
 	withdrawErr         error
 	logoutErr           error
+	disconnectErr       error
+	disconnectForget    bool
+	disconnectForce     bool
 	book                *core.OrderBook
 	bookErr             error
+	swapCosts           *core.SwapCostReport
+	swapCostsErr        error
+	matches             []*core.MatchSettlement
+	matchesErr          error
+	supportedAssets     map[uint32]*core.SupportedAsset
+	ordersPage          *core.OrdersPage
+	ordersPageOffset    int
+	ordersPageLimit     int
+	retryPolicy         *core.RetryPolicy
+	setRetryPolicyErr   error
+	signedReport        *core.SignedReport
+	signedReportErr     error
+	ordersErr           error
+	ordersFilter        *core.OrderFilter
+	loggedIn            bool
+	connStats           map[string]comms.ConnStats
+	connStatus          map[string]comms.ConnStatus
+	candles             []*core.Candle
+	candlesErr          error
+	notes               []*db.Notification
+	notesErr            error
+	notesN              int
+	rawResp             *msgjson.ResponsePayload
+	rawErr              error
+	rawPayload          json.RawMessage
 }
 
 func (c *TCore) Balance(uint32) (uint64, error) {
@@ -65,6 +160,9 @@ func (c *TCore) Book(dex string, base, quote uint32) (*core.OrderBook, error) {
 	return c.book, c.bookErr
 }
 func (c *TCore) AckNotes(ids []dex.Bytes) {}
+func (c *TCore) Candles(host string, base, quote uint32, binSize string, count int) ([]*core.Candle, error) {
+	return c.candles, c.candlesErr
+}
 func (c *TCore) AssetBalance(uint32) (*core.WalletBalance, error) {
 	return nil, c.balanceErr
 }
@@ -76,33 +174,128 @@ func (c *TCore) CreateWallet(appPW, walletPW []byte, form *core.WalletForm) erro
 	return c.createWalletErr
 }
 func (c *TCore) CloseWallet(assetID uint32) error {
+	if err, found := c.closeWalletErrs[assetID]; found {
+		return err
+	}
 	return c.closeWalletErr
 }
 func (c *TCore) Exchanges() (exchanges map[string]*core.Exchange) { return c.exchanges }
+func (c *TCore) ConnStats() map[string]comms.ConnStats            { return c.connStats }
+func (c *TCore) ConnStatus() map[string]comms.ConnStatus          { return c.connStatus }
 func (c *TCore) InitializeClient(pw []byte) error {
 	return c.initializeClientErr
 }
 func (c *TCore) Login(appPass []byte) (*core.LoginResult, error) {
 	return c.loginResult, c.loginErr
 }
+func (c *TCore) Notifications(n int) ([]*db.Notification, error) {
+	c.notesN = n
+	return c.notes, c.notesErr
+}
+func (c *TCore) LoggedIn() bool {
+	return c.loggedIn
+}
 func (c *TCore) Logout() error {
 	return c.logoutErr
 }
+func (c *TCore) DisconnectDEX(pw []byte, addr string, forget, force bool) error {
+	c.disconnectForget, c.disconnectForce = forget, force
+	return c.disconnectErr
+}
 func (c *TCore) OpenWallet(assetID uint32, pw []byte) error {
+	if err, found := c.openWalletErrs[assetID]; found {
+		return err
+	}
 	return c.openWalletErr
 }
 func (c *TCore) GetFee(url, cert string) (uint64, error) {
 	return c.regFee, c.getFeeErr
 }
+func (c *TCore) DEXConfig(addr, cert string) (*core.Exchange, error) {
+	return c.dexConfig, c.dexConfigErr
+}
+func (c *TCore) ChangeAppPass(appPass, newAppPass []byte) error {
+	return c.changeAppPassErr
+}
+func (c *TCore) Backup(appPass []byte, path string, overwrite bool) (string, []byte, error) {
+	c.backupOverwrite = overwrite
+	return path, c.backupChecksum, c.backupErr
+}
+func (c *TCore) FeeRate(assetID uint32) (uint64, error) {
+	return c.feeRate, c.feeRateErr
+}
+func (c *TCore) NewDepositAddress(assetID uint32) (string, error) {
+	return c.depositAddr, c.depositAddrErr
+}
+func (c *TCore) RescanWallet(assetID uint32) error {
+	return c.rescanErr
+}
+func (c *TCore) ValidateAddress(assetID uint32, address string) (bool, error) {
+	return c.validAddr, c.validateAddrErr
+}
+func (c *TCore) ReconfigureWallet(appPass []byte, assetID uint32, settings map[string]string) error {
+	c.reconfigSettings = settings
+	return c.reconfigWalletErr
+}
 func (c *TCore) Register(*core.RegisterForm) (*core.RegisterResult, error) {
 	return c.registerResult, c.registerErr
 }
+func (c *TCore) RetryPolicy() *core.RetryPolicy {
+	return c.retryPolicy
+}
+func (c *TCore) SetRetryPolicy(policy *core.RetryPolicy) error {
+	if c.setRetryPolicyErr != nil {
+		return c.setRetryPolicyErr
+	}
+	c.retryPolicy = policy
+	return nil
+}
+func (c *TCore) Settings() *core.TradeSettings {
+	return c.settings
+}
+func (c *TCore) SetSettings(settings *core.TradeSettings) error {
+	if c.setSettingsErr != nil {
+		return c.setSettingsErr
+	}
+	c.settings = settings
+	return nil
+}
+func (c *TCore) SignedTradeReport(appPass []byte, oid dex.Bytes) (*core.SignedReport, error) {
+	return c.signedReport, c.signedReportErr
+}
+func (c *TCore) SwapCosts(oid dex.Bytes) (*core.SwapCostReport, error) {
+	return c.swapCosts, c.swapCostsErr
+}
+func (c *TCore) Matches(oid dex.Bytes) ([]*core.MatchSettlement, error) {
+	return c.matches, c.matchesErr
+}
+func (c *TCore) SupportedAssets() map[uint32]*core.SupportedAsset {
+	return c.supportedAssets
+}
+func (c *TCore) OrdersPage(filter *core.OrderFilter, offset, limit int) (*core.OrdersPage, error) {
+	c.ordersFilter = filter
+	c.ordersPageOffset, c.ordersPageLimit = offset, limit
+	return c.ordersPage, c.ordersErr
+}
 func (c *TCore) SyncBook(dex string, base, quote uint32) (*core.BookFeed, error) {
 	return core.NewBookFeed(func(*core.BookFeed) {}), c.syncErr
 }
+func (c *TCore) SyncBookSince(dex string, base, quote uint32, since uint64) (*core.BookFeed, bool, error) {
+	return core.NewBookFeed(func(*core.BookFeed) {}), false, c.syncErr
+}
 func (c *TCore) Trade(appPass []byte, form *core.TradeForm) (order *core.Order, err error) {
 	return c.order, c.tradeErr
 }
+func (c *TCore) PreOrder(form *core.TradeForm) (*core.OrderEstimate, error) {
+	return c.orderEstimate, c.preOrderErr
+}
+func (c *TCore) MarketInfo(dex string, base, quote uint32) (*core.MarketInfo, error) {
+	return c.marketInfo, c.marketInfoErr
+}
+func (c *TCore) FeesPaid(dex string, sinceUnix int64) (*core.FeesPaid, error) {
+	c.feesPaidSince = sinceUnix
+	return c.feesPaid, c.feesPaidErr
+}
 func (c *TCore) Wallets() []*core.WalletState {
 	return c.wallets
 }
@@ -112,6 +305,10 @@ func (c *TCore) WalletState(assetID uint32) *core.WalletState {
 func (c *TCore) Withdraw(pw []byte, assetID uint32, value uint64, addr string) (asset.Coin, error) {
 	return c.coin, c.withdrawErr
 }
+func (c *TCore) SendRaw(dex, route string, payload json.RawMessage) (*msgjson.ResponsePayload, error) {
+	c.rawPayload = payload
+	return c.rawResp, c.rawErr
+}
 
 func newTServer(t *testing.T, start bool, user, pass string) (*RPCServer, func()) {
 	tSrv, fn, err := newTServerWErr(t, start, user, pass)
@@ -306,6 +503,223 @@ func TestParseHTTPRequest(t *testing.T) {
 	bbuff = bytes.NewBuffer(b)
 	r, _ = http.NewRequest("GET", "", bbuff)
 	ensureMsgErr("bad params", msgjson.RPCParseError)
+
+	// No ID. The request is rejected without being handled.
+	msg = &msgjson.Message{Type: msgjson.Request, Route: versionRoute}
+	b, _ = json.Marshal(msg)
+	r, _ = http.NewRequest("GET", "", bytes.NewBuffer(b))
+	w := &tResponseWriter{}
+	s.handleJSON(w, r)
+	if w.code != http.StatusBadRequest {
+		t.Fatalf("no ID: expected HTTP error %d, got %d", http.StatusBadRequest, w.code)
+	}
+	resp := new(msgjson.Message)
+	if err := json.Unmarshal(w.b, resp); err != nil {
+		t.Fatalf("no ID: unable to unmarshal response: %v", err)
+	}
+	payload := new(msgjson.ResponsePayload)
+	if err := json.Unmarshal(resp.Payload, payload); err != nil {
+		t.Fatalf("no ID: unable to unmarshal payload: %v", err)
+	}
+	if payload.Error == nil || payload.Error.Code != msgjson.RPCParseError {
+		t.Fatalf("no ID: expected a parse error, got %v", payload.Error)
+	}
+
+	// No route.
+	msg = &msgjson.Message{Type: msgjson.Request, ID: 1}
+	b, _ = json.Marshal(msg)
+	bbuff = bytes.NewBuffer(b)
+	r, _ = http.NewRequest("GET", "", bbuff)
+	ensureMsgErr("no route", msgjson.RPCUnknownRoute)
+
+	// Real route with the wrong number of args.
+	msg, _ = msgjson.NewRequest(1, "login", &RawParams{})
+	b, _ = json.Marshal(msg)
+	bbuff = bytes.NewBuffer(b)
+	r, _ = http.NewRequest("GET", "", bbuff)
+	ensureMsgErr("missing args", msgjson.RPCArgumentsError)
+
+	// Core errors without a specific code get the route's code.
+	tc := s.core.(*TCore)
+	tc.loginErr = fmt.Errorf("login error")
+	msg, _ = msgjson.NewRequest(1, "login", &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("abc")}})
+	b, _ = json.Marshal(msg)
+	bbuff = bytes.NewBuffer(b)
+	r, _ = http.NewRequest("GET", "", bbuff)
+	ensureMsgErr("login error", msgjson.RPCLoginError)
+	tc.loginErr = nil
+
+	// A Content-Type other than application/json is a parse error.
+	versionReq := func(contentType string) {
+		msg, _ = msgjson.NewRequest(1, "version", nil)
+		b, _ = json.Marshal(msg)
+		r, _ = http.NewRequest("POST", "", bytes.NewBuffer(b))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+	}
+	for _, ct := range []string{"application/x-www-form-urlencoded", "text/html; charset=utf-8", "application/json;;"} {
+		versionReq(ct)
+		w := &tResponseWriter{}
+		s.handleJSON(w, r)
+		if w.code != http.StatusBadRequest {
+			t.Fatalf("%s: expected HTTP error %d, got %d", ct, http.StatusBadRequest, w.code)
+		}
+		resp := new(msgjson.Message)
+		if err := json.Unmarshal(w.b, resp); err != nil {
+			t.Fatalf("%s: unable to unmarshal response: %v", ct, err)
+		}
+		payload := new(msgjson.ResponsePayload)
+		if err := json.Unmarshal(resp.Payload, payload); err != nil {
+			t.Fatalf("%s: unable to unmarshal payload: %v", ct, err)
+		}
+		if payload.Error == nil || payload.Error.Code != msgjson.RPCParseError {
+			t.Fatalf("%s: expected a parse error, got %v", ct, payload.Error)
+		}
+	}
+	// JSON, with or without parameters, and no Content-Type are accepted.
+	versionReq("application/json")
+	ensureNoErr("application/json")
+	versionReq("Application/JSON; charset=utf-8")
+	ensureNoErr("application/json with charset")
+	versionReq("")
+	ensureNoErr("no content type")
+}
+
+func TestParseHTTPBatchRequest(t *testing.T) {
+	s, shutdown := newTServer(t, false, "", "abc")
+	defer shutdown()
+
+	post := func(body string) *tResponseWriter {
+		t.Helper()
+		r, _ := http.NewRequest("POST", "", strings.NewReader(body))
+		w := &tResponseWriter{}
+		s.handleJSON(w, r)
+		return w
+	}
+
+	reqJSON := func(id uint64, route string, args interface{}) string {
+		msg, _ := msgjson.NewRequest(id, route, args)
+		b, _ := json.Marshal(msg)
+		return string(b)
+	}
+	respJSON, _ := msgjson.NewResponse(9, nil, nil)
+	respB, _ := json.Marshal(respJSON)
+
+	noIDB, _ := json.Marshal(&msgjson.Message{Type: msgjson.Request, Route: versionRoute})
+
+	// Good requests, an unknown route, a malformed entry, a response, bad
+	// args, a request without an ID, and a request reusing an earlier ID, in
+	// that order.
+	body := "\n [" + strings.Join([]string{
+		reqJSON(1, versionRoute, nil),
+		reqJSON(2, walletsRoute, nil),
+		reqJSON(3, "123", nil),
+		`{"type": "notatype"}`,
+		string(respB),
+		reqJSON(6, versionRoute, "something"),
+		string(noIDB),
+		reqJSON(2, versionRoute, nil),
+	}, ",") + "]"
+	w := post(body)
+	if w.code != http.StatusOK {
+		t.Fatalf("wrong HTTP status %d", w.code)
+	}
+	var resps []*msgjson.Message
+	if err := json.Unmarshal(w.b, &resps); err != nil {
+		t.Fatalf("unable to unmarshal responses: %v", err)
+	}
+	wantIDs := []uint64{1, 2, 3, 0, 9, 6, 0, 2}
+	wantCodes := []int{-1, -1, msgjson.RPCUnknownRoute, msgjson.RPCParseError,
+		msgjson.UnknownMessageType, msgjson.RPCParseError, msgjson.RPCParseError,
+		msgjson.InvalidRequestError}
+	if len(resps) != len(wantCodes) {
+		t.Fatalf("wanted %d responses, got %d", len(wantCodes), len(resps))
+	}
+	for i, resp := range resps {
+		if resp.Type != msgjson.Response || resp.ID != wantIDs[i] {
+			t.Fatalf("response %d: wrong type %d or ID %d", i, resp.Type, resp.ID)
+		}
+		payload := new(msgjson.ResponsePayload)
+		if err := json.Unmarshal(resp.Payload, payload); err != nil {
+			t.Fatalf("response %d: unable to unmarshal payload: %v", i, err)
+		}
+		if wantCodes[i] == -1 {
+			if payload.Error != nil {
+				t.Fatalf("response %d: unexpected error: %v", i, payload.Error)
+			}
+			continue
+		}
+		if payload.Error == nil || payload.Error.Code != wantCodes[i] {
+			t.Fatalf("response %d: wanted error code %d, got %v", i, wantCodes[i], payload.Error)
+		}
+	}
+
+	// An empty or malformed batch is an HTTP error.
+	for _, body := range []string{"[]", "[{},", "[1, 2"} {
+		if w := post(body); w.code != http.StatusUnprocessableEntity {
+			t.Fatalf("%q: wanted HTTP status %d, got %d", body, http.StatusUnprocessableEntity, w.code)
+		}
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	var logBuf bytes.Buffer
+	defer func(l dex.Logger) { log = l }(log)
+	log = dex.NewLogger("TEST", dex.LevelTrace, &logBuf)
+
+	s := &RPCServer{
+		core:        &TCore{loginErr: errors.New("bad password"), createWalletErr: errors.New("error")},
+		reqLogLevel: dex.LevelDebug,
+	}
+	request := func(route string, params *RawParams) string {
+		t.Helper()
+		logBuf.Reset()
+		msg, _ := msgjson.NewRequest(1, route, params)
+		s.handleRequest(msg, "10.0.0.1", false)
+		return logBuf.String()
+	}
+
+	// A successful request is logged at the request log level with its
+	// arguments.
+	logged := request(orderBookRoute, &RawParams{Args: []string{"dex:7232", "42", "0"}})
+	for _, want := range []string{"[DBG]", `route="orderbook"`, "ip=10.0.0.1", "duration=", "code=ok", `"dex:7232" "42" "0"`} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("%q not found in log output: %q", want, logged)
+		}
+	}
+
+	// A failed request is logged at warn. The arguments of a route that takes
+	// passwords are not logged.
+	logged = request(newWalletRoute, &RawParams{
+		PWArgs: []encode.PassBytes{encode.PassBytes("apppass"), encode.PassBytes("walletpass")},
+		Args:   []string{"42", "", `{"rpcpassword":"secret"}`},
+	})
+	if !strings.Contains(logged, "[WRN]") || !strings.Contains(logged, "code=") || strings.Contains(logged, "code=ok") {
+		t.Fatalf("failed request not logged at warn with its error code: %q", logged)
+	}
+	for _, secret := range []string{"apppass", "walletpass", "secret", "args="} {
+		if strings.Contains(logged, secret) {
+			t.Fatalf("%q found in log output: %q", secret, logged)
+		}
+	}
+
+	// Errors are logged at the request log level if it is above warn.
+	s.reqLogLevel = dex.LevelError
+	if logged = request(loginRoute, &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("abc")}}); !strings.Contains(logged, "[ERR]") {
+		t.Fatalf("failed request not logged at error: %q", logged)
+	}
+
+	// Request logging can be disabled.
+	s.reqLogLevel = dex.LevelOff
+	if logged = request(loginRoute, &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("abc")}}); logged != "" {
+		t.Fatalf("request logged with logging disabled: %q", logged)
+	}
+
+	// Unknown levels are rejected.
+	if _, err := New(&Config{Core: &TCore{}, Pass: "pass", RequestLogLevel: "loud"}); err == nil {
+		t.Fatalf("no error for an unknown request log level")
+	}
 }
 
 func TestNew(t *testing.T) {
@@ -346,6 +760,37 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestDisableAutoCert(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cfg := &Config{
+		Core:            &TCore{},
+		Addr:            "127.0.0.1:0",
+		Pass:            "pass",
+		Cert:            tempDir + "/cert.cert",
+		Key:             tempDir + "/key.key",
+		DisableAutoCert: true,
+	}
+	if _, err := New(cfg); err == nil {
+		t.Fatalf("no error for missing cert pair with auto cert disabled")
+	}
+	if fileExists(cfg.Cert) || fileExists(cfg.Key) {
+		t.Fatalf("cert pair generated with auto cert disabled")
+	}
+
+	// An existing pair is still used.
+	if err := genCertPair(cfg.Cert, cfg.Key, defaultCertOrg, time.Hour, nil); err != nil {
+		t.Fatalf("error generating cert pair: %v", err)
+	}
+	if _, err := New(cfg); err != nil {
+		t.Fatalf("error with an existing cert pair: %v", err)
+	}
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	s, shutdown := newTServer(t, false, "", "abc")
 	defer shutdown()
@@ -433,3 +878,1902 @@ func TestAuthMiddleware(t *testing.T) {
 		wantAuthError(test.name, test.wantErr)
 	}
 }
+
+func TestHealth(t *testing.T) {
+	s, shutdown := newTServer(t, false, "user", "pass")
+	defer shutdown()
+	s.core.(*TCore).loggedIn = true
+
+	// The health check does not require credentials.
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusOK, w.Code)
+	}
+	res := new(healthResponse)
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("error decoding health response: %v", err)
+	}
+	if !res.OK || !res.LoggedIn {
+		t.Fatalf("wrong health response: %+v", res)
+	}
+
+	// Other routes still do.
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("{}")))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const token = "0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+	newServer := func(metrics bool, metricsToken string) *RPCServer {
+		t.Helper()
+		s, err := New(&Config{
+			Core:         &TCore{loginErr: fmt.Errorf("login error")},
+			Addr:         "127.0.0.1:0",
+			User:         "user",
+			Pass:         "pass",
+			Cert:         tempDir + "/cert.cert",
+			Key:          tempDir + "/key.key",
+			Metrics:      metrics,
+			MetricsToken: metricsToken,
+		})
+		if err != nil {
+			t.Fatalf("error creating server: %v", err)
+		}
+		return s
+	}
+	scrape := func(s *RPCServer, auth string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		s.mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// Metrics are disabled by default.
+	if w := scrape(newServer(false, ""), ""); w.Code != http.StatusNotFound {
+		t.Fatalf("wanted HTTP status %d with metrics disabled, got %d", http.StatusNotFound, w.Code)
+	}
+
+	s := newServer(true, "")
+	request := func(route string, params *RawParams) {
+		t.Helper()
+		msg, _ := msgjson.NewRequest(1, route, params)
+		s.handleRequest(msg, "127.0.0.1", false)
+	}
+	request(versionRoute, nil)
+	request(versionRoute, nil)
+	request(loginRoute, &RawParams{})
+	request(loginRoute, &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("abc")}})
+	request("notaroute", nil)
+
+	w := scrape(s, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`dexc_rpc_requests_total{route="version",code="ok"} 2`,
+		fmt.Sprintf(`dexc_rpc_requests_total{route="login",code="%d"} 1`, msgjson.RPCArgumentsError),
+		fmt.Sprintf(`dexc_rpc_requests_total{route="login",code="%d"} 1`, msgjson.RPCLoginError),
+		fmt.Sprintf(`dexc_rpc_requests_total{route="unknown",code="%d"} 1`, msgjson.RPCUnknownRoute),
+		"dexc_rpc_websocket_clients 0",
+		"dexc_rpc_market_syncers 0",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+
+	// With a token, it is required.
+	s = newServer(true, token)
+	if w := scrape(s, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wanted HTTP status %d without token, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := scrape(s, "Bearer "+token[1:]); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wanted HTTP status %d with wrong token, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := scrape(s, "Bearer "+token); w.Code != http.StatusOK {
+		t.Fatalf("wanted HTTP status %d with token, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allow("1.2.3.4", now); !ok {
+			t.Fatalf("request %d within burst denied", i)
+		}
+	}
+	ok, wait := rl.allow("1.2.3.4", now)
+	if ok {
+		t.Fatalf("request beyond burst allowed")
+	}
+	if wait != time.Second/2 {
+		t.Fatalf("wanted wait %v, got %v", time.Second/2, wait)
+	}
+	// Other IPs have their own bucket.
+	if ok, _ := rl.allow("4.3.2.1", now); !ok {
+		t.Fatalf("request from another IP denied")
+	}
+	// One token is added every half second.
+	now = now.Add(time.Second / 2)
+	if ok, _ := rl.allow("1.2.3.4", now); !ok {
+		t.Fatalf("request after refill denied")
+	}
+	if ok, _ := rl.allow("1.2.3.4", now); ok {
+		t.Fatalf("second request after partial refill allowed")
+	}
+	// IPv6 addresses in the same /64 share a bucket.
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allow(fmt.Sprintf("2001:db8:1:2::%d", i+1), now); !ok {
+			t.Fatalf("IPv6 request %d within burst denied", i)
+		}
+	}
+	if ok, _ := rl.allow("2001:db8:1:2:ffff::1", now); ok {
+		t.Fatalf("request from the same /64 beyond burst allowed")
+	}
+	if ok, _ := rl.allow("2001:db8:1:3::1", now); !ok {
+		t.Fatalf("request from another /64 denied")
+	}
+	// Idle buckets are pruned once full.
+	now = now.Add(time.Minute)
+	rl.allow("5.6.7.8", now)
+	rl.prune(now)
+	if len(rl.buckets) != 1 {
+		t.Fatalf("wanted 1 bucket after pruning, got %d", len(rl.buckets))
+	}
+	// The number of buckets is capped, and untracked addresses share a
+	// bucket until idle ones are pruned.
+	for i := 1; i < maxRateLimitBuckets; i++ {
+		rl.allow(strconv.Itoa(i), now)
+	}
+	if len(rl.buckets) != maxRateLimitBuckets {
+		t.Fatalf("wanted %d buckets, got %d", maxRateLimitBuckets, len(rl.buckets))
+	}
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.allow(fmt.Sprintf("10.0.0.%d", i), now); !ok {
+			t.Fatalf("untracked request %d within burst denied", i)
+		}
+	}
+	if ok, _ := rl.allow("10.0.1.1", now); ok {
+		t.Fatalf("untracked request beyond the shared burst allowed")
+	}
+	if len(rl.buckets) != maxRateLimitBuckets {
+		t.Fatalf("wanted %d buckets at the cap, got %d", maxRateLimitBuckets, len(rl.buckets))
+	}
+	// A tracked address keeps its own bucket.
+	if ok, _ := rl.allow("5.6.7.8", now); !ok {
+		t.Fatalf("request from a tracked address denied at the cap")
+	}
+	now = now.Add(time.Minute)
+	rl.prune(now)
+	if len(rl.buckets) != 0 || rl.overflow != nil {
+		t.Fatalf("%d buckets left after pruning", len(rl.buckets))
+	}
+
+	// The middleware responds with 429 and Retry-After.
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	s, err := New(&Config{
+		Core:      &TCore{},
+		Addr:      "127.0.0.1:0",
+		Pass:      "pass",
+		Cert:      tempDir + "/cert.cert",
+		Key:       tempDir + "/key.key",
+		RateLimit: 0.5,
+		RateBurst: 1,
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	get := func(peerIP string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/health", nil)
+		r.RemoteAddr = net.JoinHostPort(peerIP, "1234")
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, r)
+		return w
+	}
+	if w := get("1.2.3.4"); w.Code != http.StatusOK {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusOK, w.Code)
+	}
+	w := get("1.2.3.4")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "2" {
+		t.Fatalf("wanted Retry-After 2, got %q", retry)
+	}
+	if w := get("4.3.2.1"); w.Code != http.StatusOK {
+		t.Fatalf("wanted HTTP status %d for another IP, got %d", http.StatusOK, w.Code)
+	}
+	// Changing the proxy headers does not get a new bucket.
+	for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+		r := httptest.NewRequest("GET", "/health", nil)
+		r.RemoteAddr = "1.2.3.4:1234"
+		r.Header.Set(header, "9.9.9.9")
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, r)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("wanted HTTP status %d with a spoofed %s, got %d", http.StatusTooManyRequests, header, w.Code)
+		}
+	}
+}
+
+func TestIPAllowList(t *testing.T) {
+	if _, err := newIPAllowList([]string{"1.2.3"}); err == nil {
+		t.Fatalf("no error for an invalid IP address")
+	}
+	if _, err := newIPAllowList([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("no error for an invalid CIDR range")
+	}
+
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cfg := &Config{
+		Core:     &TCore{},
+		Addr:     "127.0.0.1:0",
+		Pass:     "pass",
+		Cert:     tempDir + "/cert.cert",
+		Key:      tempDir + "/key.key",
+		AllowIPs: []string{"192.168.1.0/24", "10.0.0.5", "fd00::/8"},
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	get := func(peerIP string) int {
+		r := httptest.NewRequest("GET", "/health", nil)
+		r.RemoteAddr = net.JoinHostPort(peerIP, "1234")
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	tests := []struct {
+		ip   string
+		want int
+	}{
+		{"192.168.1.1", http.StatusOK},
+		{"192.168.1.255", http.StatusOK},
+		{"10.0.0.5", http.StatusOK},
+		{"fd12::1", http.StatusOK},
+		{"192.168.2.1", http.StatusForbidden},
+		{"10.0.0.6", http.StatusForbidden},
+		{"fe80::1", http.StatusForbidden},
+		{"not an ip", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code := get(tt.ip); code != tt.want {
+			t.Fatalf("%s: wanted HTTP status %d, got %d", tt.ip, tt.want, code)
+		}
+	}
+
+	// Disallowed requests are rejected before authentication.
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"method":"version"}`))
+	r.RemoteAddr = "8.8.8.8:1234"
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("wanted HTTP status %d before auth, got %d", http.StatusForbidden, w.Code)
+	}
+
+	// The proxy headers cannot be used to get past the allowlist, or to be
+	// rejected by it.
+	spoof := func(peerAddr, header, ip string) int {
+		r := httptest.NewRequest("GET", "/health", nil)
+		r.RemoteAddr = peerAddr
+		r.Header.Set(header, ip)
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+		if code := spoof("8.8.8.8:1234", header, "192.168.1.1"); code != http.StatusForbidden {
+			t.Fatalf("wanted HTTP status %d with a spoofed %s, got %d", http.StatusForbidden, header, code)
+		}
+		if code := spoof("192.168.1.1:1234", header, "8.8.8.8"); code != http.StatusOK {
+			t.Fatalf("wanted HTTP status %d for an allowed peer with %s set, got %d", http.StatusOK, header, code)
+		}
+	}
+
+	// An allowlist cannot be used with a unix socket.
+	cfg.Addr = "unix://" + tempDir + "/rpc.sock"
+	if _, err := New(cfg); err == nil {
+		t.Fatalf("no error for an allowlist with a unix socket")
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const user, pass, token = "user", "pass", "0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+	newServer := func(pass, token string) *RPCServer {
+		t.Helper()
+		s, err := New(&Config{
+			Core:  &TCore{},
+			Addr:  "127.0.0.1:0",
+			User:  user,
+			Pass:  pass,
+			Token: token,
+			Cert:  tempDir + "/cert.cert",
+			Key:   tempDir + "/key.key",
+		})
+		if err != nil {
+			t.Fatalf("error creating server: %v", err)
+		}
+		return s
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	bearer := "Bearer " + token
+
+	tests := []struct {
+		name, pass, token, header string
+		wantCode                  int
+	}{{
+		name:     "token ok",
+		token:    token,
+		header:   bearer,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "wrong token",
+		token:    token,
+		header:   "Bearer " + token[1:],
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "token only, basic rejected",
+		token:    token,
+		header:   basic,
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "token only, no header",
+		token:    token,
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "both, token ok",
+		pass:     pass,
+		token:    token,
+		header:   bearer,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "both, basic ok",
+		pass:     pass,
+		token:    token,
+		header:   basic,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "both, no header",
+		pass:     pass,
+		token:    token,
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "basic only, token rejected",
+		pass:     pass,
+		header:   bearer,
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "basic only, empty bearer rejected",
+		pass:     pass,
+		header:   "Bearer ",
+		wantCode: http.StatusUnauthorized,
+	}}
+	for _, test := range tests {
+		s := newServer(test.pass, test.token)
+		am := s.authMiddleware(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+		r, _ := http.NewRequest("POST", "", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := &tResponseWriter{}
+		am.ServeHTTP(w, r)
+		if w.code != test.wantCode {
+			t.Fatalf("%s: wanted HTTP status %d, got %d", test.name, test.wantCode, w.code)
+		}
+	}
+
+	// Neither a password nor a token is an error.
+	_, err = New(&Config{
+		Core: &TCore{},
+		Addr: "127.0.0.1:0",
+		User: user,
+		Cert: tempDir + "/cert.cert",
+		Key:  tempDir + "/key.key",
+	})
+	if err == nil {
+		t.Fatalf("no error for missing password and token")
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const user, pass, secret = "user", "pass", "a7c5e3f1d9b8"
+	newServer := func(pass string) *RPCServer {
+		t.Helper()
+		s, err := New(&Config{
+			Core:       &TCore{},
+			Addr:       "127.0.0.1:0",
+			User:       user,
+			Pass:       pass,
+			HMACSecret: secret,
+			Cert:       tempDir + "/cert.cert",
+			Key:        tempDir + "/key.key",
+		})
+		if err != nil {
+			t.Fatalf("error creating server: %v", err)
+		}
+		return s
+	}
+
+	body := []byte(`{"type":1,"route":"version","id":1}`)
+	now := time.Now().Unix()
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+
+	tests := []struct {
+		name, pass, auth string
+		timestamp        int64
+		sig              string
+		body             []byte
+		wantCode         int
+	}{{
+		name:      "signature ok",
+		timestamp: now,
+		sig:       HMACSignature(secret, "POST", "/", now, body),
+		body:      body,
+		wantCode:  http.StatusOK,
+	}, {
+		name:      "empty body ok",
+		timestamp: now,
+		sig:       HMACSignature(secret, "POST", "/", now, nil),
+		wantCode:  http.StatusOK,
+	}, {
+		name:      "wrong secret",
+		timestamp: now,
+		sig:       HMACSignature(secret[1:], "POST", "/", now, body),
+		body:      body,
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:      "body changed",
+		timestamp: now,
+		sig:       HMACSignature(secret, "POST", "/", now, body),
+		body:      append([]byte(" "), body...),
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:      "method changed",
+		timestamp: now,
+		sig:       HMACSignature(secret, "GET", "/", now, body),
+		body:      body,
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:      "path changed",
+		timestamp: now,
+		sig:       HMACSignature(secret, "POST", "/ws", now, body),
+		body:      body,
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:      "timestamp changed",
+		timestamp: now - 1,
+		sig:       HMACSignature(secret, "POST", "/", now, body),
+		body:      body,
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:      "stale timestamp",
+		timestamp: now - 60,
+		sig:       HMACSignature(secret, "POST", "/", now-60, body),
+		body:      body,
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:      "future timestamp",
+		timestamp: now + 60,
+		sig:       HMACSignature(secret, "POST", "/", now+60, body),
+		body:      body,
+		wantCode:  http.StatusUnauthorized,
+	}, {
+		name:     "unsigned",
+		body:     body,
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "basic with HMAC configured",
+		pass:     pass,
+		auth:     basic,
+		body:     body,
+		wantCode: http.StatusOK,
+	}, {
+		name:      "signature with basic configured",
+		pass:      pass,
+		timestamp: now,
+		sig:       HMACSignature(secret, "POST", "/", now, body),
+		body:      body,
+		wantCode:  http.StatusOK,
+	}}
+	for _, test := range tests {
+		s := newServer(test.pass)
+		var gotBody []byte
+		am := s.authMiddleware(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotBody, _ = ioutil.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			}))
+		r, _ := http.NewRequest("POST", "", bytes.NewReader(test.body))
+		if test.auth != "" {
+			r.Header.Set("Authorization", test.auth)
+		}
+		if test.sig != "" {
+			r.Header.Set(HMACTimestampHeader, strconv.FormatInt(test.timestamp, 10))
+			r.Header.Set(HMACSignatureHeader, test.sig)
+		}
+		w := &tResponseWriter{}
+		am.ServeHTTP(w, r)
+		if w.code != test.wantCode {
+			t.Fatalf("%s: wanted HTTP status %d, got %d", test.name, test.wantCode, w.code)
+		}
+		// The handler can still read the body after it was verified.
+		if w.code == http.StatusOK && !bytes.Equal(gotBody, test.body) {
+			t.Fatalf("%s: wrong body read by handler: %q", test.name, gotBody)
+		}
+	}
+
+	// SignRequest produces a valid signature.
+	s := newServer("")
+	am := s.authMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	r, _ := http.NewRequest("POST", "", bytes.NewReader(body))
+	SignRequest(r, secret, body)
+	w := &tResponseWriter{}
+	am.ServeHTTP(w, r)
+	if w.code != http.StatusOK {
+		t.Fatalf("SignRequest: wanted HTTP status %d, got %d", http.StatusOK, w.code)
+	}
+
+	// The same signed request is rejected when replayed.
+	replay, _ := http.NewRequest("POST", "", bytes.NewReader(body))
+	replay.Header = r.Header.Clone()
+	w = &tResponseWriter{}
+	am.ServeHTTP(w, replay)
+	if w.code != http.StatusUnauthorized {
+		t.Fatalf("replayed signature: wanted HTTP status %d, got %d", http.StatusUnauthorized, w.code)
+	}
+
+	// A signed websocket connection request is verified against its method
+	// and path.
+	r, _ = http.NewRequest("GET", "https://127.0.0.1:5757/ws", nil)
+	SignRequest(r, secret, nil)
+	w = &tResponseWriter{}
+	am.ServeHTTP(w, r)
+	if w.code != http.StatusOK {
+		t.Fatalf("signed websocket request: wanted HTTP status %d, got %d", http.StatusOK, w.code)
+	}
+}
+
+func TestAuthChallenge(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const user, pass = "user", "pass"
+	newServer := func(challenge, pass string) (*RPCServer, error) {
+		return New(&Config{
+			Core:          &TCore{},
+			Addr:          "127.0.0.1:0",
+			User:          user,
+			Pass:          pass,
+			Token:         "abc",
+			AuthChallenge: challenge,
+			Cert:          tempDir + "/cert.cert",
+			Key:           tempDir + "/key.key",
+		})
+	}
+	if _, err := newServer("bogus", pass); err == nil {
+		t.Fatalf("no error for unknown auth challenge")
+	}
+	if _, err := newServer(authChallengeDigest, ""); err == nil {
+		t.Fatalf("no error for digest auth without a password")
+	}
+
+	do := func(s *RPCServer, auth string) *httptest.ResponseRecorder {
+		t.Helper()
+		am := s.authMiddleware(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+		r := httptest.NewRequest("POST", "/", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		am.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, test := range []struct {
+		challenge, wantPrefix string
+	}{
+		{"", "Basic "},
+		{authChallengeBasic, "Basic "},
+		{authChallengeDigest, "Digest "},
+		{authChallengeNone, ""},
+	} {
+		s, err := newServer(test.challenge, pass)
+		if err != nil {
+			t.Fatalf("%q: error creating server: %v", test.challenge, err)
+		}
+		w := do(s, "")
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("%q: wanted HTTP status %d, got %d", test.challenge, http.StatusUnauthorized, w.Code)
+		}
+		got := w.Header().Get("WWW-Authenticate")
+		if (test.wantPrefix == "" && got != "") || !strings.HasPrefix(got, test.wantPrefix) {
+			t.Fatalf("%q: wrong challenge %q", test.challenge, got)
+		}
+		// The token is accepted regardless of the challenge.
+		if w := do(s, "Bearer abc"); w.Code != http.StatusOK {
+			t.Fatalf("%q: token rejected with HTTP status %d", test.challenge, w.Code)
+		}
+	}
+
+	s, err := newServer(authChallengeDigest, pass)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	params := parseAuthParams(strings.TrimPrefix(do(s, "").Header().Get("WWW-Authenticate"), "Digest "))
+	if params["realm"] != authRealm || params["qop"] != "auth" || params["algorithm"] != "SHA-256" {
+		t.Fatalf("wrong digest challenge params: %v", params)
+	}
+	digest := func(pass, nonce, nc string) string {
+		ha1 := sha256Hex(user + ":" + authRealm + ":" + pass)
+		ha2 := sha256Hex("POST:/")
+		resp := sha256Hex(strings.Join([]string{ha1, nonce, nc, "c0ffee", "auth", ha2}, ":"))
+		return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="/", `+
+			`algorithm=SHA-256, qop=auth, nc=%s, cnonce="c0ffee", response="%s"`,
+			user, authRealm, nonce, nc, resp)
+	}
+	nonce := params["nonce"]
+	if w := do(s, digest(pass, nonce, "00000001")); w.Code != http.StatusOK {
+		t.Fatalf("digest auth rejected with HTTP status %d", w.Code)
+	}
+	// A replayed nonce count is rejected, but the next count is accepted.
+	if w := do(s, digest(pass, nonce, "00000001")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed digest auth accepted")
+	}
+	if w := do(s, digest(pass, nonce, "00000002")); w.Code != http.StatusOK {
+		t.Fatalf("next nonce count rejected with HTTP status %d", w.Code)
+	}
+	if w := do(s, digest("wrong", nonce, "00000003")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("digest auth with the wrong password accepted")
+	}
+	// A nonce that was not issued by the server is rejected.
+	if w := do(s, digest(pass, "1-abcd", "00000001")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("digest auth with a forged nonce accepted")
+	}
+	// Basic auth is still accepted.
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	if w := do(s, basic); w.Code != http.StatusOK {
+		t.Fatalf("basic auth rejected with HTTP status %d", w.Code)
+	}
+	// An expired nonce gets a stale challenge.
+	stamp := time.Now().Add(-digestNonceLifetime - time.Minute).Unix()
+	oldNonce := strconv.FormatInt(stamp, 10) + "-" + hex.EncodeToString(s.digest.nonceMAC(stamp))
+	w := do(s, digest(pass, oldNonce, "00000001"))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("digest auth with an expired nonce accepted")
+	}
+	if !strings.Contains(w.Header().Get("WWW-Authenticate"), "stale=true") {
+		t.Fatalf("no stale challenge for an expired nonce: %q", w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestAdminScope(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const token, adminToken = "0f1e2d3c4b5a6978", "8796a5b4c3d2e1f0"
+	cfg := &Config{
+		Core:       &TCore{},
+		Addr:       "127.0.0.1:0",
+		Token:      token,
+		AdminToken: adminToken,
+		Cert:       tempDir + "/cert.cert",
+		Key:        tempDir + "/key.key",
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	tests := []struct {
+		name, header string
+		wantCode     int
+		wantAdmin    bool
+	}{{
+		name:      "admin token",
+		header:    "Bearer " + adminToken,
+		wantCode:  http.StatusOK,
+		wantAdmin: true,
+	}, {
+		name:     "token",
+		header:   "Bearer " + token,
+		wantCode: http.StatusOK,
+	}, {
+		name:     "wrong token",
+		header:   "Bearer " + adminToken[1:],
+		wantCode: http.StatusUnauthorized,
+	}}
+	for _, test := range tests {
+		var admin bool
+		am := s.authMiddleware(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				admin = hasAdminScope(r)
+				w.WriteHeader(http.StatusOK)
+			}))
+		r, _ := http.NewRequest("POST", "", nil)
+		r.Header.Set("Authorization", test.header)
+		w := &tResponseWriter{}
+		am.ServeHTTP(w, r)
+		if w.code != test.wantCode {
+			t.Fatalf("%s: wanted HTTP status %d, got %d", test.name, test.wantCode, w.code)
+		}
+		if admin != test.wantAdmin {
+			t.Fatalf("%s: wanted admin scope %t, got %t", test.name, test.wantAdmin, admin)
+		}
+	}
+
+	// Admin routes require the admin scope. Other routes do not.
+	req, _ := msgjson.NewRequest(1, wsClientsRoute, &RawParams{})
+	payload := s.routeRequest(req, false)
+	if payload.Error == nil || payload.Error.Code != msgjson.RPCAdminRequiredError {
+		t.Fatalf("wrong error for an admin route without the admin scope: %v", payload.Error)
+	}
+	if payload = s.routeRequest(req, true); payload.Error != nil {
+		t.Fatalf("admin route error with the admin scope: %v", payload.Error)
+	}
+	req, _ = msgjson.NewRequest(2, versionRoute, &RawParams{})
+	if payload = s.routeRequest(req, false); payload.Error != nil {
+		t.Fatalf("version error without the admin scope: %v", payload.Error)
+	}
+
+	// The admin token must differ from the token.
+	cfg.AdminToken = token
+	if _, err = New(cfg); err == nil {
+		t.Fatalf("no error for an admin token equal to the token")
+	}
+}
+
+// newTCertificate creates a certificate for the key, signed by the parent
+// certificate and key, or self-signed if parent is nil.
+func newTCertificate(t *testing.T, name string, isCA bool, key *ecdsa.PrivateKey,
+	parent *x509.Certificate, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCertFingerprint(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	caCert := newTCertificate(t, "test CA", true, caKey, nil, nil)
+	caFile := tempDir + "/clientcas.pem"
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatalf("error writing CA file: %v", err)
+	}
+
+	get := func(s *RPCServer) (*certFingerprintResponse, int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/certfingerprint", nil))
+		res := new(certFingerprintResponse)
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatalf("error decoding fingerprint response: %v", err)
+			}
+		}
+		return res, w.Code
+	}
+	fileFingerprint := func(path string) string {
+		t.Helper()
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading cert: %v", err)
+		}
+		block, _ := pem.Decode(b)
+		h := sha256.Sum256(block.Bytes)
+		return hex.EncodeToString(h[:])
+	}
+
+	// No credentials are required.
+	certFile := tempDir + "/cert.cert"
+	s, err := New(&Config{
+		Core: &TCore{},
+		Addr: "127.0.0.1:0",
+		Pass: "pass",
+		Cert: certFile,
+		Key:  tempDir + "/key.key",
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	res, code := get(s)
+	if code != http.StatusOK {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusOK, code)
+	}
+	if res.Cert != fileFingerprint(certFile) || len(res.ClientCAs) != 0 {
+		t.Fatalf("wrong fingerprint response: %+v", res)
+	}
+
+	// The endpoint is rate limited.
+	for i := 0; i < certFingerprintBurst; i++ {
+		_, code = get(s)
+	}
+	if code != http.StatusTooManyRequests {
+		t.Fatalf("wanted HTTP status %d, got %d", http.StatusTooManyRequests, code)
+	}
+
+	// The client CA fingerprints are reported with client certificates.
+	s, err = New(&Config{
+		Core:      &TCore{},
+		Addr:      "127.0.0.1:0",
+		Cert:      certFile,
+		Key:       tempDir + "/key.key",
+		ClientCAs: caFile,
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	res, _ = get(s)
+	if len(res.ClientCAs) != 1 || res.ClientCAs[0] != fileFingerprint(caFile) {
+		t.Fatalf("wrong client CA fingerprints: %v", res.ClientCAs)
+	}
+}
+
+func TestClientCertAuth(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("error generating key: %v", err)
+		}
+		return key
+	}
+	clientCert := func(cert *x509.Certificate, key *ecdsa.PrivateKey) tls.Certificate {
+		return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+	}
+
+	// The CA trusted by the server, and a client cert it signed.
+	caKey := newKey()
+	caCert := newTCertificate(t, "test CA", true, caKey, nil, nil)
+	goodKey := newKey()
+	good := clientCert(newTCertificate(t, "client", false, goodKey, caCert, caKey), goodKey)
+
+	// An unknown CA, and a client cert it signed.
+	otherCAKey := newKey()
+	otherCACert := newTCertificate(t, "other CA", true, otherCAKey, nil, nil)
+	badKey := newKey()
+	bad := clientCert(newTCertificate(t, "client", false, badKey, otherCACert, otherCAKey), badKey)
+
+	caFile := tempDir + "/clientcas.pem"
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatalf("error writing CA file: %v", err)
+	}
+
+	const token = "0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+	startServer := func(token string) (*RPCServer, func()) {
+		t.Helper()
+		s, err := New(&Config{
+			Core:      &TCore{},
+			Addr:      "127.0.0.1:0",
+			Token:     token,
+			Cert:      tempDir + "/cert.cert",
+			Key:       tempDir + "/key.key",
+			ClientCAs: caFile,
+		})
+		if err != nil {
+			t.Fatalf("error creating server: %v", err)
+		}
+		ctx, cancel := context.WithCancel(tCtx)
+		cm := dex.NewConnectionMaster(s)
+		if err := cm.Connect(ctx); err != nil {
+			cancel()
+			t.Fatalf("error starting server: %v", err)
+		}
+		return s, func() {
+			cancel()
+			cm.Disconnect()
+		}
+	}
+
+	reqBody, _ := json.Marshal(&msgjson.Message{ID: 1, Type: msgjson.Request, Route: versionRoute})
+	post := func(addr string, certs []tls.Certificate, auth string) (int, error) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					Certificates: certs,
+					// Only the client certificate is under test.
+					InsecureSkipVerify: true,
+				},
+			},
+		}
+		defer client.CloseIdleConnections()
+		req, _ := http.NewRequest("POST", "https://"+addr, bytes.NewReader(reqBody))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	tests := []struct {
+		name, token, auth string
+		certs             []tls.Certificate
+		wantErr           bool
+		wantCode          int
+	}{{
+		name:     "cert only, ok",
+		certs:    []tls.Certificate{good},
+		wantCode: http.StatusOK,
+	}, {
+		name:    "cert only, unknown cert",
+		certs:   []tls.Certificate{bad},
+		wantErr: true,
+	}, {
+		name:    "cert only, no cert",
+		wantErr: true,
+	}, {
+		name:     "cert and token, ok",
+		token:    token,
+		auth:     "Bearer " + token,
+		certs:    []tls.Certificate{good},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "cert and token, missing token",
+		token:    token,
+		certs:    []tls.Certificate{good},
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:    "cert and token, unknown cert",
+		token:   token,
+		auth:    "Bearer " + token,
+		certs:   []tls.Certificate{bad},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		s, shutdown := startServer(test.token)
+		code, err := post(s.addr, test.certs, test.auth)
+		shutdown()
+		if test.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected handshake error, got HTTP status %d", test.name, code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if code != test.wantCode {
+			t.Fatalf("%s: wanted HTTP status %d, got %d", test.name, test.wantCode, code)
+		}
+	}
+
+	// A CA file without certificates is an error.
+	emptyFile := tempDir + "/empty.pem"
+	if err := ioutil.WriteFile(emptyFile, []byte("not a cert"), 0644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	_, err = New(&Config{
+		Core:      &TCore{},
+		Addr:      "127.0.0.1:0",
+		Cert:      tempDir + "/cert.cert",
+		Key:       tempDir + "/key.key",
+		ClientCAs: emptyFile,
+	})
+	if err == nil {
+		t.Fatalf("no error for invalid client CAs file")
+	}
+}
+
+func TestMinTLSVersion(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	newServer := func(minVersion string) (*RPCServer, error) {
+		return New(&Config{
+			Core:          &TCore{},
+			Addr:          "127.0.0.1:0",
+			Pass:          "abc",
+			Cert:          tempDir + "/cert.cert",
+			Key:           tempDir + "/key.key",
+			MinTLSVersion: minVersion,
+		})
+	}
+
+	// Unknown versions are rejected.
+	for _, v := range []string{"1.1", "1.0", "tls1.3", "1.4"} {
+		if _, err := newServer(v); err == nil {
+			t.Fatalf("no error for minimum TLS version %q", v)
+		}
+	}
+
+	// Default to TLS 1.2.
+	for _, v := range []string{"", "1.2"} {
+		s, err := newServer(v)
+		if err != nil {
+			t.Fatalf("error creating server with minimum TLS version %q: %v", v, err)
+		}
+		if s.tlsConfig.MinVersion != tls.VersionTLS12 {
+			t.Fatalf("wrong minimum TLS version %x for %q", s.tlsConfig.MinVersion, v)
+		}
+	}
+
+	// A TLS 1.3 server refuses a TLS 1.2 client.
+	s, err := newServer("1.3")
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	if s.tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("wrong minimum TLS version %x", s.tlsConfig.MinVersion)
+	}
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	cm := dex.NewConnectionMaster(s)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer cm.Disconnect()
+
+	dial := func(maxVersion uint16) error {
+		conn, err := tls.Dial("tcp", s.addr, &tls.Config{
+			InsecureSkipVerify: true,
+			MaxVersion:         maxVersion,
+		})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	if err := dial(tls.VersionTLS12); err == nil {
+		t.Fatalf("no handshake error for a TLS 1.2 client")
+	}
+	if err := dial(tls.VersionTLS13); err != nil {
+		t.Fatalf("handshake error for a TLS 1.3 client: %v", err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const user, pass = "user", "pass"
+	newServer := func(readTimeout, writeTimeout time.Duration) *RPCServer {
+		t.Helper()
+		s, err := New(&Config{
+			Core:         &TCore{},
+			Addr:         "127.0.0.1:0",
+			User:         user,
+			Pass:         pass,
+			Cert:         tempDir + "/cert.cert",
+			Key:          tempDir + "/key.key",
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+		})
+		if err != nil {
+			t.Fatalf("error creating server: %v", err)
+		}
+		return s
+	}
+
+	// Defaults.
+	s := newServer(0, 0)
+	if s.srv.ReadTimeout != rpcTimeoutSeconds*time.Second || s.srv.WriteTimeout != rpcTimeoutSeconds*time.Second {
+		t.Fatalf("wrong default timeouts. read = %v, write = %v", s.srv.ReadTimeout, s.srv.WriteTimeout)
+	}
+
+	// A websocket connection must outlive the HTTP timeouts.
+	const timeout = 100 * time.Millisecond
+	s = newServer(timeout, 2*timeout)
+	if s.srv.ReadTimeout != timeout || s.srv.WriteTimeout != 2*timeout {
+		t.Fatalf("wrong timeouts. read = %v, write = %v", s.srv.ReadTimeout, s.srv.WriteTimeout)
+	}
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	cm := dex.NewConnectionMaster(s)
+	if err := cm.Connect(ctx); err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer cm.Disconnect()
+
+	dialer := &ws.Dialer{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+	conn, _, err := dialer.Dial("wss://"+s.addr+"/ws", header)
+	if err != nil {
+		t.Fatalf("websocket dial error: %v", err)
+	}
+	defer conn.Close()
+
+	time.Sleep(4 * timeout)
+
+	msg, _ := msgjson.NewRequest(1, "notaroute", nil)
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("websocket write error after HTTP timeouts: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp := new(msgjson.Message)
+	if err := conn.ReadJSON(resp); err != nil {
+		t.Fatalf("websocket read error after HTTP timeouts: %v", err)
+	}
+	if resp.ID != 1 {
+		t.Fatalf("wrong response ID. wanted 1, got %d", resp.ID)
+	}
+}
+
+func TestAuthFailureLogging(t *testing.T) {
+	s, shutdown := newTServer(t, false, "", "abc")
+	defer shutdown()
+
+	// Capture log output for the duration of the test.
+	var logBuf bytes.Buffer
+	defer func(l dex.Logger) { log = l }(log)
+	log = dex.NewLogger("TEST", dex.LevelTrace, &logBuf)
+
+	am := s.authMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	user, pass := "user", "hunter2typo"
+	login := user + ":" + pass
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	r, _ := http.NewRequest("GET", "", nil)
+	r.RemoteAddr = "127.0.0.1:12345"
+	r.Header.Add("Authorization", auth)
+	w := &tResponseWriter{}
+	am.ServeHTTP(w, r)
+	if w.code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized HTTP status, got %d", w.code)
+	}
+
+	logged := logBuf.String()
+	if !strings.Contains(logged, r.RemoteAddr) {
+		t.Fatalf("remote address not logged on auth failure: %q", logged)
+	}
+	for _, secret := range []string{auth, base64.StdEncoding.EncodeToString([]byte(login)), pass} {
+		if strings.Contains(logged, secret) {
+			t.Fatalf("credentials %q found in log output: %q", secret, logged)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const user, pass = "user", "pass"
+	sockPath := filepath.Join(tempDir, "rpc.sock")
+	cfg := &Config{
+		Core:      &TCore{},
+		Addr:      "unix://" + sockPath,
+		User:      user,
+		Pass:      pass,
+		UnixNoTLS: true,
+	}
+
+	// Disabling TLS requires a unix socket.
+	if _, err := New(&Config{Core: &TCore{}, Addr: "127.0.0.1:0", Pass: pass, UnixNoTLS: true}); err == nil {
+		t.Fatalf("no error disabling TLS for a TCP address")
+	}
+
+	for _, noTLS := range []bool{true, false} {
+		cfg.UnixNoTLS = noTLS
+		cfg.Cert, cfg.Key = "", ""
+		if !noTLS {
+			cfg.Cert, cfg.Key = tempDir+"/cert.cert", tempDir+"/key.key"
+		}
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatalf("error creating server: %v", err)
+		}
+		if noTLS && s.tlsConfig != nil {
+			t.Fatalf("TLS configured for UnixNoTLS")
+		}
+
+		// A stale socket file is replaced.
+		if l, err := net.Listen("unix", sockPath); err != nil {
+			t.Fatalf("error creating stale socket: %v", err)
+		} else {
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+			l.Close()
+		}
+
+		ctx, cancel := context.WithCancel(tCtx)
+		cm := dex.NewConnectionMaster(s)
+		if err := cm.Connect(ctx); err != nil {
+			cancel()
+			t.Fatalf("error starting server: %v", err)
+		}
+
+		fi, err := os.Stat(sockPath)
+		if err != nil {
+			t.Fatalf("socket file not found: %v", err)
+		}
+		if perm := fi.Mode().Perm(); perm != 0600 {
+			t.Fatalf("wrong socket file permissions %o", perm)
+		}
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, "unix", sockPath)
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		scheme := "https"
+		if noTLS {
+			scheme = "http"
+		}
+		msg, _ := msgjson.NewRequest(1, versionRoute, new(RawParams))
+		b, _ := json.Marshal(msg)
+		req, _ := http.NewRequest("POST", scheme+"://localhost/", bytes.NewReader(b))
+		// Authentication is still required.
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("wanted HTTP status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+		}
+		req, _ = http.NewRequest("POST", scheme+"://localhost/", bytes.NewReader(b))
+		req.SetBasicAuth(user, pass)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("wanted HTTP status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		cancel()
+		cm.Disconnect()
+		if _, err := os.Stat(sockPath); !os.IsNotExist(err) {
+			t.Fatalf("socket file not removed on shutdown: %v", err)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	const user, pass = "user", "pass"
+	s, shutdown := newTServer(t, false, user, pass)
+	defer shutdown()
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	wg, err := s.Connect(ctx)
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+
+	dialer := &ws.Dialer{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+	conn, _, err := dialer.Dial("wss://"+s.addr+"/ws", header)
+	if err != nil {
+		t.Fatalf("websocket dial error: %v", err)
+	}
+	defer conn.Close()
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server did not shut down")
+	}
+
+	// The websocket client was disconnected with a reason, and the listener is
+	// closed.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	if !ws.IsCloseError(err, ws.CloseNormalClosure) {
+		t.Fatalf("wanted a normal close error, got %v", err)
+	}
+	if reason := err.(*ws.CloseError).Text; reason != "RPC server shutting down" {
+		t.Fatalf("wrong close reason %q", reason)
+	}
+	if c, err := tls.Dial("tcp", s.addr, &tls.Config{InsecureSkipVerify: true}); err == nil {
+		c.Close()
+		t.Fatalf("server still accepting connections after shutdown")
+	}
+}
+
+func TestReloadCert(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	certFile, keyFile := tempDir+"/cert.cert", tempDir+"/key.key"
+	s, err := New(&Config{
+		Core: &TCore{},
+		Addr: "127.0.0.1:0",
+		Pass: "pass",
+		Cert: certFile,
+		Key:  keyFile,
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	if _, err := s.Connect(ctx); err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+
+	servedCert := func() []byte {
+		t.Helper()
+		c, err := tls.Dial("tcp", s.addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("TLS dial error: %v", err)
+		}
+		defer c.Close()
+		return c.ConnectionState().PeerCertificates[0].Raw
+	}
+	// rotate replaces the key pair files with a new pair, and returns the new
+	// certificate.
+	rotate := func(modTime time.Time) []byte {
+		t.Helper()
+		newCert, newKey := tempDir+"/new.cert", tempDir+"/new.key"
+		if err := genCertPair(newCert, newKey, defaultCertOrg, time.Hour, nil); err != nil {
+			t.Fatalf("error generating cert pair: %v", err)
+		}
+		keypair, err := tls.LoadX509KeyPair(newCert, newKey)
+		if err != nil {
+			t.Fatalf("error loading new pair: %v", err)
+		}
+		if err := os.Rename(newCert, certFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(newKey, keyFile); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(certFile, modTime, modTime)
+		os.Chtimes(keyFile, modTime, modTime)
+		return keypair.Certificate[0]
+	}
+
+	origCert := servedCert()
+
+	// Reload on demand.
+	newCert := rotate(time.Now().Add(-time.Hour))
+	if err := s.ReloadCert(); err != nil {
+		t.Fatalf("ReloadCert error: %v", err)
+	}
+	if got := servedCert(); !bytes.Equal(got, newCert) || bytes.Equal(got, origCert) {
+		t.Fatalf("new certificate not served after ReloadCert")
+	}
+
+	// An invalid pair is not swapped in.
+	if err := ioutil.WriteFile(keyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReloadCert(); err == nil {
+		t.Fatalf("no error reloading an invalid key pair")
+	}
+	if !bytes.Equal(servedCert(), newCert) {
+		t.Fatalf("invalid key pair replaced the served certificate")
+	}
+	// And is not retried until a file changes again.
+	if changed, err := s.certs.reloadIfChanged(); changed || err != nil {
+		t.Fatalf("unchanged files reloaded, err = %v", err)
+	}
+
+	// Changed files are reloaded by the periodic check.
+	watchedCert := rotate(time.Now().Add(time.Hour))
+	if changed, err := s.certs.reloadIfChanged(); !changed || err != nil {
+		t.Fatalf("changed files not reloaded, changed = %v, err = %v", changed, err)
+	}
+	if !bytes.Equal(servedCert(), watchedCert) {
+		t.Fatalf("rotated certificate not served")
+	}
+
+	// ReloadCert errors without TLS.
+	if err := (&RPCServer{}).ReloadCert(); err == nil {
+		t.Fatalf("no error reloading the certificate without TLS")
+	}
+}
+
+func TestOriginCheck(t *testing.T) {
+	const srvHost = "dex.example.com:5757"
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{{
+		name: "no origin",
+		want: true,
+	}, {
+		name:   "same origin",
+		origin: "https://" + srvHost,
+		want:   true,
+	}, {
+		name:   "default localhost",
+		origin: "http://localhost:3000",
+		want:   true,
+	}, {
+		name:   "default loopback IP",
+		origin: "http://127.0.0.1:3000",
+		want:   true,
+	}, {
+		name:   "default IPv6 loopback",
+		origin: "http://[::1]:3000",
+		want:   true,
+	}, {
+		name:   "default cross-origin",
+		origin: "https://evil.example.com",
+	}, {
+		name:   "null origin",
+		origin: "null",
+	}, {
+		name:    "allowed",
+		allowed: []string{"https://app.example.com:8080"},
+		origin:  "https://APP.example.com:8080",
+		want:    true,
+	}, {
+		name:    "allowed wrong scheme",
+		allowed: []string{"https://app.example.com:8080"},
+		origin:  "http://app.example.com:8080",
+	}, {
+		name:    "allowed list excludes localhost",
+		allowed: []string{"https://app.example.com"},
+		origin:  "http://localhost:3000",
+	}, {
+		name:    "allowed list still allows same origin",
+		allowed: []string{"https://app.example.com"},
+		origin:  "https://" + srvHost,
+		want:    true,
+	}, {
+		name:    "any",
+		allowed: []string{"*"},
+		origin:  "https://evil.example.com",
+		want:    true,
+	}}
+	for _, test := range tests {
+		checkOrigin, err := newOriginCheck(test.allowed)
+		if err != nil {
+			t.Fatalf("%s: newOriginCheck error: %v", test.name, err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "https://"+srvHost+"/ws", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if got := checkOrigin(r); got != test.want {
+			t.Fatalf("%s: wanted %v, got %v", test.name, test.want, got)
+		}
+	}
+
+	if _, err := newOriginCheck([]string{"app.example.com"}); err == nil {
+		t.Fatalf("no error for an allowed origin without a scheme")
+	}
+
+	// Websocket upgrades are allowed or rejected by origin.
+	const user, pass = "user", "pass"
+	s, shutdown := newTServer(t, true, user, pass)
+	defer shutdown()
+	dialer := &ws.Dialer{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	for _, test := range []struct {
+		origin string
+		want   bool
+	}{{"", true}, {"https://localhost", true}, {"https://evil.example.com", false}} {
+		header := http.Header{}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+		if test.origin != "" {
+			header.Set("Origin", test.origin)
+		}
+		conn, resp, err := dialer.Dial("wss://"+s.addr+"/ws", header)
+		if test.want {
+			if err != nil {
+				t.Fatalf("origin %q: websocket dial error: %v", test.origin, err)
+			}
+			conn.Close()
+			continue
+		}
+		if err == nil {
+			conn.Close()
+			t.Fatalf("origin %q: websocket connection not rejected", test.origin)
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("origin %q: expected status %d, got %v", test.origin, http.StatusForbidden, resp)
+		}
+	}
+}
+
+func TestWriteJSONGzip(t *testing.T) {
+	large := strings.Repeat("a", gzipMinSize)
+	tests := []struct {
+		name           string
+		thing          string
+		acceptEncoding string
+		wantGzip       bool
+	}{{
+		name:           "large gzip",
+		thing:          large,
+		acceptEncoding: "gzip, deflate",
+		wantGzip:       true,
+	}, {
+		name:           "large gzip with quality",
+		thing:          large,
+		acceptEncoding: "deflate;q=1.0, GZIP;q=0.5",
+		wantGzip:       true,
+	}, {
+		name:           "large gzip refused",
+		thing:          large,
+		acceptEncoding: "gzip;q=0",
+	}, {
+		name:  "large no Accept-Encoding",
+		thing: large,
+	}, {
+		name:           "small",
+		thing:          "a",
+		acceptEncoding: "gzip",
+	}}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		writeJSONWithStatus(w, r, test.thing, http.StatusTeapot)
+		if w.Code != http.StatusTeapot {
+			t.Fatalf("%s: wrong status code %d", test.name, w.Code)
+		}
+		body := w.Body.Bytes()
+		gzipped := w.Header().Get("Content-Encoding") == "gzip"
+		if gzipped != test.wantGzip {
+			t.Fatalf("%s: wanted gzip = %v, got %v", test.name, test.wantGzip, gzipped)
+		}
+		if gzipped {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("%s: gzip reader error: %v", test.name, err)
+			}
+			if body, err = ioutil.ReadAll(gz); err != nil {
+				t.Fatalf("%s: gzip read error: %v", test.name, err)
+			}
+		}
+		var got string
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("%s: JSON decode error: %v", test.name, err)
+		}
+		if got != test.thing {
+			t.Fatalf("%s: wrong response body", test.name)
+		}
+	}
+}
+
+func TestGeneratedCert(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cfg := &Config{
+		Core: &TCore{},
+		Addr: "127.0.0.1:0",
+		Pass: "pass",
+		Cert: tempDir + "/cert.cert",
+		Key:  tempDir + "/key.key",
+	}
+	readCert := func() *x509.Certificate {
+		t.Helper()
+		pemCert, err := ioutil.ReadFile(cfg.Cert)
+		if err != nil {
+			t.Fatalf("error reading cert: %v", err)
+		}
+		block, _ := pem.Decode(pemCert)
+		if block == nil {
+			t.Fatalf("no PEM block in cert file")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("error parsing cert: %v", err)
+		}
+		return cert
+	}
+	checkCert := func(org string, validity time.Duration) {
+		t.Helper()
+		cert := readCert()
+		if len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != org {
+			t.Fatalf("wanted organization %q, got %v", org, cert.Subject.Organization)
+		}
+		if diff := time.Until(cert.NotAfter) - validity; diff > time.Minute || diff < -time.Minute {
+			t.Fatalf("wanted a certificate valid for %v, expires %v", validity, cert.NotAfter)
+		}
+	}
+
+	// Defaults.
+	if _, err := New(cfg); err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	checkCert(defaultCertOrg, defaultCertValidity)
+
+	// The options do not affect an existing pair.
+	cfg.CertOrg, cfg.CertValidity = "test org", 48*time.Hour
+	if _, err := New(cfg); err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	checkCert(defaultCertOrg, defaultCertValidity)
+
+	os.Remove(cfg.Cert)
+	os.Remove(cfg.Key)
+	if _, err := New(cfg); err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	checkCert("test org", 48*time.Hour)
+
+	cfg.CertValidity = -time.Hour
+	if _, err := New(cfg); err == nil {
+		t.Fatalf("no error for negative certificate validity")
+	}
+}
+
+func TestCertAltNames(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cfg := &Config{
+		Core:     &TCore{},
+		Addr:     "dexc.example.com:5757",
+		Pass:     "pass",
+		Cert:     tempDir + "/cert.cert",
+		Key:      tempDir + "/key.key",
+		AltNames: []string{"rpc.example.com", "192.0.2.10"},
+	}
+	if _, err := New(cfg); err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	pemCert, err := ioutil.ReadFile(cfg.Cert)
+	if err != nil {
+		t.Fatalf("error reading cert: %v", err)
+	}
+	block, _ := pem.Decode(pemCert)
+	if block == nil {
+		t.Fatalf("no PEM block in cert file")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing cert: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	verify := func(name string) error {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots})
+		return err
+	}
+	for _, name := range []string{"rpc.example.com", "192.0.2.10", "dexc.example.com", "localhost", "127.0.0.1"} {
+		if err := verify(name); err != nil {
+			t.Fatalf("certificate not valid for %s: %v", name, err)
+		}
+	}
+	if verify("other.example.com") == nil {
+		t.Fatalf("certificate valid for an unconfigured name")
+	}
+
+	// Unix sockets and unspecified addresses are not added.
+	for _, addr := range []string{"unix:///tmp/rpc.sock", "0.0.0.0:5757", "[::]:5757", ":5757"} {
+		names := certAltNames(&Config{Addr: addr, AltNames: []string{"a.example.com"}})
+		if len(names) != 1 || names[0] != "a.example.com" {
+			t.Fatalf("wrong alt names %v for address %s", names, addr)
+		}
+	}
+}
+
+func TestShutdownRoute(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	const user, pass = "user", "pass"
+	s, err := New(&Config{
+		Core:     &TCore{},
+		Addr:     "127.0.0.1:0",
+		User:     user,
+		Pass:     pass,
+		Cert:     tempDir + "/cert.cert",
+		Key:      tempDir + "/key.key",
+		Shutdown: cancel,
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	wg, err := s.Connect(ctx)
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+
+	msg, _ := msgjson.NewRequest(1, shutdownRoute, nil)
+	b, _ := json.Marshal(msg)
+	req, _ := http.NewRequest(http.MethodPost, "https://"+s.addr, bytes.NewReader(b))
+	req.SetBasicAuth(user, pass)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	defer resp.Body.Close()
+	respMsg := new(msgjson.Message)
+	if err := json.NewDecoder(resp.Body).Decode(respMsg); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	payload, err := respMsg.Response()
+	if err != nil {
+		t.Fatalf("error decoding response payload: %v", err)
+	}
+	var res string
+	if err := verifyResponse(payload, &res, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server did not shut down")
+	}
+}
+
+func TestReady(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "rpcservertest")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ready := make(chan string, 1)
+	s, err := New(&Config{
+		Core: &TCore{},
+		Addr: "127.0.0.1:0",
+		Pass: "pass",
+		Cert: tempDir + "/cert.cert",
+		Key:  tempDir + "/key.key",
+		Ready: func(addr string) {
+			ready <- addr
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(tCtx)
+	defer cancel()
+	cm := dex.NewConnectionMaster(s)
+	go func() {
+		if err := cm.Connect(ctx); err != nil {
+			t.Errorf("error starting server: %v", err)
+		}
+	}()
+	defer cm.Disconnect()
+
+	var addr string
+	select {
+	case addr = <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server not ready")
+	}
+	if strings.HasSuffix(addr, ":0") {
+		t.Fatalf("ready with the unbound address %s", addr)
+	}
+
+	// The server accepts requests as soon as it is ready.
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + addr + "/health")
+	if err != nil {
+		t.Fatalf("health request error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong health status %d", resp.StatusCode)
+	}
+}
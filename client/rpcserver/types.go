@@ -4,22 +4,40 @@
 package rpcserver
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"decred.org/dcrdex/client/asset"
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex"
 	"decred.org/dcrdex/dex/config"
 	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/order"
+	"github.com/decred/slog"
 )
 
 // An orderID is a 256 bit number encoded as a hex string.
 const orderIdLen = 2 * order.OrderIDSize // 2 * 32
 
+const (
+	// defaultOrdersN is the number of orders returned by the orders route if
+	// no limit is specified.
+	defaultOrdersN = 50
+	// maxOrdersN is the maximum number of orders that may be requested from
+	// the orders route.
+	maxOrdersN = 500
+)
+
 var (
 	// errArgs is wrapped when arguments to the known command cannot be parsed.
 	errArgs = errors.New("unable to parse arguments")
@@ -31,11 +49,106 @@ type RawParams struct {
 	Args   []string           `json:"args"`
 }
 
+// UnmarshalJSON decodes the params, accepting JSON numbers as well as strings
+// in args. A number is decoded as a json.Number and kept as its literal text,
+// so an amount above 2^53 is not rounded as it would be by a float64.
+func (p *RawParams) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		PWArgs []encode.PassBytes `json:"PWArgs"`
+		Args   []json.RawMessage  `json:"args"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var args []string
+	if raw.Args != nil {
+		args = make([]string, 0, len(raw.Args))
+	}
+	for i, rawArg := range raw.Args {
+		dec := json.NewDecoder(bytes.NewReader(rawArg))
+		dec.UseNumber()
+		var arg interface{}
+		if err := dec.Decode(&arg); err != nil {
+			return err
+		}
+		switch a := arg.(type) {
+		case string:
+			args = append(args, a)
+		case json.Number:
+			args = append(args, a.String())
+		default:
+			return fmt.Errorf("argument %d is not a string or number", i)
+		}
+	}
+	p.PWArgs, p.Args = raw.PWArgs, args
+	return nil
+}
+
+// healthResponse is the body of a response from the /health endpoint.
+type healthResponse struct {
+	OK       bool `json:"ok"`
+	LoggedIn bool `json:"loggedIn"`
+}
+
+// certFingerprintResponse is the response to a /certfingerprint request. Each
+// fingerprint is the hex-encoded SHA-256 hash of a DER-encoded certificate.
+type certFingerprintResponse struct {
+	Cert      string   `json:"cert"`
+	ClientCAs []string `json:"clientCAs,omitempty"`
+}
+
+// logLevelResponse is the response to a loglevel request.
+type logLevelResponse struct {
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+// pongResponse is the response to a ping request.
+type pongResponse struct {
+	Time uint64 `json:"time"`
+}
+
+// walletResult is the result of opening or closing one of several wallets.
+type walletResult struct {
+	AssetID uint32 `json:"assetID"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newWalletResult(assetID uint32, err error) *walletResult {
+	res := &walletResult{AssetID: assetID, OK: err == nil}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
 // versionResponse holds a semver version JSON object.
 type versionResponse struct {
-	Major uint32 `json:"major"`
-	Minor uint32 `json:"minor"`
-	Patch uint32 `json:"patch"`
+	Major        uint32        `json:"major"`
+	Minor        uint32        `json:"minor"`
+	Patch        uint32        `json:"patch"`
+	App          *appVersion   `json:"app,omitempty"`
+	Routes       []string      `json:"routes"`
+	Capabilities *capabilities `json:"capabilities"`
+}
+
+// capabilities are the optional features of the RPC server, which depend on
+// its configuration.
+type capabilities struct {
+	Batch   bool `json:"batch"`
+	TLS     bool `json:"tls"`
+	MTLS    bool `json:"mtls"`
+	Metrics bool `json:"metrics"`
+	Admin   bool `json:"admin"`
+	Raw     bool `json:"raw"`
+}
+
+// appVersion is the version of the client application serving the RPC
+// requests.
+type appVersion struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
 }
 
 // String satisfies the Stringer interface.
@@ -43,9 +156,79 @@ func (vr versionResponse) String() string {
 	return fmt.Sprintf("%d.%d.%d", vr.Major, vr.Minor, vr.Patch)
 }
 
-// getFeeResponse is used when responding to the getfee route.
+// routeHelp is the structured help for a route, for clients that render usage
+// themselves.
+type routeHelp struct {
+	Route   string     `json:"route"`
+	Summary string     `json:"summary"`
+	PWArgs  []*argHelp `json:"pwArgs,omitempty"`
+	Args    []*argHelp `json:"args"`
+	Returns string     `json:"returns,omitempty"`
+}
+
+// argHelp describes a route's argument.
+type argHelp struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+// getFeeResponse is used when responding to the getfee route. Fee is in the
+// fee asset's smallest unit, and Conventional is the same amount in the
+// conventional unit. The conversion fields are empty if the fee asset is not
+// registered.
 type getFeeResponse struct {
-	Fee uint64 `json:"fee"`
+	Fee              uint64  `json:"fee"`
+	Conventional     float64 `json:"conventional"`
+	Units            string  `json:"units,omitempty"`
+	ConventionalUnit string  `json:"conventionalUnit,omitempty"`
+	ConversionFactor uint64  `json:"conversionFactor,omitempty"`
+}
+
+// feeRateResponse is used when responding to the feerate route. FeeRate is in
+// the asset's smallest unit per byte.
+type feeRateResponse struct {
+	FeeRate uint64 `json:"feeRate"`
+	Units   string `json:"units,omitempty"`
+}
+
+// supportedAssetResult is an asset in the response to the supportedassets
+// route.
+type supportedAssetResult struct {
+	ID        uint32 `json:"id"`
+	Symbol    string `json:"symbol"`
+	Name      string `json:"name"`
+	Driver    bool   `json:"driver"`
+	HasWallet bool   `json:"hasWallet"`
+	Open      bool   `json:"open"`
+	Running   bool   `json:"running"`
+}
+
+// backupResponse is used when responding to the backup route.
+type backupResponse struct {
+	Path     string `json:"path"`
+	Checksum string `json:"checksum"`
+}
+
+// retryPolicyResponse is used when responding to the getretrypolicy and
+// setretrypolicy routes. Durations are in seconds.
+type retryPolicyResponse struct {
+	MaxRedeemAttempts uint32 `json:"maxRedeemAttempts"`
+	MaxRefundAttempts uint32 `json:"maxRefundAttempts"`
+	Backoff           uint64 `json:"backoff"`
+	MaxBackoff        uint64 `json:"maxBackoff"`
+}
+
+// newRetryPolicyResponse converts a *core.RetryPolicy to a
+// *retryPolicyResponse.
+func newRetryPolicyResponse(p *core.RetryPolicy) *retryPolicyResponse {
+	return &retryPolicyResponse{
+		MaxRedeemAttempts: p.MaxRedeemAttempts,
+		MaxRefundAttempts: p.MaxRefundAttempts,
+		Backoff:           uint64(p.Backoff / time.Second),
+		MaxBackoff:        uint64(p.MaxBackoff / time.Second),
+	}
 }
 
 // tradeResponse is used when responding to the trade route.
@@ -92,10 +275,18 @@ type newWalletForm struct {
 	appPass    encode.PassBytes
 }
 
+// reconfigWalletForm is information necessary to reconfigure a wallet.
+type reconfigWalletForm struct {
+	assetID uint32
+	config  map[string]string
+	appPass encode.PassBytes
+}
+
 // helpForm is information necessary to obtain help.
 type helpForm struct {
 	helpWith         string
 	includePasswords bool
+	structured       bool
 }
 
 // tradeForm combines the application password and the user's trade details.
@@ -110,6 +301,34 @@ type cancelForm struct {
 	orderID dex.Bytes
 }
 
+// backupForm is information necessary to backup the client database.
+type backupForm struct {
+	appPass   encode.PassBytes
+	path      string
+	overwrite bool
+}
+
+// ordersForm is information necessary to fetch a page of the user's orders.
+type ordersForm struct {
+	filter *core.OrderFilter
+	offset int
+	limit  int
+}
+
+// tradeReportForm is information necessary to create a signed trade report.
+type tradeReportForm struct {
+	appPass encode.PassBytes
+	orderID dex.Bytes
+}
+
+// disconnectDEXForm is information necessary to disconnect from a DEX.
+type disconnectDEXForm struct {
+	appPass encode.PassBytes
+	addr    string
+	forget  bool
+	force   bool
+}
+
 // withdrawForm is information necessary to withdraw funds.
 type withdrawForm struct {
 	appPass encode.PassBytes
@@ -118,12 +337,62 @@ type withdrawForm struct {
 	address string
 }
 
+// marketForm is information necessary to look up a market.
+type marketForm struct {
+	host  string
+	base  uint32
+	quote uint32
+}
+
+// feesPaidForm is information necessary to summarize the fees paid at a DEX.
+type feesPaidForm struct {
+	host  string
+	since int64
+}
+
+// validateAddressForm is information necessary to validate an address.
+type validateAddressForm struct {
+	assetID uint32
+	address string
+}
+
 // orderBookForm is information necessary to fetch an order book.
 type orderBookForm struct {
 	host    string
 	base    uint32
 	quote   uint32
 	nOrders uint64
+	levels  uint64
+}
+
+// defaultCandles is the number of candles returned if the count is not
+// specified.
+const defaultCandles = 100
+
+// candlesForm is information necessary to fetch a market's candles.
+type candlesForm struct {
+	host    string
+	base    uint32
+	quote   uint32
+	binSize string
+	count   int
+}
+
+// defaultNotifications is the number of notifications returned if the count is
+// not specified.
+const defaultNotifications = 20
+
+// getNotificationsForm is information necessary to fetch notifications.
+type getNotificationsForm struct {
+	n           int
+	minSeverity db.Severity
+}
+
+// rawForm is information necessary to send a raw request to a DEX server.
+type rawForm struct {
+	host    string
+	route   string
+	payload json.RawMessage
 }
 
 // myOrdersForm is information necessary to fetch the user's orders.
@@ -136,11 +405,16 @@ type myOrdersForm struct {
 // checkNArgs checks that args and pwArgs are the correct length.
 func checkNArgs(params *RawParams, nPWArgs, nArgs []int) error {
 	// For want, one integer indicates an exact match, two are the min and max.
+	// A negative max is no maximum.
 	check := func(have int, want []int) error {
 		if len(want) == 1 {
 			if want[0] != have {
 				return fmt.Errorf("%w: wanted %d but got %d", errArgs, want[0], have)
 			}
+		} else if want[1] < 0 {
+			if have < want[0] {
+				return fmt.Errorf("%w: wanted at least %d but got %d", errArgs, want[0], have)
+			}
 		} else {
 			if have < want[0] || have > want[1] {
 				return fmt.Errorf("%w: wanted between %d and %d but got %d", errArgs, want[0], want[1], have)
@@ -165,6 +439,87 @@ func checkUIntArg(arg, name string, bitSize int) (uint64, error) {
 	return i, nil
 }
 
+// dexAddrSchemes are the URL schemes accepted in a DEX address.
+var dexAddrSchemes = map[string]bool{"https": true, "wss": true, "http": true, "ws": true}
+
+// checkDEXAddrArg validates a DEX address, which is a host with an optional
+// port and scheme, e.g. dex.example.com:7232 or https://dex.example.com:7232.
+// Trailing slashes are removed. Otherwise, the address is returned unchanged.
+func checkDEXAddrArg(addr string) (string, error) {
+	addr = strings.TrimRight(addr, "/")
+	if addr == "" {
+		return "", fmt.Errorf("%w: empty DEX address", errArgs)
+	}
+	// Parse with a scheme so that the host and port are recognized.
+	u, err := url.Parse(addr)
+	if err != nil || !strings.Contains(addr, "://") {
+		u, err = url.Parse("https://" + addr)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid DEX address %q", errArgs, addr)
+		}
+	}
+	if !dexAddrSchemes[u.Scheme] {
+		return "", fmt.Errorf("%w: unsupported scheme %q in DEX address %q", errArgs, u.Scheme, addr)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("%w: missing host in DEX address %q", errArgs, addr)
+	}
+	if u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%w: DEX address %q must be a host and optional port", errArgs, addr)
+	}
+	if port := u.Port(); port != "" || strings.HasSuffix(u.Host, ":") {
+		if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+			return "", fmt.Errorf("%w: invalid port in DEX address %q", errArgs, addr)
+		}
+	}
+	return addr, nil
+}
+
+// checkAssetIDArg parses an asset ID argument, and checks that the asset is
+// supported, i.e. has a registered wallet driver.
+func checkAssetIDArg(arg string) (uint32, error) {
+	id, err := checkUIntArg(arg, "assetID", 32)
+	if err != nil {
+		return 0, err
+	}
+	assetID := uint32(id)
+	if _, err := asset.Info(assetID); err != nil {
+		return 0, fmt.Errorf("%w: unsupported assetID %d. supported assets: %s",
+			errArgs, assetID, supportedAssets())
+	}
+	return assetID, nil
+}
+
+// supportedAssets lists the IDs and symbols of the supported assets, in order
+// of ID, e.g. "0 (btc), 42 (dcr)".
+func supportedAssets() string {
+	assets := asset.Assets()
+	ids := make([]uint32, 0, len(assets))
+	for id := range assets {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) == 0 {
+		return "none"
+	}
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, fmt.Sprintf("%d (%s)", id, assets[id].Symbol))
+	}
+	return strings.Join(strs, ", ")
+}
+
+func checkOrderIDArg(id string) (dex.Bytes, error) {
+	if len(id) != orderIdLen {
+		return nil, fmt.Errorf("%w: orderID has incorrect length", errArgs)
+	}
+	oidB, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid order id hex", errArgs)
+	}
+	return oidB, nil
+}
+
 func checkBoolArg(arg, name string) (bool, error) {
 	b, err := strconv.ParseBool(arg)
 	if err != nil {
@@ -174,7 +529,7 @@ func checkBoolArg(arg, name string) (bool, error) {
 }
 
 func parseHelpArgs(params *RawParams) (*helpForm, error) {
-	if err := checkNArgs(params, []int{0}, []int{0, 2}); err != nil {
+	if err := checkNArgs(params, []int{0}, []int{0, 3}); err != nil {
 		return nil, err
 	}
 	var helpWith string
@@ -189,101 +544,353 @@ func parseHelpArgs(params *RawParams) (*helpForm, error) {
 			return nil, err
 		}
 	}
+	var structured bool
+	if len(params.Args) > 2 {
+		var err error
+		structured, err = checkBoolArg(params.Args[2], "structured")
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &helpForm{
 		helpWith:         helpWith,
 		includePasswords: includePasswords,
+		structured:       structured,
 	}, nil
 }
 
-func parseInitArgs(params *RawParams) (encode.PassBytes, error) {
+// envPWPrefix marks a password argument that names an environment variable
+// of the RPC server's process holding the password, e.g. env:DEXC_APP_PASS.
+const envPWPrefix = "env:"
+
+// checkPWArg resolves a password argument of the form env:VARNAME to the value
+// of the environment variable, if envPrefix is set and VARNAME begins with it.
+// If envPrefix is empty, the argument is a literal password. A leading
+// backslash before env: is removed, so that a literal password beginning with
+// env: can be given as \env:pass. Other passwords are returned as is. An error is
+// returned if the variable is not allowed or not set. name describes the
+// password for the error message, which does not say why the password is
+// unavailable so that clients cannot probe the server's environment. The
+// reason is logged.
+func checkPWArg(pw encode.PassBytes, name, envPrefix string) (encode.PassBytes, error) {
+	if len(pw) > 0 && pw[0] == '\\' && strings.HasPrefix(strings.TrimLeft(string(pw), `\`), envPWPrefix) {
+		return pw[1:], nil
+	}
+	if envPrefix == "" || !strings.HasPrefix(string(pw), envPWPrefix) {
+		return pw, nil
+	}
+	varName := string(pw[len(envPWPrefix):])
+	if !strings.HasPrefix(varName, envPrefix) {
+		log.Debugf("environment variable %q for the %s does not begin with %q", varName, name, envPrefix)
+		return nil, fmt.Errorf("%w: %s unavailable", errArgs, name)
+	}
+	val, ok := os.LookupEnv(varName)
+	if !ok {
+		log.Debugf("environment variable %s for the %s is not set", varName, name)
+		return nil, fmt.Errorf("%w: %s unavailable", errArgs, name)
+	}
+	return encode.PassBytes(val), nil
+}
+
+func parseInitArgs(params *RawParams, envPrefix string) (encode.PassBytes, error) {
 	if err := checkNArgs(params, []int{1}, []int{0}); err != nil {
 		return nil, err
 	}
-	if len(params.PWArgs[0]) == 0 {
+	appPass, err := checkPWArg(params.PWArgs[0], "app password", envPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(appPass) == 0 {
 		return nil, fmt.Errorf("app password cannot be empty")
 	}
-	return params.PWArgs[0], nil
+	return appPass, nil
 }
 
-func parseLoginArgs(params *RawParams) (encode.PassBytes, error) {
+func parseLoginArgs(params *RawParams, envPrefix string) (encode.PassBytes, error) {
 	if err := checkNArgs(params, []int{1}, []int{0}); err != nil {
 		return nil, err
 	}
-	return params.PWArgs[0], nil
+	return checkPWArg(params.PWArgs[0], "app password", envPrefix)
 }
 
-func parseNewWalletArgs(params *RawParams) (*newWalletForm, error) {
-	if err := checkNArgs(params, []int{2}, []int{1, 3}); err != nil {
-		return nil, err
+func parseChangeAppPassArgs(params *RawParams) (oldPass, newPass encode.PassBytes, err error) {
+	if err := checkNArgs(params, []int{2}, []int{0}); err != nil {
+		return nil, nil, err
 	}
-	assetID, err := checkUIntArg(params.Args[0], "assetID", 32)
-	if err != nil {
+	if len(params.PWArgs[1]) == 0 {
+		return nil, nil, fmt.Errorf("new app password cannot be empty")
+	}
+	return params.PWArgs[0], params.PWArgs[1], nil
+}
+
+func parseBackupArgs(params *RawParams) (*backupForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1, 2}); err != nil {
 		return nil, err
 	}
-	req := &newWalletForm{
-		appPass:    params.PWArgs[0],
-		walletPass: params.PWArgs[1],
-		assetID:    uint32(assetID),
+	if params.Args[0] == "" {
+		return nil, fmt.Errorf("%w: backup path cannot be empty", errArgs)
+	}
+	form := &backupForm{
+		appPass: params.PWArgs[0],
+		path:    params.Args[0],
 	}
 	if len(params.Args) > 1 {
-		req.config, err = config.Parse([]byte(params.Args[1]))
+		var err error
+		form.overwrite, err = checkBoolArg(params.Args[1], "overwrite")
 		if err != nil {
-			return nil, fmt.Errorf("config parse error: %v", err)
+			return nil, err
 		}
 	}
-	if len(params.Args) > 2 {
-		cfg := make(map[string]string)
-		err := json.Unmarshal([]byte(params.Args[2]), &cfg)
-		if err != nil {
-			return nil, fmt.Errorf("JSON parse error: %v", err)
+	return form, nil
+}
+
+func parseNewWalletArgs(params *RawParams, envPrefix string) (*newWalletForm, error) {
+	if err := checkNArgs(params, []int{2}, []int{1, -1}); err != nil {
+		return nil, err
+	}
+	assetID, err := checkAssetIDArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	appPass, err := checkPWArg(params.PWArgs[0], "app password", envPrefix)
+	if err != nil {
+		return nil, err
+	}
+	walletPass, err := checkPWArg(params.PWArgs[1], "wallet password", envPrefix)
+	if err != nil {
+		return nil, err
+	}
+	req := &newWalletForm{
+		appPass:    appPass,
+		walletPass: walletPass,
+		assetID:    assetID,
+	}
+	req.config, err = parseWalletConfigArgs(params.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// parseWalletConfigArgs parses the optional wallet configuration args that
+// follow the assetID in the newwallet and reconfigwallet routes: the contents
+// of a configuration file, which may be empty, followed by any number of
+// inline settings. Each inline setting is either a JSON-encoded string->string
+// mapping of settings or a single key=value setting, as in a configuration
+// file. Inline settings take precedence over those from the file, and later
+// inline settings over earlier ones. A nil map is returned if there are no
+// args.
+func parseWalletConfigArgs(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	settings, err := config.Parse([]byte(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("config parse error: %v", err)
+	}
+	set := func(key, val string) {
+		if oldVal, found := settings[key]; found {
+			log.Infof("Overriding wallet setting %s=%s with %s", key, oldVal, val)
 		}
-		for key, val := range cfg {
-			if fileVal, found := req.config[key]; found {
-				log.Infof("Overriding config file setting %s=%s with %s", key, fileVal, val)
+		settings[key] = val
+	}
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(strings.TrimSpace(arg), "{") {
+			cfg := make(map[string]string)
+			err := json.Unmarshal([]byte(arg), &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("JSON parse error: %v", err)
+			}
+			for key, val := range cfg {
+				set(key, val)
 			}
-			req.config[key] = val
+			continue
 		}
+		kv := strings.SplitN(arg, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("%w: setting %q is not JSON or key=value", errArgs, arg)
+		}
+		set(key, strings.TrimSpace(kv[1]))
+	}
+	return settings, nil
+}
+
+func parseReconfigWalletArgs(params *RawParams) (*reconfigWalletForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{2, -1}); err != nil {
+		return nil, err
+	}
+	assetID, err := checkAssetIDArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	settings, err := parseWalletConfigArgs(params.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	req := &reconfigWalletForm{
+		appPass: params.PWArgs[0],
+		assetID: assetID,
+		config:  settings,
 	}
 	return req, nil
 }
 
-func parseOpenWalletArgs(params *RawParams) (*openWalletForm, error) {
+func parseOpenWalletArgs(params *RawParams, envPrefix string) (*openWalletForm, error) {
 	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
 		return nil, err
 	}
-	assetID, err := checkUIntArg(params.Args[0], "assetID", 32)
+	assetID, err := checkAssetIDArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	appPass, err := checkPWArg(params.PWArgs[0], "app password", envPrefix)
 	if err != nil {
 		return nil, err
 	}
-	req := &openWalletForm{appPass: params.PWArgs[0], assetID: uint32(assetID)}
+	req := &openWalletForm{appPass: appPass, assetID: assetID}
 	return req, nil
 }
 
+func parseOpenAllWalletsArgs(params *RawParams) (encode.PassBytes, error) {
+	if err := checkNArgs(params, []int{1}, []int{0}); err != nil {
+		return nil, err
+	}
+	return params.PWArgs[0], nil
+}
+
 func parseCloseWalletArgs(params *RawParams) (uint32, error) {
 	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
 		return 0, err
 	}
-	assetID, err := checkUIntArg(params.Args[0], "assetID", 32)
+	return checkAssetIDArg(params.Args[0])
+}
+
+func parseWalletStateArgs(params *RawParams) (uint32, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	return checkAssetIDArg(params.Args[0])
+}
+
+func parseWSDisconnectArgs(params *RawParams) (int32, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	id, err := checkUIntArg(params.Args[0], "id", 31)
 	if err != nil {
 		return 0, err
 	}
-	return uint32(assetID), nil
+	return int32(id), nil
+}
+
+// logLevels are the levels accepted by the loglevel route, by name.
+var logLevels = map[string]slog.Level{
+	"trace": slog.LevelTrace,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// logLevelName returns the name of the log level, e.g. "debug".
+func logLevelName(lvl slog.Level) string {
+	switch lvl {
+	case slog.LevelCritical:
+		return "critical"
+	case slog.LevelOff:
+		return "off"
+	}
+	for name, l := range logLevels {
+		if l == lvl {
+			return name
+		}
+	}
+	return lvl.String()
+}
+
+func parseLogLevelArgs(params *RawParams) (slog.Level, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	lvl, found := logLevels[strings.ToLower(params.Args[0])]
+	if !found {
+		return 0, fmt.Errorf("%w: unknown log level %q", errArgs, params.Args[0])
+	}
+	return lvl, nil
+}
+
+func parseRawArgs(params *RawParams) (*rawForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{2, 3}); err != nil {
+		return nil, err
+	}
+	host, err := checkDEXAddrArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	route := params.Args[1]
+	if route == "" {
+		return nil, fmt.Errorf("%w: empty route", errArgs)
+	}
+	form := &rawForm{host: host, route: route}
+	if len(params.Args) > 2 && params.Args[2] != "" {
+		if !json.Valid([]byte(params.Args[2])) {
+			return nil, fmt.Errorf("%w: payload is not valid JSON", errArgs)
+		}
+		form.payload = json.RawMessage(params.Args[2])
+	}
+	return form, nil
+}
+
+func parseAddressArgs(params *RawParams) (uint32, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	return checkAssetIDArg(params.Args[0])
+}
+
+func parseRescanWalletArgs(params *RawParams) (uint32, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	return checkAssetIDArg(params.Args[0])
+}
+
+func parseFeeRateArgs(params *RawParams) (uint32, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return 0, err
+	}
+	return checkAssetIDArg(params.Args[0])
 }
 
 func parseGetFeeArgs(params *RawParams) (host, cert string, err error) {
 	if err := checkNArgs(params, []int{0}, []int{1, 2}); err != nil {
 		return "", "", err
 	}
+	host, err = checkDEXAddrArg(params.Args[0])
+	if err != nil {
+		return "", "", err
+	}
 	if len(params.Args) == 1 {
-		return params.Args[0], "", nil
+		return host, "", nil
 	}
-	return params.Args[0], params.Args[1], nil
+	return host, params.Args[1], nil
+}
+
+// parseGetDEXConfigArgs parses the getdexconfig arguments, which are the same as
+// those for getfee.
+func parseGetDEXConfigArgs(params *RawParams) (host, cert string, err error) {
+	return parseGetFeeArgs(params)
 }
 
-func parseRegisterArgs(params *RawParams) (*core.RegisterForm, error) {
+func parseRegisterArgs(params *RawParams, envPrefix string) (*core.RegisterForm, error) {
 	if err := checkNArgs(params, []int{1}, []int{2, 3}); err != nil {
 		return nil, err
 	}
+	addr, err := checkDEXAddrArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
 	fee, err := checkUIntArg(params.Args[1], "fee", 64)
 	if err != nil {
 		return nil, err
@@ -292,9 +899,13 @@ func parseRegisterArgs(params *RawParams) (*core.RegisterForm, error) {
 	if len(params.Args) > 2 {
 		cert = params.Args[2]
 	}
+	appPass, err := checkPWArg(params.PWArgs[0], "app password", envPrefix)
+	if err != nil {
+		return nil, err
+	}
 	req := &core.RegisterForm{
-		AppPass: params.PWArgs[0],
-		Addr:    params.Args[0],
+		AppPass: appPass,
+		Addr:    addr,
 		Fee:     fee,
 		Cert:    cert,
 	}
@@ -305,61 +916,73 @@ func parseTradeArgs(params *RawParams) (*tradeForm, error) {
 	if err := checkNArgs(params, []int{1}, []int{8}); err != nil {
 		return nil, err
 	}
-	isLimit, err := checkBoolArg(params.Args[1], "isLimit")
+	form, err := parseOrderFormArgs(params.Args)
 	if err != nil {
 		return nil, err
 	}
-	sell, err := checkBoolArg(params.Args[2], "sell")
+	form.TifNow, err = checkBoolArg(params.Args[7], "immediate")
 	if err != nil {
 		return nil, err
 	}
-	base, err := checkUIntArg(params.Args[3], "base", 32)
+	req := &tradeForm{
+		appPass: params.PWArgs[0],
+		srvForm: form,
+	}
+	return req, nil
+}
+
+func parsePreOrderArgs(params *RawParams) (*core.TradeForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{7}); err != nil {
+		return nil, err
+	}
+	return parseOrderFormArgs(params.Args)
+}
+
+// parseOrderFormArgs parses the host, isLimit, sell, base, quote, qty and rate
+// arguments shared by the trade and preorder routes.
+func parseOrderFormArgs(args []string) (*core.TradeForm, error) {
+	isLimit, err := checkBoolArg(args[1], "isLimit")
 	if err != nil {
 		return nil, err
 	}
-	quote, err := checkUIntArg(params.Args[4], "quote", 32)
+	sell, err := checkBoolArg(args[2], "sell")
 	if err != nil {
 		return nil, err
 	}
-	qty, err := checkUIntArg(params.Args[5], "qty", 64)
+	base, err := checkUIntArg(args[3], "base", 32)
 	if err != nil {
 		return nil, err
 	}
-	rate, err := checkUIntArg(params.Args[6], "rate", 64)
+	quote, err := checkUIntArg(args[4], "quote", 32)
 	if err != nil {
 		return nil, err
 	}
-	tifnow, err := checkBoolArg(params.Args[7], "immediate")
+	qty, err := checkUIntArg(args[5], "qty", 64)
 	if err != nil {
 		return nil, err
 	}
-	req := &tradeForm{
-		appPass: params.PWArgs[0],
-		srvForm: &core.TradeForm{
-			Host:    params.Args[0],
-			IsLimit: isLimit,
-			Sell:    sell,
-			Base:    uint32(base),
-			Quote:   uint32(quote),
-			Qty:     qty,
-			Rate:    rate,
-			TifNow:  tifnow,
-		},
+	rate, err := checkUIntArg(args[6], "rate", 64)
+	if err != nil {
+		return nil, err
 	}
-	return req, nil
+	return &core.TradeForm{
+		Host:    args[0],
+		IsLimit: isLimit,
+		Sell:    sell,
+		Base:    uint32(base),
+		Quote:   uint32(quote),
+		Qty:     qty,
+		Rate:    rate,
+	}, nil
 }
 
 func parseCancelArgs(params *RawParams) (*cancelForm, error) {
 	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
 		return nil, err
 	}
-	id := params.Args[0]
-	if len(id) != orderIdLen {
-		return nil, fmt.Errorf("%w: orderID has incorrect length", errArgs)
-	}
-	oidB, err := hex.DecodeString(id)
+	oidB, err := checkOrderIDArg(params.Args[0])
 	if err != nil {
-		return nil, fmt.Errorf("%w: invalid order id hex", errArgs)
+		return nil, err
 	}
 	return &cancelForm{appPass: params.PWArgs[0], orderID: oidB}, nil
 }
@@ -385,8 +1008,22 @@ func parseWithdrawArgs(params *RawParams) (*withdrawForm, error) {
 	return req, nil
 }
 
+func parseValidateAddressArgs(params *RawParams) (*validateAddressForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{2}); err != nil {
+		return nil, err
+	}
+	assetID, err := checkAssetIDArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &validateAddressForm{
+		assetID: assetID,
+		address: params.Args[1],
+	}, nil
+}
+
 func parseOrderBookArgs(params *RawParams) (*orderBookForm, error) {
-	if err := checkNArgs(params, []int{0}, []int{3, 4}); err != nil {
+	if err := checkNArgs(params, []int{0}, []int{3, 5}); err != nil {
 		return nil, err
 	}
 	base, err := checkUIntArg(params.Args[1], "base", 32)
@@ -397,22 +1034,130 @@ func parseOrderBookArgs(params *RawParams) (*orderBookForm, error) {
 	if err != nil {
 		return nil, err
 	}
-	var nOrders uint64
+	var nOrders, levels uint64
 	if len(params.Args) > 3 {
 		nOrders, err = checkUIntArg(params.Args[3], "nOrders", 64)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if len(params.Args) > 4 {
+		levels, err = checkUIntArg(params.Args[4], "levels", 64)
+		if err != nil {
+			return nil, err
+		}
+	}
 	req := &orderBookForm{
 		host:    params.Args[0],
 		base:    uint32(base),
 		quote:   uint32(quote),
 		nOrders: nOrders,
+		levels:  levels,
 	}
 	return req, nil
 }
 
+func parseMarketArgs(params *RawParams) (*marketForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{3}); err != nil {
+		return nil, err
+	}
+	base, err := checkUIntArg(params.Args[1], "base", 32)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := checkUIntArg(params.Args[2], "quote", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &marketForm{host: params.Args[0], base: uint32(base), quote: uint32(quote)}, nil
+}
+
+func parseFeesPaidArgs(params *RawParams) (*feesPaidForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{1, 2}); err != nil {
+		return nil, err
+	}
+	form := &feesPaidForm{host: params.Args[0]}
+	if len(params.Args) > 1 {
+		since, err := checkUIntArg(params.Args[1], "since", 63)
+		if err != nil {
+			return nil, err
+		}
+		form.since = int64(since)
+	}
+	return form, nil
+}
+
+func parseCandlesArgs(params *RawParams) (*candlesForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{4, 5}); err != nil {
+		return nil, err
+	}
+	base, err := checkUIntArg(params.Args[1], "base", 32)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := checkUIntArg(params.Args[2], "quote", 32)
+	if err != nil {
+		return nil, err
+	}
+	binSize := params.Args[3]
+	if _, ok := core.CandleBinDuration(binSize); !ok {
+		return nil, fmt.Errorf("%w: unsupported binSize %q", errArgs, binSize)
+	}
+	count := uint64(defaultCandles)
+	if len(params.Args) > 4 {
+		count, err = checkUIntArg(params.Args[4], "count", 32)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 || count > core.MaxCandles {
+			return nil, fmt.Errorf("%w: count must be between 1 and %d", errArgs, core.MaxCandles)
+		}
+	}
+	req := &candlesForm{
+		host:    params.Args[0],
+		base:    uint32(base),
+		quote:   uint32(quote),
+		binSize: binSize,
+		count:   int(count),
+	}
+	return req, nil
+}
+
+// noteSeverities are the severities of stored notifications by name.
+var noteSeverities = map[string]db.Severity{
+	db.Success.String():      db.Success,
+	db.WarningLevel.String(): db.WarningLevel,
+	db.ErrorLevel.String():   db.ErrorLevel,
+}
+
+func parseGetNotificationsArgs(params *RawParams) (*getNotificationsForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{0, 2}); err != nil {
+		return nil, err
+	}
+	form := &getNotificationsForm{
+		n:           defaultNotifications,
+		minSeverity: db.Success,
+	}
+	if len(params.Args) > 0 {
+		n, err := checkUIntArg(params.Args[0], "n", 32)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 || n > core.MaxNotifications {
+			return nil, fmt.Errorf("%w: n must be between 1 and %d", errArgs, core.MaxNotifications)
+		}
+		form.n = int(n)
+	}
+	if len(params.Args) > 1 {
+		severity, found := noteSeverities[params.Args[1]]
+		if !found {
+			return nil, fmt.Errorf("%w: unknown severity %q", errArgs, params.Args[1])
+		}
+		form.minSeverity = severity
+	}
+	return form, nil
+}
+
 func parseMyOrdersArgs(params *RawParams) (*myOrdersForm, error) {
 	if err := checkNArgs(params, []int{0}, []int{0, 3}); err != nil {
 		return nil, err
@@ -444,3 +1189,180 @@ func parseMyOrdersArgs(params *RawParams) (*myOrdersForm, error) {
 	}
 	return req, nil
 }
+
+// parseOrdersArgs parses the orders route arguments. With no arguments, the
+// first page of defaultOrdersN active orders is requested.
+func parseOrdersArgs(params *RawParams) (*ordersForm, error) {
+	if err := checkNArgs(params, []int{0}, []int{0, 6}); err != nil {
+		return nil, err
+	}
+	form := &ordersForm{
+		filter: &core.OrderFilter{
+			Statuses: []order.OrderStatus{order.OrderStatusEpoch, order.OrderStatusBooked},
+		},
+		limit: defaultOrdersN,
+	}
+	switch len(params.Args) {
+	case 6:
+		base, err := checkUIntArg(params.Args[4], "base", 32)
+		if err != nil {
+			return nil, err
+		}
+		quote, err := checkUIntArg(params.Args[5], "quote", 32)
+		if err != nil {
+			return nil, err
+		}
+		form.filter.Market = &core.OrderFilterMarket{
+			Base:  uint32(base),
+			Quote: uint32(quote),
+		}
+		fallthrough
+	case 4:
+		if params.Args[3] != "" {
+			form.filter.Hosts = []string{params.Args[3]}
+		}
+		fallthrough
+	case 3:
+		offset, err := checkUIntArg(params.Args[2], "offset", 31)
+		if err != nil {
+			return nil, err
+		}
+		form.offset = int(offset)
+		fallthrough
+	case 2:
+		limit, err := checkUIntArg(params.Args[1], "limit", 32)
+		if err != nil {
+			return nil, err
+		}
+		if limit == 0 || limit > maxOrdersN {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", errArgs, maxOrdersN)
+		}
+		form.limit = int(limit)
+		fallthrough
+	case 1:
+		statuses, err := checkOrderStatusArg(params.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		form.filter.Statuses = statuses
+	case 5:
+		// Received a base ID but no quote ID.
+		return nil, fmt.Errorf("%w: no market quote ID", errArgs)
+	}
+	return form, nil
+}
+
+// checkOrderStatusArg parses the orders route status argument. "active" is
+// epoch and booked orders, and "all" is every status.
+func checkOrderStatusArg(arg string) ([]order.OrderStatus, error) {
+	switch arg {
+	case "active":
+		return []order.OrderStatus{order.OrderStatusEpoch, order.OrderStatusBooked}, nil
+	case "all":
+		return nil, nil
+	}
+	for _, status := range []order.OrderStatus{order.OrderStatusEpoch, order.OrderStatusBooked,
+		order.OrderStatusExecuted, order.OrderStatusCanceled, order.OrderStatusRevoked} {
+		if arg == status.String() {
+			return []order.OrderStatus{status}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown order status %q", errArgs, arg)
+}
+
+func parseSwapCostsArgs(params *RawParams) (dex.Bytes, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return nil, err
+	}
+	return checkOrderIDArg(params.Args[0])
+}
+
+func parseMatchesArgs(params *RawParams) (dex.Bytes, error) {
+	if err := checkNArgs(params, []int{0}, []int{1}); err != nil {
+		return nil, err
+	}
+	return checkOrderIDArg(params.Args[0])
+}
+
+func parseSetRetryPolicyArgs(params *RawParams) (*core.RetryPolicy, error) {
+	if err := checkNArgs(params, []int{0}, []int{4}); err != nil {
+		return nil, err
+	}
+	maxRedeem, err := checkUIntArg(params.Args[0], "maxRedeemAttempts", 32)
+	if err != nil {
+		return nil, err
+	}
+	maxRefund, err := checkUIntArg(params.Args[1], "maxRefundAttempts", 32)
+	if err != nil {
+		return nil, err
+	}
+	// Seconds are limited to 32 bits so the conversion to a time.Duration
+	// cannot overflow.
+	backoff, err := checkUIntArg(params.Args[2], "backoff", 32)
+	if err != nil {
+		return nil, err
+	}
+	maxBackoff, err := checkUIntArg(params.Args[3], "maxBackoff", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &core.RetryPolicy{
+		MaxRedeemAttempts: uint32(maxRedeem),
+		MaxRefundAttempts: uint32(maxRefund),
+		Backoff:           time.Duration(backoff) * time.Second,
+		MaxBackoff:        time.Duration(maxBackoff) * time.Second,
+	}, nil
+}
+
+func parseSetSettingsArgs(params *RawParams) (*core.TradeSettings, error) {
+	if err := checkNArgs(params, []int{0}, []int{2}); err != nil {
+		return nil, err
+	}
+	maxFeeRate, err := checkUIntArg(params.Args[0], "maxFeeRate", 64)
+	if err != nil {
+		return nil, err
+	}
+	maxOrderLots, err := checkUIntArg(params.Args[1], "maxOrderLots", 64)
+	if err != nil {
+		return nil, err
+	}
+	return &core.TradeSettings{
+		MaxFeeRate:   maxFeeRate,
+		MaxOrderLots: maxOrderLots,
+	}, nil
+}
+
+func parseDisconnectDEXArgs(params *RawParams) (*disconnectDEXForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1, 3}); err != nil {
+		return nil, err
+	}
+	addr, err := checkDEXAddrArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	form := &disconnectDEXForm{appPass: params.PWArgs[0], addr: addr}
+	if len(params.Args) > 1 {
+		form.forget, err = checkBoolArg(params.Args[1], "forget")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(params.Args) > 2 {
+		form.force, err = checkBoolArg(params.Args[2], "force")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return form, nil
+}
+
+func parseTradeReportArgs(params *RawParams) (*tradeReportForm, error) {
+	if err := checkNArgs(params, []int{1}, []int{1}); err != nil {
+		return nil, err
+	}
+	oidB, err := checkOrderIDArg(params.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &tradeReportForm{appPass: params.PWArgs[0], orderID: oidB}, nil
+}
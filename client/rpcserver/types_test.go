@@ -5,11 +5,19 @@ package rpcserver
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrdex/dex/order"
 )
 
 func TestCheckNArgs(t *testing.T) {
@@ -53,6 +61,16 @@ func TestCheckNArgs(t *testing.T) {
 		have:      []string{"1", "2", "3", "4", "5", "6"},
 		wantNArgs: []int{2, 5},
 		wantErr:   true,
+	}, {
+		name:      "ok no max",
+		have:      []string{"1", "2", "3", "4", "5", "6"},
+		wantNArgs: []int{2, -1},
+		wantErr:   false,
+	}, {
+		name:      "too few no max",
+		have:      []string{"1"},
+		wantNArgs: []int{2, -1},
+		wantErr:   true,
 	}}
 	for _, test := range tests {
 		pwArgs := make([]encode.PassBytes, len(test.have))
@@ -72,6 +90,151 @@ func TestCheckNArgs(t *testing.T) {
 	}
 }
 
+func TestRawParamsUnmarshal(t *testing.T) {
+	// 2^53 + 1 cannot be represented by a float64.
+	const bigAmt = "9007199254740993"
+	tests := []struct {
+		name     string
+		json     string
+		wantArgs []string
+		wantErr  bool
+	}{{
+		name:     "ok strings",
+		json:     `{"PWArgs":["pass"],"args":["42","` + bigAmt + `"]}`,
+		wantArgs: []string{"42", bigAmt},
+	}, {
+		name:     "ok numbers",
+		json:     `{"PWArgs":["pass"],"args":[42,` + bigAmt + `]}`,
+		wantArgs: []string{"42", bigAmt},
+	}, {
+		name: "ok no args",
+		json: `{"PWArgs":["pass"]}`,
+	}, {
+		name:    "bool arg",
+		json:    `{"args":[true]}`,
+		wantErr: true,
+	}, {
+		name:    "object arg",
+		json:    `{"args":[{"amt":1}]}`,
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		params := new(RawParams)
+		err := json.Unmarshal([]byte(test.json), params)
+		if test.wantErr {
+			if err == nil {
+				t.Fatalf("expected error for test %s", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for test %s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(params.Args, test.wantArgs) {
+			t.Fatalf("wrong args for test %s: %q", test.name, params.Args)
+		}
+		if len(params.PWArgs) != 1 || string(params.PWArgs[0]) != "pass" {
+			t.Fatalf("wrong password args for test %s", test.name)
+		}
+	}
+}
+
+func TestCheckPWArg(t *testing.T) {
+	const envPrefix, envVar = "RPCSERVER_TEST_", "RPCSERVER_TEST_PASS"
+	os.Setenv(envVar, "pass from env")
+	defer os.Unsetenv(envVar)
+	os.Unsetenv(envVar + "_UNSET")
+
+	tests := []struct {
+		name, pw, envPrefix, want string
+		wantErr                   error
+	}{{
+		name:      "plaintext",
+		pw:        "password123",
+		envPrefix: envPrefix,
+		want:      "password123",
+	}, {
+		name:      "env",
+		pw:        "env:" + envVar,
+		envPrefix: envPrefix,
+		want:      "pass from env",
+	}, {
+		name: "env disabled",
+		pw:   "env:" + envVar,
+		want: "env:" + envVar,
+	}, {
+		name:      "env var without the prefix",
+		pw:        "env:PATH",
+		envPrefix: envPrefix,
+		wantErr:   errArgs,
+	}, {
+		name:      "env unset",
+		pw:        "env:" + envVar + "_UNSET",
+		envPrefix: envPrefix,
+		wantErr:   errArgs,
+	}, {
+		name:      "no env var name",
+		pw:        "env:",
+		envPrefix: envPrefix,
+		wantErr:   errArgs,
+	}, {
+		name:      "escaped env",
+		pw:        `\env:` + envVar,
+		envPrefix: envPrefix,
+		want:      "env:" + envVar,
+	}, {
+		name: "escaped env with env disabled",
+		pw:   `\env:` + envVar,
+		want: "env:" + envVar,
+	}, {
+		name:      "escaped backslash",
+		pw:        `\\env:` + envVar,
+		envPrefix: envPrefix,
+		want:      `\env:` + envVar,
+	}, {
+		name:      "backslash without env",
+		pw:        `\password`,
+		envPrefix: envPrefix,
+		want:      `\password`,
+	}}
+	for _, test := range tests {
+		pw, err := checkPWArg(encode.PassBytes(test.pw), "app password", test.envPrefix)
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if string(pw) != test.want {
+			t.Fatalf("%s: wanted %q, got %q", test.name, test.want, pw)
+		}
+	}
+
+	// The error does not reveal whether a variable exists.
+	_, errUnset := checkPWArg(encode.PassBytes("env:"+envVar+"_UNSET"), "app password", envPrefix)
+	_, errNoName := checkPWArg(encode.PassBytes("env:"), "app password", envPrefix)
+	_, errNotAllowed := checkPWArg(encode.PassBytes("env:PATH"), "app password", envPrefix)
+	if errUnset.Error() != errNoName.Error() || errUnset.Error() != errNotAllowed.Error() ||
+		strings.Contains(errUnset.Error(), envVar) {
+		t.Fatalf("revealing env password errors %q, %q, and %q", errUnset, errNoName, errNotAllowed)
+	}
+
+	// The password parsers resolve env passwords.
+	params := &RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("env:" + envVar)}}
+	appPass, err := parseLoginArgs(params, envPrefix)
+	if err != nil || string(appPass) != "pass from env" {
+		t.Fatalf("parseLoginArgs: wrong password %q, err = %v", appPass, err)
+	}
+	params.PWArgs = append(params.PWArgs, encode.PassBytes("env:"+envVar+"_UNSET"))
+	params.Args = []string{"42"}
+	if _, err := parseNewWalletArgs(params, envPrefix); !errors.Is(err, errArgs) {
+		t.Fatalf("parseNewWalletArgs: expected errArgs for an unset wallet password variable, got %v", err)
+	}
+}
+
 func TestParseNewWalletArgs(t *testing.T) {
 	paramsWithAssetID := func(id string) *RawParams {
 		pw := encode.PassBytes("password123")
@@ -93,9 +256,13 @@ func TestParseNewWalletArgs(t *testing.T) {
 		name:    "assetID is not int",
 		params:  paramsWithAssetID("42.1"),
 		wantErr: errArgs,
+	}, {
+		name:    "unsupported assetID",
+		params:  paramsWithAssetID("99999"),
+		wantErr: errArgs,
 	}}
 	for _, test := range tests {
-		nwf, err := parseNewWalletArgs(test.params)
+		nwf, err := parseNewWalletArgs(test.params, "")
 		if err != nil {
 			if !errors.Is(err, test.wantErr) {
 				t.Fatalf("unexpected error %v for test %s",
@@ -122,6 +289,66 @@ func TestParseNewWalletArgs(t *testing.T) {
 	}
 }
 
+func TestParseWalletConfigArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{{
+		name: "no args",
+	}, {
+		name: "file only",
+		args: []string{"rpcuser=user\nrpclisten=127.0.0.1:19557"},
+		want: map[string]string{"rpcuser": "user", "rpclisten": "127.0.0.1:19557"},
+	}, {
+		name: "inline only",
+		args: []string{"", "rpcuser=user", "RPCPassword = pass=word"},
+		want: map[string]string{"rpcuser": "user", "rpcpassword": "pass=word"},
+	}, {
+		name: "inline overrides file",
+		args: []string{"rpcuser=user\nrpclisten=127.0.0.1:19557", "rpcuser=other"},
+		want: map[string]string{"rpcuser": "other", "rpclisten": "127.0.0.1:19557"},
+	}, {
+		name: "later overrides earlier",
+		args: []string{"", `{"account":"default","rpcuser":"user"}`, "account=trading"},
+		want: map[string]string{"account": "trading", "rpcuser": "user"},
+	}, {
+		name:    "not key=value",
+		args:    []string{"", "rpcuser"},
+		wantErr: true,
+	}, {
+		name:    "empty key",
+		args:    []string{"", "=user"},
+		wantErr: true,
+	}, {
+		name:    "bad JSON",
+		args:    []string{"", `{"rpcuser": user}`},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		settings, err := parseWalletConfigArgs(test.args)
+		if test.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(test.want) == 0 {
+			if len(settings) != 0 {
+				t.Fatalf("%s: expected no settings, got %v", test.name, settings)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(settings, test.want) {
+			t.Fatalf("%s: wanted %v, got %v", test.name, test.want, settings)
+		}
+	}
+}
+
 func TestParseOpenWalletArgs(t *testing.T) {
 	paramsWithAssetID := func(id string) *RawParams {
 		pw := encode.PassBytes("password123")
@@ -140,9 +367,13 @@ func TestParseOpenWalletArgs(t *testing.T) {
 		name:    "assetID is not int",
 		params:  paramsWithAssetID("42.1"),
 		wantErr: errArgs,
+	}, {
+		name:    "unsupported assetID",
+		params:  paramsWithAssetID("99999"),
+		wantErr: errArgs,
 	}}
 	for _, test := range tests {
-		owf, err := parseOpenWalletArgs(test.params)
+		owf, err := parseOpenWalletArgs(test.params, "")
 		if err != nil {
 			if !errors.Is(err, test.wantErr) {
 				t.Fatalf("unexpected error %v for test %s",
@@ -202,6 +433,23 @@ func TestCheckUIntArg(t *testing.T) {
 	}
 }
 
+func TestCheckAssetIDArg(t *testing.T) {
+	id, err := checkAssetIDArg("42")
+	if err != nil || id != 42 {
+		t.Fatalf("unexpected result %d, %v for a supported asset", id, err)
+	}
+	for _, arg := range []string{"99999", "-1", "dcr"} {
+		if _, err := checkAssetIDArg(arg); !errors.Is(err, errArgs) {
+			t.Fatalf("expected errArgs for %q, got %v", arg, err)
+		}
+	}
+	// The supported assets are listed, in order.
+	_, err = checkAssetIDArg("99999")
+	if !strings.Contains(err.Error(), "0 (btc), 42 (dcr)") {
+		t.Fatalf("supported assets not listed: %v", err)
+	}
+}
+
 func TestCheckBoolArg(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -244,6 +492,63 @@ func TestCheckBoolArg(t *testing.T) {
 	}
 }
 
+func TestCheckDEXAddrArg(t *testing.T) {
+	tests := []struct {
+		addr, want string
+		wantErr    bool
+	}{
+		// Valid addresses are unchanged.
+		{addr: "dex", want: "dex"},
+		{addr: "dex.example.com:7232", want: "dex.example.com:7232"},
+		{addr: "localhost:7232", want: "localhost:7232"},
+		{addr: "127.0.0.1:7232", want: "127.0.0.1:7232"},
+		{addr: "[::1]:7232", want: "[::1]:7232"},
+		{addr: "https://dex.example.com:7232", want: "https://dex.example.com:7232"},
+		{addr: "wss://dex.example.com", want: "wss://dex.example.com"},
+		// Trailing slashes are removed.
+		{addr: "dex.example.com:7232/", want: "dex.example.com:7232"},
+		{addr: "https://dex.example.com:7232//", want: "https://dex.example.com:7232"},
+		// Malformed addresses.
+		{addr: "", wantErr: true},
+		{addr: "/", wantErr: true},
+		{addr: "dex.example.com:", wantErr: true},
+		{addr: "dex.example.com:0", wantErr: true},
+		{addr: "dex.example.com:99999", wantErr: true},
+		{addr: "dex.example.com:port", wantErr: true},
+		{addr: "dex example.com:7232", wantErr: true},
+		{addr: "ftp://dex.example.com:7232", wantErr: true},
+		{addr: "https://:7232", wantErr: true},
+		{addr: "https://dex.example.com:7232/ws", wantErr: true},
+		{addr: "dex.example.com:7232?x=1", wantErr: true},
+		{addr: "user@dex.example.com:7232", wantErr: true},
+	}
+	for _, test := range tests {
+		addr, err := checkDEXAddrArg(test.addr)
+		if test.wantErr {
+			if !errors.Is(err, errArgs) {
+				t.Fatalf("%q: expected errArgs, got %v", test.addr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", test.addr, err)
+		}
+		if addr != test.want {
+			t.Fatalf("%q: wanted %q, got %q", test.addr, test.want, addr)
+		}
+	}
+
+	// The register and getfee parsers validate the address.
+	_, err := parseRegisterArgs(&RawParams{PWArgs: []encode.PassBytes{encode.PassBytes("abc")}, Args: []string{"dex:", "1000"}}, "")
+	if !errors.Is(err, errArgs) {
+		t.Fatalf("parseRegisterArgs: expected errArgs for a bad address, got %v", err)
+	}
+	host, _, err := parseGetFeeArgs(&RawParams{Args: []string{"dex.example.com:7232/"}})
+	if err != nil || host != "dex.example.com:7232" {
+		t.Fatalf("parseGetFeeArgs: wrong host %q, err = %v", host, err)
+	}
+}
+
 func TestParseRegisterArgs(t *testing.T) {
 	paramsWithFee := func(fee string) *RawParams {
 		pw := encode.PassBytes("password123")
@@ -264,7 +569,7 @@ func TestParseRegisterArgs(t *testing.T) {
 		wantErr: errArgs,
 	}}
 	for _, test := range tests {
-		reg, err := parseRegisterArgs(test.params)
+		reg, err := parseRegisterArgs(test.params, "")
 		if err != nil {
 			if !errors.Is(err, test.wantErr) {
 				t.Fatalf("unexpected error %v for test %s",
@@ -304,10 +609,18 @@ func TestParseHelpArgs(t *testing.T) {
 		name: "ok help with include passwords",
 		args: []string{"thing", "true"},
 		want: &helpForm{helpWith: "thing", includePasswords: true},
+	}, {
+		name: "ok structured",
+		args: []string{"thing", "false", "true"},
+		want: &helpForm{helpWith: "thing", structured: true},
 	}, {
 		name:    "include passwords not boolean",
 		args:    []string{"thing", "thing2"},
 		wantErr: errArgs,
+	}, {
+		name:    "structured not boolean",
+		args:    []string{"thing", "true", "thing3"},
+		wantErr: errArgs,
 	}}
 	for _, test := range tests {
 		form, err := parseHelpArgs(&RawParams{Args: test.args})
@@ -324,6 +637,9 @@ func TestParseHelpArgs(t *testing.T) {
 		if len(test.args) > 1 && fmt.Sprint(form.includePasswords) != test.args[1] {
 			t.Fatalf("includepasswords doesn't match")
 		}
+		if len(test.args) > 2 && fmt.Sprint(form.structured) != test.args[2] {
+			t.Fatalf("structured doesn't match")
+		}
 	}
 }
 
@@ -533,6 +849,13 @@ func TestParseOrderBookArgs(t *testing.T) {
 	}, {
 		name:   "ok no nOrders",
 		params: &RawParams{Args: []string{"dex", "42", "0"}},
+	}, {
+		name:   "ok with levels",
+		params: &RawParams{Args: []string{"dex", "42", "0", "0", "5"}},
+	}, {
+		name:    "levels not int",
+		params:  &RawParams{Args: []string{"dex", "42", "0", "0", "-5"}},
+		wantErr: errArgs,
 	}, {
 		name:    "base not int",
 		params:  paramsWithArgs("42.1", "0", "1"),
@@ -569,6 +892,121 @@ func TestParseOrderBookArgs(t *testing.T) {
 				t.Fatalf("nOrders doesn't match")
 			}
 		}
+		if len(test.params.Args) > 4 {
+			if fmt.Sprint(res.levels) != test.params.Args[4] {
+				t.Fatalf("levels doesn't match")
+			}
+		}
+	}
+}
+
+func TestParseCandlesArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantCount int
+		wantErr   error
+	}{{
+		name:      "ok default count",
+		args:      []string{"dex", "42", "0", "1h"},
+		wantCount: defaultCandles,
+	}, {
+		name:      "ok with count",
+		args:      []string{"dex", "42", "0", "5m", "20"},
+		wantCount: 20,
+	}, {
+		name:    "unsupported binSize",
+		args:    []string{"dex", "42", "0", "2h"},
+		wantErr: errArgs,
+	}, {
+		name:    "count too large",
+		args:    []string{"dex", "42", "0", "1d", "1001"},
+		wantErr: errArgs,
+	}, {
+		name:    "zero count",
+		args:    []string{"dex", "42", "0", "1d", "0"},
+		wantErr: errArgs,
+	}, {
+		name:    "base not int",
+		args:    []string{"dex", "42.1", "0", "1h"},
+		wantErr: errArgs,
+	}, {
+		name:    "missing binSize",
+		args:    []string{"dex", "42", "0"},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		res, err := parseCandlesArgs(&RawParams{Args: test.args})
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("%s: expected error %v, got %v", test.name, test.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if res.host != "dex" || res.base != 42 || res.quote != 0 || res.binSize != test.args[3] {
+			t.Fatalf("%s: wrong form %+v", test.name, res)
+		}
+		if res.count != test.wantCount {
+			t.Fatalf("%s: wanted count %d, got %d", test.name, test.wantCount, res.count)
+		}
+	}
+}
+
+func TestParseGetNotificationsArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantN        int
+		wantSeverity db.Severity
+		wantErr      error
+	}{{
+		name:         "ok defaults",
+		wantN:        defaultNotifications,
+		wantSeverity: db.Success,
+	}, {
+		name:         "ok with n",
+		args:         []string{"5"},
+		wantN:        5,
+		wantSeverity: db.Success,
+	}, {
+		name:         "ok with severity",
+		args:         []string{"5", "warning"},
+		wantN:        5,
+		wantSeverity: db.WarningLevel,
+	}, {
+		name:    "zero n",
+		args:    []string{"0"},
+		wantErr: errArgs,
+	}, {
+		name:    "n too large",
+		args:    []string{"1001"},
+		wantErr: errArgs,
+	}, {
+		name:    "unstored severity",
+		args:    []string{"5", "poke"},
+		wantErr: errArgs,
+	}, {
+		name:    "too many args",
+		args:    []string{"5", "error", "x"},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		res, err := parseGetNotificationsArgs(&RawParams{Args: test.args})
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("%s: expected error %v, got %v", test.name, test.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if res.n != test.wantN || res.minSeverity != test.wantSeverity {
+			t.Fatalf("%s: wrong form %+v", test.name, res)
+		}
 	}
 }
 
@@ -627,3 +1065,247 @@ func TestMyOrdersArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestParseOrdersArgs(t *testing.T) {
+	paramsWithArgs := func(ss ...string) *RawParams {
+		return &RawParams{Args: ss}
+	}
+	active := []order.OrderStatus{order.OrderStatusEpoch, order.OrderStatusBooked}
+	tests := []struct {
+		name         string
+		params       *RawParams
+		wantLimit    int
+		wantOffset   int
+		wantStatuses []order.OrderStatus
+		wantHost     string
+		wantMarket   *core.OrderFilterMarket
+		wantErr      error
+	}{{
+		name:         "ok no params",
+		params:       paramsWithArgs(),
+		wantLimit:    defaultOrdersN,
+		wantStatuses: active,
+	}, {
+		name:      "ok all statuses",
+		params:    paramsWithArgs("all"),
+		wantLimit: defaultOrdersN,
+	}, {
+		name:         "ok single status and limit",
+		params:       paramsWithArgs("executed", "10"),
+		wantLimit:    10,
+		wantStatuses: []order.OrderStatus{order.OrderStatusExecuted},
+	}, {
+		name:         "ok offset",
+		params:       paramsWithArgs("executed", "10", "100"),
+		wantLimit:    10,
+		wantOffset:   100,
+		wantStatuses: []order.OrderStatus{order.OrderStatusExecuted},
+	}, {
+		name:         "ok blank host",
+		params:       paramsWithArgs("active", "10", "0", ""),
+		wantLimit:    10,
+		wantStatuses: active,
+	}, {
+		name:         "ok with host and market",
+		params:       paramsWithArgs("active", "10", "20", "host", "42", "0"),
+		wantLimit:    10,
+		wantOffset:   20,
+		wantStatuses: active,
+		wantHost:     "host",
+		wantMarket:   &core.OrderFilterMarket{Base: 42, Quote: 0},
+	}, {
+		name:    "unknown status",
+		params:  paramsWithArgs("unknown"),
+		wantErr: errArgs,
+	}, {
+		name:    "limit not uint",
+		params:  paramsWithArgs("all", "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "limit zero",
+		params:  paramsWithArgs("all", "0"),
+		wantErr: errArgs,
+	}, {
+		name:    "limit too large",
+		params:  paramsWithArgs("all", fmt.Sprint(maxOrdersN+1)),
+		wantErr: errArgs,
+	}, {
+		name:    "offset not uint",
+		params:  paramsWithArgs("all", "10", "-1"),
+		wantErr: errArgs,
+	}, {
+		name:    "base but no quote",
+		params:  paramsWithArgs("all", "10", "0", "host", "42"),
+		wantErr: errArgs,
+	}, {
+		name:    "quote not uint32",
+		params:  paramsWithArgs("all", "10", "0", "host", "42", "blue"),
+		wantErr: errArgs,
+	}, {
+		name:    "too many args",
+		params:  paramsWithArgs("all", "10", "0", "host", "42", "0", "1"),
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseOrdersArgs(test.params)
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if form.offset != test.wantOffset || form.limit != test.wantLimit {
+			t.Fatalf("%s: wanted offset %d and limit %d, got %d and %d", test.name,
+				test.wantOffset, test.wantLimit, form.offset, form.limit)
+		}
+		if !reflect.DeepEqual(form.filter.Statuses, test.wantStatuses) {
+			t.Fatalf("%s: wanted statuses %v, got %v", test.name, test.wantStatuses, form.filter.Statuses)
+		}
+		if (test.wantHost == "") != (len(form.filter.Hosts) == 0) ||
+			(len(form.filter.Hosts) > 0 && form.filter.Hosts[0] != test.wantHost) {
+			t.Fatalf("%s: wanted host %q, got %v", test.name, test.wantHost, form.filter.Hosts)
+		}
+		if !reflect.DeepEqual(form.filter.Market, test.wantMarket) {
+			t.Fatalf("%s: wanted market %v, got %v", test.name, test.wantMarket, form.filter.Market)
+		}
+	}
+}
+
+func TestParseSwapCostsArgs(t *testing.T) {
+	paramsWithOrderID := func(orderID string) *RawParams {
+		return &RawParams{Args: []string{orderID}}
+	}
+	tests := []struct {
+		name    string
+		params  *RawParams
+		wantErr error
+	}{{
+		name:   "ok",
+		params: paramsWithOrderID("fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"),
+	}, {
+		name:    "no order ID",
+		params:  &RawParams{},
+		wantErr: errArgs,
+	}, {
+		name:    "order ID incorrect length",
+		params:  paramsWithOrderID("94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"),
+		wantErr: errArgs,
+	}, {
+		name:    "order ID not hex",
+		params:  paramsWithOrderID("zb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"),
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		oid, err := parseSwapCostsArgs(test.params)
+		if err != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %q",
+					err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %q", test.name)
+		}
+		if fmt.Sprint(oid) != test.params.Args[0] {
+			t.Fatalf("order ID doesn't match")
+		}
+	}
+}
+
+func TestParseSetRetryPolicyArgs(t *testing.T) {
+	paramsWithArgs := func(ss ...string) *RawParams {
+		return &RawParams{Args: ss}
+	}
+	tests := []struct {
+		name    string
+		params  *RawParams
+		want    *core.RetryPolicy
+		wantErr error
+	}{{
+		name:   "ok",
+		params: paramsWithArgs("5", "3", "30", "600"),
+		want: &core.RetryPolicy{
+			MaxRedeemAttempts: 5,
+			MaxRefundAttempts: 3,
+			Backoff:           30 * time.Second,
+			MaxBackoff:        10 * time.Minute,
+		},
+	}, {
+		name:    "too few args",
+		params:  paramsWithArgs("5", "3", "30"),
+		wantErr: errArgs,
+	}, {
+		name:    "attempts not uint32",
+		params:  paramsWithArgs("-1", "3", "30", "600"),
+		wantErr: errArgs,
+	}, {
+		name:    "backoff overflows",
+		params:  paramsWithArgs("5", "3", "4294967296", "600"),
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		policy, err := parseSetRetryPolicyArgs(test.params)
+		if err != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %s", err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %s", test.name)
+		}
+		if *policy != *test.want {
+			t.Fatalf("%s: wanted %+v, got %+v", test.name, test.want, policy)
+		}
+	}
+}
+
+func TestParseTradeReportArgs(t *testing.T) {
+	pw := encode.PassBytes("password123")
+	tests := []struct {
+		name    string
+		params  *RawParams
+		wantErr error
+	}{{
+		name: "ok",
+		params: &RawParams{
+			PWArgs: []encode.PassBytes{pw},
+			Args:   []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+		},
+	}, {
+		name: "no password",
+		params: &RawParams{
+			Args: []string{"fb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+		},
+		wantErr: errArgs,
+	}, {
+		name: "bad order ID",
+		params: &RawParams{
+			PWArgs: []encode.PassBytes{pw},
+			Args:   []string{"zb94fe99e4e32200a341f0f1cb33f34a08ac23eedab636e8adb991fa76343e1e"},
+		},
+		wantErr: errArgs,
+	}}
+	for _, test := range tests {
+		form, err := parseTradeReportArgs(test.params)
+		if err != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("unexpected error %v for test %q", err, test.name)
+			}
+			continue
+		}
+		if test.wantErr != nil {
+			t.Fatalf("expected error for test %q", test.name)
+		}
+		if !bytes.Equal(form.appPass, pw) {
+			t.Fatalf("appPass doesn't match")
+		}
+		if fmt.Sprint(form.orderID) != test.params.Args[0] {
+			t.Fatalf("order ID doesn't match")
+		}
+	}
+}
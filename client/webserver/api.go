@@ -364,13 +364,37 @@ func (s *WebServer) apiOrders(w http.ResponseWriter, r *http.Request) {
 		s.writeAPIError(w, "Orders error: %v", err)
 		return
 	}
-	writeJSON(w, &struct {
-		OK     bool          `json:"ok"`
-		Orders []*core.Order `json:"orders"`
-	}{
-		OK:     true,
-		Orders: ords,
+	// There may be many orders, so they are streamed.
+	err = writeJSONList(w, "orders", len(ords), func(i int) interface{} {
+		return ords[i]
 	}, s.indent)
+	if err != nil {
+		s.writeAPIError(w, "Orders error: %v", err)
+	}
+}
+
+// apiBook responds with the order book for a market. A large book is
+// streamed.
+func (s *WebServer) apiBook(w http.ResponseWriter, r *http.Request) {
+	form := new(bookForm)
+	if !readPost(w, r, form) {
+		return
+	}
+	book, err := s.core.Book(form.Host, form.Base, form.Quote)
+	if err != nil {
+		s.writeAPIError(w, "Book error: %v", err)
+		return
+	}
+	miniOrders := func(key string, ords []*core.MiniOrder) jsonList {
+		return jsonList{key: key, n: len(ords), elem: func(i int) interface{} {
+			return ords[i]
+		}}
+	}
+	err = writeJSONLists(w, s.indent, miniOrders("sells", book.Sells),
+		miniOrders("buys", book.Buys), miniOrders("epoch", book.Epoch))
+	if err != nil {
+		s.writeAPIError(w, "Book error: %v", err)
+	}
 }
 
 // apiOrder responds with data for an order.
@@ -590,6 +590,26 @@ func (c *TCore) SyncBook(dexAddr string, base, quote uint32) (*core.BookFeed, er
 	return c.feed, nil
 }
 
+func (c *TCore) Book(dexAddr string, base, quote uint32) (*core.OrderBook, error) {
+	c.orderMtx.Lock()
+	defer c.orderMtx.Unlock()
+	book := new(core.OrderBook)
+	for _, ord := range c.sells {
+		book.Sells = append(book.Sells, ord)
+	}
+	for _, ord := range c.buys {
+		book.Buys = append(book.Buys, ord)
+	}
+	sort.Slice(book.Buys, func(i, j int) bool { return book.Buys[i].Rate > book.Buys[j].Rate })
+	sort.Slice(book.Sells, func(i, j int) bool { return book.Sells[i].Rate < book.Sells[j].Rate })
+	return book, nil
+}
+
+func (c *TCore) SyncBookSince(dexAddr string, base, quote uint32, since uint64) (*core.BookFeed, bool, error) {
+	feed, err := c.SyncBook(dexAddr, base, quote)
+	return feed, false, err
+}
+
 var numBuys = 80
 var numSells = 80
 var tokenCounter uint32
@@ -63,6 +63,13 @@ type tradeForm struct {
 	Order *core.TradeForm  `json:"order"`
 }
 
+// bookForm identifies the market for an order book request.
+type bookForm struct {
+	Host  string `json:"host"`
+	Base  uint32 `json:"base"`
+	Quote uint32 `json:"quote"`
+}
+
 type cancelForm struct {
 	Pass    encode.PassBytes `json:"pw"`
 	OrderID dex.Bytes        `json:"orderID"`
@@ -4,6 +4,7 @@
 package webserver
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
@@ -85,6 +86,7 @@ type clientCore interface {
 	Logout() error
 	Orders(*core.OrderFilter) ([]*core.Order, error)
 	Order(oid dex.Bytes) (*core.Order, error)
+	Book(dex string, base, quote uint32) (*core.OrderBook, error)
 }
 
 var _ clientCore = (*core.Core)(nil)
@@ -247,6 +249,7 @@ func New(core clientCore, addr string, logger dex.Logger, reloadHTML bool) (*Web
 		r.Post("/defaultwalletcfg", s.apiDefaultWalletCfg)
 		r.Post("/orders", s.apiOrders)
 		r.Post("/order", s.apiOrder)
+		r.Post("/book", s.apiBook)
 	})
 
 	// Files
@@ -473,3 +476,105 @@ func writeJSONWithStatus(w http.ResponseWriter, thing interface{}, code int, ind
 		log.Infof("JSON encode error: %v", err)
 	}
 }
+
+// streamFlushInterval is the number of list elements written between flushes
+// of a response written by writeJSONLists.
+const streamFlushInterval = 100
+
+// jsonList is a list of n elements, returned by elem, to be written by
+// writeJSONLists under the key.
+type jsonList struct {
+	key  string
+	n    int
+	elem func(i int) interface{}
+}
+
+// writeJSONList writes an object of the form {"ok":true,"<key>":[...]} to the
+// ResponseWriter, where the list has n elements returned by elem. See
+// writeJSONLists.
+func writeJSONList(w http.ResponseWriter, key string, n int, elem func(i int) interface{}, indent bool) error {
+	return writeJSONLists(w, indent, jsonList{key: key, n: n, elem: elem})
+}
+
+// writeJSONLists writes an object of the form
+// {"ok":true,"<key1>":[...],"<key2>":[...]} to the ResponseWriter. Unlike
+// writeJSON, the elements are encoded one at a time and written every
+// streamFlushInterval elements, so a large response is never held in memory
+// and the client starts receiving it sooner. Since the response code cannot be
+// changed once writing starts, nothing is written until the first chunk is
+// encoded, and an error encoding it is returned, leaving the caller to respond
+// with an error instead. After that point, an encoding or write error is
+// logged, and the truncated response is left for the client to reject.
+func writeJSONLists(w http.ResponseWriter, indent bool, lists ...jsonList) error {
+	var elemBuf, out bytes.Buffer
+	enc := json.NewEncoder(&elemBuf)
+	if indent {
+		enc.SetIndent("        ", "    ")
+	}
+
+	var started bool
+	flush := func() error {
+		if !started {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		_, err := w.Write(out.Bytes())
+		out.Reset()
+		if err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	start, listStart, sep, elemPrefix, end := `{"ok":true`, ",", ":", "", "}\n"
+	if indent {
+		start, listStart, sep, elemPrefix, end = "{\n    \"ok\": true", ",\n    ", ": ", "\n        ", "\n}\n"
+	}
+	out.WriteString(start)
+	var written int
+	for _, list := range lists {
+		keyB, err := json.Marshal(list.key)
+		if err != nil {
+			return err
+		}
+		out.WriteString(listStart)
+		out.Write(keyB)
+		out.WriteString(sep + "[")
+		for i := 0; i < list.n; i++ {
+			elemBuf.Reset()
+			if err := enc.Encode(list.elem(i)); err != nil {
+				err = fmt.Errorf("error encoding %s element %d: %w", list.key, i, err)
+				if !started {
+					return err
+				}
+				log.Errorf("Error writing response: %v", err)
+				return nil
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteString(elemPrefix)
+			out.Write(bytes.TrimSuffix(elemBuf.Bytes(), []byte{'\n'}))
+			written++
+			if written%streamFlushInterval == 0 {
+				if err := flush(); err != nil {
+					log.Errorf("Error writing %s: %v", list.key, err)
+					return nil
+				}
+			}
+		}
+		if indent && list.n > 0 {
+			out.WriteString("\n    ")
+		}
+		out.WriteByte(']')
+	}
+	out.WriteString(end)
+	if err := flush(); err != nil {
+		log.Errorf("Error writing response: %v", err)
+	}
+	return nil
+}
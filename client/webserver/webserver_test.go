@@ -11,6 +11,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -79,6 +80,9 @@ func (c *TCore) Login(pw []byte) (*core.LoginResult, error)                  { r
 func (c *TCore) SyncBook(dex string, base, quote uint32) (*core.BookFeed, error) {
 	return c.syncFeed, c.syncErr
 }
+func (c *TCore) SyncBookSince(dex string, base, quote uint32, since uint64) (*core.BookFeed, bool, error) {
+	return c.syncFeed, false, c.syncErr
+}
 func (c *TCore) Book(dex string, base, quote uint32) (*core.OrderBook, error) {
 	return &core.OrderBook{}, nil
 }
@@ -568,3 +572,177 @@ func TestGetOrderIDCtx(t *testing.T) {
 		}
 	}
 }
+
+// tSyntheticOrders makes n orders with a few matches each.
+func tSyntheticOrders(n int) []*core.Order {
+	ords := make([]*core.Order, 0, n)
+	for i := 0; i < n; i++ {
+		ord := &core.Order{
+			Host:        "somedex.tld:7232",
+			BaseID:      42,
+			BaseSymbol:  "dcr",
+			QuoteSymbol: "btc",
+			MarketID:    "dcr_btc",
+			Type:        order.LimitOrderType,
+			ID:          encode.RandomBytes(32),
+			Stamp:       uint64(i),
+			Sig:         encode.RandomBytes(72),
+			Status:      order.OrderStatusExecuted,
+			Qty:         1e8 * uint64(i+1),
+			Sell:        i%2 == 0,
+			FeesPaid:    &core.FeeBreakdown{Swap: 5000},
+			Rate:        1e6,
+		}
+		for j := 0; j < 3; j++ {
+			ord.Matches = append(ord.Matches, &core.Match{
+				MatchID: encode.RandomBytes(32),
+				Rate:    ord.Rate,
+				Qty:     1e8,
+				Swap:    encode.RandomBytes(36),
+				Redeem:  encode.RandomBytes(36),
+			})
+		}
+		ords = append(ords, ord)
+	}
+	return ords
+}
+
+func TestWriteJSONList(t *testing.T) {
+	log = tLogger
+	for _, n := range []int{0, 1, streamFlushInterval + 1} {
+		ords := tSyntheticOrders(n)
+		for _, indent := range []bool{false, true} {
+			// The streamed response is identical to the buffered one.
+			want := httptest.NewRecorder()
+			writeJSON(want, &struct {
+				OK     bool          `json:"ok"`
+				Orders []*core.Order `json:"orders"`
+			}{
+				OK:     true,
+				Orders: ords,
+			}, indent)
+			rec := httptest.NewRecorder()
+			err := writeJSONList(rec, "orders", len(ords), func(i int) interface{} {
+				return ords[i]
+			}, indent)
+			if err != nil {
+				t.Fatalf("%d orders, indent = %t: writeJSONList error: %v", n, indent, err)
+			}
+			if !bytes.Equal(rec.Body.Bytes(), want.Body.Bytes()) {
+				t.Fatalf("%d orders, indent = %t: wrong response. wanted\n%s\ngot\n%s",
+					n, indent, want.Body.Bytes(), rec.Body.Bytes())
+			}
+			if rec.Header().Get("Content-Type") != want.Header().Get("Content-Type") {
+				t.Fatalf("wrong content type %q", rec.Header().Get("Content-Type"))
+			}
+		}
+	}
+
+	// Several lists, as for a book, match the buffered response, and each
+	// element is encoded once.
+	book := &core.OrderBook{Epoch: []*core.MiniOrder{}}
+	for i := 0; i < streamFlushInterval+1; i++ {
+		book.Sells = append(book.Sells, &core.MiniOrder{Qty: 1, Rate: float64(i), Sell: true, Token: "abcd"})
+		book.Buys = append(book.Buys, &core.MiniOrder{Qty: 2, Rate: float64(i), Token: "efgh"})
+	}
+	for _, indent := range []bool{false, true} {
+		want := httptest.NewRecorder()
+		writeJSON(want, &struct {
+			OK bool `json:"ok"`
+			*core.OrderBook
+		}{
+			OK:        true,
+			OrderBook: book,
+		}, indent)
+		var calls int
+		miniOrders := func(key string, ords []*core.MiniOrder) jsonList {
+			return jsonList{key: key, n: len(ords), elem: func(i int) interface{} {
+				calls++
+				return ords[i]
+			}}
+		}
+		rec := httptest.NewRecorder()
+		err := writeJSONLists(rec, indent, miniOrders("sells", book.Sells),
+			miniOrders("buys", book.Buys), miniOrders("epoch", book.Epoch))
+		if err != nil {
+			t.Fatalf("book, indent = %t: writeJSONLists error: %v", indent, err)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), want.Body.Bytes()) {
+			t.Fatalf("book, indent = %t: wrong response. wanted\n%s\ngot\n%s",
+				indent, want.Body.Bytes(), rec.Body.Bytes())
+		}
+		if calls != len(book.Sells)+len(book.Buys) {
+			t.Fatalf("book, indent = %t: %d elements encoded %d times", indent, len(book.Sells)+len(book.Buys), calls)
+		}
+	}
+
+	unencodableAt := func(bad int) func(i int) interface{} {
+		return func(i int) interface{} {
+			if i == bad {
+				return make(chan int)
+			}
+			return i
+		}
+	}
+
+	// An element that cannot be encoded in the first chunk is caught before
+	// anything is written.
+	rec := httptest.NewRecorder()
+	err := writeJSONList(rec, "things", 3, unencodableAt(2), false)
+	if err == nil {
+		t.Fatalf("no error for unencodable element")
+	}
+	if rec.Body.Len() > 0 || rec.Header().Get("Content-Type") != "" {
+		t.Fatalf("response written for unencodable element: %s", rec.Body.Bytes())
+	}
+
+	// After the first chunk is written, the response is truncated instead.
+	rec = httptest.NewRecorder()
+	err = writeJSONList(rec, "things", streamFlushInterval+2, unencodableAt(streamFlushInterval+1), false)
+	if err != nil {
+		t.Fatalf("error returned after writing started: %v", err)
+	}
+	if rec.Code != http.StatusOK || json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("expected a truncated response, got code %d, body %s", rec.Code, rec.Body.Bytes())
+	}
+}
+
+// tDiscardWriter is an http.ResponseWriter that discards the body, so that
+// the benchmarks measure only the memory used to write the response.
+type tDiscardWriter struct {
+	header http.Header
+}
+
+func (w *tDiscardWriter) Header() http.Header         { return w.header }
+func (w *tDiscardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *tDiscardWriter) WriteHeader(int)             {}
+
+const tBenchOrders = 20000
+
+func BenchmarkWriteJSON(b *testing.B) {
+	ords := tSyntheticOrders(tBenchOrders)
+	w := &tDiscardWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		writeJSON(w, &struct {
+			OK     bool          `json:"ok"`
+			Orders []*core.Order `json:"orders"`
+		}{
+			OK:     true,
+			Orders: ords,
+		}, false)
+	}
+}
+
+func BenchmarkWriteJSONList(b *testing.B) {
+	ords := tSyntheticOrders(tBenchOrders)
+	w := &tDiscardWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		writeJSONList(w, "orders", len(ords), func(i int) interface{} {
+			return ords[i]
+		}, false)
+	}
+}
@@ -9,12 +9,15 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
 	"decred.org/dcrdex/dex/ws"
 )
@@ -32,25 +35,58 @@ var (
 	cidCounter int32
 )
 
+// authTimeoutReason is sent in the Close control message to a client
+// disconnected by the auth timeout.
+const authTimeoutReason = "no valid request received"
+
 // wsClient is a persistent websocket connection to a client.
 type wsClient struct {
 	*ws.WSLink
 	cid int32
-
+	// active is set atomically when the client's first request is handled
+	// successfully.
+	active uint32
+	// minSeverity is the db.Severity below which notifications are not sent
+	// to the client, set atomically by the 'notifications' route. The default
+	// of zero, db.Ignorable, admits all notifications.
+	minSeverity uint32
+
+	// feedLoopMtx guards feedLoops, which is modified by the client's message
+	// handlers and read by the Server's Stats and Clients methods.
 	feedLoopMtx sync.RWMutex
-	feedLoop    *dex.StartStopWaiter
+	feedLoops   map[marketLoad]*dex.StartStopWaiter // keyed by subscribed market
 }
 
 func newWSClient(ip string, conn ws.Connection, hndlr func(msg *msgjson.Message) *msgjson.Error, logger dex.Logger) *wsClient {
 	return &wsClient{
-		WSLink: ws.NewWSLink(ip, conn, pingPeriod, hndlr, logger),
-		cid:    atomic.AddInt32(&cidCounter, 1),
+		WSLink:    ws.NewWSLink(ip, conn, pingPeriod, hndlr, logger),
+		cid:       atomic.AddInt32(&cidCounter, 1),
+		feedLoops: make(map[marketLoad]*dex.StartStopWaiter),
+	}
+}
+
+// stopFeedLoop stops the marketSyncer for the market, if running. The
+// feedLoopMtx must be locked.
+func (cl *wsClient) stopFeedLoop(market marketLoad) {
+	if feedLoop := cl.feedLoops[market]; feedLoop != nil {
+		feedLoop.Stop()
+		feedLoop.WaitForShutdown()
+		delete(cl.feedLoops, market)
+	}
+}
+
+// stopFeedLoops stops all of the client's marketSyncers. The feedLoopMtx must
+// be locked.
+func (cl *wsClient) stopFeedLoops() {
+	for market := range cl.feedLoops {
+		cl.stopFeedLoop(market)
 	}
 }
 
 // Core specifies the needed methods for Server to operate. Satisfied by *core.Core.
 type Core interface {
 	SyncBook(dex string, base, quote uint32) (*core.BookFeed, error)
+	SyncBookSince(dex string, base, quote uint32, since uint64) (*core.BookFeed, bool, error)
 	AckNotes([]dex.Bytes)
 }
 
@@ -62,8 +98,24 @@ type Server struct {
 	log  dex.Logger
 	wg   sync.WaitGroup
 
+	// clientsMtx guards clients, which is modified by each connect goroutine
+	// and read by Notify, Stats, Clients and DisconnectClient.
 	clientsMtx sync.RWMutex
 	clients    map[int32]*wsClient
+
+	// drainMtx guards draining and synchronizes the handlers WaitGroup. New
+	// messages are not handled once draining is set.
+	drainMtx sync.RWMutex
+	draining bool
+	handlers sync.WaitGroup
+
+	// checkOrigin decides whether a request with an Origin header may be
+	// upgraded. If nil, only same-origin requests are upgraded.
+	checkOrigin func(r *http.Request) bool
+
+	// authTimeout is how long a client has to make a successful request. It
+	// is disabled if zero.
+	authTimeout time.Duration
 }
 
 // New returns a new websocket Server.
@@ -75,12 +127,130 @@ func New(core Core, log dex.Logger) *Server {
 	}
 }
 
+// SetOriginCheck sets the function that decides whether a websocket request
+// from a browser, which sets the Origin header, may be upgraded. By default,
+// only same-origin requests are upgraded. SetOriginCheck must be called before
+// HandleConnect.
+func (s *Server) SetOriginCheck(checkOrigin func(r *http.Request) bool) {
+	s.checkOrigin = checkOrigin
+}
+
+// SetAuthTimeout sets how long a newly connected client has to send a request
+// that is handled successfully, which shows that the client is a working
+// consumer of the API, before it is disconnected. This keeps idle sockets from
+// lingering. The timeout is disabled if zero, the default. SetAuthTimeout must
+// be called before HandleConnect.
+func (s *Server) SetAuthTimeout(timeout time.Duration) {
+	s.authTimeout = timeout
+}
+
+// Stats returns the number of connected clients and the number of those with a
+// running market syncer.
+func (s *Server) Stats() (clients, syncers int) {
+	s.clientsMtx.RLock()
+	defer s.clientsMtx.RUnlock()
+	for _, cl := range s.clients {
+		cl.feedLoopMtx.RLock()
+		if len(cl.feedLoops) > 0 {
+			syncers++
+		}
+		cl.feedLoopMtx.RUnlock()
+	}
+	return len(s.clients), syncers
+}
+
+// ClientInfo describes a connected websocket client.
+type ClientInfo struct {
+	ID int32  `json:"id"`
+	IP string `json:"ip"`
+	// Feeds is the number of markets the client is subscribed to.
+	Feeds int `json:"feeds"`
+}
+
+// Clients returns the connected clients, ordered by ID.
+func (s *Server) Clients() []*ClientInfo {
+	s.clientsMtx.RLock()
+	clients := make([]*ClientInfo, 0, len(s.clients))
+	for _, cl := range s.clients {
+		cl.feedLoopMtx.RLock()
+		clients = append(clients, &ClientInfo{
+			ID:    cl.cid,
+			IP:    cl.IP(),
+			Feeds: len(cl.feedLoops),
+		})
+		cl.feedLoopMtx.RUnlock()
+	}
+	s.clientsMtx.RUnlock()
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+	return clients
+}
+
+// DisconnectClient disconnects the client with the ID, with the reason sent in
+// the Close control message if not empty. The client's market syncers are
+// stopped before it is disconnected. Other clients, including those subscribed
+// to the same markets, are not affected. False is returned if there is no
+// connected client with the ID.
+func (s *Server) DisconnectClient(id int32, reason string) bool {
+	s.clientsMtx.RLock()
+	cl, found := s.clients[id]
+	s.clientsMtx.RUnlock()
+	if !found {
+		return false
+	}
+	s.log.Infof("Disconnecting websocket client %d at %s", id, cl.IP())
+	cl.feedLoopMtx.Lock()
+	cl.stopFeedLoops()
+	cl.feedLoopMtx.Unlock()
+	if reason != "" {
+		cl.DisconnectWithReason(reason)
+	} else {
+		cl.Disconnect()
+	}
+	return true
+}
+
 // Shutdown gracefully shuts down all connected clients, waiting for them to
 // disconnect and any running goroutines and message handlers to return.
 func (s *Server) Shutdown() {
+	s.disconnectAll("")
+}
+
+// Drain stops handling new messages, responding to them with an error instead,
+// and waits for in-flight message handlers to return, or for ctx to be
+// canceled. All clients are then disconnected with reason sent in the Close
+// control message, and Drain waits for them as Shutdown does. Clients do not
+// have to respond to the Close message.
+func (s *Server) Drain(ctx context.Context, reason string) {
+	s.drainMtx.Lock()
+	s.draining = true
+	s.drainMtx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.log.Warnf("Disconnecting websocket clients with message handlers still running")
+	}
+
+	s.disconnectAll(reason)
+}
+
+// disconnectAll disconnects all clients, with the reason if not empty, and
+// waits for them.
+func (s *Server) disconnectAll(reason string) {
 	s.clientsMtx.Lock()
 	for _, cl := range s.clients {
-		cl.Disconnect()
+		if reason != "" {
+			cl.DisconnectWithReason(reason)
+		} else {
+			cl.Disconnect()
+		}
 	}
 	s.clientsMtx.Unlock()
 	// Each upgraded connection handler must return. This also waits for running
@@ -103,7 +273,7 @@ func (s *Server) HandleConnect(ctx context.Context, w http.ResponseWriter, r *ht
 	if err == nil && host != "" {
 		ip = host
 	}
-	wsConn, err := ws.NewConnection(w, r, pongWait)
+	wsConn, err := ws.NewConnectionWithOriginCheck(w, r, pongWait, s.checkOrigin)
 	if err != nil {
 		s.log.Errorf("ws connection error: %v", err)
 		return
@@ -142,7 +312,7 @@ func (s *Server) connect(ctx context.Context, conn ws.Connection, ip string) {
 	err := cm.Connect(ctx)
 	if err != nil {
 		s.clientsMtx.Unlock()
-		s.log.Errorf("websocketHandler client Connect: %v")
+		s.log.Errorf("websocketHandler client Connect: %v", err)
 		return
 	}
 
@@ -151,12 +321,20 @@ func (s *Server) connect(ctx context.Context, conn ws.Connection, ip string) {
 	s.clients[cl.cid] = cl
 	s.clientsMtx.Unlock()
 
+	if s.authTimeout > 0 {
+		authTimer := time.AfterFunc(s.authTimeout, func() {
+			if atomic.LoadUint32(&cl.active) == 0 {
+				s.log.Infof("Disconnecting websocket client %d at %s with no request handled in %v",
+					cl.cid, ip, s.authTimeout)
+				cl.DisconnectWithReason(authTimeoutReason)
+			}
+		})
+		defer authTimer.Stop()
+	}
+
 	defer func() {
 		cl.feedLoopMtx.Lock()
-		if cl.feedLoop != nil {
-			cl.feedLoop.Stop()
-			cl.feedLoop.WaitForShutdown()
-		}
+		cl.stopFeedLoops()
 		cl.feedLoopMtx.Unlock()
 
 		s.clientsMtx.Lock()
@@ -168,16 +346,29 @@ func (s *Server) connect(ctx context.Context, conn ws.Connection, ip string) {
 	s.log.Tracef("Disconnected websocket client %s", ip)
 }
 
-// Notify sends a notification to the websocket client.
+// severityNote is a notification payload with a severity, e.g. a
+// core.Notification, which is filtered by each client's minimum severity.
+type severityNote interface {
+	Severity() db.Severity
+}
+
+// Notify sends a notification to the websocket clients. If the payload has a
+// severity, it is only sent to the clients that have not filtered out that
+// severity with the 'notifications' route. Other notifications are sent to
+// every client.
 func (s *Server) Notify(route string, payload interface{}) {
 	msg, err := msgjson.NewNotification(route, payload)
 	if err != nil {
 		s.log.Errorf("notification encoding error: %v", err)
 		return
 	}
+	note, filtered := payload.(severityNote)
 	s.clientsMtx.RLock()
 	defer s.clientsMtx.RUnlock()
 	for _, cl := range s.clients {
+		if filtered && uint32(note.Severity()) < atomic.LoadUint32(&cl.minSeverity) {
+			continue
+		}
 		if err = cl.Send(msg); err != nil {
 			s.log.Warnf("Failed to send %v notification to client %v at %v: %v",
 				msg.Route, cl.cid, cl.IP(), err)
@@ -189,12 +380,25 @@ func (s *Server) Notify(route string, payload interface{}) {
 // the route.
 func (s *Server) handleMessage(conn *wsClient, msg *msgjson.Message) *msgjson.Error {
 	s.log.Tracef("message of type %d received for route %s", msg.Type, msg.Route)
+	s.drainMtx.RLock()
+	if s.draining {
+		s.drainMtx.RUnlock()
+		return msgjson.NewError(msgjson.TryAgainLaterError, "server is shutting down")
+	}
+	s.handlers.Add(1)
+	s.drainMtx.RUnlock()
+	defer s.handlers.Done()
+
 	if msg.Type == msgjson.Request {
 		handler, found := wsHandlers[msg.Route]
 		if !found {
 			return msgjson.NewError(msgjson.UnknownMessageType, "unknown route '"+msg.Route+"'")
 		}
-		return handler(s, conn, msg)
+		msgErr := handler(s, conn, msg)
+		if msgErr == nil {
+			atomic.StoreUint32(&conn.active, 1)
+		}
+		return msgErr
 	}
 	// Web server doesn't send requests, only responses and notifications, so
 	// a response-type message from a client is an error.
@@ -207,9 +411,13 @@ type wsHandler func(*Server, *wsClient, *msgjson.Message) *msgjson.Error
 // wsHandlers is the map used by the server to locate the router handler for a
 // request.
 var wsHandlers = map[string]wsHandler{
-	"loadmarket": wsLoadMarket,
-	"unmarket":   wsUnmarket,
-	"acknotes":   wsAckNotes,
+	"loadmarket":    wsLoadMarket,
+	"loadmarkets":   wsLoadMarkets,
+	"unmarket":      wsUnmarket,
+	"acknotes":      wsAckNotes,
+	"ping":          wsPing,
+	"subscriptions": wsSubscriptions,
+	"notifications": wsNotifications,
 }
 
 // marketLoad is sent by websocket clients to subscribe to a market and request
@@ -220,6 +428,16 @@ type marketLoad struct {
 	Quote uint32 `json:"quote"`
 }
 
+// marketLoadRequest is a market to load in a 'loadmarket' or 'loadmarkets'
+// request. Since is optional, and is the sequence number of the last book
+// update the client has for the market, e.g. when resubscribing after a brief
+// disconnect. If the updates that followed it can be replayed, they are sent
+// instead of the book.
+type marketLoadRequest struct {
+	marketLoad
+	Since uint64 `json:"since,omitempty"`
+}
+
 // marketSyncer is used to synchronize market subscriptions. The marketSyncer
 // manages a map of clients who are subscribed to the market, and distributes
 // order book updates when received.
@@ -242,7 +460,10 @@ func newMarketSyncer(cl *wsClient, feed *core.BookFeed, log dex.Logger) *dex.Sta
 }
 
 // Run starts the marketSyncer listening for BookUpdates, which it relays to the
-// websocket client as notifications.
+// websocket client as notifications. The notification route is the update's
+// action, so order updates ("book", "book_order", "epoch_order",
+// "unbook_order") and epoch updates ("epoch", "match_proof") can be told apart
+// by the consumer.
 func (m *marketSyncer) Run(ctx context.Context) {
 	defer m.feed.Close()
 out:
@@ -250,8 +471,8 @@ out:
 		select {
 		case update, ok := <-m.feed.C:
 			if !ok {
-				// Should not happen, but don't spin furiously.
-				m.log.Warnf("marketSyncer stopping on feed closed")
+				// Core closes the feeds when the DEX is disconnected.
+				m.log.Debugf("marketSyncer stopping on feed closed")
 				return
 			}
 			note, err := msgjson.NewNotification(update.Action, update)
@@ -270,56 +491,265 @@ out:
 	}
 }
 
+// syncMarket gets a book feed for the market from Core and reads the order
+// book, which is the first update on a new feed. The returned notification
+// carries the book. Updates received after the book are buffered by the feed
+// until a marketSyncer is started for it. If the market's Since is set and
+// Core queued the missed updates on the feed instead of the book, the returned
+// notification is nil. The feed is closed if there is an error.
+func (s *Server) syncMarket(market *marketLoadRequest) (*core.BookFeed, *msgjson.Message, string, *msgjson.Error) {
+	name, err := dex.MarketName(market.Base, market.Quote)
+	if err != nil {
+		errMsg := fmt.Sprintf("unknown market: %v", err)
+		s.log.Errorf(errMsg)
+		return nil, nil, "", msgjson.NewError(msgjson.UnknownMarketError, errMsg)
+	}
+
+	var feed *core.BookFeed
+	if market.Since > 0 {
+		var replayed bool
+		feed, replayed, err = s.core.SyncBookSince(market.Host, market.Base, market.Quote, market.Since)
+		if err == nil && replayed {
+			return feed, nil, name, nil
+		}
+	} else {
+		feed, err = s.core.SyncBook(market.Host, market.Base, market.Quote)
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("error getting order feed: %v", err)
+		s.log.Errorf(errMsg)
+		return nil, nil, "", msgjson.NewError(msgjson.RPCOrderBookError, errMsg)
+	}
+
+	var book *core.BookUpdate
+	select {
+	case book = <-feed.C:
+	default:
+	}
+	if book == nil || book.Action != core.FreshBookAction {
+		feed.Close()
+		errMsg := fmt.Sprintf("no order book received for market %s", name)
+		s.log.Errorf(errMsg)
+		return nil, nil, "", msgjson.NewError(msgjson.RPCOrderBookError, errMsg)
+	}
+	note, err := msgjson.NewNotification(book.Action, book)
+	if err != nil {
+		feed.Close()
+		errMsg := fmt.Sprintf("error encoding order book notification: %v", err)
+		s.log.Errorf(errMsg)
+		return nil, nil, "", msgjson.NewError(msgjson.RPCInternal, errMsg)
+	}
+	return feed, note, name, nil
+}
+
+// startFeedLoop sends the book notification, if there is one, and starts a
+// marketSyncer for the feed. Any running marketSyncer for the same market is
+// stopped first, so that no updates for it follow the book. The feed is closed
+// if the book cannot be sent. The feedLoopMtx must be locked.
+func (s *Server) startFeedLoop(cl *wsClient, market *marketLoad, feed *core.BookFeed, note *msgjson.Message, name string) error {
+	cl.stopFeedLoop(*market)
+	if note != nil {
+		if err := cl.Send(note); err != nil {
+			feed.Close()
+			return err
+		}
+	}
+	cl.feedLoops[*market] = newMarketSyncer(cl, feed, s.log.SubLogger(name))
+	return nil
+}
+
 // wsLoadMarket is the handler for the 'loadmarket' websocket route. Subscribes
-// the client to the notification feed and sends the order book.
+// the client to the notification feed and sends the order book. The book is
+// sent as a 'book' notification before any subsequent updates, and the request
+// is then acknowledged with the market, so the client has the complete book
+// when it receives the response. If the request has a Since sequence number
+// and the updates that followed it can be replayed, they are sent instead of
+// the book, and the result's Snapshot flag is false. Any other market
+// subscriptions are stopped.
 func wsLoadMarket(s *Server, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
-	market := new(marketLoad)
-	err := json.Unmarshal(msg.Payload, market)
+	req := new(marketLoadRequest)
+	err := json.Unmarshal(msg.Payload, req)
 	if err != nil {
 		errMsg := fmt.Sprintf("error unmarshalling marketload payload: %v", err)
 		s.log.Errorf(errMsg)
 		return msgjson.NewError(msgjson.RPCInternal, errMsg)
 	}
 
-	name, err := dex.MarketName(market.Base, market.Quote)
+	feed, note, name, msgErr := s.syncMarket(req)
+	if msgErr != nil {
+		return msgErr
+	}
+
+	market := &req.marketLoad
+	cl.feedLoopMtx.Lock()
+	// Stop the other marketSyncers before sending the book so that no updates
+	// for the previous markets follow it.
+	cl.stopFeedLoops()
+	err = s.startFeedLoop(cl, market, feed, note, name)
+	cl.feedLoopMtx.Unlock()
 	if err != nil {
-		errMsg := fmt.Sprintf("unknown market: %v", err)
-		s.log.Errorf(errMsg)
-		return msgjson.NewError(msgjson.UnknownMarketError, errMsg)
+		s.log.Debugf("error sending order book to client %d: %v", cl.cid, err)
+		return nil
 	}
+	return s.respond(cl, msg, &marketLoadResult{marketLoad: *market, Snapshot: note != nil})
+}
+
+// marketLoadResult is the result of a 'loadmarket' request, and for one market
+// of a 'loadmarkets' request. Snapshot is true if the book was sent, and false
+// if the updates missed since the requested sequence number were replayed
+// instead. Error is set if the client could not be subscribed to the market.
+type marketLoadResult struct {
+	marketLoad
+	Snapshot bool           `json:"snapshot"`
+	Error    *msgjson.Error `json:"error,omitempty"`
+}
 
-	feed, err := s.core.SyncBook(market.Host, market.Base, market.Quote)
+// wsLoadMarkets is the handler for the 'loadmarkets' websocket route. It
+// subscribes the client to each of the requested markets as 'loadmarket' does,
+// sending the order book of each as a 'book' notification, but without
+// stopping the client's other subscriptions. A market that cannot be loaded
+// does not prevent the others from being subscribed. The request is
+// acknowledged with a result for each market, in the order requested, once
+// all of the books have been sent.
+func wsLoadMarkets(s *Server, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
+	var markets []*marketLoadRequest
+	err := json.Unmarshal(msg.Payload, &markets)
 	if err != nil {
-		errMsg := fmt.Sprintf("error getting order feed: %v", err)
+		errMsg := fmt.Sprintf("error unmarshalling loadmarkets payload: %v", err)
 		s.log.Errorf(errMsg)
-		return msgjson.NewError(msgjson.RPCOrderBookError, errMsg)
+		return msgjson.NewError(msgjson.RPCInternal, errMsg)
 	}
 
-	cl.feedLoopMtx.Lock()
-	if cl.feedLoop != nil {
-		cl.feedLoop.Stop()
-		cl.feedLoop.WaitForShutdown()
+	results := make([]*marketLoadResult, 0, len(markets))
+	for _, market := range markets {
+		if market == nil {
+			return msgjson.NewError(msgjson.RPCInternal, "null market in loadmarkets payload")
+		}
+		res := &marketLoadResult{marketLoad: market.marketLoad}
+		results = append(results, res)
+		feed, note, name, msgErr := s.syncMarket(market)
+		if msgErr != nil {
+			res.Error = msgErr
+			continue
+		}
+		res.Snapshot = note != nil
+		cl.feedLoopMtx.Lock()
+		err = s.startFeedLoop(cl, &market.marketLoad, feed, note, name)
+		cl.feedLoopMtx.Unlock()
+		if err != nil {
+			s.log.Debugf("error sending order book to client %d: %v", cl.cid, err)
+			return nil
+		}
 	}
-	cl.feedLoop = newMarketSyncer(cl, feed, s.log.SubLogger(name))
-	cl.feedLoopMtx.Unlock()
-	return nil
+	return s.respond(cl, msg, results)
 }
 
 // wsUnmarket is the handler for the 'unmarket' websocket route. This empty
-// message is sent when the user leaves the markets page. This closes the feed,
-// and potentially unsubscribes from orderbook with the server if there are no
-// other consumers
+// message is sent when the user leaves the markets page. This closes the
+// client's feeds, and potentially unsubscribes from orderbooks with the server
+// if there are no other consumers
 func wsUnmarket(_ *Server, cl *wsClient, _ *msgjson.Message) *msgjson.Error {
 	cl.feedLoopMtx.Lock()
 	defer cl.feedLoopMtx.Unlock()
-	if cl.feedLoop != nil {
-		cl.feedLoop.Stop()
-		cl.feedLoop.WaitForShutdown()
-		cl.feedLoop = nil
+	cl.stopFeedLoops()
+	return nil
+}
+
+// pong is the response to a ping request.
+type pong struct {
+	Time uint64 `json:"time"`
+}
+
+// wsPing is the handler for the 'ping' websocket route. It responds immediately
+// with the current time so the client can check that the Core is responsive
+// and measure the round trip time.
+func wsPing(s *Server, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
+	return s.respond(cl, msg, &pong{Time: encode.UnixMilliU(time.Now())})
+}
+
+// wsSubscriptions is the handler for the 'subscriptions' websocket route. It
+// responds with the markets that the client is subscribed to, so the client can
+// reconcile its state, e.g. after reconnecting. The markets are sorted by host,
+// then base and quote asset ID.
+func wsSubscriptions(s *Server, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
+	cl.feedLoopMtx.RLock()
+	subs := make([]*marketLoad, 0, len(cl.feedLoops))
+	for market := range cl.feedLoops {
+		market := market
+		subs = append(subs, &market)
+	}
+	cl.feedLoopMtx.RUnlock()
+	sort.Slice(subs, func(i, j int) bool {
+		a, b := subs[i], subs[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Base != b.Base {
+			return a.Base < b.Base
+		}
+		return a.Quote < b.Quote
+	})
+	return s.respond(cl, msg, subs)
+}
+
+// respond sends a response with the result to the client's request.
+func (s *Server) respond(cl *wsClient, msg *msgjson.Message, result interface{}) *msgjson.Error {
+	resp, err := msgjson.NewResponse(msg.ID, result, nil)
+	if err != nil {
+		s.log.Errorf("error encoding %s response: %v", msg.Route, err)
+		return msgjson.NewError(msgjson.RPCInternal, "error encoding response")
+	}
+	if err = cl.Send(resp); err != nil {
+		s.log.Debugf("error sending %s response to client %d: %v", msg.Route, cl.cid, err)
 	}
 	return nil
 }
 
+// noteSeverities are the notification severities by name, from lowest to
+// highest: ignore, data, poke, success, warning, and error.
+var noteSeverities = map[string]db.Severity{
+	db.Ignorable.String():    db.Ignorable,
+	db.Data.String():         db.Data,
+	db.Poke.String():         db.Poke,
+	db.Success.String():      db.Success,
+	db.WarningLevel.String(): db.WarningLevel,
+	db.ErrorLevel.String():   db.ErrorLevel,
+}
+
+// noteFilter is the payload of a 'notifications' request, and its response.
+type noteFilter struct {
+	// Severity is the name of the minimum severity of the notifications to
+	// send. All notifications are sent if it is empty.
+	Severity string `json:"severity"`
+}
+
+// wsNotifications is the handler for the 'notifications' websocket route. It
+// sets the minimum severity of the notifications sent to the client, e.g.
+// "warning" for only warnings and errors, so that a client is not sent
+// notifications that it would discard. A client receives all notifications
+// until it sends this request, and may change the filter by sending it again.
+// The request is acknowledged with the severity set.
+func wsNotifications(s *Server, cl *wsClient, msg *msgjson.Message) *msgjson.Error {
+	filter := new(noteFilter)
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, filter); err != nil {
+			return msgjson.NewError(msgjson.RPCArgumentsError,
+				fmt.Sprintf("error unmarshalling notifications payload: %v", err))
+		}
+	}
+	severity := db.Ignorable
+	if filter.Severity != "" {
+		var found bool
+		severity, found = noteSeverities[filter.Severity]
+		if !found {
+			return msgjson.NewError(msgjson.RPCArgumentsError,
+				fmt.Sprintf("unknown severity %q", filter.Severity))
+		}
+	}
+	atomic.StoreUint32(&cl.minSeverity, uint32(severity))
+	return s.respond(cl, msg, &noteFilter{Severity: severity.String()})
+}
+
 type ackNoteIDs []dex.Bytes
 
 // wsAckNotes is the handler for the 'acknotes' websocket route. It informs the
@@ -3,17 +3,22 @@
 package websocket
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 
 	"decred.org/dcrdex/client/core"
+	"decred.org/dcrdex/client/db"
 	"decred.org/dcrdex/dex"
+	"decred.org/dcrdex/dex/encode"
 	"decred.org/dcrdex/dex/msgjson"
+	gorilla "github.com/gorilla/websocket"
 )
 
 var (
@@ -23,15 +28,30 @@ var (
 
 type TCore struct {
 	syncFeed   *core.BookFeed
+	syncFeeds  map[uint32]*core.BookFeed // by quote asset, if set
+	newFeed    func() *core.BookFeed     // a new feed for each call, if set
 	syncErr    error
+	syncSince  uint64 // since of the last SyncBookSince
+	replayed   bool   // whether SyncBookSince replays
 	notHas     bool
 	notRunning bool
 	notOpen    bool
 }
 
 func (c *TCore) SyncBook(dex string, base, quote uint32) (*core.BookFeed, error) {
+	if c.newFeed != nil {
+		return c.newFeed(), c.syncErr
+	}
+	if c.syncFeeds != nil {
+		return c.syncFeeds[quote], c.syncErr
+	}
 	return c.syncFeed, c.syncErr
 }
+func (c *TCore) SyncBookSince(dex string, base, quote uint32, since uint64) (*core.BookFeed, bool, error) {
+	c.syncSince = since
+	feed, err := c.SyncBook(dex, base, quote)
+	return feed, c.replayed && err == nil, err
+}
 func (c *TCore) WalletState(assetID uint32) *core.WalletState {
 	if c.notHas {
 		return nil
@@ -46,6 +66,7 @@ func (c *TCore) WalletState(assetID uint32) *core.WalletState {
 func (c *TCore) AckNotes(ids []dex.Bytes) {}
 
 type TConn struct {
+	closeMsg  []byte // from WriteControl
 	msg       []byte
 	reads     [][]byte      // data for ReadMessage
 	respReady chan []byte   // signal from WriteMessage
@@ -80,6 +101,9 @@ func (c *TConn) SetWriteDeadline(t time.Time) error {
 }
 
 func (c *TConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType == gorilla.CloseMessage {
+		c.closeMsg = data
+	}
 	return nil
 }
 
@@ -144,6 +168,8 @@ func TestLoadMarket(t *testing.T) {
 	// method to route to the proper handler with a configured logger and Core.
 
 	link := newLink()
+	// loadmarket sends both the book and a response.
+	link.conn.respReady = make(chan []byte, 2)
 	linkWg, err := link.cl.Connect(tCtx)
 	if err != nil {
 		t.Fatalf("WSLink Start: %v", err)
@@ -153,8 +179,9 @@ func TestLoadMarket(t *testing.T) {
 	// so manually stop the marketSyncer started by wsLoadMarket and the WSLink
 	// before returning from this test.
 	defer func() {
-		link.cl.feedLoop.Stop()
-		link.cl.feedLoop.WaitForShutdown()
+		link.cl.feedLoopMtx.Lock()
+		link.cl.stopFeedLoops()
+		link.cl.feedLoopMtx.Unlock()
 		link.cl.Disconnect()
 		linkWg.Wait()
 	}()
@@ -167,22 +194,107 @@ func TestLoadMarket(t *testing.T) {
 
 	subscription, _ := msgjson.NewRequest(1, "loadmarket", params)
 
+	// Add the client to the map as connect would, for Stats.
+	srv.clientsMtx.Lock()
+	srv.clients[link.cl.cid] = link.cl
+	srv.clientsMtx.Unlock()
+	ensureStats := func(wantSyncers int) {
+		t.Helper()
+		clients, syncers := srv.Stats()
+		if clients != 1 || syncers != wantSyncers {
+			t.Fatalf("wanted 1 client and %d syncers, got %d and %d", wantSyncers, clients, syncers)
+		}
+	}
+
+	nextMsg := func() *msgjson.Message {
+		t.Helper()
+		var b []byte
+		select {
+		case b = <-link.conn.respReady:
+		case <-time.After(time.Second):
+			t.Fatalf("no message sent")
+		}
+		msg, err := msgjson.DecodeMessage(b)
+		if err != nil {
+			t.Fatalf("error decoding message: %v", err)
+		}
+		return msg
+	}
+
 	ensureGood := func() {
 		t.Helper()
 		// Create a new feed for every request because a Close()d feed cannot be
 		// reused.
 		tCore.syncFeed = core.NewBookFeed(func(feed *core.BookFeed) {})
+		tCore.syncFeed.C <- &core.BookUpdate{
+			Action:   core.FreshBookAction,
+			Host:     params.Host,
+			MarketID: "btc_ltc",
+			Payload: &core.MarketOrderBook{
+				Base:  params.Base,
+				Quote: params.Quote,
+				Book:  &core.OrderBook{},
+			},
+		}
+		// An update received before the book is sent must follow it.
+		tCore.syncFeed.C <- &core.BookUpdate{
+			Action:   core.BookOrderAction,
+			Host:     params.Host,
+			MarketID: "btc_ltc",
+		}
 		msgErr := srv.handleMessage(link.cl, subscription)
 		if msgErr != nil {
 			t.Fatalf("'loadmarket' error: %d: %s", msgErr.Code, msgErr.Message)
 		}
-		if link.cl.feedLoop == nil {
+		if len(link.cl.feedLoops) != 1 {
 			t.Fatalf("nil book feed waiter after 'loadmarket'")
 		}
+		// The book is sent first, followed by the acknowledgement.
+		note := nextMsg()
+		if note.Type != msgjson.Notification || note.Route != core.FreshBookAction {
+			t.Fatalf("expected a book notification first, got %s", note.String())
+		}
+		resp := nextMsg()
+		if resp.Type != msgjson.Response || resp.ID != subscription.ID {
+			t.Fatalf("expected a loadmarket response, got %s", resp.String())
+		}
+		ack := new(marketLoad)
+		if err := resp.UnmarshalResult(ack); err != nil {
+			t.Fatalf("error unmarshalling loadmarket response: %v", err)
+		}
+		if !reflect.DeepEqual(ack, params) {
+			t.Fatalf("wrong loadmarket response %v, wanted %v", ack, params)
+		}
+		// Then the updates are streamed by the marketSyncer.
+		update := nextMsg()
+		if update.Type != msgjson.Notification || update.Route != core.BookOrderAction {
+			t.Fatalf("expected a book_order notification, got %s", update.String())
+		}
 	}
 
+	ensureSubs := func(want []*marketLoad) {
+		t.Helper()
+		req, _ := msgjson.NewRequest(3, "subscriptions", nil)
+		if msgErr := srv.handleMessage(link.cl, req); msgErr != nil {
+			t.Fatalf("'subscriptions' error: %d: %s", msgErr.Code, msgErr.Message)
+		}
+		resp := nextMsg()
+		var subs []*marketLoad
+		if err := resp.UnmarshalResult(&subs); err != nil {
+			t.Fatalf("error unmarshalling subscriptions: %v", err)
+		}
+		if subs == nil || !reflect.DeepEqual(subs, want) {
+			t.Fatalf("wrong subscriptions %v, wanted %v", subs, want)
+		}
+	}
+
+	// No subscriptions yet.
+	ensureSubs([]*marketLoad{})
+
 	// Initial success.
 	ensureGood()
+	ensureStats(1)
+	ensureSubs([]*marketLoad{params})
 
 	// Unsubscribe.
 	unsub, _ := msgjson.NewRequest(2, "unmarket", nil)
@@ -191,9 +303,11 @@ func TestLoadMarket(t *testing.T) {
 		t.Fatalf("'unmarket' error: %d: %s", msgErr.Code, msgErr.Message)
 	}
 
-	if link.cl.feedLoop != nil {
+	if len(link.cl.feedLoops) != 0 {
 		t.Fatalf("non-nil book feed waiter after 'unmarket'")
 	}
+	ensureStats(0)
+	ensureSubs([]*marketLoad{})
 
 	// Make sure a sync error propagates.
 	tCore.syncErr = fmt.Errorf("expected dummy error")
@@ -203,10 +317,206 @@ func TestLoadMarket(t *testing.T) {
 	}
 	tCore.syncErr = nil
 
+	// A feed that does not start with the book is closed.
+	feedClosed := make(chan struct{}, 1)
+	tCore.syncFeed = core.NewBookFeed(func(*core.BookFeed) { feedClosed <- struct{}{} })
+	msgErr = srv.handleMessage(link.cl, subscription)
+	if msgErr == nil || msgErr.Code != msgjson.RPCOrderBookError {
+		t.Fatalf("wrong error for a feed without a book: %v", msgErr)
+	}
+	select {
+	case <-feedClosed:
+	default:
+		t.Fatalf("feed without a book not closed")
+	}
+
+	// Resubscribing with a sequence number replays the missed updates instead
+	// of sending the book. The marketSyncer may send the update before or
+	// after the response.
+	tCore.syncFeed = core.NewBookFeed(func(*core.BookFeed) {})
+	tCore.syncFeed.C <- &core.BookUpdate{
+		Action:   core.BookOrderAction,
+		Host:     params.Host,
+		MarketID: "btc_ltc",
+		Seq:      6,
+	}
+	tCore.replayed = true
+	resync, _ := msgjson.NewRequest(4, "loadmarket", &marketLoadRequest{marketLoad: *params, Since: 5})
+	msgErr = srv.handleMessage(link.cl, resync)
+	if msgErr != nil {
+		t.Fatalf("'loadmarket' error with since: %d: %s", msgErr.Code, msgErr.Message)
+	}
+	if tCore.syncSince != 5 {
+		t.Fatalf("wrong since sequence number %d", tCore.syncSince)
+	}
+	var gotUpdate, gotResp bool
+	for i := 0; i < 2; i++ {
+		msg := nextMsg()
+		switch {
+		case msg.Type == msgjson.Notification && msg.Route == core.BookOrderAction:
+			gotUpdate = true
+		case msg.Type == msgjson.Response && msg.ID == resync.ID:
+			gotResp = true
+			res := new(marketLoadResult)
+			if err := msg.UnmarshalResult(res); err != nil {
+				t.Fatalf("error unmarshalling loadmarket response: %v", err)
+			}
+			if res.Snapshot || res.marketLoad != *params {
+				t.Fatalf("wrong loadmarket response for a replay %+v", res)
+			}
+		default:
+			t.Fatalf("unexpected message for a replay %s", msg.String())
+		}
+	}
+	if !gotUpdate || !gotResp {
+		t.Fatalf("expected a replayed update and a response")
+	}
+	ensureSubs([]*marketLoad{params})
+
+	// If the updates cannot be replayed, the book is sent and flagged.
+	tCore.replayed = false
+	tCore.syncFeed = core.NewBookFeed(func(*core.BookFeed) {})
+	tCore.syncFeed.C <- &core.BookUpdate{
+		Action:   core.FreshBookAction,
+		Host:     params.Host,
+		MarketID: "btc_ltc",
+		Seq:      200,
+		Payload:  &core.MarketOrderBook{Base: params.Base, Quote: params.Quote, Book: &core.OrderBook{}},
+	}
+	msgErr = srv.handleMessage(link.cl, resync)
+	if msgErr != nil {
+		t.Fatalf("'loadmarket' error with since: %d: %s", msgErr.Code, msgErr.Message)
+	}
+	if note := nextMsg(); note.Type != msgjson.Notification || note.Route != core.FreshBookAction {
+		t.Fatalf("expected a book notification, got %s", note.String())
+	}
+	res := new(marketLoadResult)
+	if err := nextMsg().UnmarshalResult(res); err != nil {
+		t.Fatalf("error unmarshalling loadmarket response: %v", err)
+	}
+	if !res.Snapshot {
+		t.Fatalf("book not flagged as a snapshot")
+	}
+
 	// Success again.
 	ensureGood()
 }
 
+func TestLoadMarkets(t *testing.T) {
+	srv, tCore := newTServer()
+	link := newLink()
+	link.conn.respReady = make(chan []byte, 8)
+	linkWg, err := link.cl.Connect(tCtx)
+	if err != nil {
+		t.Fatalf("WSLink Start: %v", err)
+	}
+	defer func() {
+		link.cl.feedLoopMtx.Lock()
+		link.cl.stopFeedLoops()
+		link.cl.feedLoopMtx.Unlock()
+		link.cl.Disconnect()
+		linkWg.Wait()
+	}()
+
+	dcrBTC := &marketLoad{Host: "abc", Base: 42, Quote: 0}
+	dcrLTC := &marketLoad{Host: "abc", Base: 42, Quote: 2}
+	unknown := &marketLoad{Host: "abc", Base: 42, Quote: 1e9}
+	tCore.syncFeeds = make(map[uint32]*core.BookFeed)
+	for _, mkt := range []*marketLoad{dcrBTC, dcrLTC} {
+		feed := core.NewBookFeed(func(feed *core.BookFeed) {})
+		feed.C <- &core.BookUpdate{
+			Action: core.FreshBookAction,
+			Host:   mkt.Host,
+			Payload: &core.MarketOrderBook{
+				Base:  mkt.Base,
+				Quote: mkt.Quote,
+				Book:  &core.OrderBook{},
+			},
+		}
+		tCore.syncFeeds[mkt.Quote] = feed
+	}
+
+	nextMsg := func() *msgjson.Message {
+		t.Helper()
+		var b []byte
+		select {
+		case b = <-link.conn.respReady:
+		case <-time.After(time.Second):
+			t.Fatalf("no message sent")
+		}
+		msg, err := msgjson.DecodeMessage(b)
+		if err != nil {
+			t.Fatalf("error decoding message: %v", err)
+		}
+		return msg
+	}
+
+	// The unknown market fails without preventing the others.
+	req, _ := msgjson.NewRequest(1, "loadmarkets", []*marketLoad{dcrLTC, unknown, dcrBTC})
+	if msgErr := srv.handleMessage(link.cl, req); msgErr != nil {
+		t.Fatalf("'loadmarkets' error: %d: %s", msgErr.Code, msgErr.Message)
+	}
+	for i := 0; i < 2; i++ {
+		note := nextMsg()
+		if note.Type != msgjson.Notification || note.Route != core.FreshBookAction {
+			t.Fatalf("expected a book notification, got %s", note.String())
+		}
+	}
+	resp := nextMsg()
+	if resp.Type != msgjson.Response || resp.ID != req.ID {
+		t.Fatalf("expected a loadmarkets response, got %s", resp.String())
+	}
+	var results []*marketLoadResult
+	if err := resp.UnmarshalResult(&results); err != nil {
+		t.Fatalf("error unmarshalling loadmarkets response: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, mkt := range []*marketLoad{dcrLTC, unknown, dcrBTC} {
+		if results[i].marketLoad != *mkt {
+			t.Fatalf("wrong market for result %d: %v", i, results[i].marketLoad)
+		}
+	}
+	if results[0].Error != nil || results[2].Error != nil {
+		t.Fatalf("unexpected market errors: %v, %v", results[0].Error, results[2].Error)
+	}
+	if results[1].Error == nil || results[1].Error.Code != msgjson.UnknownMarketError {
+		t.Fatalf("wrong error for unknown market: %v", results[1].Error)
+	}
+	if len(link.cl.feedLoops) != 2 {
+		t.Fatalf("expected 2 feed loops, got %d", len(link.cl.feedLoops))
+	}
+
+	// Subscriptions are sorted.
+	req, _ = msgjson.NewRequest(2, "subscriptions", nil)
+	if msgErr := srv.handleMessage(link.cl, req); msgErr != nil {
+		t.Fatalf("'subscriptions' error: %d: %s", msgErr.Code, msgErr.Message)
+	}
+	var subs []*marketLoad
+	if err := nextMsg().UnmarshalResult(&subs); err != nil {
+		t.Fatalf("error unmarshalling subscriptions: %v", err)
+	}
+	if want := []*marketLoad{dcrBTC, dcrLTC}; !reflect.DeepEqual(subs, want) {
+		t.Fatalf("wrong subscriptions %v, wanted %v", subs, want)
+	}
+
+	// A bad payload is an error.
+	req, _ = msgjson.NewRequest(3, "loadmarkets", dcrBTC)
+	if msgErr := srv.handleMessage(link.cl, req); msgErr == nil {
+		t.Fatalf("no error for a bad loadmarkets payload")
+	}
+
+	// unmarket stops all of the feeds.
+	req, _ = msgjson.NewRequest(4, "unmarket", nil)
+	if msgErr := srv.handleMessage(link.cl, req); msgErr != nil {
+		t.Fatalf("'unmarket' error: %d: %s", msgErr.Code, msgErr.Message)
+	}
+	if len(link.cl.feedLoops) != 0 {
+		t.Fatalf("expected no feed loops after 'unmarket', got %d", len(link.cl.feedLoops))
+	}
+}
+
 func TestHandleMessage(t *testing.T) {
 	link := newLink()
 	srv, _ := newTServer()
@@ -245,6 +555,142 @@ func TestHandleMessage(t *testing.T) {
 	}
 }
 
+func TestPing(t *testing.T) {
+	srv, _ := newTServer()
+	link := newLink()
+	linkWg, err := link.cl.Connect(tCtx)
+	if err != nil {
+		t.Fatalf("WSLink Start: %v", err)
+	}
+	defer func() {
+		link.cl.Disconnect()
+		linkWg.Wait()
+	}()
+
+	before := encode.UnixMilliU(time.Now())
+	ping, _ := msgjson.NewRequest(5, "ping", nil)
+	if msgErr := srv.handleMessage(link.cl, ping); msgErr != nil {
+		t.Fatalf("'ping' error: %d: %s", msgErr.Code, msgErr.Message)
+	}
+
+	var b []byte
+	select {
+	case b = <-link.conn.respReady:
+	case <-time.After(time.Second):
+		t.Fatalf("no response to ping")
+	}
+	resp, err := msgjson.DecodeMessage(b)
+	if err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if resp.Type != msgjson.Response || resp.ID != ping.ID {
+		t.Fatalf("wrong response type %d or ID %d", resp.Type, resp.ID)
+	}
+	p := new(pong)
+	if err := resp.UnmarshalResult(p); err != nil {
+		t.Fatalf("error unmarshalling pong: %v", err)
+	}
+	if p.Time < before || p.Time > encode.UnixMilliU(time.Now()) {
+		t.Fatalf("wrong pong time %d", p.Time)
+	}
+}
+
+func TestNotificationFilter(t *testing.T) {
+	srv, _ := newTServer()
+	link := newLink()
+	linkWg, err := link.cl.Connect(tCtx)
+	if err != nil {
+		t.Fatalf("WSLink Start: %v", err)
+	}
+	defer func() {
+		link.cl.Disconnect()
+		linkWg.Wait()
+	}()
+	srv.clientsMtx.Lock()
+	srv.clients[link.cl.cid] = link.cl
+	srv.clientsMtx.Unlock()
+
+	nextMsg := func() *msgjson.Message {
+		t.Helper()
+		select {
+		case b := <-link.conn.respReady:
+			msg, err := msgjson.DecodeMessage(b)
+			if err != nil {
+				t.Fatalf("error decoding message: %v", err)
+			}
+			return msg
+		case <-time.After(time.Second):
+			t.Fatalf("no message received")
+		}
+		return nil
+	}
+
+	// ensureNotified checks whether a notification of the severity is sent.
+	ensureNotified := func(severity db.Severity, want bool) {
+		t.Helper()
+		note := db.NewNotification("test", "subject", "details", severity)
+		srv.Notify("notify", &note)
+		select {
+		case b := <-link.conn.respReady:
+			if !want {
+				t.Fatalf("%s notification not filtered: %s", severity, b)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if want {
+				t.Fatalf("%s notification not sent", severity)
+			}
+		}
+	}
+
+	setFilter := func(severity string, wantErrCode int) {
+		t.Helper()
+		req, _ := msgjson.NewRequest(1, "notifications", &noteFilter{Severity: severity})
+		msgErr := srv.handleMessage(link.cl, req)
+		if wantErrCode != -1 {
+			if msgErr == nil || msgErr.Code != wantErrCode {
+				t.Fatalf("wrong error for severity %q: %v", severity, msgErr)
+			}
+			return
+		}
+		if msgErr != nil {
+			t.Fatalf("'notifications' error: %d: %s", msgErr.Code, msgErr.Message)
+		}
+		filter := new(noteFilter)
+		if err := nextMsg().UnmarshalResult(filter); err != nil {
+			t.Fatalf("error unmarshalling notifications response: %v", err)
+		}
+		if severity != "" && filter.Severity != severity {
+			t.Fatalf("wrong severity %q set, wanted %q", filter.Severity, severity)
+		}
+	}
+
+	// All notifications are sent by default.
+	ensureNotified(db.Data, true)
+	ensureNotified(db.ErrorLevel, true)
+
+	// Only warnings and errors.
+	setFilter("warning", -1)
+	ensureNotified(db.Data, false)
+	ensureNotified(db.Success, false)
+	ensureNotified(db.WarningLevel, true)
+	ensureNotified(db.ErrorLevel, true)
+
+	// Notifications without a severity are always sent.
+	srv.Notify("other", "payload")
+	nextMsg()
+
+	// An unknown severity does not change the filter.
+	setFilter("loud", msgjson.RPCArgumentsError)
+	ensureNotified(db.Success, false)
+
+	// Re-subscribing changes the filter, and no severity restores the default.
+	setFilter("success", -1)
+	ensureNotified(db.Poke, false)
+	ensureNotified(db.Success, true)
+	setFilter("", -1)
+	ensureNotified(db.Data, true)
+}
+
 func TestClientMap(t *testing.T) {
 	srv, _ := newTServer()
 	resp := make(chan []byte, 1)
@@ -288,3 +734,377 @@ func TestClientMap(t *testing.T) {
 		t.Fatal("connection not closed on server shutdown")
 	}
 }
+
+func TestDisconnectClient(t *testing.T) {
+	srv, _ := newTServer()
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	type tClient struct {
+		conn *TConn
+		cl   *wsClient
+		done chan struct{}
+	}
+	connect := func(ip string) *tClient {
+		t.Helper()
+		conn := &TConn{
+			respReady: make(chan []byte, 1),
+			close:     make(chan struct{}, 1),
+		}
+		// msg.ID == 0 gets an error response, signaling that the client is
+		// in the map.
+		read, _ := json.Marshal(msgjson.Message{ID: 0})
+		conn.addRead(read)
+		done := make(chan struct{})
+		go func() {
+			srv.connect(ctx, conn, ip)
+			close(done)
+		}()
+		<-conn.respReady
+		srv.clientsMtx.RLock()
+		defer srv.clientsMtx.RUnlock()
+		for _, cl := range srv.clients {
+			if cl.IP() == ip {
+				// Subscribe the client to a market.
+				feed := core.NewBookFeed(func(*core.BookFeed) {})
+				cl.feedLoopMtx.Lock()
+				cl.feedLoops[marketLoad{Host: "abc", Base: 42, Quote: 0}] = newMarketSyncer(cl, feed, srv.log)
+				cl.feedLoopMtx.Unlock()
+				return &tClient{conn: conn, cl: cl, done: done}
+			}
+		}
+		t.Fatalf("client %s not found", ip)
+		return nil
+	}
+	c1 := connect("10.0.0.1")
+	c2 := connect("10.0.0.2")
+
+	clients := srv.Clients()
+	if len(clients) != 2 || clients[0].ID > clients[1].ID {
+		t.Fatalf("wrong clients: %v", clients)
+	}
+	for _, ci := range clients {
+		if ci.Feeds != 1 {
+			t.Fatalf("expected 1 feed for client %d, got %d", ci.ID, ci.Feeds)
+		}
+	}
+
+	if srv.DisconnectClient(-1, "") {
+		t.Fatalf("no error for unknown client ID")
+	}
+
+	if !srv.DisconnectClient(c1.cl.cid, "misbehaving") {
+		t.Fatalf("client %d not found", c1.cl.cid)
+	}
+	select {
+	case <-c1.done:
+	case <-time.After(time.Second):
+		t.Fatalf("disconnected client's handler did not return")
+	}
+	if !c1.cl.Off() {
+		t.Fatalf("client not disconnected")
+	}
+	if len(c1.cl.feedLoops) != 0 {
+		t.Fatalf("disconnected client's feeds not stopped")
+	}
+	if want := gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, "misbehaving"); !bytes.Equal(c1.conn.closeMsg, want) {
+		t.Fatalf("wrong close message %q", c1.conn.closeMsg)
+	}
+
+	// The other client and its feed are unaffected.
+	clients = srv.Clients()
+	if len(clients) != 1 || clients[0].ID != c2.cl.cid || clients[0].Feeds != 1 {
+		t.Fatalf("wrong clients after disconnect: %v", clients)
+	}
+	if c2.cl.Off() {
+		t.Fatalf("other client disconnected")
+	}
+
+	shutdown()
+	<-c2.done
+}
+
+// TestConcurrentClients connects clients that subscribe and unsubscribe while
+// the client map is read, notified and pruned from other goroutines. It is
+// meant to be run with -race.
+func TestConcurrentClients(t *testing.T) {
+	srv, tCore := newTServer()
+	tCore.newFeed = func() *core.BookFeed {
+		feed := core.NewBookFeed(func(*core.BookFeed) {})
+		feed.C <- &core.BookUpdate{
+			Action: core.FreshBookAction,
+			Payload: &core.MarketOrderBook{
+				Book: &core.OrderBook{},
+			},
+		}
+		return feed
+	}
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	mkt := func(quote uint32) *marketLoad {
+		return &marketLoad{Host: "abc", Base: 42, Quote: quote}
+	}
+	var reqs [][]byte
+	var lastID uint64
+	addReq := func(route string, payload interface{}) {
+		lastID++
+		req, _ := msgjson.NewRequest(lastID, route, payload)
+		b, _ := json.Marshal(req)
+		reqs = append(reqs, b)
+	}
+	addReq("loadmarket", mkt(0))
+	addReq("loadmarkets", []*marketLoad{mkt(0), mkt(2)})
+	addReq("subscriptions", nil)
+	addReq("unmarket", nil)
+	addReq("loadmarket", mkt(2))
+	addReq("ping", nil)
+
+	const numClients = 8
+	var wg sync.WaitGroup
+	ready := make(chan struct{}, numClients)
+	for i := 0; i < numClients; i++ {
+		conn := &TConn{
+			respReady: make(chan []byte, 16),
+			close:     make(chan struct{}, 1),
+		}
+		for _, req := range reqs {
+			conn.addRead(req)
+		}
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			srv.connect(ctx, conn, ip)
+		}(fmt.Sprintf("10.0.0.%d", i))
+		// Signal when the last request has been answered.
+		go func() {
+			for {
+				select {
+				case b := <-conn.respReady:
+					msg, err := msgjson.DecodeMessage(b)
+					if err == nil && msg.Type == msgjson.Response && msg.ID == lastID {
+						ready <- struct{}{}
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Read and notify the clients until they have all been served.
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			srv.Stats()
+			srv.Clients()
+			srv.Notify("note", "hi")
+		}
+	}()
+	for i := 0; i < numClients; i++ {
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d clients served", i, numClients)
+		}
+	}
+
+	clients, syncers := srv.Stats()
+	if clients != numClients || syncers != numClients {
+		t.Fatalf("wanted %d clients and syncers, got %d and %d", numClients, clients, syncers)
+	}
+
+	// Disconnect half of the clients while the map is still being read.
+	for i, ci := range srv.Clients() {
+		if i%2 == 0 {
+			srv.DisconnectClient(ci.ID, "")
+		}
+	}
+	close(stop)
+	readers.Wait()
+
+	shutdown()
+	wg.Wait()
+	if clients, syncers = srv.Stats(); clients != 0 || syncers != 0 {
+		t.Fatalf("wanted no clients or syncers after shutdown, got %d and %d", clients, syncers)
+	}
+}
+
+func TestAuthTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	srv, _ := newTServer()
+	srv.SetAuthTimeout(timeout)
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	connect := func(reads ...[]byte) (*TConn, chan struct{}) {
+		conn := &TConn{
+			respReady: make(chan []byte, 1),
+			close:     make(chan struct{}, 1),
+		}
+		for _, read := range reads {
+			conn.addRead(read)
+		}
+		done := make(chan struct{})
+		go func() {
+			srv.connect(ctx, conn, "someip")
+			close(done)
+		}()
+		return conn, done
+	}
+
+	// A client that sends nothing is disconnected.
+	idle, idleDone := connect()
+	select {
+	case <-idleDone:
+	case <-time.After(10 * timeout):
+		t.Fatalf("idle client not disconnected")
+	}
+	if want := gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, authTimeoutReason); !bytes.Equal(idle.closeMsg, want) {
+		t.Fatalf("wrong close message %q", idle.closeMsg)
+	}
+
+	// So is one whose requests fail.
+	bad, _ := json.Marshal(msgjson.Message{ID: 0})
+	_, badDone := connect(bad)
+	select {
+	case <-badDone:
+	case <-time.After(10 * timeout):
+		t.Fatalf("client with failed requests not disconnected")
+	}
+
+	// A client with a successful request stays connected.
+	ping, _ := msgjson.NewRequest(1, "ping", nil)
+	pingB, _ := json.Marshal(ping)
+	_, activeDone := connect(pingB)
+	select {
+	case <-activeDone:
+		t.Fatalf("active client disconnected")
+	case <-time.After(3 * timeout):
+	}
+	shutdown()
+	<-activeDone
+}
+
+func TestDrain(t *testing.T) {
+	srv, _ := newTServer()
+	conn := &TConn{
+		respReady: make(chan []byte, 1),
+		close:     make(chan struct{}, 1),
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		srv.connect(tCtx, conn, "someip")
+		wg.Done()
+	}()
+	defer wg.Wait()
+
+	var cl *wsClient
+	for i := 0; cl == nil; i++ {
+		if i == 100 {
+			t.Fatalf("client not connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+		srv.clientsMtx.RLock()
+		for _, c := range srv.clients {
+			cl = c
+		}
+		srv.clientsMtx.RUnlock()
+	}
+
+	// A handler that runs until released.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wsHandlers["slow"] = func(*Server, *wsClient, *msgjson.Message) *msgjson.Error {
+		close(started)
+		<-release
+		return nil
+	}
+	defer delete(wsHandlers, "slow")
+	slow, _ := msgjson.NewRequest(1, "slow", nil)
+	go srv.handleMessage(cl, slow)
+	<-started
+
+	drained := make(chan struct{})
+	go func() {
+		srv.Drain(context.Background(), "shutting down")
+		close(drained)
+	}()
+
+	// New messages are rejected while draining.
+	for i := 0; ; i++ {
+		if i == 100 {
+			t.Fatalf("messages not rejected while draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+		msgErr := srv.handleMessage(cl, slow)
+		if msgErr != nil && msgErr.Code == msgjson.TryAgainLaterError {
+			break
+		}
+	}
+
+	// Clients are not disconnected until the in-flight handler returns.
+	select {
+	case <-drained:
+		t.Fatalf("drained with a handler running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if cl.Off() {
+		t.Fatalf("client disconnected with a handler running")
+	}
+	close(release)
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatalf("not drained after handler returned")
+	}
+	if !cl.Off() {
+		t.Fatalf("client not disconnected after draining")
+	}
+	wg.Wait() // connect was not started by HandleConnect, so Drain did not wait
+	if want := gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, "shutting down"); string(conn.closeMsg) != string(want) {
+		t.Fatalf("wrong close message %q", conn.closeMsg)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	srv, _ := newTServer()
+	link := newLink()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	wsHandlers["stuck"] = func(*Server, *wsClient, *msgjson.Message) *msgjson.Error {
+		close(started)
+		<-release
+		return nil
+	}
+	defer delete(wsHandlers, "stuck")
+	stuck, _ := msgjson.NewRequest(1, "stuck", nil)
+	go srv.handleMessage(link.cl, stuck)
+	<-started
+
+	// Drain returns when the context expires even if a handler is stuck.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	drained := make(chan struct{})
+	go func() {
+		srv.Drain(ctx, "")
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatalf("Drain did not return after the timeout")
+	}
+}
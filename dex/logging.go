@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/decred/slog"
 )
@@ -44,6 +45,31 @@ type LoggerMaker struct {
 	*slog.Backend
 	DefaultLevel slog.Level
 	Levels       map[string]slog.Level
+	set          *loggerSet
+}
+
+// loggerSet is the set of loggers created by a LoggerMaker, including their
+// subloggers, so that SetLevel can change all of their levels. The mtx also
+// guards the levels of the loggers and the LoggerMaker.
+type loggerSet struct {
+	mtx     sync.Mutex
+	loggers []*logger
+}
+
+// lock locks the loggerSet, if there is one. It returns the unlock function.
+func (set *loggerSet) lock() func() {
+	if set == nil {
+		return func() {}
+	}
+	set.mtx.Lock()
+	return set.mtx.Unlock
+}
+
+// add adds a logger to the set, if there is one. The mtx must be locked.
+func (set *loggerSet) add(lggr *logger) {
+	if set != nil {
+		set.loggers = append(set.loggers, lggr)
+	}
 }
 
 // logger contains the slog.Logger and fields needed to spawn subloggers. It
@@ -54,12 +80,14 @@ type logger struct {
 	level   slog.Level
 	levels  map[string]slog.Level
 	backend *slog.Backend
+	set     *loggerSet
 }
 
 // SubLogger creates a new Logger for the subsystem with the given name. If name
 // exists in the levels map, use that level, otherwise the parent's log level is
 // used.
 func (lggr *logger) SubLogger(name string) Logger {
+	defer lggr.set.lock()()
 	combinedName := fmt.Sprintf("%s[%s]", lggr.name, name)
 	newLggr := lggr.backend.Logger(combinedName)
 	level := lggr.level
@@ -68,13 +96,16 @@ func (lggr *logger) SubLogger(name string) Logger {
 		level = lvl
 	}
 	newLggr.SetLevel(level)
-	return &logger{
+	sub := &logger{
 		Logger:  newLggr,
 		name:    combinedName,
 		level:   level,
 		levels:  lggr.levels,
 		backend: lggr.backend,
+		set:     lggr.set,
 	}
+	lggr.set.add(sub)
+	return sub
 }
 
 func inUTC() slog.BackendOption {
@@ -129,6 +160,7 @@ func NewLoggerMaker(writer io.Writer, debugLevel string, utc ...bool) (*LoggerMa
 		Backend:      slog.NewBackend(writer, opts...),
 		Levels:       make(map[string]slog.Level),
 		DefaultLevel: DefaultLogLevel,
+		set:          new(loggerSet),
 	}
 
 	err := lm.SetLevels(debugLevel)
@@ -205,35 +237,65 @@ func (lm *LoggerMaker) Level(name string) slog.Level {
 // log level is specified, it is used for the Logger. Otherwise the DefaultLevel
 // is used.
 func (lm *LoggerMaker) NewLogger(name string, level ...slog.Level) Logger {
+	defer lm.set.lock()()
 	lvl := lm.DefaultLevel
 	if len(level) > 0 {
 		lvl = level[0]
 	}
 	lggr := lm.Backend.Logger(name)
 	lggr.SetLevel(lvl)
-	return &logger{
+	l := &logger{
 		Logger:  lggr,
 		name:    name,
 		level:   lvl,
 		levels:  lm.Levels,
 		backend: lm.Backend,
+		set:     lm.set,
 	}
+	lm.set.add(l)
+	return l
 }
 
 // Logger creates a logger with the provided name, using the log level for that
 // name if it was set, otherwise the default log level. This differs from
 // NewLogger, which does not look in the Level map for the name.
 func (lm *LoggerMaker) Logger(name string) Logger {
+	defer lm.set.lock()()
 	lggr := lm.Backend.Logger(name)
 	lvl := lm.bestLevel(name)
 	lggr.SetLevel(lvl)
-	return &logger{
+	l := &logger{
 		Logger:  lggr,
 		name:    name,
 		level:   lvl,
 		levels:  lm.Levels,
 		backend: lm.Backend,
+		set:     lm.set,
+	}
+	lm.set.add(l)
+	return l
+}
+
+// SetLevel sets the level of every logger created by the LoggerMaker,
+// including their subloggers, and the DefaultLevel for loggers created later.
+// Any levels set for individual subsystems are cleared. The previous
+// DefaultLevel is returned. Loggers are only tracked by a LoggerMaker created
+// with NewLoggerMaker.
+func (lm *LoggerMaker) SetLevel(lvl slog.Level) slog.Level {
+	defer lm.set.lock()()
+	prev := lm.DefaultLevel
+	lm.DefaultLevel = lvl
+	// The map is shared with the loggers for their subloggers.
+	for name := range lm.Levels {
+		delete(lm.Levels, name)
+	}
+	if lm.set != nil {
+		for _, l := range lm.set.loggers {
+			l.level = lvl
+			l.Logger.SetLevel(lvl)
+		}
 	}
+	return prev
 }
 
 // bestLevel takes a hierarchical list of logger names, least important to most
@@ -0,0 +1,42 @@
+package dex
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestLoggerMakerSetLevel(t *testing.T) {
+	lm, err := NewLoggerMaker(ioutil.Discard, "CORE=trace,RPC=warn")
+	if err != nil {
+		t.Fatalf("NewLoggerMaker error: %v", err)
+	}
+	core := lm.Logger("CORE")
+	rpc := lm.Logger("RPC")
+	asset := core.SubLogger("BTC")
+	if core.Level() != LevelTrace || rpc.Level() != LevelWarn || asset.Level() != LevelTrace {
+		t.Fatalf("wrong initial levels %s, %s, %s", core.Level(), rpc.Level(), asset.Level())
+	}
+
+	prev := lm.SetLevel(LevelError)
+	if prev != DefaultLogLevel {
+		t.Fatalf("wrong previous level %s", prev)
+	}
+	// Existing loggers and their subloggers are changed.
+	for _, lggr := range []Logger{core, rpc, asset} {
+		if lggr.Level() != LevelError {
+			t.Fatalf("level not changed: %s", lggr.Level())
+		}
+	}
+	// Loggers created later use the new level, and the subsystem levels are
+	// cleared.
+	if lvl := lm.Logger("RPC").Level(); lvl != LevelError {
+		t.Fatalf("new logger has level %s", lvl)
+	}
+	if lvl := rpc.SubLogger("CORE").Level(); lvl != LevelError {
+		t.Fatalf("new sublogger has level %s", lvl)
+	}
+
+	if prev = lm.SetLevel(LevelInfo); prev != LevelError {
+		t.Fatalf("wrong previous level %s", prev)
+	}
+}
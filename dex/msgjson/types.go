@@ -69,6 +69,35 @@ const (
 	AccountNotFoundError              // 50
 	UnpaidAccountError                // 51
 	InvalidRequestError               // 52
+	RPCSwapCostsError                 // 53
+	RPCRetryPolicyError               // 54
+	RPCTradeReportError               // 55
+	RPCLoginRequiredError             // 56
+	RPCOrdersError                    // 57
+	RPCWalletLockedError              // 58
+	RPCInsufficientFundsError         // 59
+	RPCWalletNotFoundError            // 60
+	RPCPasswordError                  // 61
+	RPCCandlesError                   // 62
+	RPCShutdownError                  // 63
+	RPCNotificationsError             // 64
+	RPCDEXConfigError                 // 65
+	RPCFeeRateError                   // 66
+	RPCReconfigWalletError            // 67
+	RPCChangeAppPassError             // 68
+	RPCBackupError                    // 69
+	RPCMatchesError                   // 70
+	RPCAdminRequiredError             // 71
+	RPCWSClientError                  // 72
+	RPCRawError                       // 73
+	RPCPreOrderError                  // 74
+	RPCSettingsError                  // 75
+	RPCDisconnectDEXError             // 76
+	RPCMarketError                    // 77
+	RPCAddressError                   // 78
+	RPCRescanWalletError              // 79
+	RPCValidateAddressError           // 80
+	RPCFeesPaidError                  // 81
 )
 
 // Routes are destinations for a "payload" of data. The type of data being
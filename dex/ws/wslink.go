@@ -24,6 +24,10 @@ const outBufferSize = 128
 
 const writeWait = 5 * time.Second
 
+// defaultCloseReason is the reason sent in the Close control message if none
+// was provided with DisconnectWithReason.
+const defaultCloseReason = "bye"
+
 // websocket.Upgrader is the preferred method of upgrading a request to a
 // websocket connection.
 var upgrader = websocket.Upgrader{}
@@ -76,6 +80,10 @@ type WSLink struct {
 	handler func(*msgjson.Message) *msgjson.Error
 	// pingPeriod is how often to ping the peer.
 	pingPeriod time.Duration
+	// closeReason is the reason sent in the Close control message. If empty,
+	// defaultCloseReason is sent.
+	closeMtx    sync.Mutex
+	closeReason string
 }
 
 type sendData struct {
@@ -202,6 +210,16 @@ func (c *WSLink) Disconnect() {
 	// NOTE: outHandler closes the c.conn on its return.
 }
 
+// DisconnectWithReason is like Disconnect, but the Close control message sent
+// to the peer includes the provided reason. The reason has no effect if the
+// link is already down.
+func (c *WSLink) DisconnectWithReason(reason string) {
+	c.closeMtx.Lock()
+	c.closeReason = reason
+	c.closeMtx.Unlock()
+	c.Disconnect()
+}
+
 // inHandler handles all incoming messages for the websocket connection. It must
 // be run as a goroutine.
 func (c *WSLink) inHandler(ctx context.Context) {
@@ -259,8 +277,14 @@ func (c *WSLink) outHandler(ctx context.Context) {
 			c.log.Debugf("Connection already dead. Not sending Close control message.")
 			return
 		}
+		c.closeMtx.Lock()
+		reason := c.closeReason
+		c.closeMtx.Unlock()
+		if reason == "" {
+			reason = defaultCloseReason
+		}
 		_ = c.conn.WriteControl(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"),
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason),
 			time.Now().Add(time.Second))
 	}()
 	defer c.stop() // in the event of context cancellation vs Disconnect call
@@ -437,9 +461,20 @@ func (c *WSLink) IP() string {
 
 // NewConnection attempts to to upgrade the http connection to a websocket
 // Connection. If the upgrade fails, a reply will be sent with an appropriate
-// error code.
+// error code. Cross-origin requests are rejected.
 func NewConnection(w http.ResponseWriter, r *http.Request, readTimeout time.Duration) (Connection, error) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+	return NewConnectionWithOriginCheck(w, r, readTimeout, nil)
+}
+
+// NewConnectionWithOriginCheck is like NewConnection, but the request is only
+// upgraded if checkOrigin returns true. If checkOrigin is nil, cross-origin
+// requests are rejected. Requests without an Origin header are not
+// cross-origin.
+func NewConnectionWithOriginCheck(w http.ResponseWriter, r *http.Request, readTimeout time.Duration,
+	checkOrigin func(r *http.Request) bool) (Connection, error) {
+	u := upgrader
+	u.CheckOrigin = checkOrigin
+	ws, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		var hsErr websocket.HandshakeError
 		if errors.As(err, &hsErr) {